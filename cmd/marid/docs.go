@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/motchang/marid/internal/site"
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/spf13/cobra"
+)
+
+func buildDocsCmd() *cobra.Command {
+	var (
+		out       string
+		mermaidJS string
+	)
+
+	docsCmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Write a static HTML documentation site of the schema",
+		Long: `Docs writes a browsable documentation site of the schema to --out: an index
+of the tables with search, an overview diagram, and a page per table with its
+columns, keys, indexes, relationships and a diagram of its neighbourhood.
+
+The site needs no network. Diagrams are rendered in the browser by the
+Mermaid bundle vendored into marid (scripts/vendor-mermaid.sh) or given with
+--mermaid-js, over SVG images drawn by marid itself, which remain for
+browsers without JavaScript. The connection and table selection flags are
+those of marid itself.`,
+		Example: `  marid docs -d shop --out site/
+  marid docs -d shop --out site/ --exclude 'tmp_*' --mermaid-js mermaid.min.js`,
+		Args: cobra.NoArgs,
+		RunE: runCommand(func(ctx context.Context, cmd *cobra.Command) error {
+			if out == "" {
+				return fmt.Errorf("--out is required")
+			}
+
+			cmdConfig := commandConfig()
+			cmdConfig.FormatOptions = formatOptions(map[string]string{
+				formatter.OptionTypeDisplay: cfgTypes,
+				formatter.OptionTypeStyle:   cfgTypeStyle,
+			})
+
+			cfg, err := resolveConfig(cmd, cmdConfig)
+			if err != nil {
+				return err
+			}
+
+			opts := site.Options{Title: cfgTitle, FormatOptions: cfg.FormatOptions}
+			if opts.Title == "" {
+				opts.Title = cfg.Database
+			}
+			if mermaidJS != "" {
+				opts.MermaidJS, err = os.ReadFile(mermaidJS)
+				if err != nil {
+					return fmt.Errorf("reading --mermaid-js: %w", err)
+				}
+			}
+
+			dbSchema, err := readSchema(ctx, cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			paths, err := generateSite(dbSchema, out, opts)
+			for _, path := range paths {
+				if _, err := fmt.Fprintln(cmd.OutOrStdout(), path); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("failed to generate site: %w", err)
+			}
+			return nil
+		}),
+	}
+
+	docsCmd.Flags().StringVar(&out, "out", "", "Directory the site is written to (required)")
+	docsCmd.Flags().StringVar(&mermaidJS, "mermaid-js", "", "Mermaid bundle copied into the site to render its diagrams, in place of the built-in one")
+	docsCmd.Flags().StringVar(&cfgTitle, "title", "", "Site title (default: the database name)")
+	docsCmd.Flags().StringVar(&cfgTypes, "type-display", "",
+		fmt.Sprintf("Column types shown: %s (e.g. varchar) or %s (e.g. varchar(255)) (default: %s)",
+			formatter.TypeDisplayShort, formatter.TypeDisplayFull, formatter.TypeDisplayShort))
+	docsCmd.Flags().StringVar(&cfgTypeStyle, "type-style", "",
+		fmt.Sprintf("Column type names: %s (MySQL's, e.g. bigint) or %s (e.g. integer) (default: %s)",
+			formatter.TypeStyleRaw, formatter.TypeStylePortable, formatter.TypeStyleRaw))
+	return docsCmd
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/spf13/cobra"
+)
+
+func buildFormatsCmd() *cobra.Command {
+	var (
+		describe string
+		asJSON   bool
+	)
+
+	formatsCmd := &cobra.Command{
+		Use:   "formats",
+		Short: "List the output formats and their options",
+		Long: `Formats lists the output formats marid can write: their media type, file
+extension, whether the output is binary, and the schema features they show.
+With --describe it lists the options a format accepts, which are set with
+--format-opt KEY=VALUE. --json prints the same as JSON for scripts. Format
+plugins found on $PATH or given with --plugin are listed too; a plugin that
+cannot be loaded is listed with the reason.`,
+		Args: cobra.NoArgs,
+		RunE: runCommand(func(_ context.Context, cmd *cobra.Command) error {
+			if describe != "" {
+				info, err := formatter.Describe(describe)
+				if err != nil {
+					return err
+				}
+				if asJSON {
+					return writeJSON(cmd.OutOrStdout(), info)
+				}
+				return describeFormat(cmd.OutOrStdout(), info)
+			}
+
+			infos := formatter.Infos()
+			if asJSON {
+				return writeJSON(cmd.OutOrStdout(), infos)
+			}
+			return listFormats(cmd.OutOrStdout(), infos)
+		}),
+	}
+
+	formatsCmd.Flags().StringVar(&describe, "describe", "", "List the options of this format")
+	formatsCmd.Flags().BoolVar(&asJSON, "json", false, "Print JSON instead of a table")
+	return formatsCmd
+}
+
+// listFormats writes a table of the formats.
+func listFormats(w io.Writer, infos []formatter.Info) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FORMAT\tMEDIA TYPE\tEXTENSION\tOUTPUT\tFEATURES\tDESCRIPTION")
+	for _, info := range infos {
+		if info.Error != "" {
+			_, _ = fmt.Fprintf(tw, "%s\t-\t-\t-\t-\tcannot be loaded: %s\n", info.Name, info.Error)
+			continue
+		}
+		output := "text"
+		if info.Binary {
+			output = "binary"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Name, info.MediaType, info.Extension, output, featureList(info.Features), info.Description)
+	}
+	return tw.Flush()
+}
+
+// describeFormat writes the options a format declares.
+func describeFormat(w io.Writer, info formatter.Info) error {
+	if len(info.Options) == 0 {
+		_, err := fmt.Fprintf(w, "%s (%s) takes no options.\n", info.Name, info.MediaType)
+		return err
+	}
+
+	_, _ = fmt.Fprintf(w, "%s (%s) options, set with --format-opt KEY=VALUE:\n\n", info.Name, info.MediaType)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "OPTION\tVALUES\tDEFAULT\tDESCRIPTION")
+	for _, spec := range info.Options {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", spec.Name, specValues(spec), spec.Default, spec.Description)
+	}
+	return tw.Flush()
+}
+
+// specValues summarizes the values an option accepts.
+func specValues(spec formatter.OptionSpec) string {
+	switch spec.Kind {
+	case formatter.KindEnum:
+		return strings.Join(spec.Values, "|")
+	case formatter.KindInt:
+		return "number"
+	default:
+		return "text"
+	}
+}
+
+func featureList(features []formatter.Feature) string {
+	if len(features) == 0 {
+		return "-"
+	}
+	names := make([]string, len(features))
+	for i, feature := range features {
+		names[i] = string(feature)
+	}
+	return strings.Join(names, ",")
+}
+
+func writeJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/motchang/marid/internal/atomicfile"
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/internal/inject"
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/spf13/cobra"
+)
+
+// Marker settings other than formatter options.
+const (
+	settingFormat         = "format"
+	settingFence          = "fence"
+	settingTables         = "tables"
+	settingInclude        = "include"
+	settingExclude        = "exclude"
+	settingFocus          = "focus"
+	settingDepth          = "depth"
+	settingFocusDirection = "focus-direction"
+	settingExternalRefs   = "external-refs"
+	settingOrder          = "order"
+)
+
+// noFence turns off the code fence of a block.
+const noFence = "none"
+
+// blockJob is a block of a document with the settings of its marker resolved.
+type blockJob struct {
+	block inject.Block
+	cfg   config.Config
+	fence string
+}
+
+func buildInjectCmd() *cobra.Command {
+	var (
+		files []string
+		check bool
+	)
+
+	injectCmd := &cobra.Command{
+		Use:   "inject",
+		Short: "Refresh the diagrams between marid markers in Markdown files",
+		Long: `Inject replaces the content between marker comments in text files, such as
+hand-written Markdown docs, with freshly rendered diagrams:
+
+  <!-- marid:start name=billing include=billing_* direction=LR -->
+  <!-- marid:end -->
+
+Each start marker names its block and may select its own tables with
+tables, include, exclude, focus, depth, focus-direction and external-refs
+(lists separated by commas), pick a text format with format (default:
+mermaid) and set any option of that format, such as title="Billing". Mermaid
+output is wrapped in a mermaid code fence; fence=LANG picks another fence and
+fence=none leaves it out. Settings missing from a marker come from the
+command line, and text outside the markers is never touched.
+
+With --check nothing is written, and inject fails when a file is out of date,
+for CI.`,
+		Example: `  marid inject -d shop --file docs/database.md
+  marid inject -d shop --file docs/database.md --check`,
+		Args: cobra.NoArgs,
+		RunE: runCommand(func(ctx context.Context, cmd *cobra.Command) error {
+			if len(files) == 0 {
+				return fmt.Errorf("--file is required")
+			}
+
+			docs := make([]*inject.Document, len(files))
+			for i, path := range files {
+				text, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("reading --file: %w", err)
+				}
+				if docs[i], err = inject.Parse(path, string(text)); err != nil {
+					return err
+				}
+				if len(docs[i].Blocks) == 0 {
+					return fmt.Errorf("%s has no <!-- marid:start name=... --> markers", path)
+				}
+			}
+
+			base, err := resolveConfig(cmd, commandConfig())
+			if err != nil {
+				return err
+			}
+
+			// Every marker is checked before connecting.
+			jobs := make([][]blockJob, len(docs))
+			for i, doc := range docs {
+				for _, block := range doc.Blocks {
+					job, err := newBlockJob(base, block)
+					if err != nil {
+						return fmt.Errorf("%s:%d: block %q: %w", doc.Path, block.Line, block.Name, err)
+					}
+					jobs[i] = append(jobs[i], job)
+				}
+			}
+
+			// Blocks selecting the same tables share one extraction.
+			schemas := make(map[string]*schema.DatabaseSchema)
+			stale := 0
+			for i, doc := range docs {
+				contents := make(map[string]string)
+				var changed []string
+				for _, job := range jobs[i] {
+					key := selectionKey(job.cfg)
+					dbSchema, ok := schemas[key]
+					if !ok {
+						if dbSchema, err = readSchema(ctx, cmd, job.cfg); err != nil {
+							return err
+						}
+						schemas[key] = dbSchema
+					}
+
+					blockSchema := *dbSchema
+					blockSchema.Config = job.cfg
+					var output bytes.Buffer
+					if err := generate(ctx, &output, &blockSchema, job.cfg.Format); err != nil {
+						return fmt.Errorf("%s:%d: block %q: failed to generate diagram: %w", doc.Path, job.block.Line, job.block.Name, err)
+					}
+
+					content := fenced(output.String(), job.fence)
+					contents[job.block.Name] = content
+					if content != strings.ReplaceAll(job.block.Content, "\r\n", "\n") {
+						changed = append(changed, job.block.Name)
+					}
+				}
+
+				status := "up to date"
+				switch {
+				case len(changed) == 0:
+				case check:
+					status = "out of date: " + strings.Join(changed, ", ")
+					stale++
+				default:
+					status = "updated " + strings.Join(changed, ", ")
+					if err := writeDocument(doc, contents); err != nil {
+						return err
+					}
+				}
+				if _, err := fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", doc.Path, status); err != nil {
+					return err
+				}
+			}
+
+			if stale > 0 {
+				// A stale file is no misuse of the command.
+				cmd.SilenceUsage = true
+				summary := "1 file is"
+				if stale > 1 {
+					summary = fmt.Sprintf("%d files are", stale)
+				}
+				return fmt.Errorf("%s out of date; run marid inject without --check to update", summary)
+			}
+			return nil
+		}),
+	}
+
+	injectCmd.Flags().StringArrayVar(&files, "file", nil, "Text file whose marid blocks are refreshed (repeatable, required)")
+	injectCmd.Flags().BoolVar(&check, "check", false, "Write nothing, and fail if a file is out of date")
+	return injectCmd
+}
+
+// newBlockJob applies the settings of a block's marker to base, taking the
+// ones it does not know for options of the block's format, and checks them.
+func newBlockJob(base config.Config, block inject.Block) (blockJob, error) {
+	job := blockJob{block: block, cfg: base}
+	job.cfg.Format = formatter.DefaultFormat
+
+	fence, fenceSet := "", false
+	options := make(map[string]string)
+	for key, value := range block.Settings {
+		switch key {
+		case settingFormat:
+			job.cfg.Format = value
+		case settingFence:
+			fence, fenceSet = value, true
+		case settingTables:
+			job.cfg.Tables = value
+		case settingInclude:
+			job.cfg.Include = splitList(value)
+		case settingExclude:
+			job.cfg.Exclude = splitList(value)
+		case settingFocus:
+			job.cfg.Focus = splitList(value)
+		case settingDepth:
+			depth, err := strconv.Atoi(value)
+			if err != nil {
+				return job, fmt.Errorf("invalid %s %q: want a number", settingDepth, value)
+			}
+			job.cfg.Depth = depth
+		case settingFocusDirection:
+			job.cfg.FocusDirection = value
+		case settingExternalRefs:
+			job.cfg.ExternalRefs = value
+		case settingOrder:
+			job.cfg.Order = value
+		default:
+			options[key] = value
+		}
+	}
+	job.cfg.FormatOptions = formatOptions(options)
+
+	if err := validateSelection(job.cfg); err != nil {
+		return job, err
+	}
+
+	fmttr, err := formatter.Get(job.cfg.Format)
+	if err != nil {
+		return job, err
+	}
+	if !formatter.IsText(fmttr.MediaType()) {
+		return job, fmt.Errorf("format %s writes %s, which cannot be injected into a text file", fmttr.Name(), fmttr.MediaType())
+	}
+	if _, err := formatter.Configure(fmttr, job.cfg.FormatOptions); err != nil {
+		return job, fmt.Errorf("invalid format options: %w", err)
+	}
+
+	if !fenceSet && fmttr.Name() == formatter.DefaultFormat {
+		fence = fmttr.Name()
+	}
+	if fence != noFence {
+		job.fence = fence
+	}
+	return job, nil
+}
+
+// selectionKey identifies the tables a configuration extracts.
+func selectionKey(cfg config.Config) string {
+	return fmt.Sprintf("%q", []any{cfg.Tables, cfg.Include, cfg.Exclude, cfg.Focus, cfg.Depth, cfg.FocusDirection, cfg.ExternalRefs})
+}
+
+// fenced ends output with a newline and wraps it in a code fence for lang,
+// unless lang is empty.
+func fenced(output, lang string) string {
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	if lang == "" {
+		return output
+	}
+	return "```" + lang + "\n" + output + "```\n"
+}
+
+// splitList splits a comma-separated marker setting.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// writeDocument replaces the document's file with its blocks refreshed,
+// keeping the file's mode.
+func writeDocument(doc *inject.Document, contents map[string]string) error {
+	info, err := os.Stat(doc.Path)
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", doc.Path, err)
+	}
+
+	return atomicfile.Write(doc.Path, info.Mode().Perm(), doc.Path, func(w io.Writer) error {
+		_, err := io.WriteString(w, doc.Replace(contents))
+		return err
+	})
+}
@@ -1,17 +1,29 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/motchang/marid/internal/atomicfile"
 	"github.com/motchang/marid/internal/config"
 	"github.com/motchang/marid/internal/database"
 	"github.com/motchang/marid/internal/diagram"
+	"github.com/motchang/marid/internal/overlay"
 	"github.com/motchang/marid/internal/schema"
 	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/plugin"
+	"github.com/motchang/marid/pkg/utils"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
@@ -21,6 +33,40 @@ var (
 	cfgPassword   string
 	cfgDatabase   string
 	cfgTables     string
+	cfgInclude    []string
+	cfgExclude    []string
+	cfgFocus      []string
+	cfgDepth      int
+	cfgDirection  string
+	cfgExternal   string
+	cfgInfer      bool
+	cfgInferPats  []string
+	cfgListInfer  bool
+	cfgOverlay    string
+	cfgTimeout    time.Duration
+	cfgProgress   bool
+	cfgWorkers    int
+	cfgTypes      string
+	cfgTypeStyle  string
+	cfgCommentLen int
+	cfgTableNotes string
+	cfgTitle      string
+	cfgLayout     string
+	cfgPadding    int
+	cfgTheme      string
+	cfgStyles     []string
+	cfgPalette    string
+	cfgConfigFile string
+	cfgOrder      string
+	cfgSplit      string
+	cfgOutputDir  string
+	cfgOutput     string
+	cfgDPI        int
+	cfgPageSize   string
+	cfgTemplate   string
+	cfgFormatOpts []string
+	cfgPlugins    []string
+	cfgPluginWait time.Duration
 	cfgFormat     string
 	cfgPromptPass bool
 	cfgUseMyCnf   bool
@@ -30,10 +76,18 @@ var (
 	promptForPassword = config.PromptForPassword
 	connect           = database.Connect
 	extract           = schema.Extract
-	generate          = diagram.Generate
+	loadOverlay       = overlay.Load
+	generate          = diagram.GenerateTo
+	generateSplit     = diagram.GenerateSplit
+	generateSite      = diagram.GenerateSite
 )
 
 func main() {
+	// Plugins on $PATH are listed with the built-in formats in the help;
+	// each command registers them again, with --plugin ones, once the flags
+	// are parsed.
+	plugin.RegisterPath(context.Background(), os.Getenv("PATH"), plugin.Options{})
+
 	rootCmd := buildRootCmd()
 
 	if err := rootCmd.Execute(); err != nil {
@@ -48,46 +102,66 @@ func buildRootCmd() *cobra.Command {
 		Short: "MySQL to Mermaid ER Diagram Generator",
 		Long: `Marid connects to a MySQL database, extracts table definitions,
 and generates Mermaid ER diagrams based on the schema.`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			cmdConfig := config.Config{
-				Host:     cfgHost,
-				Port:     cfgPort,
-				User:     cfgUser,
-				Password: cfgPassword,
-				Database: cfgDatabase,
-				Tables:   cfgTables,
-				Format:   cfgFormat,
+		RunE: runCommand(func(ctx context.Context, cmd *cobra.Command) error {
+			cmdConfig := commandConfig()
+			options := map[string]string{
+				formatter.OptionTypeDisplay:   cfgTypes,
+				formatter.OptionTypeStyle:     cfgTypeStyle,
+				formatter.OptionCommentLength: countOption(cfgCommentLen),
+				formatter.OptionTableComments: cfgTableNotes,
+				formatter.OptionTitle:         cfgTitle,
+				formatter.OptionDirection:     cfgLayout,
+				formatter.OptionEntityPadding: countOption(cfgPadding),
+				formatter.OptionTheme:         cfgTheme,
+				formatter.OptionStyle:         strings.Join(cfgStyles, formatter.StyleRuleSeparator),
+				formatter.OptionPalette:       cfgPalette,
+				formatter.OptionDPI:           countOption(cfgDPI),
+				formatter.OptionPageSize:      cfgPageSize,
+				formatter.OptionTemplate:      cfgTemplate,
+			}
+			// The dedicated flags and the config file only reach the options
+			// the format declares, so one config file serves every format.
+			keepDeclaredOptions(cmd, cfgFormat, options)
+			// --format-opt reaches every option, and wins over the dedicated flags.
+			if err := parseFormatOpts(cfgFormatOpts, options); err != nil {
+				return err
 			}
+			cmdConfig.FormatOptions = formatOptions(options)
 
 			cfg, err := resolveConfig(cmd, cmdConfig)
 			if err != nil {
 				return err
 			}
 
-			db, err := connect(cfg)
+			dbSchema, err := readSchema(ctx, cmd, cfg)
 			if err != nil {
-				return fmt.Errorf("failed to connect to database: %w", err)
-			}
-
-			if db != nil {
-				defer func(db *sql.DB) {
-					_ = db.Close()
-				}(db)
+				return err
 			}
 
-			dbSchema, err := extract(db, cfg)
-			if err != nil {
-				return fmt.Errorf("failed to extract schema: %w", err)
+			if cfgListInfer {
+				for _, relationship := range dbSchema.InferredRelationships() {
+					if _, err := fmt.Fprintln(cmd.OutOrStdout(), relationship); err != nil {
+						return err
+					}
+				}
+				return nil
 			}
 
-			mermaidDiagram, err := generate(dbSchema, cfg.Format)
-			if err != nil {
-				return fmt.Errorf("failed to generate diagram: %w", err)
+			if cfg.Split != "" {
+				paths, err := generateSplit(dbSchema, cfg.Format)
+				for _, path := range paths {
+					if _, err := fmt.Fprintln(cmd.OutOrStdout(), path); err != nil {
+						return err
+					}
+				}
+				if err != nil {
+					return fmt.Errorf("failed to generate diagrams: %w", err)
+				}
+				return nil
 			}
 
-			_, err = fmt.Fprintln(cmd.OutOrStdout(), mermaidDiagram)
-			return err
-		},
+			return writeOutput(ctx, cmd.OutOrStdout(), dbSchema, cfg)
+		}),
 	}
 
 	// Use shorthand-enabled flag helpers (VarP/VarP) to match the documented short options.
@@ -97,20 +171,179 @@ and generates Mermaid ER diagrams based on the schema.`,
 		formatDesc += fmt.Sprintf("; available: %s", strings.Join(availableFormats, ", "))
 	}
 
-	rootCmd.Flags().StringVarP(&cfgHost, "host", "H", "localhost", "MySQL host address")
-	rootCmd.Flags().IntVarP(&cfgPort, "port", "P", 3306, "MySQL port")
-	rootCmd.Flags().StringVarP(&cfgUser, "user", "u", "root", "MySQL username")
-	rootCmd.Flags().StringVarP(&cfgPassword, "password", "p", "", "MySQL password (insecure, prefer --ask-password)")
-	rootCmd.Flags().BoolVar(&cfgPromptPass, "ask-password", false, "Prompt for password (secure)")
-	rootCmd.Flags().BoolVarP(&cfgUseMyCnf, "use-mycnf", "c", false, "Read connection info from ~/.my.cnf")
-	rootCmd.Flags().BoolVarP(&cfgNoPassword, "no-password", "n", false, "Connect without a password")
-	rootCmd.Flags().StringVarP(&cfgDatabase, "database", "d", "", "Database name (required)")
-	rootCmd.Flags().StringVarP(&cfgTables, "tables", "t", "", "Comma-separated list of tables (default: all tables)")
+	rootCmd.PersistentFlags().StringVarP(&cfgHost, "host", "H", "localhost", "MySQL host address")
+	rootCmd.PersistentFlags().IntVarP(&cfgPort, "port", "P", 3306, "MySQL port")
+	rootCmd.PersistentFlags().StringVarP(&cfgUser, "user", "u", "root", "MySQL username")
+	rootCmd.PersistentFlags().StringVarP(&cfgPassword, "password", "p", "", "MySQL password (insecure, prefer --ask-password)")
+	rootCmd.PersistentFlags().BoolVar(&cfgPromptPass, "ask-password", false, "Prompt for password (secure)")
+	rootCmd.PersistentFlags().BoolVarP(&cfgUseMyCnf, "use-mycnf", "c", false, "Read connection info from ~/.my.cnf")
+	rootCmd.PersistentFlags().BoolVarP(&cfgNoPassword, "no-password", "n", false, "Connect without a password")
+	rootCmd.PersistentFlags().StringVarP(&cfgDatabase, "database", "d", "", "Database name (required)")
+	rootCmd.PersistentFlags().StringVarP(&cfgTables, "tables", "t", "", "Comma-separated list of tables (default: all tables)")
+	rootCmd.PersistentFlags().StringArrayVar(&cfgInclude, "include", nil, "Include tables matching a glob or re:<regexp> pattern (repeatable)")
+	rootCmd.PersistentFlags().StringArrayVar(&cfgExclude, "exclude", nil, "Exclude tables matching a glob or re:<regexp> pattern (repeatable)")
+	rootCmd.PersistentFlags().StringSliceVar(&cfgFocus, "focus", nil, "Only render tables within --depth foreign-key hops of these tables (comma-separated or repeatable)")
+	rootCmd.PersistentFlags().IntVar(&cfgDepth, "depth", 1, "Number of foreign-key hops followed from --focus tables")
+	rootCmd.PersistentFlags().StringVar(&cfgDirection, "focus-direction", config.FocusBoth,
+		fmt.Sprintf("Foreign keys followed from --focus tables: %s, %s or %s", config.FocusBoth, config.FocusReferencing, config.FocusReferenced))
+	rootCmd.PersistentFlags().StringVar(&cfgExternal, "external-refs", config.ExternalStub,
+		fmt.Sprintf("Foreign keys to tables outside the selection: %s (render a stub entity), %s or %s (add the referenced table)",
+			config.ExternalStub, config.ExternalDrop, config.ExternalInclude))
+	rootCmd.PersistentFlags().BoolVar(&cfgInfer, "infer-relations", false, "Infer undeclared foreign keys from column names such as user_id")
+	rootCmd.PersistentFlags().StringArrayVar(&cfgInferPats, "infer-pattern", nil,
+		"Column naming pattern for --infer-relations, COLUMN or COLUMN=TABLE.COLUMN with {name} and {plural} placeholders (repeatable; default: {name}_id)")
+	rootCmd.Flags().BoolVar(&cfgListInfer, "list-inferred", false, "Print the inferred foreign keys instead of a diagram (implies --infer-relations)")
+	rootCmd.PersistentFlags().StringVar(&cfgOverlay, "overlay", "", "YAML or JSON file with virtual relationships, aliases, comments, hidden columns and groups")
+	rootCmd.PersistentFlags().DurationVar(&cfgTimeout, "timeout", 0, "Give up connecting and extracting after this long, e.g. 2m (default: no limit)")
+	rootCmd.PersistentFlags().BoolVar(&cfgProgress, "progress", false, "Report extraction progress on stderr: each query, or with --concurrency each table")
+	rootCmd.PersistentFlags().IntVar(&cfgWorkers, "concurrency", 0, "Read table details per table with this many parallel workers (default: read the whole database at once)")
+	rootCmd.Flags().StringVar(&cfgTypes, "type-display", "",
+		fmt.Sprintf("Column types shown in the diagram: %s (e.g. varchar) or %s (e.g. varchar(255)) (default: %s)",
+			formatter.TypeDisplayShort, formatter.TypeDisplayFull, formatter.TypeDisplayShort))
+	rootCmd.Flags().StringVar(&cfgTypeStyle, "type-style", "",
+		fmt.Sprintf("Column type names: %s (MySQL's, e.g. bigint) or %s (e.g. integer) (default: %s)",
+			formatter.TypeStyleRaw, formatter.TypeStylePortable, formatter.TypeStyleRaw))
+	rootCmd.Flags().IntVar(&cfgCommentLen, "comment-length", 0, "Truncate comments in the diagram to this many characters (default: no limit)")
+	rootCmd.Flags().StringVar(&cfgTableNotes, "table-comments", "",
+		fmt.Sprintf("How table comments are shown: %s (in the entity label), %s (as a %%%% line) or %s (default: %s)",
+			formatter.TableCommentsAlias, formatter.TableCommentsComment, formatter.TableCommentsNone, formatter.TableCommentsNone))
+	rootCmd.Flags().StringVar(&cfgTitle, "title", "", "Diagram title")
+	rootCmd.Flags().StringVar(&cfgLayout, "direction", "", fmt.Sprintf("Layout direction: %s", strings.Join(formatter.Directions, ", ")))
+	rootCmd.Flags().IntVar(&cfgPadding, "entity-padding", 0, "Space in pixels around the text of each entity")
+	rootCmd.Flags().StringVar(&cfgTheme, "theme", "", "Renderer theme, e.g. default, neutral, dark, forest or base")
+	rootCmd.Flags().StringArrayVar(&cfgStyles, "style", nil,
+		"Style tables with CLASS=SELECTOR, where SELECTOR is a name pattern, tag:TAG (a #TAG in the table comment) or group:GROUP (repeatable)")
+	rootCmd.Flags().StringVar(&cfgPalette, "palette", "",
+		fmt.Sprintf("Palette colouring the --style classes: %s (default: %s)", strings.Join(formatter.PaletteNames(), ", "), formatter.DefaultPalette))
+	rootCmd.PersistentFlags().StringVar(&cfgOrder, "order", config.OrderAlpha,
+		fmt.Sprintf("Order tables are declared in, which guides the layout: %s, %s (referenced tables first) or %s (%s with fewer crossing relationships)",
+			config.OrderAlpha, config.OrderTopo, config.OrderOptimized, config.OrderTopo))
+	rootCmd.Flags().StringVar(&cfgSplit, "split", "",
+		fmt.Sprintf("Write one diagram per cluster of tables, plus an overview, to --output-dir; clusters by %s (table name before the first _), %s (overlay group) or %s (densely linked tables)",
+			config.SplitPrefix, config.SplitGroup, config.SplitCommunity))
+	rootCmd.Flags().StringVar(&cfgOutputDir, "output-dir", "", "Directory the --split diagrams are written to")
+	rootCmd.Flags().StringVarP(&cfgOutput, "output", "o", "", "Write the diagram to this file instead of standard output")
+	rootCmd.Flags().IntVar(&cfgDPI, "dpi", 0, "Resolution of png and pdf output in dots per inch (default: 96 for png, 192 for pdf)")
+	rootCmd.Flags().StringVar(&cfgPageSize, "page-size", "",
+		fmt.Sprintf("Page size of pdf output: %s (default: %s, the size of the diagram)", strings.Join(formatter.PageSizes, ", "), formatter.PageSizeFit))
+	rootCmd.Flags().StringVar(&cfgTemplate, "template", "", "Go text/template file rendered by the template format")
+	rootCmd.PersistentFlags().StringVar(&cfgConfigFile, "config", "", "YAML file of flag settings, e.g. \"title: Shop\"; flags on the command line take precedence")
 	rootCmd.Flags().StringVarP(&cfgFormat, "format", "f", formatter.DefaultFormat, formatDesc)
+	rootCmd.Flags().StringArrayVar(&cfgFormatOpts, "format-opt", nil,
+		"Set a formatter option as KEY=VALUE (repeatable); \"marid formats --describe FORMAT\" lists them")
+	rootCmd.PersistentFlags().StringArrayVar(&cfgPlugins, "plugin", nil,
+		fmt.Sprintf("Use the plugin executable at PATH as the format NAME, given as NAME=PATH (repeatable); %sNAME executables on $PATH are found without it", plugin.CommandPrefix))
+	rootCmd.PersistentFlags().DurationVar(&cfgPluginWait, "plugin-timeout", plugin.DefaultTimeout, "Give up on a format plugin that runs longer than this; 0 means no limit")
+
+	rootCmd.AddCommand(buildFormatsCmd())
+	rootCmd.AddCommand(buildDocsCmd())
+	rootCmd.AddCommand(buildInjectCmd())
 
 	return rootCmd
 }
 
+// runCommand returns the RunE of a command running run. Every command first
+// takes the settings of --config and registers the --plugin executables, and
+// runs under a context that Ctrl-C cancels, so whatever query is running is
+// cancelled instead of left behind.
+func runCommand(run func(ctx context.Context, cmd *cobra.Command) error) func(*cobra.Command, []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		if cfgConfigFile != "" {
+			if err := applyConfigFile(cmd, cfgConfigFile); err != nil {
+				return err
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		if err := registerPlugins(ctx, cmd); err != nil {
+			return err
+		}
+		return run(ctx, cmd)
+	}
+}
+
+// commandConfig gathers the settings of the connection, selection and output
+// flags. Formatter options are left to the command.
+func commandConfig() config.Config {
+	return config.Config{
+		Host:     cfgHost,
+		Port:     cfgPort,
+		User:     cfgUser,
+		Password: cfgPassword,
+		Database: cfgDatabase,
+		Tables:   cfgTables,
+		Format:   cfgFormat,
+		Include:  cfgInclude,
+		Exclude:  cfgExclude,
+
+		Focus:          cfgFocus,
+		Depth:          cfgDepth,
+		FocusDirection: cfgDirection,
+		ExternalRefs:   cfgExternal,
+
+		InferRelations: cfgInfer || cfgListInfer,
+		InferPatterns:  cfgInferPats,
+		Overlay:        cfgOverlay,
+		Timeout:        cfgTimeout,
+		Concurrency:    cfgWorkers,
+
+		Order:     cfgOrder,
+		Split:     cfgSplit,
+		OutputDir: cfgOutputDir,
+		Output:    cfgOutput,
+	}
+}
+
+// readSchema loads the --overlay file, connects to the database and extracts
+// the schema cfg selects with the overlay applied, printing its warnings on
+// stderr. --timeout bounds connecting and extracting; ctx interrupts them.
+func readSchema(ctx context.Context, cmd *cobra.Command, cfg config.Config) (*schema.DatabaseSchema, error) {
+	var annotations *overlay.Overlay
+	if cfg.Overlay != "" {
+		var err error
+		annotations, err = loadOverlay(cfg.Overlay)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	db, err := connect(ctx, cfg)
+	if err != nil {
+		return nil, interruptionError(ctx, cfg.Timeout, fmt.Errorf("failed to connect to database: %w", err))
+	}
+
+	if db != nil {
+		defer func(db *sql.DB) {
+			_ = db.Close()
+		}(db)
+	}
+
+	var progress schema.ProgressFunc
+	if cfgProgress {
+		progress = progressReporter(cmd.ErrOrStderr())
+	}
+
+	dbSchema, err := extract(ctx, db, cfg, progress)
+	if err != nil {
+		return nil, interruptionError(ctx, cfg.Timeout, fmt.Errorf("failed to extract schema: %w", err))
+	}
+
+	annotations.Apply(dbSchema)
+
+	for _, warning := range dbSchema.Warnings {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", warning)
+	}
+	return dbSchema, nil
+}
+
 // passwordFlagConflict lists the password flags the user selected when more than
 // one of them is in play, and nil otherwise. The three name contradictory
 // sources for one value, so combining them is rejected rather than resolved by a
@@ -144,6 +377,267 @@ func passwordFlagConflict(cmd *cobra.Command) []string {
 	return selected
 }
 
+// validateSelection rejects table patterns, focus settings, external
+// reference policies and table orders that extraction and rendering cannot
+// honour.
+func validateSelection(cfg config.Config) error {
+	if _, err := utils.CompilePatterns(cfg.Include); err != nil {
+		return fmt.Errorf("invalid --include pattern: %w", err)
+	}
+
+	if _, err := utils.CompilePatterns(cfg.Exclude); err != nil {
+		return fmt.Errorf("invalid --exclude pattern: %w", err)
+	}
+
+	if err := validateFocus(cfg); err != nil {
+		return err
+	}
+
+	switch cfg.ExternalRefs {
+	case config.ExternalStub, config.ExternalDrop, config.ExternalInclude:
+	default:
+		return fmt.Errorf("invalid --external-refs %q: want %s, %s or %s",
+			cfg.ExternalRefs, config.ExternalStub, config.ExternalDrop, config.ExternalInclude)
+	}
+
+	switch cfg.Order {
+	case config.OrderAlpha, config.OrderTopo, config.OrderOptimized:
+	default:
+		return fmt.Errorf("invalid --order %q: want %s, %s or %s",
+			cfg.Order, config.OrderAlpha, config.OrderTopo, config.OrderOptimized)
+	}
+
+	return nil
+}
+
+// validateFocus rejects --depth and --focus-direction values the focus walk
+// cannot honour, and flags that only make sense alongside --focus.
+func validateFocus(cfg config.Config) error {
+	switch cfg.FocusDirection {
+	case config.FocusBoth, config.FocusReferencing, config.FocusReferenced:
+	default:
+		return fmt.Errorf("invalid --focus-direction %q: want %s, %s or %s",
+			cfg.FocusDirection, config.FocusBoth, config.FocusReferencing, config.FocusReferenced)
+	}
+
+	if cfg.Depth < 0 {
+		return fmt.Errorf("invalid --depth %d: must not be negative", cfg.Depth)
+	}
+
+	return nil
+}
+
+// interruptionError explains an error caused by ctx ending, which otherwise
+// surfaces as a bare "context canceled" from deep inside the driver.
+func interruptionError(ctx context.Context, timeout time.Duration, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
+	case errors.Is(ctx.Err(), context.Canceled):
+		return fmt.Errorf("interrupted: %w", err)
+	default:
+		return err
+	}
+}
+
+// configFileAnnotation marks the flags applyConfigFile set.
+const configFileAnnotation = "marid-config-file"
+
+// applyConfigFile sets every flag named in the config file at path that the
+// command line did not set. List flags take each item of a YAML sequence.
+// Settings of the root command's own flags, such as format, are skipped by
+// subcommands that lack them, so one file serves every command.
+func applyConfigFile(cmd *cobra.Command, path string) error {
+	settings, err := config.LoadFile(path)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil && cmd != cmd.Root() && cmd.Root().Flags().Lookup(name) != nil {
+			continue
+		}
+		if flag == nil || name == "config" || name == "help" {
+			return fmt.Errorf("invalid config file %s: unknown setting %q", path, name)
+		}
+		if flag.Changed {
+			continue
+		}
+
+		for _, value := range settings[name] {
+			if err := cmd.Flags().Set(name, value); err != nil {
+				return fmt.Errorf("invalid config file %s: %s: %w", path, name, err)
+			}
+		}
+		_ = cmd.Flags().SetAnnotation(name, configFileAnnotation, []string{path})
+	}
+
+	return nil
+}
+
+// keepDeclaredOptions removes from options, set by the dedicated flags, those
+// the format does not declare. Flags set on the command line are warned
+// about; settings of the config file are dropped quietly. Formats that cannot
+// be loaded keep every option, and fail later with their own error.
+func keepDeclaredOptions(cmd *cobra.Command, format string, options map[string]string) {
+	fmttr, err := formatter.Get(format)
+	if err != nil {
+		return
+	}
+	_, configurable := fmttr.(formatter.Configurable)
+	if _, describes := fmttr.(formatter.Describer); configurable && !describes {
+		return
+	}
+
+	declared := make(map[string]bool)
+	for _, spec := range formatter.Specs(fmttr) {
+		declared[spec.Name] = true
+	}
+
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if declared[name] || options[name] == "" {
+			continue
+		}
+		delete(options, name)
+
+		flag := cmd.Flags().Lookup(name)
+		if flag != nil && flag.Changed && flag.Annotations[configFileAnnotation] == nil {
+			_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: --%s is ignored for format %s\n", name, fmttr.Name())
+		}
+	}
+}
+
+// registerPlugins registers the plugins on $PATH and the --plugin
+// executables, which take precedence over plugins of the same name on $PATH.
+// They run under ctx, so an interrupt stops them, bounded by
+// --plugin-timeout.
+func registerPlugins(ctx context.Context, cmd *cobra.Command) error {
+	if cfgPluginWait < 0 {
+		return fmt.Errorf("invalid --plugin-timeout %s: must not be negative", cfgPluginWait)
+	}
+	run := plugin.Options{Timeout: cfgPluginWait, Stderr: cmd.ErrOrStderr()}
+	plugin.RegisterPath(ctx, os.Getenv("PATH"), run)
+
+	for _, setting := range cfgPlugins {
+		name, path, ok := strings.Cut(setting, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || path == "" {
+			return fmt.Errorf("invalid --plugin %q: want NAME=PATH", setting)
+		}
+		if err := plugin.Register(ctx, name, path, run); err != nil {
+			return fmt.Errorf("invalid --plugin %q: %w", setting, err)
+		}
+	}
+	return nil
+}
+
+// countOption passes a numeric flag on as a formatter option, leaving zero,
+// the flags' "not set" value, out.
+func countOption(count int) string {
+	if count == 0 {
+		return ""
+	}
+	return strconv.Itoa(count)
+}
+
+// parseFormatOpts adds --format-opt KEY=VALUE settings to options; a later
+// setting of a key wins.
+func parseFormatOpts(settings []string, options map[string]string) error {
+	for _, setting := range settings {
+		name, value, ok := strings.Cut(setting, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("invalid --format-opt %q: want KEY=VALUE", setting)
+		}
+		options[name] = value
+	}
+	return nil
+}
+
+// formatOptions keeps the formatter options the user set, so formats without
+// options are not handed empty ones. It returns nil when none are set.
+func formatOptions(values map[string]string) map[string]string {
+	var opts map[string]string
+	for name, value := range values {
+		if value == "" {
+			continue
+		}
+		if opts == nil {
+			opts = make(map[string]string)
+		}
+		opts[name] = value
+	}
+	return opts
+}
+
+// writeOutput streams the diagram to the --output file, or to w.
+func writeOutput(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, cfg config.Config) error {
+	if cfg.Output == "" {
+		return writeDiagram(ctx, w, dbSchema, cfg.Format)
+	}
+
+	return atomicfile.Write(cfg.Output, 0o644, "diagram", func(w io.Writer) error {
+		return writeDiagram(ctx, w, dbSchema, cfg.Format)
+	})
+}
+
+// writeDiagram renders the diagram straight to w. Text ends with a newline,
+// added unless the formatter wrote one; binary formats are written as they
+// are rendered.
+func writeDiagram(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+	out := &tailWriter{w: w}
+	if err := generate(ctx, out, dbSchema, format); err != nil {
+		return fmt.Errorf("failed to generate diagram: %w", err)
+	}
+
+	if fmttr, err := formatter.Get(format); err == nil && formatter.IsText(fmttr.MediaType()) && out.last != '\n' {
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tailWriter remembers the last byte written through it.
+type tailWriter struct {
+	w    io.Writer
+	last byte
+}
+
+func (t *tailWriter) Write(p []byte) (int, error) {
+	n, err := t.w.Write(p)
+	if n > 0 {
+		t.last = p[n-1]
+	}
+	return n, err
+}
+
+// isTerminal reports whether w is an interactive terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(file.Fd()))
+}
+
+// progressReporter writes one line per extraction progress update.
+func progressReporter(w io.Writer) schema.ProgressFunc {
+	return func(step string, done, total int) {
+		_, _ = fmt.Fprintf(w, "Progress: %s %d/%d\n", step, done, total)
+	}
+}
+
 // resolveConfig merges ~/.my.cnf settings (when requested) into cmdConfig,
 // validates the result, and applies password overrides.
 func resolveConfig(cmd *cobra.Command, cmdConfig config.Config) (config.Config, error) {
@@ -167,6 +661,57 @@ func resolveConfig(cmd *cobra.Command, cmdConfig config.Config) (config.Config,
 		return cfg, fmt.Errorf("database name is required")
 	}
 
+	// Reject malformed selections before prompting for a password or connecting.
+	if err := validateSelection(cfg); err != nil {
+		return cfg, err
+	}
+
+	if cfg.Timeout < 0 {
+		return cfg, fmt.Errorf("invalid --timeout %s: must not be negative", cfg.Timeout)
+	}
+
+	// Unknown formats are reported when the diagram is generated; options a
+	// known format rejects, and plugins that fail their handshake, are
+	// reported before connecting.
+	if fmttr, err := formatter.Get(cfg.Format); err != nil && formatter.Registered(cfg.Format) {
+		return cfg, err
+	} else if err == nil {
+		if _, err := formatter.Configure(fmttr, cfg.FormatOptions); err != nil {
+			return cfg, fmt.Errorf("invalid format options: %w", err)
+		}
+
+		if !formatter.IsText(fmttr.MediaType()) && cfg.Output == "" && cfg.Split == "" && !cfgListInfer &&
+			isTerminal(cmd.OutOrStdout()) {
+			return cfg, fmt.Errorf("refusing to write %s output to a terminal; use --output or redirect standard output", fmttr.Name())
+		}
+	}
+
+	if cfg.Output != "" && cfg.Split != "" {
+		return cfg, fmt.Errorf("--output cannot be combined with --split; use --output-dir")
+	}
+
+	if cfg.Concurrency < 0 {
+		return cfg, fmt.Errorf("invalid --concurrency %d: must not be negative", cfg.Concurrency)
+	}
+
+	switch cfg.Split {
+	case "":
+		if cfg.OutputDir != "" {
+			return cfg, fmt.Errorf("--output-dir requires --split")
+		}
+	case config.SplitPrefix, config.SplitGroup, config.SplitCommunity:
+		if cfg.OutputDir == "" {
+			return cfg, fmt.Errorf("--split requires --output-dir")
+		}
+	default:
+		return cfg, fmt.Errorf("invalid --split %q: want %s, %s or %s",
+			cfg.Split, config.SplitPrefix, config.SplitGroup, config.SplitCommunity)
+	}
+
+	if _, err := schema.CompileInferenceRules(cfg.InferPatterns); err != nil {
+		return cfg, fmt.Errorf("invalid --infer-pattern: %w", err)
+	}
+
 	if cfgNoPassword {
 		cfg.Password = ""
 	} else if cfgPromptPass {
@@ -2,8 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
 	"strings"
 	"testing"
 
@@ -12,8 +21,11 @@ import (
 	"github.com/motchang/marid/internal/config"
 	"github.com/motchang/marid/internal/database"
 	"github.com/motchang/marid/internal/diagram"
+	"github.com/motchang/marid/internal/overlay"
 	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/internal/site"
 	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/plugin"
 )
 
 func resetGlobals() {
@@ -23,6 +35,40 @@ func resetGlobals() {
 	cfgPassword = ""
 	cfgDatabase = ""
 	cfgTables = ""
+	cfgInclude = nil
+	cfgExclude = nil
+	cfgFocus = nil
+	cfgDepth = 1
+	cfgDirection = config.FocusBoth
+	cfgExternal = config.ExternalStub
+	cfgInfer = false
+	cfgInferPats = nil
+	cfgListInfer = false
+	cfgOverlay = ""
+	cfgTimeout = 0
+	cfgProgress = false
+	cfgWorkers = 0
+	cfgTypes = ""
+	cfgTypeStyle = ""
+	cfgCommentLen = 0
+	cfgTableNotes = ""
+	cfgTitle = ""
+	cfgLayout = ""
+	cfgPadding = 0
+	cfgTheme = ""
+	cfgStyles = nil
+	cfgPalette = ""
+	cfgConfigFile = ""
+	cfgOrder = config.OrderAlpha
+	cfgSplit = ""
+	cfgOutputDir = ""
+	cfgOutput = ""
+	cfgDPI = 0
+	cfgPageSize = ""
+	cfgTemplate = ""
+	cfgFormatOpts = nil
+	cfgPlugins = nil
+	cfgPluginWait = plugin.DefaultTimeout
 	cfgFormat = formatter.DefaultFormat
 	cfgPromptPass = false
 	cfgUseMyCnf = false
@@ -32,7 +78,10 @@ func resetGlobals() {
 	promptForPassword = config.PromptForPassword
 	connect = database.Connect
 	extract = schema.Extract
-	generate = diagram.Generate
+	loadOverlay = overlay.Load
+	generate = diagram.GenerateTo
+	generateSplit = diagram.GenerateSplit
+	generateSite = diagram.GenerateSite
 }
 
 func TestMissingDatabaseError(t *testing.T) {
@@ -40,7 +89,7 @@ func TestMissingDatabaseError(t *testing.T) {
 	t.Cleanup(resetGlobals)
 
 	connectCalled := false
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		connectCalled = true
 		return nil, nil
 	}
@@ -80,7 +129,7 @@ func TestUseMyCnfMergeSuccess(t *testing.T) {
 	}
 
 	var received config.Config
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		received = cfg
 		return nil, errors.New("stop connect")
 	}
@@ -121,7 +170,7 @@ func TestUseMyCnfMergeFailure(t *testing.T) {
 	}
 
 	var received config.Config
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		received = cfg
 		return nil, errors.New("stop connect")
 	}
@@ -167,7 +216,7 @@ func TestAskPasswordOverrides(t *testing.T) {
 	}
 
 	var received config.Config
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		received = cfg
 		return nil, errors.New("stop connect")
 	}
@@ -201,7 +250,7 @@ func TestSuccessfulExecution(t *testing.T) {
 	extractCalled := false
 	generateCalled := false
 
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		connectCalled = true
 		if cfg.Database != "cli-db" {
 			t.Fatalf("unexpected database: %s", cfg.Database)
@@ -209,7 +258,7 @@ func TestSuccessfulExecution(t *testing.T) {
 		return nil, nil
 	}
 
-	extract = func(db *sql.DB, cfg config.Config) (*schema.DatabaseSchema, error) {
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
 		extractCalled = true
 		if cfg.Database != "cli-db" {
 			t.Fatalf("unexpected database in extract: %s", cfg.Database)
@@ -217,7 +266,7 @@ func TestSuccessfulExecution(t *testing.T) {
 		return &schema.DatabaseSchema{Config: cfg}, nil
 	}
 
-	generate = func(dbSchema *schema.DatabaseSchema, format string) (string, error) {
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
 		generateCalled = true
 		if dbSchema.Config.Database != "cli-db" {
 			t.Fatalf("unexpected database in schema: %s", dbSchema.Config.Database)
@@ -225,7 +274,8 @@ func TestSuccessfulExecution(t *testing.T) {
 		if format != formatter.DefaultFormat {
 			t.Fatalf("unexpected format: %s", format)
 		}
-		return "diagram-output", nil
+		_, err := io.WriteString(w, "diagram-output")
+		return err
 	}
 
 	cmd := buildRootCmd()
@@ -254,7 +304,7 @@ func TestSuccessfulExecutionWithExplicitMermaidFormat(t *testing.T) {
 	extractCalled := false
 	generateCalled := false
 
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		connectCalled = true
 		if cfg.Format != "mermaid" {
 			t.Fatalf("unexpected format propagated to connect: %s", cfg.Format)
@@ -262,7 +312,7 @@ func TestSuccessfulExecutionWithExplicitMermaidFormat(t *testing.T) {
 		return nil, nil
 	}
 
-	extract = func(db *sql.DB, cfg config.Config) (*schema.DatabaseSchema, error) {
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
 		extractCalled = true
 		if cfg.Format != "mermaid" {
 			t.Fatalf("unexpected format propagated to extract: %s", cfg.Format)
@@ -270,12 +320,13 @@ func TestSuccessfulExecutionWithExplicitMermaidFormat(t *testing.T) {
 		return &schema.DatabaseSchema{Config: cfg}, nil
 	}
 
-	generate = func(dbSchema *schema.DatabaseSchema, format string) (string, error) {
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
 		generateCalled = true
 		if format != "mermaid" {
 			t.Fatalf("unexpected format received by generate: %s", format)
 		}
-		return "mermaid-diagram-output", nil
+		_, err := io.WriteString(w, "mermaid-diagram-output")
+		return err
 	}
 
 	cmd := buildRootCmd()
@@ -308,7 +359,7 @@ func TestNoPasswordFlagClearsPassword(t *testing.T) {
 	}
 
 	var received config.Config
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		received = cfg
 		return nil, errors.New("stop connect")
 	}
@@ -335,19 +386,20 @@ func TestSuccessfulExecutionClosesNonNilDB(t *testing.T) {
 		t.Fatalf("failed to create sqlmock: %v", err)
 	}
 
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		return mockDB, nil
 	}
 
-	extract = func(db *sql.DB, cfg config.Config) (*schema.DatabaseSchema, error) {
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
 		if db != mockDB {
 			t.Fatalf("expected extract to receive the connected db")
 		}
 		return &schema.DatabaseSchema{Config: cfg}, nil
 	}
 
-	generate = func(dbSchema *schema.DatabaseSchema, format string) (string, error) {
-		return "diagram-output", nil
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+		_, err := io.WriteString(w, "diagram-output")
+		return err
 	}
 
 	cmd := buildRootCmd()
@@ -374,7 +426,7 @@ func TestAskPasswordPromptError(t *testing.T) {
 	}
 
 	connectCalled := false
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		connectCalled = true
 		return nil, nil
 	}
@@ -468,7 +520,7 @@ func TestConflictingPasswordFlagsAreRejected(t *testing.T) {
 			}
 
 			connectCalled := false
-			connect = func(cfg config.Config) (*sql.DB, error) {
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 				connectCalled = true
 				return nil, nil
 			}
@@ -575,7 +627,7 @@ func TestNonConflictingPasswordFlagsAreAccepted(t *testing.T) {
 			}
 
 			var received config.Config
-			connect = func(cfg config.Config) (*sql.DB, error) {
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 				received = cfg
 				return nil, errors.New("stop connect")
 			}
@@ -607,18 +659,18 @@ func TestExtractError(t *testing.T) {
 		t.Fatalf("failed to create sqlmock: %v", err)
 	}
 
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		return mockDB, nil
 	}
 
-	extract = func(db *sql.DB, cfg config.Config) (*schema.DatabaseSchema, error) {
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
 		return nil, extractErr
 	}
 
 	generateCalled := false
-	generate = func(dbSchema *schema.DatabaseSchema, format string) (string, error) {
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
 		generateCalled = true
-		return "", nil
+		return nil
 	}
 
 	cmd := buildRootCmd()
@@ -664,16 +716,17 @@ func TestOutputWriteErrorIsReturned(t *testing.T) {
 
 	writeErr := errors.New("broken pipe")
 
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		return nil, nil
 	}
 
-	extract = func(db *sql.DB, cfg config.Config) (*schema.DatabaseSchema, error) {
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
 		return &schema.DatabaseSchema{Config: cfg}, nil
 	}
 
-	generate = func(dbSchema *schema.DatabaseSchema, format string) (string, error) {
-		return "diagram-output", nil
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+		_, err := io.WriteString(w, "diagram-output")
+		return err
 	}
 
 	cmd := buildRootCmd()
@@ -696,18 +749,18 @@ func TestUnknownFormatError(t *testing.T) {
 	resetGlobals()
 	t.Cleanup(resetGlobals)
 
-	connect = func(cfg config.Config) (*sql.DB, error) {
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 		if cfg.Format != "unknown" {
 			t.Fatalf("expected format to be forwarded, got %q", cfg.Format)
 		}
 		return nil, nil
 	}
 
-	extract = func(db *sql.DB, cfg config.Config) (*schema.DatabaseSchema, error) {
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
 		return &schema.DatabaseSchema{Config: cfg, Tables: []schema.Table{{Name: "users"}}}, nil
 	}
 
-	generate = diagram.Generate
+	generate = diagram.GenerateTo
 
 	cmd := buildRootCmd()
 	cmd.SetArgs([]string{"--database", "cli-db", "--format", "unknown"})
@@ -717,8 +770,1593 @@ func TestUnknownFormatError(t *testing.T) {
 		t.Fatalf("expected error for unknown format")
 	}
 
-	const want = "failed to generate diagram: unknown format \"unknown\". Available formats: mermaid"
+	const want = "failed to generate diagram: unknown format \"unknown\". Available formats: markdown, mermaid, pdf, png, svg, template"
 	if err.Error() != want {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
+
+func TestTablePatternsAreForwardedAndWarningsPrinted(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+
+	var received config.Config
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		received = cfg
+		return &schema.DatabaseSchema{
+			Config:   cfg,
+			Warnings: []string{`table "ghosts" listed in --tables does not exist in database "cli-db"`},
+		}, nil
+	}
+
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+		_, err := io.WriteString(w, "diagram-output")
+		return err
+	}
+
+	cmd := buildRootCmd()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--database", "cli-db", "--tables", "ghosts",
+		"--include", "billing_*", "--include", "re:^a{1,2}_", "--exclude", "tmp_*"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected successful execution, got %v", err)
+	}
+
+	// --include is a string array rather than a slice so a comma inside a
+	// regular expression is not treated as a separator.
+	if got := strings.Join(received.Include, " "); got != "billing_* re:^a{1,2}_" {
+		t.Errorf("include patterns = %q", got)
+	}
+
+	if got := strings.Join(received.Exclude, " "); got != "tmp_*" {
+		t.Errorf("exclude patterns = %q", got)
+	}
+
+	if want := "Warning: table \"ghosts\" listed in --tables does not exist in database \"cli-db\"\n"; stderr.String() != want {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+
+	if strings.Contains(stdout.String(), "Warning") {
+		t.Errorf("warnings must not be mixed into the diagram output, got %q", stdout.String())
+	}
+}
+
+func TestInvalidTablePatternIsRejectedBeforeConnecting(t *testing.T) {
+	for _, flag := range []string{"--include", "--exclude"} {
+		t.Run(flag, func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			connectCalled := false
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				connectCalled = true
+				return nil, nil
+			}
+
+			cmd := buildRootCmd()
+			cmd.SetErr(&bytes.Buffer{})
+			cmd.SetArgs([]string{"--database", "cli-db", flag, "re:("})
+
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), "invalid "+flag+" pattern") {
+				t.Fatalf("expected an invalid pattern error naming %s, got %v", flag, err)
+			}
+
+			if connectCalled {
+				t.Errorf("connect should not run with an invalid pattern")
+			}
+		})
+	}
+}
+
+func TestFocusFlagsAreForwarded(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	var received config.Config
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		received = cfg
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--focus", "orders,invoices", "--focus", "users",
+		"--depth", "2", "--focus-direction", "referenced"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+
+	if got := strings.Join(received.Focus, ","); got != "orders,invoices,users" {
+		t.Errorf("focus = %q", got)
+	}
+
+	if received.Depth != 2 {
+		t.Errorf("depth = %d, want 2", received.Depth)
+	}
+
+	if received.FocusDirection != config.FocusReferenced {
+		t.Errorf("focus direction = %q, want %q", received.FocusDirection, config.FocusReferenced)
+	}
+}
+
+func TestInvalidFocusFlagsAreRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "unknown direction",
+			args:    []string{"--database", "cli-db", "--focus", "orders", "--focus-direction", "sideways"},
+			wantErr: "invalid --focus-direction",
+		},
+		{
+			name:    "negative depth",
+			args:    []string{"--database", "cli-db", "--focus", "orders", "--depth", "-1"},
+			wantErr: "invalid --depth",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			connectCalled := false
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				connectCalled = true
+				return nil, nil
+			}
+
+			cmd := buildRootCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+
+			if connectCalled {
+				t.Errorf("connect should not run with invalid focus flags")
+			}
+		})
+	}
+}
+
+func TestExternalRefsFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{name: "defaults to stub", args: []string{"--database", "cli-db"}, want: config.ExternalStub},
+		{name: "drop", args: []string{"--database", "cli-db", "--external-refs", "drop"}, want: config.ExternalDrop},
+		{name: "include", args: []string{"--database", "cli-db", "--external-refs", "include"}, want: config.ExternalInclude},
+		{name: "unknown policy", args: []string{"--database", "cli-db", "--external-refs", "ignore"}, wantErr: "invalid --external-refs"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			var received config.Config
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				received = cfg
+				return nil, errors.New("stop connect")
+			}
+
+			cmd := buildRootCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "failed to connect") {
+				t.Fatalf("expected the run to reach connect, got %v", err)
+			}
+
+			if received.ExternalRefs != tt.want {
+				t.Errorf("external refs = %q, want %q", received.ExternalRefs, tt.want)
+			}
+		})
+	}
+}
+
+func TestInferFlagsAreForwarded(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantInfer    bool
+		wantPatterns []string
+		wantErr      string
+	}{
+		{name: "off by default", args: []string{"--database", "cli-db"}},
+		{name: "infer relations", args: []string{"--database", "cli-db", "--infer-relations"}, wantInfer: true},
+		{
+			name:         "patterns",
+			args:         []string{"--database", "cli-db", "--infer-relations", "--infer-pattern", "{name}_id", "--infer-pattern", "fk_{name}={plural}.id"},
+			wantInfer:    true,
+			wantPatterns: []string{"{name}_id", "fk_{name}={plural}.id"},
+		},
+		{name: "list implies inference", args: []string{"--database", "cli-db", "--list-inferred"}, wantInfer: true},
+		{name: "malformed pattern", args: []string{"--database", "cli-db", "--infer-pattern", "user_id"}, wantErr: "invalid --infer-pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			var received config.Config
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				received = cfg
+				return nil, errors.New("stop connect")
+			}
+
+			cmd := buildRootCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "failed to connect") {
+				t.Fatalf("expected the run to reach connect, got %v", err)
+			}
+
+			if received.InferRelations != tt.wantInfer {
+				t.Errorf("infer relations = %v, want %v", received.InferRelations, tt.wantInfer)
+			}
+
+			if !slices.Equal(received.InferPatterns, tt.wantPatterns) {
+				t.Errorf("infer patterns = %q, want %q", received.InferPatterns, tt.wantPatterns)
+			}
+		})
+	}
+}
+
+func TestListInferredPrintsRelationshipsInsteadOfDiagram(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		return &schema.DatabaseSchema{
+			Tables: []schema.Table{{
+				Name: "orders",
+				ForeignKeys: []schema.ForeignKey{
+					{ColumnName: "shop_id", ReferencedTable: "shops", ReferencedColumn: "id", RelationName: "fk_shop"},
+					{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "user_id", Inferred: true},
+				},
+			}},
+		}, nil
+	}
+	generate = func(context.Context, io.Writer, *schema.DatabaseSchema, string) error {
+		t.Fatal("generate should not run with --list-inferred")
+		return nil
+	}
+
+	var stdout bytes.Buffer
+	cmd := buildRootCmd()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"--database", "cli-db", "--list-inferred"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := stdout.String(), "orders.user_id -> users.id\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestOverlayIsAppliedBeforeGenerate(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	path := filepath.Join(t.TempDir(), "overlay.yaml")
+	content := "tables:\n  users:\n    alias: Customer\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing overlay: %v", err)
+	}
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		return &schema.DatabaseSchema{Tables: []schema.Table{{Name: "users"}}}, nil
+	}
+
+	var alias string
+	generate = func(ctx context.Context, w io.Writer, s *schema.DatabaseSchema, format string) error {
+		alias = s.Tables[0].Alias
+		_, err := io.WriteString(w, "diagram")
+		return err
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--database", "cli-db", "--overlay", path})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if alias != "Customer" {
+		t.Errorf("alias = %q, want the overlay's %q", alias, "Customer")
+	}
+}
+
+func TestInvalidOverlayFailsBeforeConnecting(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	path := filepath.Join(t.TempDir(), "overlay.yaml")
+	if err := os.WriteFile(path, []byte("tabels: {}\n"), 0o600); err != nil {
+		t.Fatalf("writing overlay: %v", err)
+	}
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		t.Fatal("connect should not run with an invalid overlay")
+		return nil, nil
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--overlay", path})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "invalid overlay file") {
+		t.Fatalf("expected an invalid overlay error, got %v", err)
+	}
+}
+
+func TestTimeoutBoundsConnectAndExtract(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("expected connect to run under a deadline")
+		}
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--timeout", "10ms"})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "timed out after 10ms: failed to extract schema") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline error to be wrapped, got %v", err)
+	}
+}
+
+func TestNoTimeoutByDefault(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		if _, ok := ctx.Deadline(); ok {
+			t.Error("expected no deadline without --timeout")
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "failed to connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+}
+
+func TestNegativeTimeoutIsRejected(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--timeout", "-1s"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid --timeout") {
+		t.Fatalf("expected an invalid timeout error, got %v", err)
+	}
+}
+
+func TestInterruptionErrorDescribesCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := interruptionError(ctx, 0, fmt.Errorf("failed to extract schema: %w", ctx.Err()))
+	if got, want := err.Error(), "interrupted: failed to extract schema: context canceled"; got != want {
+		t.Errorf("error = %q, want %q", got, want)
+	}
+
+	plain := errors.New("boom")
+	if got := interruptionError(context.Background(), 0, plain); got != plain {
+		t.Errorf("expected errors unrelated to the context to pass through, got %v", got)
+	}
+}
+
+func TestProgressIsReportedOnStderr(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("progress=%v", enabled), func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				return nil, nil
+			}
+			extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+				if (progress != nil) != enabled {
+					t.Fatalf("progress callback set = %v, want %v", progress != nil, enabled)
+				}
+				if progress != nil {
+					progress("tables", 3, 3)
+				}
+				return &schema.DatabaseSchema{Tables: []schema.Table{{Name: "users"}}}, nil
+			}
+			generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+				_, err := io.WriteString(w, "diagram")
+				return err
+			}
+
+			args := []string{"--database", "cli-db"}
+			if enabled {
+				args = append(args, "--progress")
+			}
+
+			var stdout, stderr bytes.Buffer
+			cmd := buildRootCmd()
+			cmd.SetOut(&stdout)
+			cmd.SetErr(&stderr)
+			cmd.SetArgs(args)
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := ""
+			if enabled {
+				want = "Progress: tables 3/3\n"
+			}
+			if stderr.String() != want {
+				t.Errorf("stderr = %q, want %q", stderr.String(), want)
+			}
+
+			if stdout.String() != "diagram\n" {
+				t.Errorf("stdout = %q, want only the diagram", stdout.String())
+			}
+		})
+	}
+}
+
+func TestConcurrencyFlagReachesExtract(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		if cfg.Concurrency != 8 {
+			t.Errorf("Concurrency = %d, want 8", cfg.Concurrency)
+		}
+		return nil, errors.New("stop extract")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--concurrency", "8"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop extract") {
+		t.Fatalf("expected the run to reach extract, got %v", err)
+	}
+}
+
+func TestNegativeConcurrencyIsRejected(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--concurrency", "-2"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid --concurrency") {
+		t.Fatalf("expected an invalid concurrency error, got %v", err)
+	}
+}
+
+func TestFormatterFlagsBecomeFormatOptions(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		want := map[string]string{"type-display": "full", "type-style": "portable", "comment-length": "40"}
+		if !reflect.DeepEqual(cfg.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", cfg.FormatOptions, want)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--type-display", "full", "--type-style", "portable", "--comment-length", "40"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+}
+
+func TestInvalidTypeDisplayIsRejectedBeforeConnecting(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		t.Error("connect should not be called")
+		return nil, nil
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--type-display", "medium"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid format options") {
+		t.Fatalf("expected an invalid option error, got %v", err)
+	}
+}
+
+func TestNegativeCommentLengthIsRejected(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--comment-length", "-1"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "invalid comment-length") {
+		t.Fatalf("expected an invalid comment length error, got %v", err)
+	}
+}
+
+func TestDiagramFlagsBecomeFormatOptions(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		want := map[string]string{
+			"table-comments": "comment",
+			"title":          "Shop",
+			"direction":      "LR",
+			"entity-padding": "20",
+			"theme":          "dark",
+		}
+		if !reflect.DeepEqual(cfg.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", cfg.FormatOptions, want)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--table-comments", "comment", "--title", "Shop",
+		"--direction", "LR", "--entity-padding", "20", "--theme", "dark"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+}
+
+func TestConfigFileSetsFlagsTheCommandLineLeavesOut(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	path := filepath.Join(t.TempDir(), "marid.yaml")
+	content := "database: file-db\ntitle: From file\ntheme: forest\nexclude: [\"*_old\", \"tmp_*\"]\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		if cfg.Database != "file-db" {
+			t.Errorf("Database = %q, want %q", cfg.Database, "file-db")
+		}
+		if !slices.Equal(cfg.Exclude, []string{"*_old", "tmp_*"}) {
+			t.Errorf("Exclude = %v", cfg.Exclude)
+		}
+		want := map[string]string{"title": "From flag", "theme": "forest"}
+		if !reflect.DeepEqual(cfg.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", cfg.FormatOptions, want)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--config", path, "--title", "From flag"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+}
+
+func TestConfigFileServesFormatsWithoutItsOptions(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	path := filepath.Join(t.TempDir(), "marid.yaml")
+	content := "database: file-db\ntitle: Shop\ndirection: LR\ntheme: dark\ntable-comments: alias\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		// svg has a title but no direction, theme or table comments.
+		want := map[string]string{"title": "Shop"}
+		if !reflect.DeepEqual(cfg.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", cfg.FormatOptions, want)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--config", path, "--format", "svg"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+	if strings.Contains(stderr.String(), "Warning") {
+		t.Errorf("settings of the config file were warned about:\n%s", stderr.String())
+	}
+}
+
+func TestFlagsTheFormatLacksAreIgnoredWithAWarning(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		if cfg.FormatOptions != nil {
+			t.Errorf("FormatOptions = %v, want none", cfg.FormatOptions)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	var stderr bytes.Buffer
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--database", "cli-db", "--direction", "LR", "--format", "png", "--output", "schema.png"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+	if want := "Warning: --direction is ignored for format png\n"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("stderr = %q, want %q", stderr.String(), want)
+	}
+}
+
+func TestStyleFlagsBecomeFormatOptions(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	path := filepath.Join(t.TempDir(), "marid.yaml")
+	if err := os.WriteFile(path, []byte("style: [\"billing=billing_*\", \"auth=tag:auth\"]\n"), 0o600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		want := map[string]string{"style": "billing=billing_*;auth=tag:auth", "palette": "mono"}
+		if !reflect.DeepEqual(cfg.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", cfg.FormatOptions, want)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--config", path, "--palette", "mono"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+}
+
+func TestInvalidStyleRuleIsRejected(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--style", "focus=orders"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `class "focus" is reserved`) {
+		t.Fatalf("expected a reserved class error, got %v", err)
+	}
+}
+
+func TestOrderFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{name: "defaults to alpha", args: []string{"--database", "cli-db"}, want: config.OrderAlpha},
+		{name: "topo", args: []string{"--database", "cli-db", "--order", "topo"}, want: config.OrderTopo},
+		{name: "optimized", args: []string{"--database", "cli-db", "--order", "optimized"}, want: config.OrderOptimized},
+		{name: "unknown order", args: []string{"--database", "cli-db", "--order", "random"}, wantErr: `invalid --order "random"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			var received config.Config
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				received = cfg
+				return nil, errors.New("stop connect")
+			}
+
+			cmd := buildRootCmd()
+			cmd.SetArgs(tt.args)
+
+			err := cmd.Execute()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), "failed to connect") {
+				t.Fatalf("expected the run to reach connect, got %v", err)
+			}
+
+			if received.Order != tt.want {
+				t.Errorf("order = %q, want %q", received.Order, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitReportsTheFilesWritten(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		return &schema.DatabaseSchema{Config: cfg}, nil
+	}
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+		t.Fatal("expected no single diagram when splitting")
+		return nil
+	}
+	generateSplit = func(dbSchema *schema.DatabaseSchema, format string) ([]string, error) {
+		if dbSchema.Config.Split != config.SplitCommunity || dbSchema.Config.OutputDir != "out" {
+			t.Errorf("Split = %q, OutputDir = %q", dbSchema.Config.Split, dbSchema.Config.OutputDir)
+		}
+		return []string{"out/overview.mmd", "out/orders.mmd"}, nil
+	}
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"--database", "cli-db", "--split", "community", "--output-dir", "out"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected successful execution, got %v", err)
+	}
+
+	if want := "out/overview.mmd\nout/orders.mmd\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestSplitFlagsAreValidated(t *testing.T) {
+	t.Cleanup(resetGlobals)
+
+	tests := map[string][]string{
+		"--split requires --output-dir": {"--split", "prefix"},
+		"--output-dir requires --split": {"--output-dir", "out"},
+		`invalid --split "size"`:        {"--split", "size", "--output-dir", "out"},
+	}
+
+	for want, args := range tests {
+		resetGlobals()
+
+		cmd := buildRootCmd()
+		cmd.SetArgs(append([]string{"--database", "cli-db"}, args...))
+
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("args %v: expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestRasterFlagsBecomeFormatOptions(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		want := map[string]string{"dpi": "300", "page-size": "a4"}
+		if !reflect.DeepEqual(cfg.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", cfg.FormatOptions, want)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--format", "pdf", "--dpi", "300", "--page-size", "a4", "--output", "schema.pdf"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+}
+
+func TestInvalidRasterFlagsAreRejected(t *testing.T) {
+	t.Cleanup(resetGlobals)
+
+	tests := map[string][]string{
+		`invalid dpi "5000"`:                       {"--format", "png", "--dpi", "5000"},
+		`invalid page-size "tabloid"`:              {"--format", "pdf", "--page-size", "tabloid"},
+		`format "png" has no option "page-size"`:   {"--format", "png", "--format-opt", "page-size=a4"},
+		"--output cannot be combined with --split": {"--split", "prefix", "--output-dir", "out", "--output", "out.mmd"},
+	}
+
+	for want, args := range tests {
+		resetGlobals()
+
+		cmd := buildRootCmd()
+		cmd.SetArgs(append([]string{"--database", "cli-db"}, args...))
+
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("args %v: expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestOutputIsWrittenToFile(t *testing.T) {
+	tests := []struct {
+		format string
+		output string
+		want   string
+	}{
+		{format: "mermaid", output: "erDiagram", want: "erDiagram\n"},
+		{format: "png", output: "\x89PNG\r\n\x1a\n", want: "\x89PNG\r\n\x1a\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				return nil, nil
+			}
+			extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+				return &schema.DatabaseSchema{Config: cfg}, nil
+			}
+			generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+				_, err := io.WriteString(w, tt.output)
+				return err
+			}
+
+			path := filepath.Join(t.TempDir(), "diagram")
+			cmd := buildRootCmd()
+			var stdout bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetArgs([]string{"--database", "cli-db", "--format", tt.format, "--output", path})
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("expected successful execution, got %v", err)
+			}
+
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("reading the output file: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("file = %q, want %q", got, tt.want)
+			}
+			if stdout.Len() != 0 {
+				t.Errorf("stdout = %q, want nothing", stdout.String())
+			}
+		})
+	}
+}
+
+func TestFailedRunLeavesOutputFileUntouched(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.mmd")
+	if err := os.WriteFile(path, []byte("old diagram\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		return &schema.DatabaseSchema{Config: cfg}, nil
+	}
+	renderErr := errors.New("render failed")
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+		_, _ = io.WriteString(w, "erDiagram")
+		return renderErr
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--output", path})
+
+	if err := cmd.Execute(); !errors.Is(err, renderErr) {
+		t.Fatalf("expected the render error, got %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != "old diagram\n" {
+		t.Errorf("output file = %q, %v; want it untouched", got, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("output directory holds %d files, want only the output", len(entries))
+	}
+}
+
+func TestBinaryOutputIsWrittenAsIs(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		return &schema.DatabaseSchema{Config: cfg}, nil
+	}
+	generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+		_, err := io.WriteString(w, "%PDF-1.4\n%%EOF\n")
+		return err
+	}
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"--database", "cli-db", "--format", "pdf"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected successful execution, got %v", err)
+	}
+
+	if want := "%PDF-1.4\n%%EOF\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestTextOutputEndsWithOneNewline(t *testing.T) {
+	for _, tt := range []struct {
+		format, rendered, want string
+	}{
+		{"mermaid", "erDiagram", "erDiagram\n"},
+		{"svg", "<svg></svg>\n", "<svg></svg>\n"},
+	} {
+		t.Run(tt.format, func(t *testing.T) {
+			resetGlobals()
+			t.Cleanup(resetGlobals)
+
+			connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+				return nil, nil
+			}
+			extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+				return &schema.DatabaseSchema{Config: cfg}, nil
+			}
+			generate = func(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+				_, err := io.WriteString(w, tt.rendered)
+				return err
+			}
+
+			cmd := buildRootCmd()
+			var stdout bytes.Buffer
+			cmd.SetOut(&stdout)
+			cmd.SetArgs([]string{"--database", "cli-db", "--format", tt.format})
+
+			if err := cmd.Execute(); err != nil {
+				t.Fatalf("expected successful execution, got %v", err)
+			}
+
+			if stdout.String() != tt.want {
+				t.Errorf("stdout = %q, want %q", stdout.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatOptFlagsBecomeFormatOptions(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		want := map[string]string{"type-display": "full", "theme": "dark", "title": "a=b"}
+		if !reflect.DeepEqual(cfg.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", cfg.FormatOptions, want)
+		}
+		return nil, errors.New("stop connect")
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--database", "cli-db", "--type-display", "short", "--title", "Shop",
+		"--format-opt", "type-display=full", "--format-opt", "theme=dark", "--format-opt", "title=a=b"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "stop connect") {
+		t.Fatalf("expected the run to reach connect, got %v", err)
+	}
+}
+
+func TestInvalidFormatOptsAreRejected(t *testing.T) {
+	t.Cleanup(resetGlobals)
+
+	tests := map[string][]string{
+		`invalid --format-opt "theme": want KEY=VALUE`:               {"--format-opt", "theme"},
+		`invalid --format-opt "=dark": want KEY=VALUE`:               {"--format-opt", "=dark"},
+		`format "mermaid" has no option "colour"`:                    {"--format-opt", "colour=red"},
+		`invalid theme "neon"`:                                       {"--format-opt", "theme=neon"},
+		`format "svg" has no option "theme"; it accepts`:             {"--format", "svg", "--format-opt", "theme=dark"},
+		`invalid comment-length "long": want a non-negative integer`: {"--format-opt", "comment-length=long"},
+	}
+
+	for want, args := range tests {
+		resetGlobals()
+		connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+			t.Errorf("args %v: expected no connection", args)
+			return nil, errors.New("stop connect")
+		}
+
+		cmd := buildRootCmd()
+		cmd.SetArgs(append([]string{"--database", "cli-db"}, args...))
+
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("args %v: expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestFormatsCommandListsFormats(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"formats"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("formats failed: %v", err)
+	}
+
+	for _, want := range []string{"FORMAT", "mermaid   text/plain", "png       image/png"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestFormatsCommandPrintsJSON(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"formats", "--json"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("formats --json failed: %v", err)
+	}
+
+	var infos []formatter.Info
+	if err := json.Unmarshal(stdout.Bytes(), &infos); err != nil {
+		t.Fatalf("output is not a JSON list of formats: %v\n%s", err, stdout.String())
+	}
+	byName := make(map[string]formatter.Info)
+	for _, info := range infos {
+		byName[info.Name] = info
+	}
+	if png := byName["png"]; !png.Binary || png.Extension != ".png" || len(png.Options) == 0 {
+		t.Errorf("png = %+v, want a binary .png format with options", png)
+	}
+	if mermaid := byName["mermaid"]; mermaid.Binary || !slices.Contains(mermaid.Features, formatter.FeatureComments) {
+		t.Errorf("mermaid = %+v, want a text format showing comments", mermaid)
+	}
+
+	cmd = buildRootCmd()
+	stdout.Reset()
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"formats", "--describe", "svg", "--json"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("formats --describe --json failed: %v", err)
+	}
+	var info formatter.Info
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil || info.Name != "svg" {
+		t.Errorf("formats --describe svg --json = %+v, %v", info, err)
+	}
+}
+
+func TestFormatsCommandDescribesOptions(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"formats", "--describe", "mermaid"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("formats --describe failed: %v", err)
+	}
+
+	out := stdout.String()
+	if !strings.HasPrefix(out, "mermaid (text/plain) options") {
+		t.Errorf("output does not name the format:\n%s", out)
+	}
+	for _, want := range []string{"type-display", "short|full", "theme", "default|neutral|dark|forest|base"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output lacks %q:\n%s", want, out)
+		}
+	}
+
+	cmd = buildRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"formats", "--describe", "dot"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `unknown format "dot"`) {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+}
+
+func TestConfigFileRejectsUnknownSettings(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	path := filepath.Join(t.TempDir(), "marid.yaml")
+	if err := os.WriteFile(path, []byte("database: shop\ncolour: blue\n"), 0o600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+
+	cmd := buildRootCmd()
+	cmd.SetArgs([]string{"--config", path})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `unknown setting "colour"`) {
+		t.Fatalf("expected an unknown setting error, got %v", err)
+	}
+}
+
+// writePluginScript writes a format plugin answering the handshake with a
+// text format that takes a "prefix" option, and rendering "PREFIX tables".
+func writePluginScript(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a POSIX shell")
+	}
+
+	path := filepath.Join(t.TempDir(), "marid-format-notes")
+	script := `#!/bin/sh
+request=$(cat)
+case "$request" in
+*'"action":"describe"'*)
+	printf '{"protocol":1,"media_type":"text/plain","description":"Notes","extension":".txt","options":[{"name":"prefix","kind":"string"}]}'
+	;;
+*'"prefix":"=>"'*)
+	echo "notes plugin rendering" >&2
+	printf '=> tables'
+	;;
+*)
+	exit 4
+	;;
+esac
+`
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestPluginFormatsRenderThroughThePlugin(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		return &schema.DatabaseSchema{Tables: []schema.Table{{Name: "users"}}, Config: cfg}, nil
+	}
+
+	configPath := filepath.Join(t.TempDir(), "marid.yaml")
+	if err := os.WriteFile(configPath, []byte("plugin:\n  - notes="+writePluginScript(t)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := buildRootCmd()
+	var stdout, stderr bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	cmd.SetArgs([]string{"--database", "cli-db", "--config", configPath, "--format", "notes", "--format-opt", "prefix==>"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got := stdout.String(); got != "=> tables\n" {
+		t.Errorf("stdout = %q, want the plugin's output", got)
+	}
+	if got := stderr.String(); got != "notes plugin rendering\n" {
+		t.Errorf("stderr = %q, want the plugin's stderr", got)
+	}
+
+	cmd = buildRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--database", "cli-db", "--config", configPath, "--format", "notes", "--format-opt", "colour=red"})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `format "notes" has no option "colour"`) {
+		t.Errorf("expected the undeclared option to be rejected, got %v", err)
+	}
+}
+
+func TestFormatsCommandListsPlugins(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"formats", "--plugin", "notes=" + writePluginScript(t)})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("formats failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "notes     text/plain       .txt") {
+		t.Errorf("output lacks the plugin:\n%s", stdout.String())
+	}
+}
+
+func TestFormatsCommandReadsTheConfigFile(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	// Settings of the root command's own flags, such as format, are skipped.
+	configPath := filepath.Join(t.TempDir(), "marid.yaml")
+	if err := os.WriteFile(configPath, []byte("format: svg\nplugin:\n  - notes="+writePluginScript(t)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"formats", "--config", configPath})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("formats failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "notes     text/plain       .txt") {
+		t.Errorf("output lacks the plugin of the config file:\n%s", stdout.String())
+	}
+}
+
+func TestFormatsCommandListsBrokenPlugins(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts need a POSIX shell")
+	}
+
+	broken := filepath.Join(t.TempDir(), "marid-format-broken")
+	if err := os.WriteFile(broken, []byte("#!/bin/sh\nexit 3\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"formats"}, []string{"broken    -", "cannot be loaded: ", "notes     text/plain", "mermaid   text/plain"}},
+		{[]string{"formats", "--json"}, []string{`"name": "broken"`, `"error": "`, `"name": "notes"`, `"name": "mermaid"`}},
+	}
+
+	for _, tt := range tests {
+		cmd := buildRootCmd()
+		var stdout bytes.Buffer
+		cmd.SetOut(&stdout)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(append(tt.args, "--plugin", "broken="+broken, "--plugin", "notes="+writePluginScript(t)))
+
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", tt.args, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("%v output lacks %q:\n%s", tt.args, want, stdout.String())
+			}
+		}
+	}
+}
+
+func TestInvalidPluginsAreRejected(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--plugin", "notes"}, `invalid --plugin "notes": want NAME=PATH`},
+		{[]string{"--plugin", "mermaid=/bin/true"}, `format "mermaid" is built in`},
+		{[]string{"--plugin-timeout", "-1s"}, "invalid --plugin-timeout -1s: must not be negative"},
+	}
+
+	for _, tt := range tests {
+		resetGlobals()
+		cmd := buildRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetArgs(append([]string{"--database", "cli-db"}, tt.args...))
+
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%v: got %v, want an error containing %q", tt.args, err, tt.want)
+		}
+	}
+	resetGlobals()
+}
+
+func TestTemplateFlagRendersTheTemplate(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		return &schema.DatabaseSchema{Tables: []schema.Table{{Name: "users"}, {Name: "orders"}}, Config: cfg}, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "tables.tmpl")
+	if err := os.WriteFile(path, []byte("{{ range .Tables }}{{ .Name | upper }} {{ end }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"--database", "cli-db", "--format", "template", "--template", path})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("execute failed: %v", err)
+	}
+	if got := stdout.String(); got != "USERS ORDERS \n" {
+		t.Errorf("stdout = %q", got)
+	}
+
+	resetGlobals()
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		t.Error("expected no connection")
+		return nil, errors.New("stop connect")
+	}
+	if err := os.WriteFile(path, []byte("{{ range .Tables }}\n{{ .Name | shout }}\n{{ end }}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd = buildRootCmd()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--database", "cli-db", "--format", "template", "--template", path})
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), `tables.tmpl:2: function "shout" not defined`) {
+		t.Errorf("expected the template error before connecting, got %v", err)
+	}
+}
+
+func TestDocsWritesTheSite(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	path := filepath.Join(t.TempDir(), "marid.yaml")
+	if err := os.WriteFile(path, []byte("database: file-db\nformat: png\ntheme: dark\nexclude: [\"tmp_*\"]\n"), 0o600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		if cfg.Database != "file-db" || !slices.Equal(cfg.Exclude, []string{"tmp_*"}) {
+			t.Errorf("Database = %q, Exclude = %v", cfg.Database, cfg.Exclude)
+		}
+		return &schema.DatabaseSchema{Tables: []schema.Table{{Name: "users"}}, Config: cfg}, nil
+	}
+	generateSite = func(dbSchema *schema.DatabaseSchema, dir string, opts site.Options) ([]string, error) {
+		if dir != "site" {
+			t.Errorf("dir = %q, want site", dir)
+		}
+		if opts.Title != "file-db" {
+			t.Errorf("Title = %q, want the database name", opts.Title)
+		}
+		if want := (formatter.Options{formatter.OptionTypeDisplay: formatter.TypeDisplayFull}); !reflect.DeepEqual(opts.FormatOptions, want) {
+			t.Errorf("FormatOptions = %v, want %v", opts.FormatOptions, want)
+		}
+		return []string{"site/index.html", "site/tables/users.html"}, nil
+	}
+
+	cmd := buildRootCmd()
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	cmd.SetArgs([]string{"docs", "--config", path, "--out", "site", "--type-display", "full"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("expected successful execution, got %v", err)
+	}
+	if want := "site/index.html\nsite/tables/users.html\n"; stdout.String() != want {
+		t.Errorf("stdout = %q, want %q", stdout.String(), want)
+	}
+}
+
+func TestDocsFlagsAreValidated(t *testing.T) {
+	t.Cleanup(resetGlobals)
+
+	tests := map[string][]string{
+		"--out is required":         {"docs", "--database", "cli-db"},
+		"reading --mermaid-js":      {"docs", "--database", "cli-db", "--out", "site", "--mermaid-js", filepath.Join(t.TempDir(), "missing.js")},
+		"database name is required": {"docs", "--out", "site"},
+	}
+
+	for want, args := range tests {
+		resetGlobals()
+		connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+			t.Errorf("%s: expected no connection", want)
+			return nil, errors.New("stop connect")
+		}
+
+		cmd := buildRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: expected %q, got %v", args, want, err)
+		}
+	}
+}
+
+func TestInjectRefreshesBlocks(t *testing.T) {
+	resetGlobals()
+	t.Cleanup(resetGlobals)
+
+	connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+		return nil, nil
+	}
+	extractions := 0
+	extract = func(ctx context.Context, db *sql.DB, cfg config.Config, progress schema.ProgressFunc) (*schema.DatabaseSchema, error) {
+		extractions++
+		tables := []schema.Table{{Name: "orders"}, {Name: "users"}}
+		if cfg.Tables != "" {
+			tables = []schema.Table{{Name: cfg.Tables}}
+		}
+		return &schema.DatabaseSchema{Tables: tables, Config: cfg}, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "database.md")
+	original := "# Database\n\n" +
+		"<!-- marid:start name=overview -->\nstale\n<!-- marid:end -->\n\n" +
+		"Users:\n\n" +
+		"<!-- marid:start name=users tables=users title=\"User accounts\" -->\n<!-- marid:end -->\n\n" +
+		"<!-- marid:start name=again -->\n<!-- marid:end -->\n"
+	if err := os.WriteFile(path, []byte(original), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) (string, error) {
+		cmd := buildRootCmd()
+		var stdout bytes.Buffer
+		cmd.SetOut(&stdout)
+		cmd.SetArgs(append([]string{"inject", "--database", "cli-db", "--file", path}, args...))
+		err := cmd.Execute()
+		return stdout.String(), err
+	}
+
+	stdout, err := run("--check")
+	if err == nil || !strings.Contains(err.Error(), "1 file is out of date") {
+		t.Fatalf("--check on a stale file returned %v", err)
+	}
+	if want := path + ": out of date: overview, users, again\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+	if got := readTestFile(t, path); got != original {
+		t.Errorf("--check changed the file:\n%s", got)
+	}
+
+	extractions = 0
+	if stdout, err = run(); err != nil {
+		t.Fatalf("inject returned error: %v", err)
+	}
+	if want := path + ": updated overview, users, again\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+	if extractions != 2 {
+		t.Errorf("extracted %d times, want one per table selection", extractions)
+	}
+
+	want := "# Database\n\n" +
+		"<!-- marid:start name=overview -->\n```mermaid\nerDiagram\n    orders {\n    }\n    users {\n    }\n```\n<!-- marid:end -->\n\n" +
+		"Users:\n\n" +
+		"<!-- marid:start name=users tables=users title=\"User accounts\" -->\n```mermaid\n---\ntitle: User accounts\n---\nerDiagram\n    users {\n    }\n```\n<!-- marid:end -->\n\n" +
+		"<!-- marid:start name=again -->\n```mermaid\nerDiagram\n    orders {\n    }\n    users {\n    }\n```\n<!-- marid:end -->\n"
+	if got := readTestFile(t, path); got != want {
+		t.Errorf("file =\n%s\nwant\n%s", got, want)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("file mode = %v, %v; want 0640 kept", info.Mode(), err)
+	}
+
+	if stdout, err = run("--check"); err != nil {
+		t.Fatalf("--check on a fresh file returned %v", err)
+	}
+	if want := path + ": up to date\n"; stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+}
+
+func TestInjectRejectsInvalidMarkersBeforeConnecting(t *testing.T) {
+	t.Cleanup(resetGlobals)
+
+	tests := map[string]string{
+		"format png writes image/png":      "<!-- marid:start name=a format=png -->",
+		`unknown format "bogus"`:           "<!-- marid:start name=a format=bogus -->",
+		`has no option "colour"`:           "<!-- marid:start name=a colour=red -->",
+		`invalid depth "two"`:              "<!-- marid:start name=a focus=users depth=two -->",
+		`invalid --focus-direction "up"`:   "<!-- marid:start name=a focus=users focus-direction=up -->",
+		`block "a" has no marid:end`:       "<!-- marid:start name=a fence=none -->\ntext",
+		"has no <!-- marid:start name=...": "no markers here",
+	}
+
+	for want, marker := range tests {
+		resetGlobals()
+		connect = func(ctx context.Context, cfg config.Config) (*sql.DB, error) {
+			t.Errorf("%s: expected no connection", want)
+			return nil, errors.New("stop connect")
+		}
+
+		path := filepath.Join(t.TempDir(), "database.md")
+		text := marker
+		if strings.HasSuffix(marker, "-->") {
+			text += "\n<!-- marid:end -->\n"
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := buildRootCmd()
+		cmd.SetOut(&bytes.Buffer{})
+		cmd.SetArgs([]string{"inject", "--database", "cli-db", "--file", path})
+		if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected %q, got %v", marker, want, err)
+		}
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
@@ -0,0 +1,41 @@
+// Package atomicfile replaces files so that readers never see one half
+// written.
+package atomicfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Write writes the file at path with write and the given mode. The file is
+// written under a temporary name in the same directory and renamed into place
+// once complete, so a failed run leaves any earlier file untouched. Errors
+// handling the file say that writing what failed; write's own are returned as
+// they are.
+func Write(path string, mode os.FileMode, what string, write func(io.Writer) error) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", what, err)
+	}
+	defer func() {
+		_ = os.Remove(file.Name())
+	}()
+
+	err = write(file)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing %s: %w", what, closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(file.Name(), mode); err != nil {
+		return fmt.Errorf("writing %s: %w", what, err)
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("writing %s: %w", what, err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteReplacesTheFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.mmd")
+	if err := os.WriteFile(path, []byte("old diagram\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Write(path, 0o644, "diagram", func(w io.Writer) error {
+		_, err := io.WriteString(w, "erDiagram\n")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != "erDiagram\n" {
+		t.Errorf("file = %q, %v; want the new diagram", got, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Errorf("mode = %v, %v; want 0644", info.Mode().Perm(), err)
+	}
+}
+
+func TestFailedWriteLeavesTheFileUntouched(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.mmd")
+	if err := os.WriteFile(path, []byte("old diagram\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	renderErr := errors.New("render failed")
+	err := Write(path, 0o644, "diagram", func(w io.Writer) error {
+		_, _ = io.WriteString(w, "erDiagram")
+		return renderErr
+	})
+	if !errors.Is(err, renderErr) {
+		t.Fatalf("Write returned %v, want the render error", err)
+	}
+
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != "old diagram\n" {
+		t.Errorf("file = %q, %v; want it untouched", got, err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("directory holds %d files, want only the file", len(entries))
+	}
+}
+
+func TestWriteReportsWhatFailed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "schema.mmd")
+	err := Write(path, 0o644, "diagram", func(io.Writer) error { return nil })
+	if err == nil || !strings.HasPrefix(err.Error(), "writing diagram: ") {
+		t.Errorf("Write returned %v, want a writing diagram error", err)
+	}
+}
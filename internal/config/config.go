@@ -1,5 +1,7 @@
 package config
 
+import "time"
+
 // Config holds application configuration
 type Config struct {
 	Host     string
@@ -9,8 +11,91 @@ type Config struct {
 	Database string
 	Tables   string
 	Format   string
+	// Include and Exclude hold table name patterns (globs, or regular
+	// expressions prefixed with "re:") that widen and narrow the selection.
+	Include []string
+	Exclude []string
+	// Focus restricts the diagram to the tables within Depth foreign-key hops
+	// of the named tables, following FocusDirection.
+	Focus          []string
+	Depth          int
+	FocusDirection string
+	// ExternalRefs decides what happens to foreign keys that reference a table
+	// outside the selection.
+	ExternalRefs string
+	// InferRelations proposes foreign keys the database does not declare from
+	// column names matching InferPatterns (COLUMN or COLUMN=TABLE.COLUMN, with
+	// a {name} placeholder). Without patterns, {name}_id is used.
+	InferRelations bool
+	InferPatterns  []string
+	// Overlay is the path of a YAML or JSON file whose annotations and
+	// virtual relationships are merged into the extracted schema.
+	Overlay string
+	// Timeout bounds connecting and extracting together; zero means no limit.
+	Timeout time.Duration
+	// Concurrency is the number of workers reading table details per table;
+	// zero reads the whole database with a few set-based queries instead.
+	Concurrency int
+	// FormatOptions configure the output formatter, such as
+	// "type-display": "full".
+	FormatOptions map[string]string
+	// Order decides the order tables are declared in, which guides how
+	// renderers lay them out.
+	Order string
+	// Split, when set, partitions the diagram into one diagram per cluster of
+	// tables plus an overview, written as files in OutputDir.
+	Split     string
+	OutputDir string
+	// Output is the file a single diagram is written to; empty means
+	// standard output.
+	Output string
 }
 
+// Directions accepted by FocusDirection. An empty direction means FocusBoth.
+const (
+	// FocusBoth follows foreign keys in either direction.
+	FocusBoth = "both"
+	// FocusReferencing follows edges to the tables that reference the current one.
+	FocusReferencing = "referencing"
+	// FocusReferenced follows edges to the tables the current one references.
+	FocusReferenced = "referenced"
+)
+
+// Policies accepted by ExternalRefs. An empty policy means ExternalStub.
+const (
+	// ExternalStub renders a referenced table outside the selection as a
+	// minimal entity holding only the referenced columns, marked as external.
+	ExternalStub = "stub"
+	// ExternalDrop omits foreign keys to tables outside the selection.
+	ExternalDrop = "drop"
+	// ExternalInclude adds referenced tables to the selection. Tables that
+	// still cannot be included, such as those in another database, fall back
+	// to stubs.
+	ExternalInclude = "include"
+)
+
+// Orders accepted by Order. An empty order means OrderAlpha.
+const (
+	// OrderAlpha keeps the alphabetical order tables are extracted in.
+	OrderAlpha = "alpha"
+	// OrderTopo declares referenced tables before the tables referencing
+	// them, by foreign-key depth.
+	OrderTopo = "topo"
+	// OrderOptimized refines OrderTopo to reduce crossing relationships.
+	OrderOptimized = "optimized"
+)
+
+// Strategies accepted by Split. An empty strategy renders a single diagram.
+const (
+	// SplitPrefix clusters tables by the part of their name before the first
+	// underscore.
+	SplitPrefix = "prefix"
+	// SplitGroup clusters tables by the group an overlay file assigns them.
+	SplitGroup = "group"
+	// SplitCommunity clusters tables that are densely linked by foreign keys.
+	SplitCommunity = "community"
+)
+
 // GetTablesList returns a slice of table names from the comma-separated list
 func (c *Config) GetTablesList() []string {
 	if c.Tables == "" {
@@ -65,6 +65,8 @@ func TestMergeWithCommandLineConfig(t *testing.T) {
 		Database: "cli-db",
 		Tables:   "users,orders",
 		Format:   "mermaid",
+		Include:  []string{"billing_*"},
+		Exclude:  []string{"re:_old$"},
 	}
 
 	merged := MergeWithCommandLineConfig(mycnf, cmdCfg)
@@ -97,6 +99,14 @@ func TestMergeWithCommandLineConfig(t *testing.T) {
 		t.Fatalf("expected format %q, got %q", cmdCfg.Format, merged.Format)
 	}
 
+	if len(merged.Include) != 1 || merged.Include[0] != "billing_*" {
+		t.Fatalf("expected include patterns from command line, got %v", merged.Include)
+	}
+
+	if len(merged.Exclude) != 1 || merged.Exclude[0] != "re:_old$" {
+		t.Fatalf("expected exclude patterns from command line, got %v", merged.Exclude)
+	}
+
 	t.Run("falls back to mycnf values when cli is empty", func(t *testing.T) {
 		cmdOnlyTables := &Config{Tables: "one"}
 		mergedFallback := MergeWithCommandLineConfig(mycnf, cmdOnlyTables)
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadFile reads a marid configuration file: a YAML mapping from long flag
+// names to values, such as
+//
+//	database: shop
+//	exclude: ["*_old", "tmp_*"]
+//	title: Shop
+//	direction: LR
+//
+// Scalars are returned as one value and sequences as one value per item, in
+// the form the flag would take them on the command line.
+func LoadFile(path string) (map[string][]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	settings := make(map[string][]string, len(raw))
+	for name, value := range raw {
+		switch value := value.(type) {
+		case nil:
+			return nil, fmt.Errorf("invalid config file %s: %q has no value", path, name)
+		case []any:
+			values := make([]string, 0, len(value))
+			for _, item := range value {
+				if !isScalar(item) {
+					return nil, fmt.Errorf("invalid config file %s: %q must list plain values", path, name)
+				}
+				values = append(values, fmt.Sprint(item))
+			}
+			settings[name] = values
+		default:
+			if !isScalar(value) {
+				return nil, fmt.Errorf("invalid config file %s: %q must be a plain value or a list", path, name)
+			}
+			settings[name] = []string{fmt.Sprint(value)}
+		}
+	}
+
+	return settings, nil
+}
+
+// isScalar reports whether a decoded YAML value is a string, number or bool.
+func isScalar(value any) bool {
+	switch value.(type) {
+	case string, int, int64, uint64, float64, bool:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "marid.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfigFile(t, `
+database: shop
+port: 3307
+infer-relations: true
+exclude: ["*_old", tmp_*]
+title: "Shop: orders"
+`)
+
+	got, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile returned error: %v", err)
+	}
+
+	want := map[string][]string{
+		"database":        {"shop"},
+		"port":            {"3307"},
+		"infer-relations": {"true"},
+		"exclude":         {"*_old", "tmp_*"},
+		"title":           {"Shop: orders"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LoadFile() = %v, want %v", got, want)
+	}
+}
+
+func TestLoadFileRejectsInvalidContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "not a mapping", content: "- database\n", want: "invalid config file"},
+		{name: "empty value", content: "title:\n", want: "has no value"},
+		{name: "nested mapping", content: "er:\n  direction: LR\n", want: "plain value or a list"},
+		{name: "nested list", content: "exclude: [[a]]\n", want: "plain values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writeConfigFile(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
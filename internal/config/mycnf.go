@@ -77,41 +77,30 @@ func GetMyCnfConfig() (*MySQLConfig, error) {
 // MergeWithCommandLineConfig merges my.cnf values with command line values
 // Command line values take precedence over my.cnf values
 func MergeWithCommandLineConfig(myCnfConfig *MySQLConfig, cmdConfig *Config) *Config {
-	// Start with my.cnf values
-	mergedConfig := &Config{
-		Host:     myCnfConfig.Host,
-		Port:     myCnfConfig.Port,
-		User:     myCnfConfig.User,
-		Password: myCnfConfig.Password,
-		Database: myCnfConfig.Database,
-		Tables:   cmdConfig.Tables, // Tables are only specified via command line
-		Format:   cmdConfig.Format,
-	}
-
-	// Override with command line values if they're not empty
-	if cmdConfig.Host != "" {
-		mergedConfig.Host = cmdConfig.Host
-	}
+	// Start with the command line values: everything except the connection
+	// settings below is only specified via the command line
+	mergedConfig := *cmdConfig
 
-	if cmdConfig.Port != 0 {
-		mergedConfig.Port = cmdConfig.Port
+	// Fall back to my.cnf values where the command line left them empty
+	if mergedConfig.Host == "" {
+		mergedConfig.Host = myCnfConfig.Host
 	}
 
-	if cmdConfig.User != "" {
-		mergedConfig.User = cmdConfig.User
+	if mergedConfig.Port == 0 {
+		mergedConfig.Port = myCnfConfig.Port
 	}
 
-	if cmdConfig.Password != "" {
-		mergedConfig.Password = cmdConfig.Password
+	if mergedConfig.User == "" {
+		mergedConfig.User = myCnfConfig.User
 	}
 
-	if cmdConfig.Database != "" {
-		mergedConfig.Database = cmdConfig.Database
+	if mergedConfig.Password == "" {
+		mergedConfig.Password = myCnfConfig.Password
 	}
 
-	if cmdConfig.Format != "" {
-		mergedConfig.Format = cmdConfig.Format
+	if mergedConfig.Database == "" {
+		mergedConfig.Database = myCnfConfig.Database
 	}
 
-	return mergedConfig
+	return &mergedConfig
 }
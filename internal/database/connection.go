@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -10,7 +11,7 @@ import (
 )
 
 type sqlDB interface {
-	Ping() error
+	PingContext(context.Context) error
 	Close() error
 	SetMaxOpenConns(int)
 	SetMaxIdleConns(int)
@@ -26,8 +27,8 @@ type wrappedDB struct {
 	DB *sql.DB
 }
 
-func (w *wrappedDB) Ping() error {
-	return w.DB.Ping()
+func (w *wrappedDB) PingContext(ctx context.Context) error {
+	return w.DB.PingContext(ctx)
 }
 
 func (w *wrappedDB) Close() error {
@@ -61,8 +62,9 @@ var openDB = func(driverName, dataSourceName string) (dbHandle, error) {
 
 var defaultOpenDB = openDB
 
-// Connect establishes a connection to the MySQL database
-func Connect(cfg config.Config) (*sql.DB, error) {
+// Connect establishes a connection to the MySQL database. The context bounds
+// the initial ping; the DSN's own timeout still limits dialing.
+func Connect(ctx context.Context, cfg config.Config) (*sql.DB, error) {
 	// Create DSN (Data Source Name)
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&timeout=30s",
 		cfg.User, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
@@ -74,12 +76,13 @@ func Connect(cfg config.Config) (*sql.DB, error) {
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(10)
+	// Leave a connection for every extraction worker
+	db.SetMaxOpenConns(max(10, cfg.Concurrency))
 	db.SetMaxIdleConns(5)
 	db.SetConnMaxLifetime(time.Minute * 3)
 
 	// Test connection
-	err = db.Ping()
+	err = db.PingContext(ctx)
 	if err != nil {
 		_ = db.Close()
 		return nil, fmt.Errorf("error connecting to database: %w", err)
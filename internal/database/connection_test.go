@@ -21,9 +21,12 @@ type stubDB struct {
 	pingCalled  bool
 }
 
-func (s *stubDB) Ping() error {
+func (s *stubDB) PingContext(ctx context.Context) error {
 	s.pingCalled = true
-	return s.pingErr
+	if s.pingErr != nil {
+		return s.pingErr
+	}
+	return ctx.Err()
 }
 
 func (s *stubDB) Close() error {
@@ -109,7 +112,7 @@ func TestWrappedDBDelegatesToUnderlyingSQLDB(t *testing.T) {
 		t.Fatalf("expected SetConnMaxLifetime to apply to the underlying db, no expired connections were closed")
 	}
 
-	if err := w.Ping(); err != nil {
+	if err := w.PingContext(ctx); err != nil {
 		t.Fatalf("expected Ping to succeed, got %v", err)
 	}
 
@@ -142,7 +145,7 @@ func TestConnectConfiguresDatabase(t *testing.T) {
 		Database: "db",
 	}
 
-	db, err := Connect(cfg)
+	db, err := Connect(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("Connect returned error: %v", err)
 	}
@@ -189,7 +192,7 @@ func TestConnectReturnsOpenError(t *testing.T) {
 		return nil, openErr
 	}
 
-	_, err := Connect(config.Config{})
+	_, err := Connect(context.Background(), config.Config{})
 	if err == nil || !errors.Is(err, openErr) {
 		t.Fatalf("expected wrapped open error, got %v", err)
 	}
@@ -205,7 +208,7 @@ func TestConnectReturnsPingErrorAndCloses(t *testing.T) {
 		return stub, nil
 	}
 
-	_, err := Connect(config.Config{})
+	_, err := Connect(context.Background(), config.Config{})
 	if err == nil || !errors.Is(err, pingErr) {
 		t.Fatalf("expected wrapped ping error, got %v", err)
 	}
@@ -214,3 +217,41 @@ func TestConnectReturnsPingErrorAndCloses(t *testing.T) {
 		t.Fatalf("expected Close to be called after ping failure")
 	}
 }
+
+func TestConnectHonoursCancelledContext(t *testing.T) {
+	t.Cleanup(func() { openDB = defaultOpenDB })
+
+	stub := &stubDB{}
+	openDB = func(string, string) (dbHandle, error) {
+		return stub, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Connect(ctx, config.Config{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled ping, got %v", err)
+	}
+
+	if !stub.closeCalled {
+		t.Fatalf("expected Close to be called after a cancelled ping")
+	}
+}
+
+func TestConnectSizesPoolForConcurrency(t *testing.T) {
+	t.Cleanup(func() { openDB = defaultOpenDB })
+
+	stub := &stubDB{}
+	openDB = func(string, string) (dbHandle, error) {
+		return stub, nil
+	}
+
+	if _, err := Connect(context.Background(), config.Config{Concurrency: 16}); err != nil {
+		t.Fatalf("Connect returned error: %v", err)
+	}
+
+	if stub.maxOpen != 16 {
+		t.Errorf("expected a connection per worker, got MaxOpenConns %d", stub.maxOpen)
+	}
+}
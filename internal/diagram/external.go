@@ -0,0 +1,140 @@
+package diagram
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// resolveExternalReferences applies the external reference policy to foreign
+// keys whose referenced table is not part of the render data, so formatters
+// never have to invent entities for them.
+//
+// ExternalDrop removes such foreign keys. ExternalStub, and ExternalInclude for
+// whatever extraction could not include, append one stub table per referenced
+// table holding only the referenced columns, typed like the columns that
+// reference them and marked as its key; see markStubKeys.
+func resolveExternalReferences(data formatter.RenderData, policy string) (formatter.RenderData, error) {
+	switch policy {
+	case "", config.ExternalStub, config.ExternalInclude:
+	case config.ExternalDrop:
+	default:
+		return data, fmt.Errorf("unknown external reference policy %q", policy)
+	}
+
+	present := make(map[string]bool, len(data.Tables))
+	for _, table := range data.Tables {
+		present[table.Name] = true
+	}
+
+	if policy == config.ExternalDrop {
+		for i, table := range data.Tables {
+			var kept []formatter.ForeignKey
+			for _, fk := range table.ForeignKeys {
+				if present[fk.ReferencedTable] {
+					kept = append(kept, fk)
+				}
+			}
+			data.Tables[i].ForeignKeys = kept
+		}
+		return data, nil
+	}
+
+	stubs := make(map[string]*formatter.Table)
+	// keys holds the referenced columns of each stub by foreign key.
+	keys := make(map[string]map[string][]string)
+	for _, table := range data.Tables {
+		for _, fk := range table.ForeignKeys {
+			if present[fk.ReferencedTable] {
+				continue
+			}
+
+			stub, ok := stubs[fk.ReferencedTable]
+			if !ok {
+				stub = &formatter.Table{Name: fk.ReferencedTable, External: true}
+				stubs[fk.ReferencedTable] = stub
+				keys[fk.ReferencedTable] = make(map[string][]string)
+			}
+
+			constraint := table.Name + "." + fk.RelationName
+			if fk.RelationName == "" {
+				constraint = table.Name + "." + fk.ColumnName
+			}
+			keys[fk.ReferencedTable][constraint] = append(keys[fk.ReferencedTable][constraint], fk.ReferencedColumn)
+
+			if !hasColumn(*stub, fk.ReferencedColumn) {
+				stub.Columns = append(stub.Columns, formatter.Column{
+					Name:     fk.ReferencedColumn,
+					DataType: columnType(table, fk.ColumnName),
+				})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(stubs))
+	for name := range stubs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		markStubKeys(stubs[name], keys[name])
+		data.Tables = append(data.Tables, *stubs[name])
+	}
+
+	return data, nil
+}
+
+// markStubKeys marks the columns of a stub as the key the foreign keys point
+// at. Only the referenced columns are known, so when every foreign key
+// references all of them they are taken for the primary key; when foreign
+// keys reference different columns, which of them is the primary key cannot
+// be told, and each is marked unique instead.
+func markStubKeys(stub *formatter.Table, keys map[string][]string) {
+	primary := true
+	for _, columns := range keys {
+		if len(distinct(columns)) != len(stub.Columns) {
+			primary = false
+			break
+		}
+	}
+
+	for i := range stub.Columns {
+		if primary {
+			stub.Columns[i].IsPrimary = true
+			stub.PrimaryKey = append(stub.PrimaryKey, stub.Columns[i].Name)
+		} else {
+			stub.Columns[i].IsUnique = true
+		}
+	}
+}
+
+func distinct(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return set
+}
+
+func hasColumn(table formatter.Table, name string) bool {
+	for _, column := range table.Columns {
+		if column.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// columnType returns the data type of the named column, or an empty string
+// when the table does not have it.
+func columnType(table formatter.Table, name string) string {
+	for _, column := range table.Columns {
+		if column.Name == name {
+			return column.DataType
+		}
+	}
+	return ""
+}
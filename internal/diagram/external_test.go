@@ -0,0 +1,192 @@
+package diagram
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// ordersOutsideCustomers is a selection where orders references customers and
+// regions, neither of which was selected, plus users, which was.
+func ordersOutsideCustomers() formatter.RenderData {
+	return formatter.RenderData{
+		Tables: []formatter.Table{
+			{
+				Name: "orders",
+				Columns: []formatter.Column{
+					{Name: "id", DataType: "bigint"},
+					{Name: "customer_id", DataType: "bigint"},
+					{Name: "customer_code", DataType: "varchar"},
+					{Name: "region_id", DataType: "int"},
+					{Name: "user_id", DataType: "bigint"},
+				},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "region_id", ReferencedTable: "regions", ReferencedColumn: "id", RelationName: "fk_region"},
+					{ColumnName: "customer_id", ReferencedTable: "customers", ReferencedColumn: "id", RelationName: "fk_customer"},
+					{ColumnName: "customer_code", ReferencedTable: "customers", ReferencedColumn: "code", RelationName: "fk_customer_code"},
+					{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_user"},
+				},
+			},
+			{Name: "users", Columns: []formatter.Column{{Name: "id", DataType: "bigint"}}},
+		},
+	}
+}
+
+func TestResolveExternalReferencesStubs(t *testing.T) {
+	for _, policy := range []string{"", config.ExternalStub, config.ExternalInclude} {
+		t.Run("policy "+policy, func(t *testing.T) {
+			got, err := resolveExternalReferences(ordersOutsideCustomers(), policy)
+			if err != nil {
+				t.Fatalf("resolveExternalReferences returned error: %v", err)
+			}
+
+			if len(got.Tables) != 4 {
+				t.Fatalf("expected two stubs to be appended, got %+v", got.Tables)
+			}
+
+			// Foreign keys are untouched; the stubs give them something to point at.
+			if len(got.Tables[0].ForeignKeys) != 4 {
+				t.Errorf("foreign keys should be kept, got %+v", got.Tables[0].ForeignKeys)
+			}
+
+			wantStubs := []formatter.Table{
+				{
+					Name:     "customers",
+					External: true,
+					// Two foreign keys reference different columns, so
+					// neither can be told to be the primary key.
+					Columns: []formatter.Column{
+						{Name: "id", DataType: "bigint", IsUnique: true},
+						{Name: "code", DataType: "varchar", IsUnique: true},
+					},
+				},
+				{
+					Name:       "regions",
+					External:   true,
+					Columns:    []formatter.Column{{Name: "id", DataType: "int", IsPrimary: true}},
+					PrimaryKey: []string{"id"},
+				},
+			}
+
+			if !reflect.DeepEqual(got.Tables[2:], wantStubs) {
+				t.Errorf("stubs = %+v, want %+v", got.Tables[2:], wantStubs)
+			}
+		})
+	}
+}
+
+func TestResolveExternalReferencesStubKeys(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{
+				Name: "invoices",
+				Columns: []formatter.Column{
+					{Name: "customer_id", DataType: "int"},
+					{Name: "country", DataType: "char"},
+					{Name: "number", DataType: "int"},
+				},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "customer_id", ReferencedTable: "customers", ReferencedColumn: "id", RelationName: "fk_customer"},
+					{ColumnName: "country", ReferencedTable: "tax_numbers", ReferencedColumn: "country", RelationName: "fk_tax"},
+					{ColumnName: "number", ReferencedTable: "tax_numbers", ReferencedColumn: "number", RelationName: "fk_tax"},
+				},
+			},
+			{
+				Name:    "orders",
+				Columns: []formatter.Column{{Name: "customer_id", DataType: "int"}},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "customer_id", ReferencedTable: "customers", ReferencedColumn: "id"},
+				},
+			},
+		},
+	}
+
+	got, err := resolveExternalReferences(data, config.ExternalStub)
+	if err != nil {
+		t.Fatalf("resolveExternalReferences returned error: %v", err)
+	}
+
+	// A column every foreign key references, and the columns of a composite
+	// foreign key, are the primary key of the stub.
+	wantKeys := map[string][]string{"customers": {"id"}, "tax_numbers": {"country", "number"}}
+	for _, stub := range got.Tables[2:] {
+		if !reflect.DeepEqual(stub.PrimaryKey, wantKeys[stub.Name]) {
+			t.Errorf("%s primary key = %v, want %v", stub.Name, stub.PrimaryKey, wantKeys[stub.Name])
+		}
+		for _, column := range stub.Columns {
+			if !column.IsPrimary || column.IsUnique {
+				t.Errorf("%s.%s = %+v, want it marked primary", stub.Name, column.Name, column)
+			}
+		}
+	}
+}
+
+func TestResolveExternalReferencesDrop(t *testing.T) {
+	got, err := resolveExternalReferences(ordersOutsideCustomers(), config.ExternalDrop)
+	if err != nil {
+		t.Fatalf("resolveExternalReferences returned error: %v", err)
+	}
+
+	if len(got.Tables) != 2 {
+		t.Fatalf("drop must not add tables, got %+v", got.Tables)
+	}
+
+	want := []formatter.ForeignKey{{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_user"}}
+	if !reflect.DeepEqual(got.Tables[0].ForeignKeys, want) {
+		t.Errorf("foreign keys = %+v, want only the one inside the selection", got.Tables[0].ForeignKeys)
+	}
+}
+
+func TestResolveExternalReferencesRejectsUnknownPolicy(t *testing.T) {
+	if _, err := resolveExternalReferences(ordersOutsideCustomers(), "ignore"); err == nil {
+		t.Fatal("expected an unknown policy to be rejected")
+	}
+}
+
+func TestGenerateRendersExternalStubByDefault(t *testing.T) {
+	dbSchema := &schema.DatabaseSchema{
+		Tables: []schema.Table{
+			{
+				Name:    "orders",
+				Columns: []schema.Column{{Name: "id", DataType: "int"}, {Name: "customer_id", DataType: "int"}},
+				ForeignKeys: []schema.ForeignKey{
+					{ColumnName: "customer_id", ReferencedTable: "customers", ReferencedColumn: "id", RelationName: "placed_by"},
+				},
+			},
+		},
+	}
+
+	expected := "erDiagram\n" +
+		"    orders {\n" +
+		"        id int\n" +
+		"        customer_id int FK\n" +
+		"    }\n" +
+		"    customers[\"customers (external)\"] {\n" +
+		"        id int PK\n" +
+		"    }\n" +
+		"    customers ||--o{ orders : \"placed_by\"\n" +
+		"    classDef external stroke-dasharray:5 5\n" +
+		"    class customers external\n"
+
+	got, err := Generate(dbSchema, "")
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	if got != expected {
+		t.Fatalf("unexpected diagram output:\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	dbSchema.Config.ExternalRefs = config.ExternalDrop
+	got, err = Generate(dbSchema, "")
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	if want := "erDiagram\n    orders {\n        id int\n        customer_id int\n    }\n"; got != want {
+		t.Fatalf("unexpected diagram output with drop:\nexpected:\n%s\ngot:\n%s", want, got)
+	}
+}
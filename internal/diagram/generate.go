@@ -1,11 +1,18 @@
 package diagram
 
 import (
+	"context"
 	"fmt"
+	"io"
 
 	"github.com/motchang/marid/internal/schema"
 	"github.com/motchang/marid/pkg/formatter"
+	_ "github.com/motchang/marid/pkg/formatter/markdown"
 	_ "github.com/motchang/marid/pkg/formatter/mermaid"
+	_ "github.com/motchang/marid/pkg/formatter/pdf"
+	_ "github.com/motchang/marid/pkg/formatter/png"
+	_ "github.com/motchang/marid/pkg/formatter/svg"
+	_ "github.com/motchang/marid/pkg/formatter/template"
 )
 
 // Generator coordinates rendering using a formatter.
@@ -23,9 +30,10 @@ func New(f formatter.Formatter) *Generator {
 
 // Generate renders a diagram using the formatter associated with the provided format name.
 //
-// If format is empty, formatter.DefaultFormat is used.
+// If format is empty, formatter.DefaultFormat is used. The schema's
+// Config.FormatOptions configure the formatter.
 func Generate(dbSchema *schema.DatabaseSchema, format string) (string, error) {
-	fmttr, err := formatter.Get(format)
+	fmttr, err := formatter.Get(format, formatOptions(dbSchema))
 	if err != nil {
 		return "", err
 	}
@@ -34,16 +42,66 @@ func Generate(dbSchema *schema.DatabaseSchema, format string) (string, error) {
 	return generator.Generate(dbSchema)
 }
 
+// GenerateTo writes a diagram to w as it renders, using the formatter
+// associated with the provided format name and configured by the schema's
+// Config.FormatOptions. It stops with ctx's error once ctx is done.
+func GenerateTo(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema, format string) error {
+	fmttr, err := formatter.Get(format, formatOptions(dbSchema))
+	if err != nil {
+		return err
+	}
+
+	generator := New(fmttr)
+	return generator.GenerateTo(ctx, w, dbSchema)
+}
+
 // Generate renders a diagram using the configured formatter.
 func (g *Generator) Generate(dbSchema *schema.DatabaseSchema) (string, error) {
-	if dbSchema == nil || len(dbSchema.Tables) == 0 {
-		return "", fmt.Errorf("no tables found in schema")
+	renderData, err := prepare(dbSchema)
+	if err != nil {
+		return "", err
 	}
 
-	renderData := toRenderData(dbSchema)
 	return g.formatter.Render(renderData)
 }
 
+// GenerateTo writes a diagram to w using the configured formatter.
+func (g *Generator) GenerateTo(ctx context.Context, w io.Writer, dbSchema *schema.DatabaseSchema) error {
+	renderData, err := prepare(dbSchema)
+	if err != nil {
+		return err
+	}
+
+	return formatter.RenderTo(ctx, g.formatter, w, renderData, nil)
+}
+
+// formatOptions returns the schema's formatter options, if there is a schema.
+func formatOptions(dbSchema *schema.DatabaseSchema) formatter.Options {
+	if dbSchema == nil {
+		return nil
+	}
+	return dbSchema.Config.FormatOptions
+}
+
+// prepare converts the schema to render data with its external references
+// resolved and its tables ordered.
+func prepare(dbSchema *schema.DatabaseSchema) (formatter.RenderData, error) {
+	if dbSchema == nil || len(dbSchema.Tables) == 0 {
+		return formatter.RenderData{}, fmt.Errorf("no tables found in schema")
+	}
+
+	renderData, err := resolveExternalReferences(toRenderData(dbSchema), dbSchema.Config.ExternalRefs)
+	if err != nil {
+		return formatter.RenderData{}, err
+	}
+
+	renderData.Tables, err = orderTables(renderData.Tables, dbSchema.Config.Order)
+	if err != nil {
+		return formatter.RenderData{}, err
+	}
+	return renderData, nil
+}
+
 func toRenderData(dbSchema *schema.DatabaseSchema) formatter.RenderData {
 	tables := make([]formatter.Table, len(dbSchema.Tables))
 
@@ -57,6 +115,17 @@ func toRenderData(dbSchema *schema.DatabaseSchema) formatter.RenderData {
 				IsPrimary:  col.IsPrimary,
 				IsUnique:   col.IsUnique,
 				Comment:    col.Comment,
+
+				ColumnType:         col.ColumnType,
+				CharacterMaxLength: col.CharacterMaxLength,
+				NumericPrecision:   col.NumericPrecision,
+				NumericScale:       col.NumericScale,
+				Unsigned:           col.Unsigned,
+				EnumValues:         append([]string(nil), col.EnumValues...),
+				Default:            col.Default,
+				Extra:              col.Extra,
+				CharacterSet:       col.CharacterSet,
+				Collation:          col.Collation,
 			}
 		}
 
@@ -67,15 +136,32 @@ func toRenderData(dbSchema *schema.DatabaseSchema) formatter.RenderData {
 				ReferencedTable:  fk.ReferencedTable,
 				ReferencedColumn: fk.ReferencedColumn,
 				RelationName:     fk.RelationName,
+				Inferred:         fk.Inferred,
+				Virtual:          fk.Virtual,
+				Cardinality:      fk.Cardinality,
 			}
 		}
 
+		var indexes []formatter.Index
+		for _, index := range tbl.Indexes {
+			indexes = append(indexes, formatter.Index{
+				Name:    index.Name,
+				Columns: append([]string(nil), index.Columns...),
+				Unique:  index.Unique,
+				Type:    index.Type,
+			})
+		}
+
 		tables[i] = formatter.Table{
 			Name:        tbl.Name,
 			Comment:     tbl.Comment,
 			Columns:     columns,
 			PrimaryKey:  append([]string(nil), tbl.PrimaryKey...),
 			ForeignKeys: foreignKeys,
+			Indexes:     indexes,
+			Focus:       tbl.Focus,
+			Alias:       tbl.Alias,
+			Group:       tbl.Group,
 		}
 	}
 
@@ -1,9 +1,14 @@
 package diagram
 
 import (
+	"context"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/motchang/marid/internal/config"
 	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
 )
 
 func TestGenerate(t *testing.T) {
@@ -68,8 +73,139 @@ func TestGenerateReturnsErrorForUnknownFormat(t *testing.T) {
 		t.Fatal("expected error when format is unknown")
 	}
 
-	const want = "unknown format \"unknown\". Available formats: mermaid"
+	const want = "unknown format \"unknown\". Available formats: markdown, mermaid, pdf, png, svg, template"
 	if err.Error() != want {
 		t.Fatalf("unexpected error message: %q", err.Error())
 	}
 }
+
+func TestToRenderDataCarriesFocus(t *testing.T) {
+	data := toRenderData(&schema.DatabaseSchema{
+		Tables: []schema.Table{{Name: "users"}, {Name: "orders", Focus: true}},
+	})
+
+	if data.Tables[0].Focus || !data.Tables[1].Focus {
+		t.Errorf("focus flags not carried over: %+v", data.Tables)
+	}
+}
+
+func TestToRenderDataCarriesInferredForeignKeys(t *testing.T) {
+	data := toRenderData(&schema.DatabaseSchema{
+		Tables: []schema.Table{{
+			Name:        "orders",
+			ForeignKeys: []schema.ForeignKey{{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", Inferred: true}},
+		}},
+	})
+
+	if !data.Tables[0].ForeignKeys[0].Inferred {
+		t.Errorf("inferred flag not carried over: %+v", data.Tables[0].ForeignKeys)
+	}
+}
+
+func TestToRenderDataCarriesIndexes(t *testing.T) {
+	data := toRenderData(&schema.DatabaseSchema{
+		Tables: []schema.Table{{
+			Name:    "users",
+			Indexes: []schema.Index{{Name: "idx_name", Columns: []string{"last_name", "first_name"}, Unique: true, Type: "BTREE"}},
+		}},
+	})
+
+	want := []formatter.Index{{Name: "idx_name", Columns: []string{"last_name", "first_name"}, Unique: true, Type: "BTREE"}}
+	if !reflect.DeepEqual(data.Tables[0].Indexes, want) {
+		t.Errorf("indexes = %+v, want %+v", data.Tables[0].Indexes, want)
+	}
+}
+
+func TestToRenderDataCarriesColumnDetails(t *testing.T) {
+	length := int64(255)
+	data := toRenderData(&schema.DatabaseSchema{
+		Tables: []schema.Table{{
+			Name: "users",
+			Columns: []schema.Column{{
+				Name:               "email",
+				DataType:           "varchar",
+				ColumnType:         "varchar(255)",
+				CharacterMaxLength: &length,
+				Collation:          "utf8mb4_bin",
+			}},
+		}},
+	})
+
+	column := data.Tables[0].Columns[0]
+	if column.ColumnType != "varchar(255)" || column.CharacterMaxLength == nil || *column.CharacterMaxLength != 255 || column.Collation != "utf8mb4_bin" {
+		t.Errorf("column details not carried over: %+v", column)
+	}
+}
+
+func TestGenerateAppliesFormatOptions(t *testing.T) {
+	dbSchema := &schema.DatabaseSchema{
+		Tables: []schema.Table{{
+			Name:    "users",
+			Columns: []schema.Column{{Name: "email", DataType: "varchar", ColumnType: "varchar(255)"}},
+		}},
+		Config: config.Config{FormatOptions: map[string]string{"type-display": "full"}},
+	}
+
+	got, err := Generate(dbSchema, "")
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+	if !strings.Contains(got, "        email varchar(255)\n") {
+		t.Errorf("expected the full type in output:\n%s", got)
+	}
+
+	dbSchema.Config.FormatOptions = map[string]string{"type-display": "huge"}
+	if _, err := Generate(dbSchema, ""); err == nil {
+		t.Error("expected an error for an invalid option")
+	}
+}
+
+func TestGenerateToMatchesGenerate(t *testing.T) {
+	dbSchema := &schema.DatabaseSchema{
+		Tables: []schema.Table{
+			{Name: "teams", Columns: []schema.Column{{Name: "id", DataType: "int", IsPrimary: true}}, PrimaryKey: []string{"id"}},
+			{
+				Name:        "users",
+				Columns:     []schema.Column{{Name: "team_id", DataType: "int", ColumnType: "int unsigned"}},
+				ForeignKeys: []schema.ForeignKey{{ColumnName: "team_id", ReferencedTable: "teams", ReferencedColumn: "id"}},
+			},
+		},
+		Config: config.Config{FormatOptions: map[string]string{"type-display": "full", "title": "Teams"}},
+	}
+
+	for _, format := range []string{"mermaid", "svg"} {
+		want, err := Generate(dbSchema, format)
+		if err != nil {
+			t.Fatalf("Generate(%s) returned error: %v", format, err)
+		}
+
+		var got strings.Builder
+		if err := GenerateTo(context.Background(), &got, dbSchema, format); err != nil {
+			t.Fatalf("GenerateTo(%s) returned error: %v", format, err)
+		}
+		if got.String() != want {
+			t.Errorf("GenerateTo(%s) wrote\n%s\nwant\n%s", format, got.String(), want)
+		}
+	}
+}
+
+func TestGenerateToReportsErrors(t *testing.T) {
+	var b strings.Builder
+	if err := GenerateTo(context.Background(), &b, &schema.DatabaseSchema{}, ""); err == nil {
+		t.Error("expected an error when the schema has no tables")
+	}
+
+	dbSchema := &schema.DatabaseSchema{
+		Tables: []schema.Table{{Name: "users"}},
+		Config: config.Config{FormatOptions: map[string]string{"type-display": "huge"}},
+	}
+	if err := GenerateTo(context.Background(), &b, dbSchema, "svg"); err == nil {
+		t.Error("expected an error for an invalid option")
+	}
+	if err := GenerateTo(context.Background(), &b, dbSchema, "unknown"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if b.Len() != 0 {
+		t.Errorf("GenerateTo wrote %q despite failing", b.String())
+	}
+}
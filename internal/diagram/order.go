@@ -0,0 +1,35 @@
+package diagram
+
+import (
+	"fmt"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/layout"
+)
+
+// orderTables reorders tables as order asks. The result depends only on the
+// tables and their order on input, so identical schemas always render alike.
+func orderTables(tables []formatter.Table, order string) ([]formatter.Table, error) {
+	switch order {
+	case "", config.OrderAlpha:
+		return tables, nil
+	case config.OrderTopo, config.OrderOptimized:
+	default:
+		return nil, fmt.Errorf("unknown table order %q", order)
+	}
+
+	graph := layout.NewGraph(tables)
+	layers := graph.Layers()
+	if order == config.OrderOptimized {
+		layers = graph.ReduceCrossings(layers)
+	}
+
+	ordered := make([]formatter.Table, 0, len(tables))
+	for _, layer := range layers {
+		for _, i := range layer {
+			ordered = append(ordered, tables[i])
+		}
+	}
+	return ordered, nil
+}
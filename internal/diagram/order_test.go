@@ -0,0 +1,145 @@
+package diagram
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func tableNames(tables []formatter.Table) []string {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.Name
+	}
+	return names
+}
+
+// crossingTables references the first two tables in the opposite order of
+// the last two, so a layered drawing in input order crosses once.
+func crossingTables() []formatter.Table {
+	return []formatter.Table{
+		{Name: "accounts"},
+		{Name: "brands"},
+		{Name: "products", ForeignKeys: []formatter.ForeignKey{fk("brand_id", "brands")}},
+		{Name: "sessions", ForeignKeys: []formatter.ForeignKey{fk("account_id", "accounts")}},
+	}
+}
+
+func TestOrderTablesAlphaKeepsInputOrder(t *testing.T) {
+	for _, order := range []string{"", config.OrderAlpha} {
+		got, err := orderTables(crossingTables(), order)
+		if err != nil {
+			t.Fatalf("orderTables(%q) returned error: %v", order, err)
+		}
+		if want := []string{"accounts", "brands", "products", "sessions"}; !reflect.DeepEqual(tableNames(got), want) {
+			t.Errorf("orderTables(%q) = %v, want %v", order, tableNames(got), want)
+		}
+	}
+}
+
+func TestOrderTablesTopoPlacesReferencedTablesFirst(t *testing.T) {
+	tables := []formatter.Table{
+		{Name: "order_items", ForeignKeys: []formatter.ForeignKey{fk("order_id", "orders"), fk("product_id", "products")}},
+		{Name: "orders", ForeignKeys: []formatter.ForeignKey{fk("user_id", "users")}},
+		{Name: "products"},
+		{Name: "categories", ForeignKeys: []formatter.ForeignKey{fk("parent_id", "categories")}},
+		{Name: "users", ForeignKeys: []formatter.ForeignKey{fk("referrer_id", "users"), fk("team_id", "external_teams")}},
+	}
+
+	got, err := orderTables(tables, config.OrderTopo)
+	if err != nil {
+		t.Fatalf("orderTables returned error: %v", err)
+	}
+
+	want := []string{"products", "categories", "users", "orders", "order_items"}
+	if !reflect.DeepEqual(tableNames(got), want) {
+		t.Errorf("orderTables() = %v, want %v", tableNames(got), want)
+	}
+}
+
+func TestOrderTablesTopoBreaksCycles(t *testing.T) {
+	tables := []formatter.Table{
+		{Name: "departments", ForeignKeys: []formatter.ForeignKey{fk("manager_id", "employees")}},
+		{Name: "employees", ForeignKeys: []formatter.ForeignKey{fk("department_id", "departments"), fk("site_id", "sites")}},
+		{Name: "sites"},
+	}
+
+	got, err := orderTables(tables, config.OrderTopo)
+	if err != nil {
+		t.Fatalf("orderTables returned error: %v", err)
+	}
+
+	want := []string{"departments", "sites", "employees"}
+	if !reflect.DeepEqual(tableNames(got), want) {
+		t.Errorf("orderTables() = %v, want %v", tableNames(got), want)
+	}
+}
+
+func TestOrderTablesOptimizedRemovesCrossings(t *testing.T) {
+	topo, err := orderTables(crossingTables(), config.OrderTopo)
+	if err != nil {
+		t.Fatalf("orderTables returned error: %v", err)
+	}
+	if want := []string{"accounts", "brands", "products", "sessions"}; !reflect.DeepEqual(tableNames(topo), want) {
+		t.Errorf("topo order = %v, want %v", tableNames(topo), want)
+	}
+
+	optimized, err := orderTables(crossingTables(), config.OrderOptimized)
+	if err != nil {
+		t.Fatalf("orderTables returned error: %v", err)
+	}
+	if want := []string{"accounts", "brands", "sessions", "products"}; !reflect.DeepEqual(tableNames(optimized), want) {
+		t.Errorf("optimized order = %v, want %v", tableNames(optimized), want)
+	}
+}
+
+func TestOrderTablesOptimizedIsDeterministic(t *testing.T) {
+	tables := []formatter.Table{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	for i, name := range []string{"d", "e", "f", "g", "h", "i"} {
+		tables = append(tables, formatter.Table{
+			Name:        name,
+			ForeignKeys: []formatter.ForeignKey{fk("x_id", tables[(i*2)%3].Name), fk("y_id", tables[(i+2)%3].Name)},
+		})
+	}
+
+	first, err := orderTables(tables, config.OrderOptimized)
+	if err != nil {
+		t.Fatalf("orderTables returned error: %v", err)
+	}
+	for run := 0; run < 20; run++ {
+		got, _ := orderTables(tables, config.OrderOptimized)
+		if !reflect.DeepEqual(tableNames(got), tableNames(first)) {
+			t.Fatalf("run %d: order = %v, want %v", run, tableNames(got), tableNames(first))
+		}
+	}
+}
+
+func TestOrderTablesRejectsUnknownOrder(t *testing.T) {
+	if _, err := orderTables(nil, "random"); err == nil {
+		t.Error("expected an error for an unknown order")
+	}
+}
+
+func TestGenerateOrdersTables(t *testing.T) {
+	dbSchema := &schema.DatabaseSchema{
+		Tables: []schema.Table{
+			{Name: "orders", Columns: []schema.Column{{Name: "user_id", DataType: "int"}},
+				ForeignKeys: []schema.ForeignKey{{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_user"}}},
+			{Name: "users", Columns: []schema.Column{{Name: "id", DataType: "int"}}},
+		},
+		Config: config.Config{Order: config.OrderTopo},
+	}
+
+	got, err := Generate(dbSchema, "")
+	if err != nil {
+		t.Fatalf("Generate returned error: %v", err)
+	}
+
+	if users, orders := strings.Index(got, "    users {"), strings.Index(got, "    orders {"); users < 0 || orders < users {
+		t.Errorf("expected users to be declared before orders:\n%s", got)
+	}
+}
@@ -0,0 +1,23 @@
+package diagram
+
+import (
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/internal/site"
+)
+
+// GenerateSite writes a static HTML documentation site of the schema to dir:
+// an index with search, an overview diagram and a page per table. The tables
+// follow Config.ExternalRefs and Config.Order, as in a single diagram, and
+// Config.FormatOptions configure the diagrams unless opts sets its own. It
+// returns the paths written, index first.
+func GenerateSite(dbSchema *schema.DatabaseSchema, dir string, opts site.Options) ([]string, error) {
+	data, err := prepare(dbSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.FormatOptions == nil {
+		opts.FormatOptions = formatOptions(dbSchema)
+	}
+	return site.Build(dir, data, opts)
+}
@@ -0,0 +1,48 @@
+package diagram
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/internal/site"
+)
+
+func TestGenerateSiteGivesStubsNoPage(t *testing.T) {
+	dir := t.TempDir()
+	dbSchema := &schema.DatabaseSchema{
+		Tables: []schema.Table{
+			{Name: "orders", Columns: []schema.Column{{Name: "id", DataType: "int"}, {Name: "user_id", DataType: "int"}},
+				ForeignKeys: []schema.ForeignKey{{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id"}}},
+		},
+		Config: config.Config{ExternalRefs: config.ExternalStub},
+	}
+
+	paths, err := GenerateSite(dbSchema, dir, site.Options{})
+	if err != nil {
+		t.Fatalf("GenerateSite returned error: %v", err)
+	}
+	if paths[0] != filepath.Join(dir, "index.html") {
+		t.Errorf("paths start with %s, want the index", paths[0])
+	}
+	if _, err := os.Stat(filepath.Join(dir, "tables", "users.html")); !os.IsNotExist(err) {
+		t.Errorf("stub table got a page: %v", err)
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "tables", "orders.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<code>user_id</code> → <code>users</code>.<code>id</code>"; !strings.Contains(string(page), want) {
+		t.Errorf("orders page lacks %q:\n%s", want, page)
+	}
+}
+
+func TestGenerateSiteReturnsErrorWhenNoTables(t *testing.T) {
+	if _, err := GenerateSite(&schema.DatabaseSchema{}, t.TempDir(), site.Options{}); err == nil {
+		t.Error("expected an error for an empty schema")
+	}
+}
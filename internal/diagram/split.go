@@ -0,0 +1,359 @@
+package diagram
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/motchang/marid/internal/atomicfile"
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/utils"
+)
+
+// otherCluster collects the tables no strategy places with any other table.
+const otherCluster = "other"
+
+// overviewFile is the base name of the overview diagram of a split.
+const overviewFile = "overview"
+
+// maxMoveRounds bounds community detection on graphs whose tables keep
+// moving between equally good communities.
+const maxMoveRounds = 100
+
+// cluster is a set of tables rendered as one diagram of a split.
+type cluster struct {
+	name   string
+	file   string
+	tables []formatter.Table
+}
+
+// GenerateSplit partitions the schema's tables into clusters by
+// Config.Split, renders one diagram per cluster and an overview diagram of
+// the clusters and the foreign keys between them, and writes them to
+// Config.OutputDir. Foreign keys leaving a cluster follow
+// Config.ExternalRefs, so each diagram shows where its tables point, and every
+// diagram's tables follow Config.Order. It
+// returns the paths written, overview first.
+func GenerateSplit(dbSchema *schema.DatabaseSchema, format string) ([]string, error) {
+	if dbSchema == nil || len(dbSchema.Tables) == 0 {
+		return nil, fmt.Errorf("no tables found in schema")
+	}
+
+	fmttr, err := formatter.Get(format, dbSchema.Config.FormatOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	clusters, err := partition(toRenderData(dbSchema).Tables, dbSchema.Config.Split)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := formatter.Describe(format)
+	if err != nil {
+		return nil, err
+	}
+	assignFiles(clusters, info.Extension)
+	text := !info.Binary
+
+	dir := dbSchema.Config.OutputDir
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating output directory: %w", err)
+	}
+
+	overviewData := overview(clusters)
+	overviewData.Tables, err = orderTables(overviewData.Tables, dbSchema.Config.Order)
+	if err != nil {
+		return nil, err
+	}
+
+	overviewPath := filepath.Join(dir, overviewFile+info.Extension)
+	output, err := fmttr.Render(overviewData)
+	if err != nil {
+		return nil, fmt.Errorf("rendering overview: %w", err)
+	}
+	if err := writeDiagram(overviewPath, output, text); err != nil {
+		return nil, err
+	}
+	paths := []string{overviewPath}
+
+	for _, c := range clusters {
+		data, err := resolveExternalReferences(formatter.RenderData{Tables: c.tables}, dbSchema.Config.ExternalRefs)
+		if err != nil {
+			return paths, err
+		}
+		data.Tables, err = orderTables(data.Tables, dbSchema.Config.Order)
+		if err != nil {
+			return paths, err
+		}
+
+		output, err := fmttr.Render(data)
+		if err != nil {
+			return paths, fmt.Errorf("rendering cluster %s: %w", c.name, err)
+		}
+
+		path := filepath.Join(dir, c.file)
+		if err := writeDiagram(path, output, text); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// writeDiagram replaces the diagram at path, ending text with a newline.
+func writeDiagram(path, output string, text bool) error {
+	if text && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	return atomicfile.Write(path, 0o644, "diagram", func(w io.Writer) error {
+		_, err := io.WriteString(w, output)
+		return err
+	})
+}
+
+// partition assigns every table to a cluster by strategy. Clusters are
+// sorted by name with otherCluster last, and keep the tables' order.
+func partition(tables []formatter.Table, strategy string) ([]cluster, error) {
+	var keys []string
+	switch strategy {
+	case config.SplitPrefix:
+		keys = prefixKeys(tables)
+	case config.SplitGroup:
+		keys = groupKeys(tables)
+	case config.SplitCommunity:
+		keys = communityKeys(tables)
+	default:
+		return nil, fmt.Errorf("unknown split strategy %q", strategy)
+	}
+
+	byName := make(map[string]*cluster)
+	var names []string
+	for i, table := range tables {
+		c, ok := byName[keys[i]]
+		if !ok {
+			c = &cluster{name: keys[i]}
+			byName[keys[i]] = c
+			names = append(names, keys[i])
+		}
+		c.tables = append(c.tables, table)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == otherCluster) != (names[j] == otherCluster) {
+			return names[j] == otherCluster
+		}
+		return names[i] < names[j]
+	})
+
+	clusters := make([]cluster, len(names))
+	for i, name := range names {
+		clusters[i] = *byName[name]
+	}
+	return clusters, nil
+}
+
+// prefixKeys clusters tables by the part of their name before the first
+// underscore, or the whole name when there is none. Prefixes only one table
+// has go to otherCluster.
+func prefixKeys(tables []formatter.Table) []string {
+	keys := make([]string, len(tables))
+	for i, table := range tables {
+		keys[i], _, _ = strings.Cut(table.Name, "_")
+	}
+	return mergeSingletons(keys)
+}
+
+// groupKeys clusters tables by their overlay group. Tables without one go to
+// otherCluster.
+func groupKeys(tables []formatter.Table) []string {
+	keys := make([]string, len(tables))
+	for i, table := range tables {
+		keys[i] = table.Group
+		if keys[i] == "" {
+			keys[i] = otherCluster
+		}
+	}
+	return keys
+}
+
+// communityKeys clusters tables into communities of the foreign-key graph,
+// taken as undirected, by greedy modularity optimisation: starting with every
+// table alone, each table in turn moves to the neighbouring community that
+// most increases modularity, until no move helps. Ties keep a table where it
+// is, or otherwise favour the earliest community. Each community is named
+// after its most connected table, and tables left on their own go to
+// otherCluster.
+func communityKeys(tables []formatter.Table) []string {
+	index := make(map[string]int, len(tables))
+	for i, table := range tables {
+		index[table.Name] = i
+	}
+
+	neighbours := make([][]int, len(tables))
+	edges := 0
+	for i, table := range tables {
+		for _, fk := range table.ForeignKeys {
+			j, ok := index[fk.ReferencedTable]
+			if !ok || j == i {
+				continue
+			}
+			neighbours[i] = append(neighbours[i], j)
+			neighbours[j] = append(neighbours[j], i)
+			edges++
+		}
+	}
+
+	community := make([]int, len(tables))
+	// total is the sum of the degrees of each community's tables.
+	total := make([]int, len(tables))
+	for i := range community {
+		community[i] = i
+		total[i] = len(neighbours[i])
+	}
+
+	for round := 0; round < maxMoveRounds && edges > 0; round++ {
+		moved := false
+		for i, adjacent := range neighbours {
+			if len(adjacent) == 0 {
+				continue
+			}
+
+			current, degree := community[i], len(adjacent)
+			total[current] -= degree
+
+			links := make(map[int]int)
+			for _, j := range adjacent {
+				links[community[j]]++
+			}
+
+			// gain is proportional to the modularity gained by joining c.
+			gain := func(c int) float64 {
+				return float64(links[c]) - float64(degree*total[c])/float64(2*edges)
+			}
+
+			best, bestGain := current, gain(current)
+			for c := range links {
+				g := gain(c)
+				if g > bestGain || g == bestGain && best != current && c < best {
+					best, bestGain = c, g
+				}
+			}
+
+			community[i] = best
+			total[best] += degree
+			if best != current {
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+
+	hub := make(map[int]int)
+	for i, c := range community {
+		h, ok := hub[c]
+		if !ok || len(neighbours[i]) > len(neighbours[h]) {
+			hub[c] = i
+		}
+	}
+
+	keys := make([]string, len(tables))
+	for i, c := range community {
+		keys[i] = tables[hub[c]].Name
+	}
+	return mergeSingletons(keys)
+}
+
+// mergeSingletons moves keys that only one table has to otherCluster.
+func mergeSingletons(keys []string) []string {
+	counts := make(map[string]int)
+	for _, key := range keys {
+		counts[key]++
+	}
+	for i, key := range keys {
+		if counts[key] == 1 {
+			keys[i] = otherCluster
+		}
+	}
+	return keys
+}
+
+// assignFiles names each cluster's file after the cluster, sanitized and made
+// unique, and never clashing with the overview.
+func assignFiles(clusters []cluster, ext string) {
+	taken := map[string]bool{overviewFile: true}
+	for i := range clusters {
+		base := strings.ToLower(utils.SanitizeIdentifier(clusters[i].name))
+		if base == "" {
+			base = "_"
+		}
+
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		taken[name] = true
+		clusters[i].file = name + ext
+	}
+}
+
+// overview renders each cluster as an entity labelled with its size and file.
+// Relationships link clusters and count the foreign keys between them.
+func overview(clusters []cluster) formatter.RenderData {
+	clusterOf := make(map[string]string)
+	for _, c := range clusters {
+		for _, table := range c.tables {
+			clusterOf[table.Name] = c.name
+		}
+	}
+
+	tables := make([]formatter.Table, len(clusters))
+	for i, c := range clusters {
+		counts := make(map[string]int)
+		var referenced []string
+		for _, table := range c.tables {
+			for _, fk := range table.ForeignKeys {
+				target, ok := clusterOf[fk.ReferencedTable]
+				if !ok || target == c.name {
+					continue
+				}
+				if counts[target] == 0 {
+					referenced = append(referenced, target)
+				}
+				counts[target]++
+			}
+		}
+		sort.Strings(referenced)
+
+		var foreignKeys []formatter.ForeignKey
+		for _, target := range referenced {
+			foreignKeys = append(foreignKeys, formatter.ForeignKey{
+				ReferencedTable: target,
+				RelationName:    countLabel(counts[target], "foreign key", "foreign keys"),
+			})
+		}
+
+		tables[i] = formatter.Table{
+			Name:        c.name,
+			Alias:       fmt.Sprintf("%s (%s): %s", c.name, countLabel(len(c.tables), "table", "tables"), c.file),
+			ForeignKeys: foreignKeys,
+		}
+	}
+
+	return formatter.RenderData{Tables: tables}
+}
+
+func countLabel(count int, singular, plural string) string {
+	if count == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", count, plural)
+}
@@ -0,0 +1,193 @@
+package diagram
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func fk(column, table string) formatter.ForeignKey {
+	return formatter.ForeignKey{ColumnName: column, ReferencedTable: table, ReferencedColumn: "id", RelationName: column}
+}
+
+func clusterTables(clusters []cluster) map[string][]string {
+	got := make(map[string][]string)
+	for _, c := range clusters {
+		for _, table := range c.tables {
+			got[c.name] = append(got[c.name], table.Name)
+		}
+	}
+	return got
+}
+
+func TestPartitionByPrefix(t *testing.T) {
+	tables := []formatter.Table{
+		{Name: "billing_invoices"}, {Name: "users"}, {Name: "billing_payments"},
+		{Name: "auth_sessions"}, {Name: "auth_tokens"},
+	}
+
+	clusters, err := partition(tables, config.SplitPrefix)
+	if err != nil {
+		t.Fatalf("partition returned error: %v", err)
+	}
+
+	var names []string
+	for _, c := range clusters {
+		names = append(names, c.name)
+	}
+	if want := []string{"auth", "billing", otherCluster}; !reflect.DeepEqual(names, want) {
+		t.Errorf("cluster order = %v, want %v", names, want)
+	}
+
+	want := map[string][]string{
+		"auth":       {"auth_sessions", "auth_tokens"},
+		"billing":    {"billing_invoices", "billing_payments"},
+		otherCluster: {"users"},
+	}
+	if got := clusterTables(clusters); !reflect.DeepEqual(got, want) {
+		t.Errorf("partition() = %v, want %v", got, want)
+	}
+}
+
+func TestPartitionByGroup(t *testing.T) {
+	tables := []formatter.Table{{Name: "users", Group: "Accounts"}, {Name: "logs"}, {Name: "orders", Group: "Sales"}}
+
+	clusters, err := partition(tables, config.SplitGroup)
+	if err != nil {
+		t.Fatalf("partition returned error: %v", err)
+	}
+
+	want := map[string][]string{"Accounts": {"users"}, "Sales": {"orders"}, otherCluster: {"logs"}}
+	if got := clusterTables(clusters); !reflect.DeepEqual(got, want) {
+		t.Errorf("partition() = %v, want %v", got, want)
+	}
+}
+
+func TestPartitionByCommunity(t *testing.T) {
+	// Two triangles joined by a single edge, and an isolated table.
+	tables := []formatter.Table{
+		{Name: "customers"},
+		{Name: "orders", ForeignKeys: []formatter.ForeignKey{fk("customer_id", "customers")}},
+		{Name: "order_items", ForeignKeys: []formatter.ForeignKey{fk("order_id", "orders"), fk("customer_id", "customers"), fk("product_id", "products")}},
+		{Name: "products", ForeignKeys: []formatter.ForeignKey{fk("category_id", "categories")}},
+		{Name: "categories"},
+		{Name: "prices", ForeignKeys: []formatter.ForeignKey{fk("product_id", "products"), fk("category_id", "categories")}},
+		{Name: "settings"},
+	}
+
+	clusters, err := partition(tables, config.SplitCommunity)
+	if err != nil {
+		t.Fatalf("partition returned error: %v", err)
+	}
+
+	want := map[string][]string{
+		"order_items": {"customers", "orders", "order_items"},
+		"products":    {"products", "categories", "prices"},
+		otherCluster:  {"settings"},
+	}
+	if got := clusterTables(clusters); !reflect.DeepEqual(got, want) {
+		t.Errorf("partition() = %v, want %v", got, want)
+	}
+}
+
+func TestPartitionRejectsUnknownStrategy(t *testing.T) {
+	if _, err := partition(nil, "size"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
+func TestAssignFilesAvoidsClashes(t *testing.T) {
+	clusters := []cluster{{name: "Overview"}, {name: "shop.orders"}, {name: "shop_orders"}, {name: "注文"}}
+	assignFiles(clusters, ".mmd")
+
+	var got []string
+	for _, c := range clusters {
+		got = append(got, c.file)
+	}
+	want := []string{"overview_2.mmd", "shop_orders.mmd", "shop_orders_2.mmd", "__.mmd"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("files = %v, want %v", got, want)
+	}
+}
+
+func TestOverviewCountsForeignKeysBetweenClusters(t *testing.T) {
+	clusters := []cluster{
+		{name: "billing", file: "billing.mmd", tables: []formatter.Table{
+			{Name: "billing_invoices", ForeignKeys: []formatter.ForeignKey{fk("user_id", "users"), fk("order_id", "shop_orders")}},
+			{Name: "billing_payments", ForeignKeys: []formatter.ForeignKey{fk("invoice_id", "billing_invoices"), fk("user_id", "users"), fk("x_id", "elsewhere")}},
+		}},
+		{name: "other", file: "other.mmd", tables: []formatter.Table{{Name: "users"}, {Name: "shop_orders"}}},
+	}
+
+	data := overview(clusters)
+
+	want := []formatter.Table{
+		{
+			Name:  "billing",
+			Alias: "billing (2 tables): billing.mmd",
+			ForeignKeys: []formatter.ForeignKey{
+				{ReferencedTable: "other", RelationName: "3 foreign keys"},
+			},
+		},
+		{Name: "other", Alias: "other (2 tables): other.mmd"},
+	}
+	if !reflect.DeepEqual(data.Tables, want) {
+		t.Errorf("overview() = %+v, want %+v", data.Tables, want)
+	}
+}
+
+func TestGenerateSplitWritesClusterAndOverviewDiagrams(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "diagrams")
+	dbSchema := &schema.DatabaseSchema{
+		Tables: []schema.Table{
+			{Name: "users", Columns: []schema.Column{{Name: "id", DataType: "int"}}},
+			{Name: "billing_invoices", Columns: []schema.Column{{Name: "id", DataType: "int"}, {Name: "user_id", DataType: "int"}},
+				ForeignKeys: []schema.ForeignKey{{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_user"}}},
+			{Name: "billing_payments", Columns: []schema.Column{{Name: "id", DataType: "int"}}},
+		},
+		Config: config.Config{Split: config.SplitPrefix, OutputDir: dir},
+	}
+
+	paths, err := GenerateSplit(dbSchema, "")
+	if err != nil {
+		t.Fatalf("GenerateSplit returned error: %v", err)
+	}
+
+	want := []string{filepath.Join(dir, "overview.mmd"), filepath.Join(dir, "billing.mmd"), filepath.Join(dir, "other.mmd")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("paths = %v, want %v", paths, want)
+	}
+
+	overviewDiagram, err := os.ReadFile(paths[0])
+	if err != nil {
+		t.Fatalf("reading overview: %v", err)
+	}
+	for _, line := range []string{
+		`    billing["billing (2 tables): billing.mmd"] {`,
+		`    other ||--o{ billing : "1 foreign key"`,
+	} {
+		if !strings.Contains(string(overviewDiagram), line+"\n") {
+			t.Errorf("overview is missing %q:\n%s", line, overviewDiagram)
+		}
+	}
+
+	billing, err := os.ReadFile(paths[1])
+	if err != nil {
+		t.Fatalf("reading cluster diagram: %v", err)
+	}
+	if !strings.Contains(string(billing), `    users["users (external)"] {`) {
+		t.Errorf("expected the referenced table as an external stub:\n%s", billing)
+	}
+}
+
+func TestGenerateSplitReturnsErrorWhenNoTables(t *testing.T) {
+	if _, err := GenerateSplit(&schema.DatabaseSchema{}, ""); err == nil {
+		t.Error("expected an error for an empty schema")
+	}
+}
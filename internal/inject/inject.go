@@ -0,0 +1,223 @@
+// Package inject finds the blocks of a text file that marid keeps up to date
+// and replaces their content. A block lies between a start marker naming it
+// and carrying its settings, and an end marker, each an HTML comment on a
+// line of its own:
+//
+//	<!-- marid:start name=billing include=billing_* direction=LR -->
+//	...
+//	<!-- marid:end -->
+//
+// Values containing spaces are double-quoted, as in title="Billing tables".
+// Markers inside fenced code blocks are left alone, so documents can show
+// them.
+package inject
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// NameSetting is the start marker setting naming a block.
+const NameSetting = "name"
+
+var (
+	startMarker = regexp.MustCompile(`^\s*<!--\s*marid:start\b(.*?)-->\s*$`)
+	endMarker   = regexp.MustCompile(`^\s*<!--\s*marid:end\s*-->\s*$`)
+)
+
+// Block is a block of a document.
+type Block struct {
+	// Name identifies the block in messages.
+	Name string
+	// Line is the line number of the start marker.
+	Line int
+	// Settings are the settings of the start marker but the name.
+	Settings map[string]string
+	// Content is the text between the markers.
+	Content string
+
+	// start and end delimit Content in the document.
+	start, end int
+	// newline is the line ending of the start marker.
+	newline string
+}
+
+// Document is a text file with blocks.
+type Document struct {
+	// Path names the document in errors.
+	Path   string
+	Blocks []Block
+
+	text string
+}
+
+// Parse finds the blocks of text, read from path. Blocks must be named,
+// uniquely, and closed, and must not nest.
+func Parse(path, text string) (*Document, error) {
+	doc := &Document{Path: path, text: text}
+	names := make(map[string]int)
+
+	var (
+		open  *Block
+		fence string
+	)
+	offset := 0
+	for number := 1; offset < len(text); number++ {
+		end := strings.IndexByte(text[offset:], '\n')
+		if end < 0 {
+			end = len(text)
+		} else {
+			end += offset + 1
+		}
+		line := text[offset:end]
+		trimmed := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case open != nil:
+			if endMarker.MatchString(trimmed) {
+				open.end = offset
+				open.Content = text[open.start:open.end]
+				doc.Blocks = append(doc.Blocks, *open)
+				open = nil
+			} else if startMarker.MatchString(trimmed) {
+				return nil, fmt.Errorf("%s:%d: block %q is not closed before the next one starts", path, open.Line, open.Name)
+			}
+		case fence != "":
+			if closesFence(trimmed, fence) {
+				fence = ""
+			}
+		case endMarker.MatchString(trimmed):
+			return nil, fmt.Errorf("%s:%d: marid:end without a marid:start", path, number)
+		default:
+			if match := startMarker.FindStringSubmatch(trimmed); match != nil {
+				block, err := newBlock(match[1], number)
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", path, number, err)
+				}
+				if first, ok := names[block.Name]; ok {
+					return nil, fmt.Errorf("%s:%d: block %q is already defined on line %d", path, number, block.Name, first)
+				}
+				names[block.Name] = number
+
+				block.start = end
+				block.newline = line[len(trimmed):]
+				if block.newline == "" {
+					block.newline = "\n"
+				}
+				open = &block
+			} else {
+				fence = opensFence(trimmed)
+			}
+		}
+		offset = end
+	}
+
+	if open != nil {
+		return nil, fmt.Errorf("%s:%d: block %q has no marid:end", path, open.Line, open.Name)
+	}
+	return doc, nil
+}
+
+// Replace returns the text of the document with the content of every block
+// named in contents replaced, ending the content's lines as the block's start
+// marker ends. Other blocks and all text outside blocks are kept as they are.
+func (d *Document) Replace(contents map[string]string) string {
+	var b strings.Builder
+	last := 0
+	for _, block := range d.Blocks {
+		content, ok := contents[block.Name]
+		if !ok {
+			continue
+		}
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		if block.newline != "\n" {
+			content = strings.ReplaceAll(content, "\n", block.newline)
+		}
+
+		b.WriteString(d.text[last:block.start])
+		b.WriteString(content)
+		last = block.end
+	}
+	b.WriteString(d.text[last:])
+	return b.String()
+}
+
+func newBlock(attributes string, line int) (Block, error) {
+	settings, err := parseSettings(attributes)
+	if err != nil {
+		return Block{}, err
+	}
+
+	name := settings[NameSetting]
+	if name == "" {
+		return Block{}, fmt.Errorf("marid:start needs a name, as in <!-- marid:start name=overview -->")
+	}
+	delete(settings, NameSetting)
+	return Block{Name: name, Line: line, Settings: settings}, nil
+}
+
+// parseSettings parses space-separated KEY=VALUE settings, whose values may
+// be double-quoted Go strings.
+func parseSettings(text string) (map[string]string, error) {
+	settings := make(map[string]string)
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		eq := strings.IndexAny(rest, "= \t")
+		if eq <= 0 || rest[eq] != '=' {
+			field, _, _ := strings.Cut(rest, " ")
+			return nil, fmt.Errorf("invalid marker setting %q: want KEY=VALUE", field)
+		}
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid marker setting %s: unterminated quoted value", key)
+			}
+			value, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+			if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+				return nil, fmt.Errorf("invalid marker setting %s: want a space after the quoted value", key)
+			}
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+
+		if _, ok := settings[key]; ok {
+			return nil, fmt.Errorf("marker setting %s is given twice", key)
+		}
+		settings[key] = value
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return settings, nil
+}
+
+// opensFence returns the fence a line opens a fenced code block with, such as
+// "```", or "" if it opens none.
+func opensFence(line string) string {
+	line = strings.TrimLeft(line, " ")
+	for _, char := range []string{"`", "~"} {
+		fence := line[:len(line)-len(strings.TrimLeft(line, char))]
+		if len(fence) >= 3 {
+			return fence
+		}
+	}
+	return ""
+}
+
+// closesFence reports whether a line closes the fenced code block fence
+// opened.
+func closesFence(line, fence string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == ""
+}
@@ -0,0 +1,118 @@
+package inject
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const sample = "# Database\n" +
+	"\n" +
+	"Hand-written text.\n" +
+	"\n" +
+	"<!-- marid:start name=overview -->\n" +
+	"```mermaid\n" +
+	"erDiagram\n" +
+	"```\n" +
+	"<!-- marid:end -->\n" +
+	"\n" +
+	"## Billing\n" +
+	"\n" +
+	"<!-- marid:start name=billing include=billing_* title=\"Billing tables\" direction=LR -->\n" +
+	"<!-- marid:end -->\n" +
+	"\n" +
+	"Example marker:\n" +
+	"\n" +
+	"```markdown\n" +
+	"<!-- marid:start name=example -->\n" +
+	"<!-- marid:end -->\n" +
+	"```\n"
+
+func TestParseFindsBlocks(t *testing.T) {
+	doc, err := Parse("database.md", sample)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(doc.Blocks) != 2 {
+		t.Fatalf("Parse found %d blocks, want 2: %+v", len(doc.Blocks), doc.Blocks)
+	}
+
+	overview := doc.Blocks[0]
+	if overview.Name != "overview" || overview.Line != 5 || len(overview.Settings) != 0 {
+		t.Errorf("overview = %+v", overview)
+	}
+	if want := "```mermaid\nerDiagram\n```\n"; overview.Content != want {
+		t.Errorf("overview content = %q, want %q", overview.Content, want)
+	}
+
+	billing := doc.Blocks[1]
+	want := map[string]string{"include": "billing_*", "title": "Billing tables", "direction": "LR"}
+	if billing.Name != "billing" || billing.Line != 13 || !reflect.DeepEqual(billing.Settings, want) {
+		t.Errorf("billing = %+v, want settings %v", billing, want)
+	}
+	if billing.Content != "" {
+		t.Errorf("billing content = %q, want empty", billing.Content)
+	}
+}
+
+func TestReplaceKeepsTheRestOfTheDocument(t *testing.T) {
+	doc, err := Parse("database.md", sample)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	got := doc.Replace(map[string]string{"billing": "```mermaid\nerDiagram\n    billing_invoices {\n    }\n```"})
+	want := strings.Replace(sample,
+		"title=\"Billing tables\" direction=LR -->\n<!-- marid:end -->",
+		"title=\"Billing tables\" direction=LR -->\n```mermaid\nerDiagram\n    billing_invoices {\n    }\n```\n<!-- marid:end -->", 1)
+	if got != want {
+		t.Errorf("Replace =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := doc.Replace(nil); got != sample {
+		t.Errorf("Replace(nil) changed the document:\n%s", got)
+	}
+}
+
+func TestReplaceKeepsCRLFLineEndings(t *testing.T) {
+	doc, err := Parse("database.md", "intro\r\n<!-- marid:start name=a -->\r\nold\r\n<!-- marid:end -->\r\n")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if got, want := doc.Replace(map[string]string{"a": "new\nlines\n"}), "intro\r\n<!-- marid:start name=a -->\r\nnew\r\nlines\r\n<!-- marid:end -->\r\n"; got != want {
+		t.Errorf("Replace = %q, want %q", got, want)
+	}
+}
+
+func TestParseRejectsMalformedMarkers(t *testing.T) {
+	tests := map[string]string{
+		"unclosed":  "<!-- marid:start name=a -->\ntext\n",
+		"nested":    "<!-- marid:start name=a -->\n<!-- marid:start name=b -->\n<!-- marid:end -->\n",
+		"stray end": "text\n<!-- marid:end -->\n",
+		"unnamed":   "<!-- marid:start tables=users -->\n<!-- marid:end -->\n",
+		"duplicate": "<!-- marid:start name=a -->\n<!-- marid:end -->\n<!-- marid:start name=a -->\n<!-- marid:end -->\n",
+		"setting":   "<!-- marid:start name=a direction -->\n<!-- marid:end -->\n",
+		"quote":     "<!-- marid:start name=a title=\"Shop -->\n<!-- marid:end -->\n",
+		"twice":     "<!-- marid:start name=a name=b -->\n<!-- marid:end -->\n",
+	}
+
+	wants := map[string]string{
+		"unclosed":  `database.md:1: block "a" has no marid:end`,
+		"nested":    `database.md:1: block "a" is not closed before the next one starts`,
+		"stray end": "database.md:2: marid:end without a marid:start",
+		"unnamed":   "database.md:1: marid:start needs a name",
+		"duplicate": `database.md:3: block "a" is already defined on line 1`,
+		"setting":   `database.md:1: invalid marker setting "direction": want KEY=VALUE`,
+		"quote":     "database.md:1: invalid marker setting title: unterminated quoted value",
+		"twice":     "database.md:1: marker setting name is given twice",
+	}
+
+	for name, text := range tests {
+		_, err := Parse("database.md", text)
+		if err == nil || !strings.Contains(err.Error(), wants[name]) {
+			t.Errorf("%s: Parse returned %v, want %q", name, err, wants[name])
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package overlay merges hand-written annotations into an extracted schema:
+// relationships that only exist in application code, display aliases, comment
+// overrides, hidden columns and table groups.
+package overlay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// Overlay is the content of an overlay file.
+type Overlay struct {
+	// Tables annotates tables by name.
+	Tables map[string]Table `yaml:"tables" json:"tables"`
+	// Relationships declares foreign keys the database does not know about.
+	Relationships []Relationship `yaml:"relationships" json:"relationships"`
+}
+
+// Table annotates one table.
+type Table struct {
+	// Alias is the name diagrams display instead of the table name.
+	Alias string `yaml:"alias" json:"alias"`
+	// Comment replaces the table comment; an empty string clears it.
+	Comment *string `yaml:"comment" json:"comment"`
+	// Group assigns the table to a named group.
+	Group string `yaml:"group" json:"group"`
+	// Columns annotates columns by name.
+	Columns map[string]Column `yaml:"columns" json:"columns"`
+}
+
+// Column annotates one column.
+type Column struct {
+	// Comment replaces the column comment; an empty string clears it.
+	Comment *string `yaml:"comment" json:"comment"`
+	// Hidden leaves the column out of the diagram.
+	Hidden bool `yaml:"hidden" json:"hidden"`
+}
+
+// Relationship declares a foreign key from one column to another, written as
+// table.column.
+type Relationship struct {
+	From string `yaml:"from" json:"from"`
+	To   string `yaml:"to" json:"to"`
+	// Cardinality is one of formatter.Cardinalities; empty means many-to-one.
+	Cardinality string `yaml:"cardinality" json:"cardinality"`
+	// Label names the relationship; it defaults to the referencing column.
+	Label string `yaml:"label" json:"label"`
+}
+
+// Load reads an overlay file. Files ending in .json are decoded as JSON and
+// anything else as YAML; unknown keys are rejected in both.
+func Load(path string) (*Overlay, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read overlay file: %w", err)
+	}
+
+	overlay, err := Parse(content, strings.EqualFold(filepath.Ext(path), ".json"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid overlay file %s: %w", path, err)
+	}
+
+	return overlay, nil
+}
+
+// Parse decodes and validates overlay content.
+func Parse(content []byte, isJSON bool) (*Overlay, error) {
+	overlay := &Overlay{}
+
+	if isJSON {
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(overlay); err != nil {
+			return nil, err
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		// An empty file decodes to io.EOF, which is an empty overlay.
+		if err := decoder.Decode(overlay); err != nil && len(bytes.TrimSpace(content)) > 0 {
+			return nil, err
+		}
+	}
+
+	if err := overlay.validate(); err != nil {
+		return nil, err
+	}
+
+	return overlay, nil
+}
+
+func (o *Overlay) validate() error {
+	for i, rel := range o.Relationships {
+		if _, _, ok := splitColumnRef(rel.From); !ok {
+			return fmt.Errorf("relationship %d: from %q must be written as table.column", i+1, rel.From)
+		}
+
+		if _, _, ok := splitColumnRef(rel.To); !ok {
+			return fmt.Errorf("relationship %d: to %q must be written as table.column", i+1, rel.To)
+		}
+
+		if rel.Cardinality != "" && !slices.Contains(formatter.Cardinalities, rel.Cardinality) {
+			return fmt.Errorf("relationship %d: unknown cardinality %q, want one of %s",
+				i+1, rel.Cardinality, strings.Join(formatter.Cardinalities, ", "))
+		}
+	}
+
+	return nil
+}
+
+// splitColumnRef splits "table.column" at its last dot.
+func splitColumnRef(ref string) (string, string, bool) {
+	i := strings.LastIndex(ref, ".")
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", false
+	}
+	return ref[:i], ref[i+1:], true
+}
+
+// Apply merges the overlay into the schema. Annotations for tables or columns
+// the schema does not contain are skipped with a warning, so one overlay can
+// serve diagrams of different selections. A relationship whose referenced
+// table is missing is kept and left to the external reference policy, and it
+// replaces any inferred relationship on the same column.
+func (o *Overlay) Apply(s *schema.DatabaseSchema) {
+	if o == nil {
+		return
+	}
+
+	index := make(map[string]int, len(s.Tables))
+	for i, table := range s.Tables {
+		index[table.Name] = i
+	}
+
+	for _, rel := range o.Relationships {
+		fromTable, fromColumn, _ := splitColumnRef(rel.From)
+		toTable, toColumn, _ := splitColumnRef(rel.To)
+
+		i, ok := index[fromTable]
+		if !ok {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("overlay relationship from %q skipped: table %q is not in the diagram", rel.From, fromTable))
+			continue
+		}
+
+		table := &s.Tables[i]
+		if !slices.ContainsFunc(table.Columns, func(c schema.Column) bool { return c.Name == fromColumn }) {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("overlay relationship from %q skipped: table %q has no column %q", rel.From, fromTable, fromColumn))
+			continue
+		}
+
+		label := rel.Label
+		if label == "" {
+			label = fromColumn
+		}
+
+		table.ForeignKeys = slices.DeleteFunc(table.ForeignKeys, func(fk schema.ForeignKey) bool {
+			// A declared relationship supersedes a guess about the same column.
+			return fk.Inferred && fk.ColumnName == fromColumn
+		})
+		table.ForeignKeys = append(table.ForeignKeys, schema.ForeignKey{
+			ColumnName:       fromColumn,
+			ReferencedTable:  toTable,
+			ReferencedColumn: toColumn,
+			RelationName:     label,
+			Virtual:          true,
+			Cardinality:      rel.Cardinality,
+		})
+	}
+
+	// Columns are hidden last so relationships can still start from them.
+	for _, name := range sortedKeys(o.Tables) {
+		i, ok := index[name]
+		if !ok {
+			s.Warnings = append(s.Warnings, fmt.Sprintf("overlay annotates table %q, which is not in the diagram", name))
+			continue
+		}
+		s.Warnings = append(s.Warnings, o.Tables[name].apply(&s.Tables[i])...)
+	}
+}
+
+func (t Table) apply(table *schema.Table) []string {
+	var warnings []string
+
+	if t.Alias != "" {
+		table.Alias = t.Alias
+	}
+
+	if t.Comment != nil {
+		table.Comment = *t.Comment
+	}
+
+	if t.Group != "" {
+		table.Group = t.Group
+	}
+
+	for _, name := range sortedKeys(t.Columns) {
+		annotation := t.Columns[name]
+
+		i := slices.IndexFunc(table.Columns, func(c schema.Column) bool { return c.Name == name })
+		if i < 0 {
+			warnings = append(warnings, fmt.Sprintf("overlay annotates column %q, which table %q does not have", name, table.Name))
+			continue
+		}
+
+		if annotation.Comment != nil {
+			table.Columns[i].Comment = *annotation.Comment
+		}
+	}
+
+	table.Columns = slices.DeleteFunc(table.Columns, func(c schema.Column) bool {
+		return t.Columns[c.Name].Hidden
+	})
+
+	return warnings
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
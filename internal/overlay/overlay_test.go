@@ -0,0 +1,234 @@
+package overlay
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/internal/schema"
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+const sampleYAML = `
+tables:
+  users:
+    alias: Customer
+    comment: People who place orders
+    group: accounts
+    columns:
+      password_hash:
+        hidden: true
+      email:
+        comment: Login address
+  ghosts:
+    alias: Nobody
+relationships:
+  - from: comments.owner_id
+    to: posts.id
+    label: owner (post)
+  - from: comments.owner_id
+    to: photos.id
+    cardinality: many-to-one
+    label: owner (photo)
+  - from: users.billing_account_id
+    to: billing.accounts.id
+    cardinality: one-to-one
+`
+
+func sampleSchema() *schema.DatabaseSchema {
+	return &schema.DatabaseSchema{
+		Tables: []schema.Table{
+			{
+				Name: "comments",
+				Columns: []schema.Column{
+					{Name: "id", DataType: "int"},
+					{Name: "owner_type", DataType: "varchar"},
+					{Name: "owner_id", DataType: "int"},
+				},
+				ForeignKeys: []schema.ForeignKey{
+					{ColumnName: "owner_id", ReferencedTable: "owners", ReferencedColumn: "id", RelationName: "owner_id", Inferred: true},
+				},
+			},
+			{Name: "posts", Columns: []schema.Column{{Name: "id", DataType: "int"}}},
+			{
+				Name:    "users",
+				Comment: "Registered users",
+				Columns: []schema.Column{
+					{Name: "id", DataType: "int"},
+					{Name: "email", DataType: "varchar"},
+					{Name: "password_hash", DataType: "varchar"},
+					{Name: "billing_account_id", DataType: "int"},
+				},
+			},
+		},
+	}
+}
+
+func TestParseYAMLAndJSON(t *testing.T) {
+	fromYAML, err := Parse([]byte(sampleYAML), false)
+	if err != nil {
+		t.Fatalf("Parse(yaml) returned error: %v", err)
+	}
+
+	fromJSON, err := Parse([]byte(`{
+		"tables": {"users": {"alias": "Customer", "columns": {"password_hash": {"hidden": true}}}},
+		"relationships": [{"from": "comments.owner_id", "to": "posts.id", "cardinality": "many-to-many"}]
+	}`), true)
+	if err != nil {
+		t.Fatalf("Parse(json) returned error: %v", err)
+	}
+
+	if fromYAML.Tables["users"].Alias != "Customer" || fromJSON.Tables["users"].Alias != "Customer" {
+		t.Errorf("alias not decoded: yaml %+v, json %+v", fromYAML.Tables["users"], fromJSON.Tables["users"])
+	}
+
+	if !fromJSON.Tables["users"].Columns["password_hash"].Hidden {
+		t.Errorf("hidden column not decoded: %+v", fromJSON.Tables["users"].Columns)
+	}
+
+	if got := fromJSON.Relationships[0].Cardinality; got != formatter.CardinalityManyToMany {
+		t.Errorf("cardinality = %q, want %q", got, formatter.CardinalityManyToMany)
+	}
+}
+
+func TestParseEmptyFile(t *testing.T) {
+	overlay, err := Parse([]byte("\n"), false)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	if len(overlay.Tables) != 0 || len(overlay.Relationships) != 0 {
+		t.Errorf("expected an empty overlay, got %+v", overlay)
+	}
+}
+
+func TestParseRejectsInvalidContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		isJSON  bool
+		want    string
+	}{
+		{name: "unknown yaml key", content: "tabels: {}\n", want: "tabels"},
+		{name: "unknown json key", content: `{"relations": []}`, isJSON: true, want: "relations"},
+		{name: "from without column", content: "relationships:\n  - from: comments\n    to: posts.id\n", want: "table.column"},
+		{name: "to without table", content: "relationships:\n  - from: comments.owner_id\n    to: .id\n", want: "table.column"},
+		{name: "unknown cardinality", content: "relationships:\n  - from: a.b_id\n    to: b.id\n    cardinality: lots\n", want: "unknown cardinality"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.content), tt.isJSON)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestLoadDetectsFormatFromExtension(t *testing.T) {
+	dir := t.TempDir()
+
+	jsonPath := filepath.Join(dir, "overlay.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"tables": {"users": {"group": "accounts"}}}`), 0o600); err != nil {
+		t.Fatalf("writing overlay: %v", err)
+	}
+
+	overlay, err := Load(jsonPath)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	if overlay.Tables["users"].Group != "accounts" {
+		t.Errorf("group not decoded: %+v", overlay.Tables)
+	}
+
+	if _, err := Load(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestApply(t *testing.T) {
+	overlay, err := Parse([]byte(sampleYAML), false)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+
+	s := sampleSchema()
+	overlay.Apply(s)
+
+	users := s.Tables[2]
+	if users.Alias != "Customer" || users.Group != "accounts" || users.Comment != "People who place orders" {
+		t.Errorf("table annotations not applied: %+v", users)
+	}
+
+	var columns []string
+	for _, column := range users.Columns {
+		columns = append(columns, column.Name)
+	}
+	if want := []string{"id", "email", "billing_account_id"}; !slices.Equal(columns, want) {
+		t.Errorf("columns = %v, want %v", columns, want)
+	}
+
+	if users.Columns[1].Comment != "Login address" {
+		t.Errorf("column comment = %q, want %q", users.Columns[1].Comment, "Login address")
+	}
+
+	// The declared polymorphic relationships replace the inferred guess.
+	wantComments := []schema.ForeignKey{
+		{ColumnName: "owner_id", ReferencedTable: "posts", ReferencedColumn: "id", RelationName: "owner (post)", Virtual: true},
+		{ColumnName: "owner_id", ReferencedTable: "photos", ReferencedColumn: "id", RelationName: "owner (photo)", Virtual: true, Cardinality: formatter.CardinalityManyToOne},
+	}
+	if got := s.Tables[0].ForeignKeys; !reflect.DeepEqual(got, wantComments) {
+		t.Errorf("comments foreign keys = %+v, want %+v", got, wantComments)
+	}
+
+	// Only the last dot separates the column, so schema-qualified targets work.
+	wantUsers := []schema.ForeignKey{
+		{ColumnName: "billing_account_id", ReferencedTable: "billing.accounts", ReferencedColumn: "id", RelationName: "billing_account_id", Virtual: true, Cardinality: formatter.CardinalityOneToOne},
+	}
+	if !reflect.DeepEqual(users.ForeignKeys, wantUsers) {
+		t.Errorf("users foreign keys = %+v, want %+v", users.ForeignKeys, wantUsers)
+	}
+
+	wantWarnings := []string{`overlay annotates table "ghosts", which is not in the diagram`}
+	if !slices.Equal(s.Warnings, wantWarnings) {
+		t.Errorf("warnings = %q, want %q", s.Warnings, wantWarnings)
+	}
+}
+
+func TestApplyWarnsAboutUnknownTargets(t *testing.T) {
+	overlay := &Overlay{
+		Tables: map[string]Table{"posts": {Columns: map[string]Column{"title": {Hidden: true}}}},
+		Relationships: []Relationship{
+			{From: "likes.post_id", To: "posts.id"},
+			{From: "posts.author_id", To: "users.id"},
+		},
+	}
+
+	s := sampleSchema()
+	overlay.Apply(s)
+
+	want := []string{
+		`overlay relationship from "likes.post_id" skipped: table "likes" is not in the diagram`,
+		`overlay relationship from "posts.author_id" skipped: table "posts" has no column "author_id"`,
+		`overlay annotates column "title", which table "posts" does not have`,
+	}
+	if !slices.Equal(s.Warnings, want) {
+		t.Errorf("warnings = %q, want %q", s.Warnings, want)
+	}
+}
+
+func TestApplyNilOverlay(t *testing.T) {
+	var overlay *Overlay
+
+	s := sampleSchema()
+	overlay.Apply(s)
+
+	if !reflect.DeepEqual(s, sampleSchema()) {
+		t.Errorf("nil overlay changed the schema: %+v", s)
+	}
+}
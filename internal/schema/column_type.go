@@ -0,0 +1,56 @@
+package schema
+
+import "strings"
+
+// isUnsigned reports whether a COLUMN_TYPE such as "bigint unsigned zerofill"
+// is unsigned.
+func isUnsigned(columnType string) bool {
+	for _, word := range strings.Fields(strings.ToLower(columnType)) {
+		if word == "unsigned" {
+			return true
+		}
+	}
+	return false
+}
+
+// enumValues returns the members of an ENUM or SET COLUMN_TYPE, such as
+// "enum('draft','live')", or nil for any other type. MySQL doubles the single
+// quotes inside a member.
+func enumValues(columnType string) []string {
+	lower := strings.ToLower(columnType)
+	var rest string
+	switch {
+	case strings.HasPrefix(lower, "enum("):
+		rest = columnType[len("enum("):]
+	case strings.HasPrefix(lower, "set("):
+		rest = columnType[len("set("):]
+	default:
+		return nil
+	}
+
+	values := []string{}
+	for {
+		if !strings.HasPrefix(rest, "'") {
+			return values
+		}
+
+		var value strings.Builder
+		i := 1
+		for i < len(rest) {
+			if rest[i] == '\'' {
+				if i+1 < len(rest) && rest[i+1] == '\'' {
+					value.WriteByte('\'')
+					i += 2
+					continue
+				}
+				break
+			}
+			value.WriteByte(rest[i])
+			i++
+		}
+		values = append(values, value.String())
+
+		// Skip the closing quote and the separating comma
+		rest = strings.TrimPrefix(rest[min(i+1, len(rest)):], ",")
+	}
+}
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestIsUnsigned(t *testing.T) {
+	tests := map[string]bool{
+		"int":                       false,
+		"bigint unsigned":           true,
+		"int(10) UNSIGNED ZEROFILL": true,
+		"varchar(255)":              false,
+		"enum('unsigned')":          false,
+	}
+
+	for columnType, want := range tests {
+		if got := isUnsigned(columnType); got != want {
+			t.Errorf("isUnsigned(%q) = %v, want %v", columnType, got, want)
+		}
+	}
+}
+
+func TestEnumValues(t *testing.T) {
+	tests := []struct {
+		columnType string
+		want       []string
+	}{
+		{columnType: "int", want: nil},
+		{columnType: "enum('draft','published')", want: []string{"draft", "published"}},
+		{columnType: "SET('a','b','c')", want: []string{"a", "b", "c"}},
+		{columnType: "enum('it''s','a,b','')", want: []string{"it's", "a,b", ""}},
+		{columnType: "enum()", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := enumValues(tt.columnType); !slices.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("enumValues(%q) = %q, want %q", tt.columnType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package schema
+
+import (
+	"context"
+	"sort"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/pkg/utils"
+)
+
+// withReferencedTables adds to names the tables they reference but do not
+// hold, one hop deep, keeping the database's table order. Tables that do not
+// exist in the database (e.g. references into another schema) or that
+// --exclude removes are left out; the diagram renders those as stubs. load
+// fills in the referenced tables.
+func withReferencedTables(ctx context.Context, cfg config.Config, names []string, tables catalog, available []string, load detailLoader) ([]string, error) {
+	selector, err := newTableSelector(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+	}
+
+	var referenced []string
+	for _, name := range names {
+		for _, fk := range tables[name].ForeignKeys {
+			target := fk.ReferencedTable
+			if present[target] || !contains(available, target) || utils.MatchAny(selector.exclude, target) {
+				continue
+			}
+			present[target] = true
+			referenced = append(referenced, target)
+		}
+	}
+
+	if len(referenced) == 0 {
+		return names, nil
+	}
+
+	if err := load(ctx, referenced); err != nil {
+		return nil, err
+	}
+
+	position := make(map[string]int, len(available))
+	for i, name := range available {
+		position[name] = i
+	}
+
+	all := append(append([]string(nil), names...), referenced...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return position[all[i]] < position[all[j]]
+	})
+	return all, nil
+}
@@ -0,0 +1,69 @@
+package schema
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/motchang/marid/internal/config"
+)
+
+func TestExtractIncludesReferencedTables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	cfg := config.Config{
+		Database:     "shop",
+		Tables:       "orders",
+		Exclude:      []string{"tmp_*"},
+		ExternalRefs: config.ExternalInclude,
+	}
+
+	tables, columns, primaryKeys := tablesWithIDs("addresses", "customers", "orders", "regions", "tmp_audit")
+	expectCatalog(mock, "shop", tables, columns, primaryKeys, foreignKeyRows().
+		// One hop only: the included tables' own references are not followed.
+		AddRow("customers", "region_id", "regions", "id", "fk_region").
+		AddRow("orders", "customer_id", "customers", "id", "fk_customer").
+		AddRow("orders", "address_id", "addresses", "id", "fk_address").
+		AddRow("orders", "billing_address_id", "addresses", "id", "fk_billing_address").
+		AddRow("orders", "audit_id", "tmp_audit", "id", "fk_audit").
+		AddRow("orders", "account_id", "accounts", "id", "fk_other_schema"))
+
+	schema, err := Extract(context.Background(), db, cfg, nil)
+	mustNoError(t, err, "extracting schema")
+
+	var names []string
+	for _, table := range schema.Tables {
+		names = append(names, table.Name)
+	}
+
+	// Excluded and nonexistent tables are left for the diagram to stub, and
+	// the result follows the database's table order.
+	if want := []string{"addresses", "customers", "orders"}; !slices.Equal(names, want) {
+		t.Fatalf("tables = %v, want %v", names, want)
+	}
+
+	expectNoRemaining(t, mock)
+}
+
+func TestExtractDoesNotIncludeReferencedTablesByDefault(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	tables, columns, primaryKeys := tablesWithIDs("customers", "orders")
+	expectCatalog(mock, "shop", tables, columns, primaryKeys,
+		foreignKeyRows().AddRow("orders", "customer_id", "customers", "id", "fk_customer"))
+
+	schema, err := Extract(context.Background(), db, config.Config{Database: "shop", Tables: "orders"}, nil)
+	mustNoError(t, err, "extracting schema")
+
+	if len(schema.Tables) != 1 {
+		t.Fatalf("expected only the selected table, got %d tables", len(schema.Tables))
+	}
+
+	expectNoRemaining(t, mock)
+}
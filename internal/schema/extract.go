@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
@@ -16,6 +17,24 @@ type Column struct {
 	IsPrimary  bool
 	IsUnique   bool
 	Comment    string
+	// ColumnType is the full type, such as "decimal(10,2)" or
+	// "bigint unsigned", where DataType is only "decimal" or "bigint".
+	ColumnType string
+	// CharacterMaxLength, NumericPrecision and NumericScale are nil when they
+	// do not apply to the type.
+	CharacterMaxLength *int64
+	NumericPrecision   *int64
+	NumericScale       *int64
+	Unsigned           bool
+	// EnumValues lists the members of an ENUM or SET column.
+	EnumValues []string
+	// Default is the default expression, or nil when the column has none.
+	Default *string
+	// Extra holds MySQL's EXTRA flags, such as "auto_increment",
+	// "on update CURRENT_TIMESTAMP" or "DEFAULT_GENERATED".
+	Extra        string
+	CharacterSet string
+	Collation    string
 }
 
 // ForeignKey represents a foreign key relationship
@@ -24,6 +43,24 @@ type ForeignKey struct {
 	ReferencedTable  string
 	ReferencedColumn string
 	RelationName     string
+	// Inferred marks a relationship proposed from column naming rather than
+	// declared in the database.
+	Inferred bool
+	// Virtual marks a relationship declared in an overlay file, and
+	// Cardinality optionally overrides the default many-to-one.
+	Virtual     bool
+	Cardinality string
+}
+
+// Index represents a table index, the primary key's included.
+type Index struct {
+	Name string
+	// Columns lists the indexed columns in index order; functional key parts,
+	// which index an expression, are shown as "(expression)".
+	Columns []string
+	Unique  bool
+	// Type is MySQL's index type, such as "BTREE" or "FULLTEXT".
+	Type string
 }
 
 // Table represents a database table
@@ -34,177 +71,357 @@ type Table struct {
 	Columns     []Column
 	PrimaryKey  []string
 	ForeignKeys []ForeignKey
+	// Indexes lists the table's indexes, the primary key first and the others
+	// by name.
+	Indexes []Index
+	// Focus marks the tables named with --focus.
+	Focus bool
+	// Alias and Group come from an overlay file: a display name and a
+	// grouping label.
+	Alias string
+	Group string
 }
 
 // DatabaseSchema represents the complete database schema
 type DatabaseSchema struct {
 	Tables []Table
 	Config config.Config
+	// Warnings collects non-fatal problems found during extraction, such as
+	// tables requested with --tables that do not exist.
+	Warnings []string
+}
+
+// ProgressFunc is told how many of the total units of a step are done. Steps
+// are "queries" as each query finishes while the whole database is read at
+// once, and "tables" as the parallel workers finish each table.
+type ProgressFunc func(step string, done, total int)
+
+// detailQueries fill in the columns and keys of the catalog tables in scope.
+var detailQueries = []func(context.Context, *sql.DB, scope, catalog) error{
+	extractColumns,
+	extractPrimaryKeys,
+	extractForeignKeys,
+	extractIndexes,
 }
 
-// Extract extracts the database schema
-func Extract(db *sql.DB, cfg config.Config) (*DatabaseSchema, error) {
+// catalogQueries is the number of queries Extract runs when it reads the
+// whole database at once: the table list and the detail queries.
+var catalogQueries = 1 + len(detailQueries)
+
+// Extract extracts the database schema. Queries run under ctx, so cancelling
+// it abandons the extraction. progress may be nil.
+//
+// By default the whole database is read with a constant number of set-based
+// queries — tables, columns, primary keys, foreign keys and indexes — and the tables
+// are assembled in memory, so the number of round trips does not grow with the
+// number of tables. With cfg.Concurrency set, the details are instead read per
+// table by that many workers, which suits databases too large to read at once.
+func Extract(ctx context.Context, db *sql.DB, cfg config.Config, progress ProgressFunc) (*DatabaseSchema, error) {
+	if progress == nil {
+		progress = func(string, int, int) {}
+	}
+
+	if cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("concurrency must not be negative, got %d", cfg.Concurrency)
+	}
+
 	schema := &DatabaseSchema{
 		Tables: []Table{},
 		Config: cfg,
 	}
 
 	// Get list of tables
-	tables, err := getTables(db, cfg)
+	selection, err := getTables(ctx, db, cfg)
 	if err != nil {
 		return nil, err
 	}
+	schema.Warnings = append(schema.Warnings, selection.Warnings...)
 
-	// Extract table details
-	for _, tableName := range tables {
-		table, err := extractTableInfo(db, tableName)
+	// Fill in the details of the selected tables; the focus walk needs the
+	// foreign keys of all of them
+	load := newDetailLoader(db, cfg, selection.Catalog, progress)
+	if err := load(ctx, selection.Tables); err != nil {
+		return nil, err
+	}
+
+	// Propose relationships the database does not declare, so the focus walk
+	// and the referenced tables follow them too
+	if cfg.InferRelations {
+		patterns := cfg.InferPatterns
+		if len(patterns) == 0 {
+			patterns = DefaultInferencePatterns
+		}
+
+		rules, err := CompileInferenceRules(patterns)
 		if err != nil {
 			return nil, err
 		}
-		schema.Tables = append(schema.Tables, *table)
+		selection.Catalog.inferRelationships(selection.Tables, rules)
 	}
 
-	return schema, nil
-}
+	// Narrow the selection to the neighbourhood of the focus tables
+	tables := selection.Tables
+	if len(cfg.Focus) > 0 {
+		tables, err = focusTables(cfg, tables, selection.Catalog.edges(selection.Available))
+		if err != nil {
+			return nil, err
+		}
+	}
 
-// getTables gets the list of tables from the database
-func getTables(db *sql.DB, cfg config.Config) ([]string, error) {
-	filterTables := cfg.GetTablesList()
-	hasTableFilter := len(filterTables) > 0
+	// Pull in the tables the selection references when asked to
+	if cfg.ExternalRefs == config.ExternalInclude {
+		if tables, err = withReferencedTables(ctx, cfg, tables, selection.Catalog, selection.Available, load); err != nil {
+			return nil, err
+		}
+	}
 
-	// Query to get all tables in the database
-	query := `
-		SELECT TABLE_NAME
-		FROM INFORMATION_SCHEMA.TABLES
-		WHERE TABLE_SCHEMA = ?
-		AND TABLE_TYPE = 'BASE TABLE'
-		ORDER BY TABLE_NAME
-	`
+	for _, tableName := range tables {
+		table := *selection.Catalog[tableName]
+		table.Focus = contains(cfg.Focus, tableName)
+		schema.Tables = append(schema.Tables, table)
+	}
 
-	rows, err := db.Query(query, cfg.Database)
-	if err != nil {
-		return nil, fmt.Errorf("error querying tables: %w", err)
+	return schema, nil
+}
+
+// detailLoader fills in the columns and keys of the named catalog tables.
+type detailLoader func(ctx context.Context, names []string) error
+
+// newDetailLoader returns the loader cfg asks for. The set-based loader reads
+// every table on its first call and does nothing afterwards; the parallel one
+// reads each named table it has not read yet.
+func newDetailLoader(db *sql.DB, cfg config.Config, tables catalog, progress ProgressFunc) detailLoader {
+	if cfg.Concurrency > 0 {
+		loaded := make(map[string]bool)
+		return func(ctx context.Context, names []string) error {
+			var pending []string
+			for _, name := range names {
+				if !loaded[name] {
+					loaded[name] = true
+					pending = append(pending, name)
+				}
+			}
+			return loadTablesInParallel(ctx, db, cfg.Database, tables, pending, cfg.Concurrency, progress)
+		}
 	}
-	defer func(rows *sql.Rows) {
-		_ = rows.Close()
-	}(rows)
 
-	var tables []string
-	for rows.Next() {
-		var tableName string
-		if err := rows.Scan(&tableName); err != nil {
-			return nil, fmt.Errorf("error scanning table name: %w", err)
+	loaded := false
+	return func(ctx context.Context, _ []string) error {
+		if loaded {
+			return nil
 		}
 
-		// Apply table filter if specified
-		if hasTableFilter {
-			for _, filterTable := range filterTables {
-				if tableName == filterTable {
-					tables = append(tables, tableName)
-					break
-				}
+		progress("queries", 1, catalogQueries)
+		for i, query := range detailQueries {
+			if err := query(ctx, db, scope{Database: cfg.Database}, tables); err != nil {
+				return err
 			}
-		} else {
-			tables = append(tables, tableName)
+			progress("queries", i+2, catalogQueries)
 		}
+
+		loaded = true
+		return nil
 	}
+}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating table rows: %w", err)
+// InferredRelationships returns the foreign keys that inference added, as
+// "table.column -> referenced_table.referenced_column" lines for review.
+func (s *DatabaseSchema) InferredRelationships() []string {
+	var inferred []string
+	for _, table := range s.Tables {
+		for _, fk := range table.ForeignKeys {
+			if fk.Inferred {
+				inferred = append(inferred, fmt.Sprintf("%s.%s -> %s.%s",
+					table.Name, fk.ColumnName, fk.ReferencedTable, fk.ReferencedColumn))
+			}
+		}
 	}
+	return inferred
+}
+
+// catalog holds every base table of the database by name while the detail
+// queries fill them in.
+type catalog map[string]*Table
+
+// edges lists the foreign keys of the catalog at table level, visiting tables
+// in the given order.
+func (c catalog) edges(order []string) []foreignKeyEdge {
+	var edges []foreignKeyEdge
+	for _, name := range order {
+		for _, fk := range c[name].ForeignKeys {
+			edges = append(edges, foreignKeyEdge{Table: name, Referenced: fk.ReferencedTable})
+		}
+	}
+	return edges
+}
 
-	return tables, nil
+// tableSelection is the outcome of getTables.
+type tableSelection struct {
+	// Tables are the selected table names in alphabetical order.
+	Tables []string
+	// Available lists every base table in the database.
+	Available []string
+	// Catalog holds a Table, so far with only its name and comment, for
+	// every available table.
+	Catalog catalog
+	// Warnings describe selection criteria that matched nothing.
+	Warnings []string
 }
 
-// extractTableInfo extracts detailed information about a table
-// extractTableInfo extracts detailed information about a table
-func extractTableInfo(db *sql.DB, tableName string) (*Table, error) {
-	table := &Table{
-		Name:        tableName,
-		Columns:     []Column{},
-		PrimaryKey:  []string{},
-		ForeignKeys: []ForeignKey{},
+// getTables gets every table of the database with its comment, and the names
+// selected by --tables, --include and --exclude.
+func getTables(ctx context.Context, db *sql.DB, cfg config.Config) (*tableSelection, error) {
+	selector, err := newTableSelector(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	// Get table comment
-	commentQuery := `
-		SELECT 
+	// Query to get all tables in the database
+	query := `
+		SELECT
+			TABLE_NAME,
 			TABLE_COMMENT
-		FROM 
+		FROM
 			INFORMATION_SCHEMA.TABLES
-		WHERE 
-			TABLE_SCHEMA = DATABASE()
-			AND TABLE_NAME = ?
+		WHERE
+			TABLE_SCHEMA = ?
+			AND TABLE_TYPE = 'BASE TABLE'
+		ORDER BY
+			TABLE_NAME
 	`
 
-	err := db.QueryRow(commentQuery, tableName).Scan(&table.Comment)
+	rows, err := db.QueryContext(ctx, query, cfg.Database)
 	if err != nil {
-		return nil, fmt.Errorf("error querying table comment for %s: %w", tableName, err)
+		return nil, fmt.Errorf("error querying tables: %w", err)
 	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
 
-	// Get column information
-	if err := extractColumns(db, table); err != nil {
-		return nil, err
-	}
+	selection := &tableSelection{Catalog: catalog{}}
+	for rows.Next() {
+		table := &Table{
+			Columns:     []Column{},
+			PrimaryKey:  []string{},
+			ForeignKeys: []ForeignKey{},
+		}
+		if err := rows.Scan(&table.Name, &table.Comment); err != nil {
+			return nil, fmt.Errorf("error scanning table: %w", err)
+		}
 
-	// Get primary key information
-	if err := extractPrimaryKeys(db, table); err != nil {
-		return nil, err
+		selection.Catalog[table.Name] = table
+		selection.Available = append(selection.Available, table.Name)
+		if selector.selects(table.Name) {
+			selection.Tables = append(selection.Tables, table.Name)
+		}
 	}
 
-	// Get foreign key information
-	if err := extractForeignKeys(db, table); err != nil {
-		return nil, err
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating table rows: %w", err)
 	}
 
-	return table, nil
+	selection.Warnings = selector.warnings(cfg.Database, selection.Available)
+	return selection, nil
+}
+
+// scope restricts the detail queries to a database, and to one of its tables
+// when Table is set.
+type scope struct {
+	Database string
+	Table    string
+}
+
+// where returns the condition selecting the rows in scope and its arguments.
+func (s scope) where() (string, []any) {
+	if s.Table == "" {
+		return "TABLE_SCHEMA = ?", []any{s.Database}
+	}
+	return "TABLE_SCHEMA = ? AND TABLE_NAME = ?", []any{s.Database, s.Table}
 }
 
-// extractColumns extracts column information for the table
-// extractColumns extracts column information for the table
-func extractColumns(db *sql.DB, table *Table) error {
+// extractColumns extracts column information for the catalog tables in scope.
+// Columns of other relations, such as views, are skipped.
+func extractColumns(ctx context.Context, db *sql.DB, scope scope, tables catalog) error {
 	query := `
-		SELECT 
+		SELECT
+			TABLE_NAME,
 			COLUMN_NAME,
 			DATA_TYPE,
 			IS_NULLABLE,
 			COLUMN_KEY,
-			COLUMN_COMMENT
-		FROM 
+			COLUMN_COMMENT,
+			COLUMN_TYPE,
+			CHARACTER_MAXIMUM_LENGTH,
+			NUMERIC_PRECISION,
+			NUMERIC_SCALE,
+			COLUMN_DEFAULT,
+			EXTRA,
+			CHARACTER_SET_NAME,
+			COLLATION_NAME
+		FROM
 			INFORMATION_SCHEMA.COLUMNS
-		WHERE 
-			TABLE_SCHEMA = DATABASE()
-			AND TABLE_NAME = ?
-		ORDER BY 
+		WHERE
+			%s
+		ORDER BY
+			TABLE_NAME,
 			ORDINAL_POSITION
 	`
 
-	rows, err := db.Query(query, table.Name)
+	condition, args := scope.where()
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(query, condition), args...)
 	if err != nil {
-		return fmt.Errorf("error querying columns for table %s: %w", table.Name, err)
+		return fmt.Errorf("error querying columns: %w", err)
 	}
 	defer func(rows *sql.Rows) {
 		_ = rows.Close()
 	}(rows)
 
 	for rows.Next() {
+		var tableName string
 		var column Column
 		var isNullable, columnKey string
+		var maxLength, precision, scale sql.NullInt64
+		var columnDefault, characterSet, collation sql.NullString
 
 		if err := rows.Scan(
+			&tableName,
 			&column.Name,
 			&column.DataType,
 			&isNullable,
 			&columnKey,
 			&column.Comment,
+			&column.ColumnType,
+			&maxLength,
+			&precision,
+			&scale,
+			&columnDefault,
+			&column.Extra,
+			&characterSet,
+			&collation,
 		); err != nil {
 			return fmt.Errorf("error scanning column: %w", err)
 		}
 
+		table, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+
 		column.IsNullable = strings.ToUpper(isNullable) == "YES"
 		column.IsPrimary = strings.ToUpper(columnKey) == "PRI"
 		column.IsUnique = strings.ToUpper(columnKey) == "UNI"
 
+		column.CharacterMaxLength = nullInt64(maxLength)
+		column.NumericPrecision = nullInt64(precision)
+		column.NumericScale = nullInt64(scale)
+		column.Unsigned = isUnsigned(column.ColumnType)
+		column.EnumValues = enumValues(column.ColumnType)
+		if columnDefault.Valid {
+			column.Default = &columnDefault.String
+		}
+		column.CharacterSet = characterSet.String
+		column.Collation = collation.String
+
 		table.Columns = append(table.Columns, column)
 	}
 
@@ -215,35 +432,41 @@ func extractColumns(db *sql.DB, table *Table) error {
 	return nil
 }
 
-// extractPrimaryKeys extracts primary key information for the table
-func extractPrimaryKeys(db *sql.DB, table *Table) error {
+// extractPrimaryKeys extracts primary key information for the catalog tables in
+// scope.
+func extractPrimaryKeys(ctx context.Context, db *sql.DB, scope scope, tables catalog) error {
 	query := `
 		SELECT
+			TABLE_NAME,
 			COLUMN_NAME
 		FROM
 			INFORMATION_SCHEMA.KEY_COLUMN_USAGE
 		WHERE
-			TABLE_SCHEMA = DATABASE()
-			AND TABLE_NAME = ?
+			%s
 			AND CONSTRAINT_NAME = 'PRIMARY'
 		ORDER BY
+			TABLE_NAME,
 			ORDINAL_POSITION
 	`
 
-	rows, err := db.Query(query, table.Name)
+	condition, args := scope.where()
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(query, condition), args...)
 	if err != nil {
-		return fmt.Errorf("error querying primary keys for table %s: %w", table.Name, err)
+		return fmt.Errorf("error querying primary keys: %w", err)
 	}
 	defer func(rows *sql.Rows) {
 		_ = rows.Close()
 	}(rows)
 
 	for rows.Next() {
-		var columnName string
-		if err := rows.Scan(&columnName); err != nil {
+		var tableName, columnName string
+		if err := rows.Scan(&tableName, &columnName); err != nil {
 			return fmt.Errorf("error scanning primary key: %w", err)
 		}
-		table.PrimaryKey = append(table.PrimaryKey, columnName)
+
+		if table, ok := tables[tableName]; ok {
+			table.PrimaryKey = append(table.PrimaryKey, columnName)
+		}
 	}
 
 	if err := rows.Err(); err != nil {
@@ -253,10 +476,12 @@ func extractPrimaryKeys(db *sql.DB, table *Table) error {
 	return nil
 }
 
-// extractForeignKeys extracts foreign key information for the table
-func extractForeignKeys(db *sql.DB, table *Table) error {
+// extractForeignKeys extracts foreign key information for the catalog tables in
+// scope.
+func extractForeignKeys(ctx context.Context, db *sql.DB, scope scope, tables catalog) error {
 	query := `
 		SELECT
+			TABLE_NAME,
 			COLUMN_NAME,
 			REFERENCED_TABLE_NAME,
 			REFERENCED_COLUMN_NAME,
@@ -264,24 +489,27 @@ func extractForeignKeys(db *sql.DB, table *Table) error {
 		FROM
 			INFORMATION_SCHEMA.KEY_COLUMN_USAGE
 		WHERE
-			TABLE_SCHEMA = DATABASE()
-			AND TABLE_NAME = ?
+			%s
 			AND REFERENCED_TABLE_NAME IS NOT NULL
 		ORDER BY
+			TABLE_NAME,
 			ORDINAL_POSITION
 	`
 
-	rows, err := db.Query(query, table.Name)
+	condition, args := scope.where()
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(query, condition), args...)
 	if err != nil {
-		return fmt.Errorf("error querying foreign keys for table %s: %w", table.Name, err)
+		return fmt.Errorf("error querying foreign keys: %w", err)
 	}
 	defer func(rows *sql.Rows) {
 		_ = rows.Close()
 	}(rows)
 
 	for rows.Next() {
+		var tableName string
 		var fk ForeignKey
 		if err := rows.Scan(
+			&tableName,
 			&fk.ColumnName,
 			&fk.ReferencedTable,
 			&fk.ReferencedColumn,
@@ -289,7 +517,10 @@ func extractForeignKeys(db *sql.DB, table *Table) error {
 		); err != nil {
 			return fmt.Errorf("error scanning foreign key: %w", err)
 		}
-		table.ForeignKeys = append(table.ForeignKeys, fk)
+
+		if table, ok := tables[tableName]; ok {
+			table.ForeignKeys = append(table.ForeignKeys, fk)
+		}
 	}
 
 	if err := rows.Err(); err != nil {
@@ -298,3 +529,81 @@ func extractForeignKeys(db *sql.DB, table *Table) error {
 
 	return nil
 }
+
+// expressionKeyPart stands in for the column of a functional key part.
+const expressionKeyPart = "(expression)"
+
+// extractIndexes extracts the indexes of the catalog tables in scope.
+func extractIndexes(ctx context.Context, db *sql.DB, scope scope, tables catalog) error {
+	query := `
+		SELECT
+			TABLE_NAME,
+			INDEX_NAME,
+			NON_UNIQUE,
+			COLUMN_NAME,
+			INDEX_TYPE
+		FROM
+			INFORMATION_SCHEMA.STATISTICS
+		WHERE
+			%s
+		ORDER BY
+			TABLE_NAME,
+			INDEX_NAME = 'PRIMARY' DESC,
+			INDEX_NAME,
+			SEQ_IN_INDEX
+	`
+
+	condition, args := scope.where()
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(query, condition), args...)
+	if err != nil {
+		return fmt.Errorf("error querying indexes: %w", err)
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var tableName, indexName, indexType string
+		var nonUnique int
+		var columnName sql.NullString
+		if err := rows.Scan(&tableName, &indexName, &nonUnique, &columnName, &indexType); err != nil {
+			return fmt.Errorf("error scanning index: %w", err)
+		}
+
+		table, ok := tables[tableName]
+		if !ok {
+			continue
+		}
+
+		column := columnName.String
+		if !columnName.Valid {
+			column = expressionKeyPart
+		}
+
+		// Rows of an index are adjacent, in key part order.
+		if n := len(table.Indexes); n > 0 && table.Indexes[n-1].Name == indexName {
+			table.Indexes[n-1].Columns = append(table.Indexes[n-1].Columns, column)
+			continue
+		}
+		table.Indexes = append(table.Indexes, Index{
+			Name:    indexName,
+			Columns: []string{column},
+			Unique:  nonUnique == 0,
+			Type:    indexType,
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating index rows: %w", err)
+	}
+
+	return nil
+}
+
+// nullInt64 returns a pointer to the value, or nil when it is NULL.
+func nullInt64(value sql.NullInt64) *int64 {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Int64
+}
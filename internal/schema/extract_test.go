@@ -1,8 +1,10 @@
 package schema
 
 import (
-	"database/sql"
+	"context"
 	"errors"
+	"fmt"
+	"reflect"
 	"regexp"
 	"testing"
 
@@ -11,101 +13,61 @@ import (
 	"github.com/motchang/marid/internal/config"
 )
 
-func mustNoError(t *testing.T, err error, msg string) {
-	t.Helper()
-	if err != nil {
-		t.Fatalf("%s: %v", msg, err)
-	}
-}
-
-func expectError(t *testing.T, err error, msg string) {
-	t.Helper()
-	if err == nil {
-		t.Fatalf("expected error: %s", msg)
-	}
-}
-
-func expectNoRemaining(t *testing.T, mock sqlmock.Sqlmock) {
-	t.Helper()
-	if err := mock.ExpectationsWereMet(); err != nil {
-		t.Fatalf("unmet expectations: %v", err)
-	}
-}
-
-func TestExtractSuccessWithFiltering(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
-
-	cfg := config.Config{Database: "testdb", Tables: "users"}
-
-	tablesRows := sqlmock.NewRows([]string{"TABLE_NAME"}).
-		AddRow("users").
-		AddRow("orders")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT TABLE_NAME
-                FROM INFORMATION_SCHEMA.TABLES
-                WHERE TABLE_SCHEMA = ?
-                AND TABLE_TYPE = 'BASE TABLE'
-                ORDER BY TABLE_NAME
-        `)).
-		WithArgs(cfg.Database).
-		WillReturnRows(tablesRows)
-
-	mock.ExpectQuery(regexp.QuoteMeta(`
+const tablesQuery = `
                 SELECT
+                        TABLE_NAME,
                         TABLE_COMMENT
                 FROM
                         INFORMATION_SCHEMA.TABLES
                 WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-        `)).
-		WithArgs("users").
-		WillReturnRows(sqlmock.NewRows([]string{"TABLE_COMMENT"}).AddRow("users table"))
-
-	columnsRows := sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).
-		AddRow("id", "int", "NO", "PRI", "primary id").
-		AddRow("email", "varchar", "NO", "UNI", "email column").
-		AddRow("org_id", "int", "YES", "", "organization id")
-	mock.ExpectQuery(regexp.QuoteMeta(`
+                        TABLE_SCHEMA = ?
+                        AND TABLE_TYPE = 'BASE TABLE'
+                ORDER BY
+                        TABLE_NAME
+        `
+
+const columnsQuery = `
                 SELECT
+                        TABLE_NAME,
                         COLUMN_NAME,
                         DATA_TYPE,
                         IS_NULLABLE,
                         COLUMN_KEY,
-                        COLUMN_COMMENT
+                        COLUMN_COMMENT,
+                        COLUMN_TYPE,
+                        CHARACTER_MAXIMUM_LENGTH,
+                        NUMERIC_PRECISION,
+                        NUMERIC_SCALE,
+                        COLUMN_DEFAULT,
+                        EXTRA,
+                        CHARACTER_SET_NAME,
+                        COLLATION_NAME
                 FROM
                         INFORMATION_SCHEMA.COLUMNS
                 WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
+                        TABLE_SCHEMA = ?
                 ORDER BY
+                        TABLE_NAME,
                         ORDINAL_POSITION
-        `)).
-		WithArgs("users").
-		WillReturnRows(columnsRows)
+        `
 
-	pkRows := sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id")
-	mock.ExpectQuery(regexp.QuoteMeta(`
+const primaryKeysQuery = `
                 SELECT
+                        TABLE_NAME,
                         COLUMN_NAME
                 FROM
                         INFORMATION_SCHEMA.KEY_COLUMN_USAGE
                 WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
+                        TABLE_SCHEMA = ?
                         AND CONSTRAINT_NAME = 'PRIMARY'
                 ORDER BY
+                        TABLE_NAME,
                         ORDINAL_POSITION
-        `)).
-		WithArgs("users").
-		WillReturnRows(pkRows)
+        `
 
-	fkRows := sqlmock.NewRows([]string{"COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"}).
-		AddRow("org_id", "organizations", "id", "fk_users_org")
-	mock.ExpectQuery(regexp.QuoteMeta(`
+const foreignKeysQuery = `
                 SELECT
+                        TABLE_NAME,
                         COLUMN_NAME,
                         REFERENCED_TABLE_NAME,
                         REFERENCED_COLUMN_NAME,
@@ -113,621 +75,426 @@ func TestExtractSuccessWithFiltering(t *testing.T) {
                 FROM
                         INFORMATION_SCHEMA.KEY_COLUMN_USAGE
                 WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
+                        TABLE_SCHEMA = ?
                         AND REFERENCED_TABLE_NAME IS NOT NULL
                 ORDER BY
+                        TABLE_NAME,
                         ORDINAL_POSITION
-        `)).
-		WithArgs("users").
-		WillReturnRows(fkRows)
+        `
 
-	schema, err := Extract(db, cfg)
-	mustNoError(t, err, "extracting schema")
+const indexesQuery = `
+                SELECT
+                        TABLE_NAME,
+                        INDEX_NAME,
+                        NON_UNIQUE,
+                        COLUMN_NAME,
+                        INDEX_TYPE
+                FROM
+                        INFORMATION_SCHEMA.STATISTICS
+                WHERE
+                        TABLE_SCHEMA = ?
+                ORDER BY
+                        TABLE_NAME,
+                        INDEX_NAME = 'PRIMARY' DESC,
+                        INDEX_NAME,
+                        SEQ_IN_INDEX
+        `
 
-	if len(schema.Tables) != 1 {
-		t.Fatalf("expected 1 table, got %d", len(schema.Tables))
-	}
-	users := schema.Tables[0]
-	if users.Name != "users" {
-		t.Fatalf("unexpected table name: %s", users.Name)
-	}
-	if users.Comment != "users table" {
-		t.Fatalf("unexpected table comment: %s", users.Comment)
+func mustNoError(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", msg, err)
 	}
+}
 
-	expectedColumns := []Column{
-		{Name: "id", DataType: "int", IsNullable: false, IsPrimary: true, IsUnique: false, Comment: "primary id"},
-		{Name: "email", DataType: "varchar", IsNullable: false, IsPrimary: false, IsUnique: true, Comment: "email column"},
-		{Name: "org_id", DataType: "int", IsNullable: true, IsPrimary: false, IsUnique: false, Comment: "organization id"},
-	}
-	if len(users.Columns) != len(expectedColumns) {
-		t.Fatalf("unexpected column count: %d", len(users.Columns))
-	}
-	for i, col := range expectedColumns {
-		if users.Columns[i] != col {
-			t.Fatalf("unexpected column at %d: %#v", i, users.Columns[i])
-		}
+func expectError(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("expected error: %s", msg)
 	}
+}
 
-	if len(users.PrimaryKey) != 1 || users.PrimaryKey[0] != "id" {
-		t.Fatalf("unexpected primary key: %#v", users.PrimaryKey)
+func expectNoRemaining(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
 	}
+}
 
-	expectedFK := ForeignKey{ColumnName: "org_id", ReferencedTable: "organizations", ReferencedColumn: "id", RelationName: "fk_users_org"}
-	if len(users.ForeignKeys) != 1 || users.ForeignKeys[0] != expectedFK {
-		t.Fatalf("unexpected foreign keys: %#v", users.ForeignKeys)
-	}
+func tableRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"TABLE_NAME", "TABLE_COMMENT"})
+}
 
-	expectNoRemaining(t, mock)
+func columnRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"TABLE_NAME", "COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT",
+		"COLUMN_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE",
+		"COLUMN_DEFAULT", "EXTRA", "CHARACTER_SET_NAME", "COLLATION_NAME",
+	})
 }
 
-func TestGetTablesQueryError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+// column returns a columns row whose full type is its data type and which has
+// no further details.
+func column(table, name, dataType, nullable, key, comment any) []any {
+	return []any{table, name, dataType, nullable, key, comment, dataType, nil, nil, nil, nil, "", nil, nil}
+}
 
-	cfg := config.Config{Database: "db"}
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT TABLE_NAME
-                FROM INFORMATION_SCHEMA.TABLES
-                WHERE TABLE_SCHEMA = ?
-                AND TABLE_TYPE = 'BASE TABLE'
-                ORDER BY TABLE_NAME
-        `)).
-		WithArgs(cfg.Database).
-		WillReturnError(errors.New("query failed"))
-
-	_, err = getTables(db, cfg)
-	expectError(t, err, "getTables query error")
-	expectNoRemaining(t, mock)
+func primaryKeyRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"TABLE_NAME", "COLUMN_NAME"})
 }
 
-func TestGetTablesScanError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+func foreignKeyRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"TABLE_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"})
+}
 
-	cfg := config.Config{Database: "db"}
-	rows := sqlmock.NewRows([]string{"TABLE_NAME"}).AddRow(nil)
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT TABLE_NAME
-                FROM INFORMATION_SCHEMA.TABLES
-                WHERE TABLE_SCHEMA = ?
-                AND TABLE_TYPE = 'BASE TABLE'
-                ORDER BY TABLE_NAME
-        `)).
-		WithArgs(cfg.Database).
-		WillReturnRows(rows)
-
-	_, err = getTables(db, cfg)
-	expectError(t, err, "getTables scan error")
-	expectNoRemaining(t, mock)
+func indexRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"TABLE_NAME", "INDEX_NAME", "NON_UNIQUE", "COLUMN_NAME", "INDEX_TYPE"})
 }
 
-func TestGetTablesRowsError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+// expectCatalog registers the five set-based queries of Extract, finding no
+// indexes.
+func expectCatalog(mock sqlmock.Sqlmock, database string, tables, columns, primaryKeys, foreignKeys *sqlmock.Rows) {
+	mock.ExpectQuery(regexp.QuoteMeta(tablesQuery)).WithArgs(database).WillReturnRows(tables)
+	mock.ExpectQuery(regexp.QuoteMeta(columnsQuery)).WithArgs(database).WillReturnRows(columns)
+	mock.ExpectQuery(regexp.QuoteMeta(primaryKeysQuery)).WithArgs(database).WillReturnRows(primaryKeys)
+	mock.ExpectQuery(regexp.QuoteMeta(foreignKeysQuery)).WithArgs(database).WillReturnRows(foreignKeys)
+	mock.ExpectQuery(regexp.QuoteMeta(indexesQuery)).WithArgs(database).WillReturnRows(indexRows())
+}
 
-	cfg := config.Config{Database: "db"}
-	rows := sqlmock.NewRows([]string{"TABLE_NAME"}).AddRow("users").RowError(0, errors.New("row error"))
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT TABLE_NAME
-                FROM INFORMATION_SCHEMA.TABLES
-                WHERE TABLE_SCHEMA = ?
-                AND TABLE_TYPE = 'BASE TABLE'
-                ORDER BY TABLE_NAME
-        `)).
-		WithArgs(cfg.Database).
-		WillReturnRows(rows)
-
-	_, err = getTables(db, cfg)
-	expectError(t, err, "getTables row error")
-	expectNoRemaining(t, mock)
+// tablesWithIDs returns table rows for the named tables, and column and
+// primary key rows giving each of them an int id primary key.
+func tablesWithIDs(names ...string) (*sqlmock.Rows, *sqlmock.Rows, *sqlmock.Rows) {
+	tables, columns, primaryKeys := tableRows(), columnRows(), primaryKeyRows()
+	for _, name := range names {
+		tables.AddRow(name, "")
+		columns.AddRow(column(name, "id", "int", "NO", "PRI", "")...)
+		primaryKeys.AddRow(name, "id")
+	}
+	return tables, columns, primaryKeys
 }
 
-func TestExtractColumnsQueryError(t *testing.T) {
+func TestExtractSuccessWithFiltering(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	table := &Table{Name: "users"}
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnError(errors.New("column query failed"))
+	cfg := config.Config{Database: "testdb", Tables: "users"}
 
-	err = extractColumns(db, table)
-	expectError(t, err, "extractColumns query error")
-	expectNoRemaining(t, mock)
-}
+	expectCatalog(mock, cfg.Database,
+		tableRows().
+			AddRow("orders", "orders table").
+			AddRow("users", "users table"),
+		columnRows().
+			AddRow(column("orders", "id", "int", "NO", "PRI", "")...).
+			AddRow(column("users", "id", "int", "NO", "PRI", "primary id")...).
+			AddRow(column("users", "email", "varchar", "NO", "UNI", "email column")...).
+			AddRow(column("users", "org_id", "int", "YES", "", "organization id")...),
+		primaryKeyRows().
+			AddRow("orders", "id").
+			AddRow("users", "id"),
+		foreignKeyRows().
+			AddRow("users", "org_id", "organizations", "id", "fk_users_org"))
+
+	schema, err := Extract(context.Background(), db, cfg, nil)
+	mustNoError(t, err, "extracting schema")
 
-func TestExtractColumnsScanError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+	if len(schema.Tables) != 1 {
+		t.Fatalf("expected 1 table, got %d", len(schema.Tables))
+	}
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).
-		AddRow("id", "int", nil, "PRI", "comment")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	want := Table{
+		Name:    "users",
+		Comment: "users table",
+		Columns: []Column{
+			{Name: "id", DataType: "int", ColumnType: "int", IsNullable: false, IsPrimary: true, IsUnique: false, Comment: "primary id"},
+			{Name: "email", DataType: "varchar", ColumnType: "varchar", IsNullable: false, IsPrimary: false, IsUnique: true, Comment: "email column"},
+			{Name: "org_id", DataType: "int", ColumnType: "int", IsNullable: true, IsPrimary: false, IsUnique: false, Comment: "organization id"},
+		},
+		PrimaryKey: []string{"id"},
+		ForeignKeys: []ForeignKey{
+			{ColumnName: "org_id", ReferencedTable: "organizations", ReferencedColumn: "id", RelationName: "fk_users_org"},
+		},
+	}
+	if !reflect.DeepEqual(schema.Tables[0], want) {
+		t.Fatalf("unexpected table:\n got %#v\nwant %#v", schema.Tables[0], want)
+	}
 
-	err = extractColumns(db, table)
-	expectError(t, err, "extractColumns scan error")
 	expectNoRemaining(t, mock)
 }
 
-func TestExtractColumnsRowsError(t *testing.T) {
+func TestExtractHandlesMultipleTables(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).
-		AddRow("id", "int", "NO", "PRI", "comment").
-		RowError(0, errors.New("row error"))
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	cfg := config.Config{Database: "testdb"}
 
-	err = extractColumns(db, table)
-	expectError(t, err, "extractColumns row error")
-	expectNoRemaining(t, mock)
-}
+	expectCatalog(mock, cfg.Database,
+		tableRows().
+			AddRow("users", "users table").
+			AddRow("orders", "orders table"),
+		columnRows().
+			AddRow(column("orders", "id", "int", "NO", "PRI", "id")...).
+			AddRow(column("orders", "user_id", "int", "NO", "", "user id")...).
+			AddRow(column("users", "id", "int", "NO", "PRI", "id")...).
+			AddRow(column("users", "name", "varchar", "YES", "", "name")...),
+		primaryKeyRows().
+			AddRow("orders", "id").
+			AddRow("users", "id"),
+		foreignKeyRows().
+			AddRow("orders", "user_id", "users", "id", "fk_orders_users"))
+
+	schema, err := Extract(context.Background(), db, cfg, nil)
+	mustNoError(t, err, "extracting schema")
 
-func TestExtractPrimaryKeysQueryError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+	want := []Table{
+		{
+			Name:    "users",
+			Comment: "users table",
+			Columns: []Column{
+				{Name: "id", DataType: "int", ColumnType: "int", IsPrimary: true, Comment: "id"},
+				{Name: "name", DataType: "varchar", ColumnType: "varchar", IsNullable: true, Comment: "name"},
+			},
+			PrimaryKey:  []string{"id"},
+			ForeignKeys: []ForeignKey{},
+		},
+		{
+			Name:    "orders",
+			Comment: "orders table",
+			Columns: []Column{
+				{Name: "id", DataType: "int", ColumnType: "int", IsPrimary: true, Comment: "id"},
+				{Name: "user_id", DataType: "int", ColumnType: "int", Comment: "user id"},
+			},
+			PrimaryKey:  []string{"id"},
+			ForeignKeys: []ForeignKey{{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_orders_users"}},
+		},
+	}
 
-	table := &Table{Name: "users"}
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnError(errors.New("pk query failed"))
+	// The tables keep the order the database listed them in.
+	if !reflect.DeepEqual(schema.Tables, want) {
+		t.Fatalf("unexpected tables:\n got %#v\nwant %#v", schema.Tables, want)
+	}
 
-	err = extractPrimaryKeys(db, table)
-	expectError(t, err, "extractPrimaryKeys query error")
 	expectNoRemaining(t, mock)
 }
 
-func TestExtractPrimaryKeysScanError(t *testing.T) {
+func TestExtractKeepsTablesWithoutDetails(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow(nil)
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	// Rows of views and other relations that are not base tables are ignored.
+	expectCatalog(mock, "testdb",
+		tableRows().AddRow("empty", ""),
+		columnRows().AddRow(column("active_users", "id", "int", "NO", "", "")...),
+		primaryKeyRows(),
+		foreignKeyRows())
 
-	err = extractPrimaryKeys(db, table)
-	expectError(t, err, "extractPrimaryKeys scan error")
-	expectNoRemaining(t, mock)
-}
-
-func TestExtractPrimaryKeysRowsError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+	schema, err := Extract(context.Background(), db, config.Config{Database: "testdb"}, nil)
+	mustNoError(t, err, "extracting schema")
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id").RowError(0, errors.New("row error"))
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	want := []Table{{Name: "empty", Columns: []Column{}, PrimaryKey: []string{}, ForeignKeys: []ForeignKey{}}}
+	if !reflect.DeepEqual(schema.Tables, want) {
+		t.Fatalf("unexpected tables: %#v", schema.Tables)
+	}
 
-	err = extractPrimaryKeys(db, table)
-	expectError(t, err, "extractPrimaryKeys row error")
 	expectNoRemaining(t, mock)
 }
 
-func TestExtractForeignKeysQueryError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
-
-	table := &Table{Name: "users"}
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        REFERENCED_TABLE_NAME,
-                        REFERENCED_COLUMN_NAME,
-                        CONSTRAINT_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND REFERENCED_TABLE_NAME IS NOT NULL
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnError(errors.New("fk query failed"))
+func TestExtractPropagatesQueryErrors(t *testing.T) {
+	queries := []struct {
+		name  string
+		query string
+	}{
+		{name: "tables", query: tablesQuery},
+		{name: "columns", query: columnsQuery},
+		{name: "primary keys", query: primaryKeysQuery},
+		{name: "foreign keys", query: foreignKeysQuery},
+		{name: "indexes", query: indexesQuery},
+	}
 
-	err = extractForeignKeys(db, table)
-	expectError(t, err, "extractForeignKeys query error")
-	expectNoRemaining(t, mock)
+	for failing, tt := range queries {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			mustNoError(t, err, "creating mock")
+			defer func() { _ = db.Close() }()
+
+			tables, columns, primaryKeys := tablesWithIDs("users")
+			results := []*sqlmock.Rows{tables, columns, primaryKeys, foreignKeyRows(), indexRows()}
+
+			for i, query := range queries[:failing+1] {
+				expectation := mock.ExpectQuery(regexp.QuoteMeta(query.query)).WithArgs("testdb")
+				if i == failing {
+					expectation.WillReturnError(errors.New("query failed"))
+				} else {
+					expectation.WillReturnRows(results[i])
+				}
+			}
+
+			_, err = Extract(context.Background(), db, config.Config{Database: "testdb"}, nil)
+			expectError(t, err, tt.name+" query error")
+			expectNoRemaining(t, mock)
+		})
+	}
 }
 
-func TestExtractForeignKeysScanError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+func TestGetTablesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+	}{
+		{name: "scan error", rows: tableRows().AddRow(nil, "")},
+		{name: "rows error", rows: tableRows().AddRow("users", "").RowError(0, errors.New("row error"))},
+	}
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"}).
-		AddRow(nil, "ref_table", "id", "fk_name")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        REFERENCED_TABLE_NAME,
-                        REFERENCED_COLUMN_NAME,
-                        CONSTRAINT_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND REFERENCED_TABLE_NAME IS NOT NULL
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			mustNoError(t, err, "creating mock")
+			defer func() { _ = db.Close() }()
 
-	err = extractForeignKeys(db, table)
-	expectError(t, err, "extractForeignKeys scan error")
-	expectNoRemaining(t, mock)
+			mock.ExpectQuery(regexp.QuoteMeta(tablesQuery)).WithArgs("db").WillReturnRows(tt.rows)
+
+			_, err = getTables(context.Background(), db, config.Config{Database: "db"})
+			expectError(t, err, "getTables "+tt.name)
+			expectNoRemaining(t, mock)
+		})
+	}
 }
 
-func TestExtractForeignKeysRowsError(t *testing.T) {
+func TestExtractColumnsSetsFlags(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"}).
-		AddRow("org_id", "organizations", "id", "fk_users_org").
-		RowError(0, errors.New("row error"))
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        REFERENCED_TABLE_NAME,
-                        REFERENCED_COLUMN_NAME,
-                        CONSTRAINT_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND REFERENCED_TABLE_NAME IS NOT NULL
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	tables := catalog{"users": {Name: "users"}, "groups": {Name: "groups"}}
+	rows := columnRows().
+		AddRow(column("groups", "id", "int", "NO", "PRI", "")...).
+		AddRow(column("users", "id", "int", "NO", "PRI", "primary id")...).
+		AddRow(column("users", "code", "varchar", "YES", "UNI", "unique code")...).
+		AddRow(column("users", "group_id", "int", "YES", "", "fk to groups")...)
+	mock.ExpectQuery(regexp.QuoteMeta(columnsQuery)).WithArgs("db").WillReturnRows(rows)
 
-	err = extractForeignKeys(db, table)
-	expectError(t, err, "extractForeignKeys row error")
-	expectNoRemaining(t, mock)
-}
+	err = extractColumns(context.Background(), db, scope{Database: "db"}, tables)
+	mustNoError(t, err, "extracting columns")
 
-func TestExtractTableInfoCommentError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+	expected := []Column{
+		{Name: "id", DataType: "int", ColumnType: "int", IsNullable: false, IsPrimary: true, IsUnique: false, Comment: "primary id"},
+		{Name: "code", DataType: "varchar", ColumnType: "varchar", IsNullable: true, IsPrimary: false, IsUnique: true, Comment: "unique code"},
+		{Name: "group_id", DataType: "int", ColumnType: "int", IsNullable: true, IsPrimary: false, IsUnique: false, Comment: "fk to groups"},
+	}
+	if !reflect.DeepEqual(tables["users"].Columns, expected) {
+		t.Fatalf("unexpected columns: %#v", tables["users"].Columns)
+	}
+
+	if len(tables["groups"].Columns) != 1 {
+		t.Fatalf("expected the groups column to be assigned to groups, got %#v", tables["groups"].Columns)
+	}
 
-	tableName := "users"
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        TABLE_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.TABLES
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-        `)).
-		WithArgs(tableName).
-		WillReturnError(errors.New("comment query failed"))
-
-	_, err = extractTableInfo(db, tableName)
-	expectError(t, err, "table comment query error")
 	expectNoRemaining(t, mock)
 }
 
-func TestExtractTableInfoColumnError(t *testing.T) {
+func TestExtractColumnsReadsTypeDetails(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	tableName := "users"
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        TABLE_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.TABLES
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-        `)).
-		WithArgs(tableName).
-		WillReturnRows(sqlmock.NewRows([]string{"TABLE_COMMENT"}).AddRow("comment"))
+	tables := catalog{"orders": {Name: "orders"}}
+	rows := columnRows().
+		AddRow("orders", "id", "bigint", "NO", "PRI", "", "bigint unsigned", nil, int64(20), int64(0), nil, "auto_increment", nil, nil).
+		AddRow("orders", "code", "varchar", "NO", "UNI", "", "varchar(32)", int64(32), nil, nil, "", "", "utf8mb4", "utf8mb4_bin").
+		AddRow("orders", "total", "decimal", "NO", "", "", "decimal(10,2)", nil, int64(10), int64(2), "0.00", "", nil, nil).
+		AddRow("orders", "status", "enum", "NO", "", "", "enum('new','paid')", int64(4), nil, nil, "new", "", "utf8mb4", "utf8mb4_0900_ai_ci").
+		AddRow("orders", "updated_at", "timestamp", "YES", "", "", "timestamp", nil, nil, nil, "CURRENT_TIMESTAMP", "DEFAULT_GENERATED on update CURRENT_TIMESTAMP", nil, nil)
+	mock.ExpectQuery(regexp.QuoteMeta(columnsQuery)).WithArgs("db").WillReturnRows(rows)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(tableName).
-		WillReturnError(errors.New("column extraction failed"))
+	err = extractColumns(context.Background(), db, scope{Database: "db"}, tables)
+	mustNoError(t, err, "extracting columns")
+
+	ptr := func(n int64) *int64 { return &n }
+	text := func(s string) *string { return &s }
+	expected := []Column{
+		{Name: "id", DataType: "bigint", IsPrimary: true, ColumnType: "bigint unsigned", NumericPrecision: ptr(20), NumericScale: ptr(0), Unsigned: true, Extra: "auto_increment"},
+		{Name: "code", DataType: "varchar", IsUnique: true, ColumnType: "varchar(32)", CharacterMaxLength: ptr(32), Default: text(""), CharacterSet: "utf8mb4", Collation: "utf8mb4_bin"},
+		{Name: "total", DataType: "decimal", ColumnType: "decimal(10,2)", NumericPrecision: ptr(10), NumericScale: ptr(2), Default: text("0.00")},
+		{Name: "status", DataType: "enum", ColumnType: "enum('new','paid')", CharacterMaxLength: ptr(4), EnumValues: []string{"new", "paid"}, Default: text("new"), CharacterSet: "utf8mb4", Collation: "utf8mb4_0900_ai_ci"},
+		{Name: "updated_at", DataType: "timestamp", IsNullable: true, ColumnType: "timestamp", Default: text("CURRENT_TIMESTAMP"), Extra: "DEFAULT_GENERATED on update CURRENT_TIMESTAMP"},
+	}
+	if !reflect.DeepEqual(tables["orders"].Columns, expected) {
+		t.Fatalf("unexpected columns:\n got %#v\nwant %#v", tables["orders"].Columns, expected)
+	}
 
-	_, err = extractTableInfo(db, tableName)
-	expectError(t, err, "column extraction failure")
 	expectNoRemaining(t, mock)
 }
 
-func TestExtractTableInfoPrimaryKeyError(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+func TestExtractColumnsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+	}{
+		{name: "scan error", rows: columnRows().AddRow(column("users", "id", "int", nil, "PRI", "comment")...)},
+		{name: "rows error", rows: columnRows().AddRow(column("users", "id", "int", "NO", "PRI", "comment")...).RowError(0, errors.New("row error"))},
+	}
 
-	tableName := "users"
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        TABLE_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.TABLES
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-        `)).
-		WithArgs(tableName).
-		WillReturnRows(sqlmock.NewRows([]string{"TABLE_COMMENT"}).AddRow("comment"))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			mustNoError(t, err, "creating mock")
+			defer func() { _ = db.Close() }()
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(tableName).
-		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).AddRow("id", "int", "NO", "PRI", "comment"))
-
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(tableName).
-		WillReturnError(errors.New("pk extraction failed"))
+			mock.ExpectQuery(regexp.QuoteMeta(columnsQuery)).WithArgs("db").WillReturnRows(tt.rows)
 
-	_, err = extractTableInfo(db, tableName)
-	expectError(t, err, "primary key extraction failure")
-	expectNoRemaining(t, mock)
+			err = extractColumns(context.Background(), db, scope{Database: "db"}, catalog{"users": {Name: "users"}})
+			expectError(t, err, "extractColumns "+tt.name)
+			expectNoRemaining(t, mock)
+		})
+	}
 }
 
-func TestExtractTableInfoForeignKeyError(t *testing.T) {
+func TestExtractPrimaryKeysPopulate(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	tableName := "users"
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        TABLE_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.TABLES
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-        `)).
-		WithArgs(tableName).
-		WillReturnRows(sqlmock.NewRows([]string{"TABLE_COMMENT"}).AddRow("comment"))
+	tables := catalog{"users": {Name: "users"}}
+	rows := primaryKeyRows().
+		AddRow("users", "id").
+		AddRow("users", "email").
+		AddRow("users_view", "id")
+	mock.ExpectQuery(regexp.QuoteMeta(primaryKeysQuery)).WithArgs("db").WillReturnRows(rows)
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(tableName).
-		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).AddRow("id", "int", "NO", "PRI", "comment"))
-
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(tableName).
-		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	err = extractPrimaryKeys(context.Background(), db, scope{Database: "db"}, tables)
+	mustNoError(t, err, "extracting primary keys")
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        REFERENCED_TABLE_NAME,
-                        REFERENCED_COLUMN_NAME,
-                        CONSTRAINT_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND REFERENCED_TABLE_NAME IS NOT NULL
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(tableName).
-		WillReturnError(errors.New("fk extraction failed"))
+	if want := []string{"id", "email"}; !reflect.DeepEqual(tables["users"].PrimaryKey, want) {
+		t.Fatalf("unexpected primary key: %#v", tables["users"].PrimaryKey)
+	}
 
-	_, err = extractTableInfo(db, tableName)
-	expectError(t, err, "foreign key extraction failure")
 	expectNoRemaining(t, mock)
 }
 
-func TestExtractColumnsSetsFlags(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+func TestExtractPrimaryKeysErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+	}{
+		{name: "scan error", rows: primaryKeyRows().AddRow("users", nil)},
+		{name: "rows error", rows: primaryKeyRows().AddRow("users", "id").RowError(0, errors.New("row error"))},
+	}
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).
-		AddRow("id", "int", "NO", "PRI", "primary id").
-		AddRow("code", "varchar", "YES", "UNI", "unique code").
-		AddRow("group_id", "int", "YES", "", "fk to groups")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			mustNoError(t, err, "creating mock")
+			defer func() { _ = db.Close() }()
 
-	err = extractColumns(db, table)
-	mustNoError(t, err, "extracting columns")
+			mock.ExpectQuery(regexp.QuoteMeta(primaryKeysQuery)).WithArgs("db").WillReturnRows(tt.rows)
 
-	expected := []Column{
-		{Name: "id", DataType: "int", IsNullable: false, IsPrimary: true, IsUnique: false, Comment: "primary id"},
-		{Name: "code", DataType: "varchar", IsNullable: true, IsPrimary: false, IsUnique: true, Comment: "unique code"},
-		{Name: "group_id", DataType: "int", IsNullable: true, IsPrimary: false, IsUnique: false, Comment: "fk to groups"},
+			err = extractPrimaryKeys(context.Background(), db, scope{Database: "db"}, catalog{"users": {Name: "users"}})
+			expectError(t, err, "extractPrimaryKeys "+tt.name)
+			expectNoRemaining(t, mock)
+		})
 	}
-	for i, col := range expected {
-		if table.Columns[i] != col {
-			t.Fatalf("unexpected column at %d: %#v", i, table.Columns[i])
-		}
-	}
-
-	expectNoRemaining(t, mock)
 }
 
 func TestExtractForeignKeysCaptured(t *testing.T) {
@@ -735,246 +502,133 @@ func TestExtractForeignKeysCaptured(t *testing.T) {
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	table := &Table{Name: "orders"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"}).
-		AddRow("user_id", "users", "id", "fk_orders_users").
-		AddRow("org_id", "organizations", "id", "fk_orders_orgs")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        REFERENCED_TABLE_NAME,
-                        REFERENCED_COLUMN_NAME,
-                        CONSTRAINT_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND REFERENCED_TABLE_NAME IS NOT NULL
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	tables := catalog{"orders": {Name: "orders"}, "users": {Name: "users"}}
+	rows := foreignKeyRows().
+		AddRow("orders", "user_id", "users", "id", "fk_orders_users").
+		AddRow("orders", "org_id", "organizations", "id", "fk_orders_orgs").
+		AddRow("users", "org_id", "organizations", "id", "fk_users_orgs")
+	mock.ExpectQuery(regexp.QuoteMeta(foreignKeysQuery)).WithArgs("db").WillReturnRows(rows)
 
-	err = extractForeignKeys(db, table)
+	err = extractForeignKeys(context.Background(), db, scope{Database: "db"}, tables)
 	mustNoError(t, err, "extracting foreign keys")
 
 	expected := []ForeignKey{
 		{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_orders_users"},
 		{ColumnName: "org_id", ReferencedTable: "organizations", ReferencedColumn: "id", RelationName: "fk_orders_orgs"},
 	}
-	for i, fk := range expected {
-		if table.ForeignKeys[i] != fk {
-			t.Fatalf("unexpected foreign key at %d: %#v", i, table.ForeignKeys[i])
-		}
+	if !reflect.DeepEqual(tables["orders"].ForeignKeys, expected) {
+		t.Fatalf("unexpected foreign keys: %#v", tables["orders"].ForeignKeys)
+	}
+
+	if len(tables["users"].ForeignKeys) != 1 {
+		t.Fatalf("expected one foreign key on users, got %#v", tables["users"].ForeignKeys)
 	}
 
 	expectNoRemaining(t, mock)
 }
 
-func TestExtractPrimaryKeysPopulate(t *testing.T) {
-	db, mock, err := sqlmock.New()
-	mustNoError(t, err, "creating mock")
-	defer func() { _ = db.Close() }()
+func TestExtractForeignKeysErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+	}{
+		{name: "scan error", rows: foreignKeyRows().AddRow("users", nil, "ref_table", "id", "fk_name")},
+		{name: "rows error", rows: foreignKeyRows().AddRow("users", "org_id", "organizations", "id", "fk_users_org").RowError(0, errors.New("row error"))},
+	}
 
-	table := &Table{Name: "users"}
-	rows := sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id").AddRow("email")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs(table.Name).
-		WillReturnRows(rows)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			mustNoError(t, err, "creating mock")
+			defer func() { _ = db.Close() }()
 
-	err = extractPrimaryKeys(db, table)
-	mustNoError(t, err, "extracting primary keys")
+			mock.ExpectQuery(regexp.QuoteMeta(foreignKeysQuery)).WithArgs("db").WillReturnRows(tt.rows)
 
-	expected := []string{"id", "email"}
-	for i, v := range expected {
-		if table.PrimaryKey[i] != v {
-			t.Fatalf("unexpected primary key at %d: %s", i, table.PrimaryKey[i])
-		}
+			err = extractForeignKeys(context.Background(), db, scope{Database: "db"}, catalog{"users": {Name: "users"}})
+			expectError(t, err, "extractForeignKeys "+tt.name)
+			expectNoRemaining(t, mock)
+		})
 	}
-
-	expectNoRemaining(t, mock)
 }
 
-func TestExtractHandlesMultipleTables(t *testing.T) {
+func TestExtractIndexesGroupsKeyParts(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	mustNoError(t, err, "creating mock")
 	defer func() { _ = db.Close() }()
 
-	cfg := config.Config{Database: "testdb"}
+	tables := catalog{"users": {Name: "users"}}
+	rows := indexRows().
+		AddRow("users", "PRIMARY", 0, "id", "BTREE").
+		AddRow("users", "idx_name", 1, "last_name", "BTREE").
+		AddRow("users", "idx_name", 1, "first_name", "BTREE").
+		AddRow("users", "idx_lower_email", 1, nil, "BTREE").
+		AddRow("users", "ft_bio", 1, "bio", "FULLTEXT").
+		AddRow("archived_users", "PRIMARY", 0, "id", "BTREE")
+	mock.ExpectQuery(regexp.QuoteMeta(indexesQuery)).WithArgs("db").WillReturnRows(rows)
+
+	err = extractIndexes(context.Background(), db, scope{Database: "db"}, tables)
+	mustNoError(t, err, "extracting indexes")
+
+	expected := []Index{
+		{Name: "PRIMARY", Columns: []string{"id"}, Unique: true, Type: "BTREE"},
+		{Name: "idx_name", Columns: []string{"last_name", "first_name"}, Type: "BTREE"},
+		{Name: "idx_lower_email", Columns: []string{"(expression)"}, Type: "BTREE"},
+		{Name: "ft_bio", Columns: []string{"bio"}, Type: "FULLTEXT"},
+	}
+	if !reflect.DeepEqual(tables["users"].Indexes, expected) {
+		t.Fatalf("unexpected indexes: %#v", tables["users"].Indexes)
+	}
 
-	tablesRows := sqlmock.NewRows([]string{"TABLE_NAME"}).AddRow("users").AddRow("orders")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT TABLE_NAME
-                FROM INFORMATION_SCHEMA.TABLES
-                WHERE TABLE_SCHEMA = ?
-                AND TABLE_TYPE = 'BASE TABLE'
-                ORDER BY TABLE_NAME
-        `)).
-		WithArgs(cfg.Database).
-		WillReturnRows(tablesRows)
-
-	// users table setup
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        TABLE_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.TABLES
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-        `)).
-		WithArgs("users").
-		WillReturnRows(sqlmock.NewRows([]string{"TABLE_COMMENT"}).AddRow("users table"))
-
-	usersColumns := sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).
-		AddRow("id", "int", "NO", "PRI", "id").
-		AddRow("name", "varchar", "YES", "", "name")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs("users").
-		WillReturnRows(usersColumns)
+	expectNoRemaining(t, mock)
+}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs("users").
-		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+func TestCatalogEdges(t *testing.T) {
+	tables := catalog{
+		"orders": {Name: "orders", ForeignKeys: []ForeignKey{
+			{ColumnName: "user_id", ReferencedTable: "users"},
+			{ColumnName: "shop_id", ReferencedTable: "shops"},
+		}},
+		"users": {Name: "users"},
+	}
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        REFERENCED_TABLE_NAME,
-                        REFERENCED_COLUMN_NAME,
-                        CONSTRAINT_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND REFERENCED_TABLE_NAME IS NOT NULL
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs("users").
-		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"}))
+	want := []foreignKeyEdge{{Table: "orders", Referenced: "users"}, {Table: "orders", Referenced: "shops"}}
+	if got := tables.edges([]string{"orders", "users"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("edges() = %#v, want %#v", got, want)
+	}
+}
 
-	// orders table setup
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        TABLE_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.TABLES
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-        `)).
-		WithArgs("orders").
-		WillReturnRows(sqlmock.NewRows([]string{"TABLE_COMMENT"}).AddRow("orders table"))
-
-	ordersColumns := sqlmock.NewRows([]string{"COLUMN_NAME", "DATA_TYPE", "IS_NULLABLE", "COLUMN_KEY", "COLUMN_COMMENT"}).
-		AddRow("id", "int", "NO", "PRI", "id").
-		AddRow("user_id", "int", "NO", "", "user id")
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        DATA_TYPE,
-                        IS_NULLABLE,
-                        COLUMN_KEY,
-                        COLUMN_COMMENT
-                FROM
-                        INFORMATION_SCHEMA.COLUMNS
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs("orders").
-		WillReturnRows(ordersColumns)
+func TestExtractReportsProgress(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND CONSTRAINT_NAME = 'PRIMARY'
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs("orders").
-		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME"}).AddRow("id"))
+	tables, columns, primaryKeys := tablesWithIDs("orders", "users")
+	expectCatalog(mock, "testdb", tables, columns, primaryKeys, foreignKeyRows())
 
-	mock.ExpectQuery(regexp.QuoteMeta(`
-                SELECT
-                        COLUMN_NAME,
-                        REFERENCED_TABLE_NAME,
-                        REFERENCED_COLUMN_NAME,
-                        CONSTRAINT_NAME
-                FROM
-                        INFORMATION_SCHEMA.KEY_COLUMN_USAGE
-                WHERE
-                        TABLE_SCHEMA = DATABASE()
-                        AND TABLE_NAME = ?
-                        AND REFERENCED_TABLE_NAME IS NOT NULL
-                ORDER BY
-                        ORDINAL_POSITION
-        `)).
-		WithArgs("orders").
-		WillReturnRows(sqlmock.NewRows([]string{"COLUMN_NAME", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME", "CONSTRAINT_NAME"}).AddRow("user_id", "users", "id", "fk_orders_users"))
+	var got []string
+	progress := func(step string, done, total int) {
+		got = append(got, fmt.Sprintf("%s %d/%d", step, done, total))
+	}
 
-	schema, err := Extract(db, cfg)
+	_, err = Extract(context.Background(), db, config.Config{Database: "testdb"}, progress)
 	mustNoError(t, err, "extracting schema")
 
-	if len(schema.Tables) != 2 {
-		t.Fatalf("expected 2 tables, got %d", len(schema.Tables))
+	want := []string{"queries 1/5", "queries 2/5", "queries 3/5", "queries 4/5", "queries 5/5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("progress = %q, want %q", got, want)
 	}
-	if schema.Tables[0].Name != "users" || schema.Tables[1].Name != "orders" {
-		t.Fatalf("unexpected table order: %#v", []string{schema.Tables[0].Name, schema.Tables[1].Name})
-	}
-	expectedFKs := []ForeignKey{{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_orders_users"}}
-	if len(schema.Tables[1].ForeignKeys) != 1 || schema.Tables[1].ForeignKeys[0] != expectedFKs[0] {
-		t.Fatalf("unexpected foreign keys: %#v", schema.Tables[1].ForeignKeys)
-	}
-
-	expectNoRemaining(t, mock)
 }
 
-var _ = sql.DB{}
+func TestExtractStopsWhenContextIsCancelled(t *testing.T) {
+	db, _, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = Extract(ctx, db, config.Config{Database: "testdb"}, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancellation error, got %v", err)
+	}
+}
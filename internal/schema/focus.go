@@ -0,0 +1,94 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/motchang/marid/internal/config"
+)
+
+// foreignKeyEdge is a table-level foreign key: Table references Referenced.
+type foreignKeyEdge struct {
+	Table      string
+	Referenced string
+}
+
+// focusTables narrows candidates to the tables reachable from cfg.Focus within
+// cfg.Depth foreign-key hops. Only candidates take part in the walk, so a table
+// removed by --exclude also stops the walk from passing through it. The result
+// keeps the order of candidates.
+func focusTables(cfg config.Config, candidates []string, edges []foreignKeyEdge) ([]string, error) {
+	for _, name := range cfg.Focus {
+		if !contains(candidates, name) {
+			return nil, fmt.Errorf("focus table %q is not among the selected tables of database %q", name, cfg.Database)
+		}
+	}
+
+	if cfg.Depth < 0 {
+		return nil, fmt.Errorf("focus depth must not be negative, got %d", cfg.Depth)
+	}
+
+	neighbours, err := focusNeighbours(edges, cfg.FocusDirection)
+	if err != nil {
+		return nil, err
+	}
+
+	reached := make(map[string]bool, len(cfg.Focus))
+	frontier := make([]string, 0, len(cfg.Focus))
+	for _, name := range cfg.Focus {
+		if !reached[name] {
+			reached[name] = true
+			frontier = append(frontier, name)
+		}
+	}
+
+	for hop := 0; hop < cfg.Depth && len(frontier) > 0; hop++ {
+		var next []string
+		for _, name := range frontier {
+			for _, neighbour := range neighbours[name] {
+				if reached[neighbour] || !contains(candidates, neighbour) {
+					continue
+				}
+				reached[neighbour] = true
+				next = append(next, neighbour)
+			}
+		}
+		frontier = next
+	}
+
+	var tables []string
+	for _, name := range candidates {
+		if reached[name] {
+			tables = append(tables, name)
+		}
+	}
+
+	return tables, nil
+}
+
+// focusNeighbours builds the adjacency list the focus walk follows.
+func focusNeighbours(edges []foreignKeyEdge, direction string) (map[string][]string, error) {
+	followReferenced, followReferencing := true, true
+
+	switch direction {
+	case "", config.FocusBoth:
+	case config.FocusReferenced:
+		followReferencing = false
+	case config.FocusReferencing:
+		followReferenced = false
+	default:
+		return nil, fmt.Errorf("unknown focus direction %q (want %s, %s or %s)",
+			direction, config.FocusBoth, config.FocusReferencing, config.FocusReferenced)
+	}
+
+	neighbours := make(map[string][]string)
+	for _, edge := range edges {
+		if followReferenced {
+			neighbours[edge.Table] = append(neighbours[edge.Table], edge.Referenced)
+		}
+		if followReferencing {
+			neighbours[edge.Referenced] = append(neighbours[edge.Referenced], edge.Table)
+		}
+	}
+
+	return neighbours, nil
+}
@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/motchang/marid/internal/config"
+)
+
+// shopEdges is the foreign key graph
+//
+//	customers <- orders <- order_items -> products <- inventory
+//	orders -> addresses
+//
+// so each test can walk it in a different direction and depth.
+var shopEdges = []foreignKeyEdge{
+	{Table: "inventory", Referenced: "products"},
+	{Table: "order_items", Referenced: "orders"},
+	{Table: "order_items", Referenced: "products"},
+	{Table: "orders", Referenced: "customers"},
+	{Table: "orders", Referenced: "addresses"},
+}
+
+var shopTables = []string{"addresses", "customers", "inventory", "order_items", "orders", "products"}
+
+func TestFocusTablesWalksTheForeignKeyGraph(t *testing.T) {
+	tests := []struct {
+		name       string
+		focus      []string
+		depth      int
+		direction  string
+		candidates []string
+		want       []string
+	}{
+		{
+			name:  "depth zero keeps only the focus table",
+			focus: []string{"orders"},
+			depth: 0,
+			want:  []string{"orders"},
+		},
+		{
+			name:  "one hop in both directions",
+			focus: []string{"orders"},
+			depth: 1,
+			want:  []string{"addresses", "customers", "order_items", "orders"},
+		},
+		{
+			name:      "explicit both matches the default",
+			focus:     []string{"orders"},
+			depth:     1,
+			direction: config.FocusBoth,
+			want:      []string{"addresses", "customers", "order_items", "orders"},
+		},
+		{
+			name:  "two hops reach transitively connected tables",
+			focus: []string{"orders"},
+			depth: 2,
+			want:  []string{"addresses", "customers", "order_items", "orders", "products"},
+		},
+		{
+			name:      "referenced direction only follows outgoing keys",
+			focus:     []string{"order_items"},
+			depth:     3,
+			direction: config.FocusReferenced,
+			want:      []string{"addresses", "customers", "order_items", "orders", "products"},
+		},
+		{
+			name:      "referencing direction only follows incoming keys",
+			focus:     []string{"products"},
+			depth:     3,
+			direction: config.FocusReferencing,
+			want:      []string{"inventory", "order_items", "products"},
+		},
+		{
+			name:  "several focus tables",
+			focus: []string{"customers", "inventory"},
+			depth: 1,
+			want:  []string{"customers", "inventory", "orders", "products"},
+		},
+		{
+			name:       "tables outside the candidates block the walk",
+			focus:      []string{"customers"},
+			depth:      3,
+			candidates: []string{"customers", "order_items", "products"},
+			want:       []string{"customers"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			candidates := tt.candidates
+			if candidates == nil {
+				candidates = shopTables
+			}
+
+			cfg := config.Config{Database: "shop", Focus: tt.focus, Depth: tt.depth, FocusDirection: tt.direction}
+			got, err := focusTables(cfg, candidates, shopEdges)
+			mustNoError(t, err, "walking focus graph")
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("focusTables() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFocusTablesRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+	}{
+		{
+			name: "unknown focus table",
+			cfg:  config.Config{Database: "shop", Focus: []string{"ghosts"}, Depth: 1},
+		},
+		{
+			name: "negative depth",
+			cfg:  config.Config{Database: "shop", Focus: []string{"orders"}, Depth: -1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := focusTables(tt.cfg, shopTables, shopEdges)
+			expectError(t, err, tt.name)
+		})
+	}
+}
+
+func TestFocusTablesRejectsUnknownDirection(t *testing.T) {
+	cfg := config.Config{Database: "shop", Focus: []string{"orders"}, Depth: 1, FocusDirection: "sideways"}
+	_, err := focusTables(cfg, shopTables, shopEdges)
+	expectError(t, err, "unknown direction")
+}
+
+func TestExtractRestrictsToFocusNeighbourhood(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	cfg := config.Config{Database: "shop", Focus: []string{"orders"}, Depth: 1}
+
+	tables, columns, primaryKeys := tablesWithIDs("customers", "orders", "products")
+	expectCatalog(mock, "shop", tables, columns, primaryKeys,
+		foreignKeyRows().AddRow("orders", "customer_id", "customers", "id", "fk_customer"))
+
+	schema, err := Extract(context.Background(), db, cfg, nil)
+	mustNoError(t, err, "extracting schema")
+
+	// The walk follows the foreign keys read with the rest of the schema.
+	if len(schema.Tables) != 2 || schema.Tables[0].Name != "customers" || schema.Tables[1].Name != "orders" {
+		t.Fatalf("expected the focus table and its neighbour, got %#v", schema.Tables)
+	}
+
+	if schema.Tables[0].Focus || !schema.Tables[1].Focus {
+		t.Errorf("expected only the focus table to be marked")
+	}
+
+	expectNoRemaining(t, mock)
+}
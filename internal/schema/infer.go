@@ -0,0 +1,183 @@
+package schema
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultInferencePatterns are used when relationship inference is enabled
+// without explicit patterns: user_id points at the primary key of users (or
+// of a table literally named user).
+var DefaultInferencePatterns = []string{"{name}_id"}
+
+// namePlaceholder captures the referenced table's name in an inference pattern.
+const namePlaceholder = "{name}"
+
+// InferenceRule proposes a relationship for every column whose name matches
+// the column side of an inference pattern.
+//
+// Patterns have the form COLUMN or COLUMN=TABLE.COLUMN. The column side must
+// contain {name}, which captures part of the column name. The optional target
+// names the referenced table and column, where {name} is the captured text and
+// {plural} its English plural. Without a target, the rule looks for a table
+// called {name} or {plural} and references its single-column primary key.
+type InferenceRule struct {
+	pattern      string
+	column       *regexp.Regexp
+	targetTable  string
+	targetColumn string
+}
+
+// CompileInferenceRules parses inference patterns.
+func CompileInferenceRules(patterns []string) ([]InferenceRule, error) {
+	rules := make([]InferenceRule, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		columnSide, target, hasTarget := strings.Cut(pattern, "=")
+
+		if strings.Count(columnSide, namePlaceholder) != 1 {
+			return nil, fmt.Errorf("inference pattern %q must contain %s exactly once before any =", pattern, namePlaceholder)
+		}
+
+		prefix, suffix, _ := strings.Cut(columnSide, namePlaceholder)
+		rule := InferenceRule{
+			pattern: pattern,
+			column:  regexp.MustCompile("(?i)^" + regexp.QuoteMeta(prefix) + "(.+)" + regexp.QuoteMeta(suffix) + "$"),
+		}
+
+		if hasTarget {
+			table, column, ok := strings.Cut(target, ".")
+			if !ok || table == "" || column == "" {
+				return nil, fmt.Errorf("inference pattern %q must name its target as TABLE.COLUMN", pattern)
+			}
+			rule.targetTable, rule.targetColumn = table, column
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+// String returns the pattern the rule was compiled from.
+func (r InferenceRule) String() string {
+	return r.pattern
+}
+
+// inferRelationships adds a foreign key, marked Inferred, for every column of
+// the named catalog tables that an inference rule links to another of them. A
+// candidate is only accepted when the referenced column exists and has the
+// same data type. Columns that already carry a declared foreign key are
+// skipped, and the first matching rule wins.
+func (c catalog) inferRelationships(names []string, rules []InferenceRule) {
+	byName := make(map[string]*Table, len(names))
+	for _, name := range names {
+		byName[strings.ToLower(name)] = c[name]
+	}
+
+	for _, name := range names {
+		table := c[name]
+		declared := make(map[string]bool, len(table.ForeignKeys))
+		for _, fk := range table.ForeignKeys {
+			declared[fk.ColumnName] = true
+		}
+
+		for _, column := range table.Columns {
+			if declared[column.Name] {
+				continue
+			}
+
+			for _, rule := range rules {
+				target, targetColumn, ok := rule.resolve(column.Name, byName)
+				if !ok || (target.Name == table.Name && targetColumn.Name == column.Name) {
+					continue
+				}
+
+				if !strings.EqualFold(targetColumn.DataType, column.DataType) {
+					continue
+				}
+
+				table.ForeignKeys = append(table.ForeignKeys, ForeignKey{
+					ColumnName:       column.Name,
+					ReferencedTable:  target.Name,
+					ReferencedColumn: targetColumn.Name,
+					RelationName:     column.Name,
+					Inferred:         true,
+				})
+				break
+			}
+		}
+	}
+}
+
+// resolve finds the table and column a column name points at under the rule.
+func (r InferenceRule) resolve(columnName string, tables map[string]*Table) (*Table, Column, bool) {
+	match := r.column.FindStringSubmatch(columnName)
+	if match == nil {
+		return nil, Column{}, false
+	}
+
+	name := match[1]
+	expand := strings.NewReplacer(namePlaceholder, name, "{plural}", pluralize(name))
+
+	if r.targetTable != "" {
+		target, ok := tables[strings.ToLower(expand.Replace(r.targetTable))]
+		if !ok {
+			return nil, Column{}, false
+		}
+
+		column, ok := findColumn(*target, expand.Replace(r.targetColumn))
+		return target, column, ok
+	}
+
+	for _, candidate := range []string{name, pluralize(name)} {
+		target, ok := tables[strings.ToLower(candidate)]
+		if !ok || len(target.PrimaryKey) != 1 {
+			continue
+		}
+
+		if column, ok := findColumn(*target, target.PrimaryKey[0]); ok {
+			return target, column, true
+		}
+	}
+
+	return nil, Column{}, false
+}
+
+func findColumn(table Table, name string) (Column, bool) {
+	for _, column := range table.Columns {
+		if strings.EqualFold(column.Name, name) {
+			return column, true
+		}
+	}
+	return Column{}, false
+}
+
+// irregularPlurals covers common table nouns the suffix rules get wrong.
+var irregularPlurals = map[string]string{
+	"person": "people",
+	"child":  "children",
+	"man":    "men",
+	"woman":  "women",
+}
+
+// pluralize returns a naive English plural, which is how tables are usually
+// named after the entity their foreign key columns mention.
+func pluralize(word string) string {
+	lower := strings.ToLower(word)
+
+	if plural, ok := irregularPlurals[lower]; ok {
+		return plural
+	}
+
+	switch {
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return word[:len(word)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return word + "es"
+	default:
+		return word + "s"
+	}
+}
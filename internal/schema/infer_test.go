@@ -0,0 +1,218 @@
+package schema
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/motchang/marid/internal/config"
+)
+
+// legacyTables is a schema without declared foreign keys.
+func legacyTables() []Table {
+	return []Table{
+		{
+			Name:       "categories",
+			Columns:    []Column{{Name: "id", DataType: "int", IsPrimary: true}},
+			PrimaryKey: []string{"id"},
+		},
+		{
+			Name: "order_items",
+			Columns: []Column{
+				{Name: "order_id", DataType: "bigint"},
+				{Name: "product_code", DataType: "varchar"},
+			},
+			PrimaryKey: []string{"order_id", "product_code"},
+		},
+		{
+			Name: "orders",
+			Columns: []Column{
+				{Name: "id", DataType: "bigint", IsPrimary: true},
+				{Name: "user_id", DataType: "int"},
+				{Name: "session_id", DataType: "varchar"},
+			},
+			PrimaryKey: []string{"id"},
+		},
+		{
+			Name: "products",
+			Columns: []Column{
+				{Name: "code", DataType: "varchar", IsPrimary: true},
+				{Name: "category_id", DataType: "int"},
+			},
+			PrimaryKey: []string{"code"},
+		},
+		{
+			Name:       "users",
+			Columns:    []Column{{Name: "id", DataType: "bigint", IsPrimary: true}},
+			PrimaryKey: []string{"id"},
+		},
+	}
+}
+
+func inferredKeys(tables []Table) map[string][]ForeignKey {
+	keys := make(map[string][]ForeignKey)
+	for _, table := range tables {
+		if len(table.ForeignKeys) > 0 {
+			keys[table.Name] = table.ForeignKeys
+		}
+	}
+	return keys
+}
+
+// inferAll runs inference over every table, updating them in place.
+func inferAll(tables []Table, rules []InferenceRule) {
+	c := make(catalog, len(tables))
+	names := make([]string, 0, len(tables))
+	for i := range tables {
+		c[tables[i].Name] = &tables[i]
+		names = append(names, tables[i].Name)
+	}
+	c.inferRelationships(names, rules)
+}
+
+func TestInferRelationshipsWithDefaultPattern(t *testing.T) {
+	rules, err := CompileInferenceRules(DefaultInferencePatterns)
+	mustNoError(t, err, "compiling rules")
+
+	tables := legacyTables()
+	inferAll(tables, rules)
+
+	// user_id is int while users.id is bigint, so it is not proposed; there
+	// is no sessions table for session_id.
+	want := map[string][]ForeignKey{
+		"order_items": {{ColumnName: "order_id", ReferencedTable: "orders", ReferencedColumn: "id", RelationName: "order_id", Inferred: true}},
+		"products":    {{ColumnName: "category_id", ReferencedTable: "categories", ReferencedColumn: "id", RelationName: "category_id", Inferred: true}},
+	}
+
+	if got := inferredKeys(tables); !reflect.DeepEqual(got, want) {
+		t.Errorf("inferred %+v, want %+v", got, want)
+	}
+}
+
+func TestInferRelationshipsWithExplicitTarget(t *testing.T) {
+	rules, err := CompileInferenceRules([]string{"product_{name}={plural}.{name}", "{name}_id"})
+	mustNoError(t, err, "compiling rules")
+
+	tables := append(legacyTables(), Table{Name: "codes", Columns: []Column{{Name: "code", DataType: "varchar"}}})
+	inferAll(tables, rules)
+
+	want := []ForeignKey{
+		{ColumnName: "order_id", ReferencedTable: "orders", ReferencedColumn: "id", RelationName: "order_id", Inferred: true},
+		{ColumnName: "product_code", ReferencedTable: "codes", ReferencedColumn: "code", RelationName: "product_code", Inferred: true},
+	}
+	if got := inferredKeys(tables)["order_items"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("inferred %+v, want %+v", got, want)
+	}
+}
+
+func TestInferRelationshipsKeepsDeclaredKeys(t *testing.T) {
+	rules, err := CompileInferenceRules(DefaultInferencePatterns)
+	mustNoError(t, err, "compiling rules")
+
+	declared := ForeignKey{ColumnName: "category_id", ReferencedTable: "categories", ReferencedColumn: "id", RelationName: "fk_category"}
+	tables := legacyTables()
+	tables[3].ForeignKeys = []ForeignKey{declared}
+	inferAll(tables, rules)
+
+	if got := tables[3].ForeignKeys; !reflect.DeepEqual(got, []ForeignKey{declared}) {
+		t.Errorf("declared key changed: %+v", got)
+	}
+}
+
+func TestInferRelationshipsIgnoresSelfReferenceOfKey(t *testing.T) {
+	rules, err := CompileInferenceRules([]string{"{name}"})
+	mustNoError(t, err, "compiling rules")
+
+	tables := []Table{{Name: "id", Columns: []Column{{Name: "id", DataType: "int"}}, PrimaryKey: []string{"id"}}}
+	inferAll(tables, rules)
+
+	if len(tables[0].ForeignKeys) != 0 {
+		t.Errorf("expected a key not to reference itself, got %+v", tables[0].ForeignKeys)
+	}
+}
+
+func TestCompileInferenceRulesRejectsMalformedPatterns(t *testing.T) {
+	for _, pattern := range []string{"user_id", "{name}_{name}", "{name}_id=users", "{name}_id=.id", "x=users.{name}"} {
+		if _, err := CompileInferenceRules([]string{pattern}); err == nil {
+			t.Errorf("expected pattern %q to be rejected", pattern)
+		}
+	}
+}
+
+func TestPluralize(t *testing.T) {
+	tests := map[string]string{
+		"user":     "users",
+		"category": "categories",
+		"day":      "days",
+		"address":  "addresses",
+		"box":      "boxes",
+		"batch":    "batches",
+		"person":   "people",
+		"Company":  "Companies",
+	}
+
+	for in, want := range tests {
+		if got := pluralize(in); got != want {
+			t.Errorf("pluralize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestExtractInfersRelationships(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	cfg := config.Config{Database: "shop", Tables: "orders,users", InferRelations: true}
+
+	expectCatalog(mock, "shop",
+		tableRows().AddRow("orders", "").AddRow("users", ""),
+		columnRows().
+			AddRow(column("orders", "id", "int", "NO", "PRI", "")...).
+			AddRow(column("orders", "user_id", "int", "NO", "", "")...).
+			AddRow(column("users", "id", "int", "NO", "PRI", "")...),
+		primaryKeyRows().AddRow("orders", "id").AddRow("users", "id"),
+		foreignKeyRows())
+
+	schema, err := Extract(context.Background(), db, cfg, nil)
+	mustNoError(t, err, "extracting schema")
+	expectNoRemaining(t, mock)
+
+	want := []string{"orders.user_id -> users.id"}
+	if got := schema.InferredRelationships(); !slices.Equal(got, want) {
+		t.Errorf("InferredRelationships() = %q, want %q", got, want)
+	}
+}
+
+func TestExtractFocusFollowsInferredRelationships(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	cfg := config.Config{Database: "shop", Focus: []string{"orders"}, Depth: 1, InferRelations: true}
+
+	tables, columns, primaryKeys := tablesWithIDs("customers", "orders", "products")
+	columns.AddRow(column("orders", "customer_id", "int", "NO", "", "")...)
+	expectCatalog(mock, "shop", tables, columns, primaryKeys, foreignKeyRows())
+
+	schema, err := Extract(context.Background(), db, cfg, nil)
+	mustNoError(t, err, "extracting schema")
+	expectNoRemaining(t, mock)
+
+	// Only the inferred orders.customer_id links orders to a neighbour.
+	var names []string
+	for _, table := range schema.Tables {
+		names = append(names, table.Name)
+	}
+	if want := []string{"customers", "orders"}; !slices.Equal(names, want) {
+		t.Errorf("tables = %q, want %q", names, want)
+	}
+
+	want := []string{"orders.customer_id -> customers.id"}
+	if got := schema.InferredRelationships(); !slices.Equal(got, want) {
+		t.Errorf("InferredRelationships() = %q, want %q", got, want)
+	}
+}
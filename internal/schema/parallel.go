@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+// loadTablesInParallel fills in the named catalog tables with per-table
+// queries run by a pool of workers. Each table is written by the one worker
+// that loads it, so the catalog needs no locking and the caller keeps control
+// of table order. The first error cancels the remaining work and is returned.
+func loadTablesInParallel(ctx context.Context, db *sql.DB, database string, tables catalog, names []string, workers int, progress ProgressFunc) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+		done     int
+	)
+
+	jobs := make(chan string)
+	for range min(workers, len(names)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				err := loadTable(ctx, db, scope{Database: database, Table: name}, tables)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					done++
+					progress("tables", done, len(names))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, name := range names {
+		select {
+		case jobs <- name:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// loadTable runs the detail queries for a single table.
+func loadTable(ctx context.Context, db *sql.DB, scope scope, tables catalog) error {
+	for _, query := range detailQueries {
+		if err := query(ctx, db, scope, tables); err != nil {
+			return fmt.Errorf("table %s: %w", scope.Table, err)
+		}
+	}
+	return nil
+}
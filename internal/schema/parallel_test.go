@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/motchang/marid/internal/config"
+)
+
+// tableScoped narrows a detail query to a single table, as the workers run it.
+func tableScoped(query string) string {
+	return regexp.QuoteMeta(strings.Replace(query, "TABLE_SCHEMA = ?", "TABLE_SCHEMA = ? AND TABLE_NAME = ?", 1))
+}
+
+// expectTableDetails registers the per-table queries for a table with an int
+// id primary key.
+func expectTableDetails(mock sqlmock.Sqlmock, database, table string, foreignKeys *sqlmock.Rows) {
+	mock.ExpectQuery(tableScoped(columnsQuery)).WithArgs(database, table).
+		WillReturnRows(columnRows().AddRow(column(table, "id", "int", "NO", "PRI", "")...))
+	mock.ExpectQuery(tableScoped(primaryKeysQuery)).WithArgs(database, table).
+		WillReturnRows(primaryKeyRows().AddRow(table, "id"))
+	mock.ExpectQuery(tableScoped(foreignKeysQuery)).WithArgs(database, table).
+		WillReturnRows(foreignKeys)
+	mock.ExpectQuery(tableScoped(indexesQuery)).WithArgs(database, table).
+		WillReturnRows(indexRows())
+}
+
+func TestExtractConcurrentlyKeepsTableOrder(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+	mock.MatchExpectationsInOrder(false)
+
+	names := []string{"accounts", "invoices", "orders", "products", "users"}
+	tables, _, _ := tablesWithIDs(names...)
+	mock.ExpectQuery(regexp.QuoteMeta(tablesQuery)).WithArgs("shop").WillReturnRows(tables)
+	for _, name := range names {
+		foreignKeys := foreignKeyRows()
+		if name == "orders" {
+			foreignKeys.AddRow("orders", "user_id", "users", "id", "fk_orders_users")
+		}
+		expectTableDetails(mock, "shop", name, foreignKeys)
+	}
+
+	var (
+		mu    sync.Mutex
+		steps []string
+	)
+	progress := func(step string, done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		steps = append(steps, step)
+		if done > total {
+			t.Errorf("progress %d/%d overshoots", done, total)
+		}
+	}
+
+	schema, err := Extract(context.Background(), db, config.Config{Database: "shop", Concurrency: 3}, progress)
+	mustNoError(t, err, "extracting schema")
+
+	var got []string
+	for _, table := range schema.Tables {
+		got = append(got, table.Name)
+		if len(table.Columns) != 1 || !reflect.DeepEqual(table.PrimaryKey, []string{"id"}) {
+			t.Errorf("table %s not filled in: %+v", table.Name, table)
+		}
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("tables = %v, want %v", got, names)
+	}
+
+	if fks := schema.Tables[2].ForeignKeys; len(fks) != 1 || fks[0].ReferencedTable != "users" {
+		t.Errorf("orders foreign keys = %+v", fks)
+	}
+
+	// Workers report one step per table.
+	if want := []string{"tables", "tables", "tables", "tables", "tables"}; !reflect.DeepEqual(steps, want) {
+		t.Errorf("progress steps = %v, want %v", steps, want)
+	}
+
+	expectNoRemaining(t, mock)
+}
+
+func TestExtractConcurrentlyReturnsFirstError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+	mock.MatchExpectationsInOrder(false)
+
+	tables, _, _ := tablesWithIDs("a", "b", "c", "d")
+	mock.ExpectQuery(regexp.QuoteMeta(tablesQuery)).WithArgs("db").WillReturnRows(tables)
+	mock.ExpectQuery(tableScoped(columnsQuery)).WithArgs("db", "a").WillReturnError(errors.New("query failed"))
+	for _, name := range []string{"b", "c", "d"} {
+		expectTableDetails(mock, "db", name, foreignKeyRows())
+	}
+
+	_, err = Extract(context.Background(), db, config.Config{Database: "db", Concurrency: 2}, nil)
+	if err == nil || !strings.Contains(err.Error(), "table a") || !strings.Contains(err.Error(), "query failed") {
+		t.Fatalf("expected the failing table's error, got %v", err)
+	}
+	// The other workers are cancelled, so some expectations may be left.
+}
+
+func TestExtractConcurrentlyLoadsEachTableOnce(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	// A single worker keeps the queries in order.
+	tables, _, _ := tablesWithIDs("customers", "orders")
+	mock.ExpectQuery(regexp.QuoteMeta(tablesQuery)).WithArgs("shop").WillReturnRows(tables)
+	expectTableDetails(mock, "shop", "customers", foreignKeyRows())
+	expectTableDetails(mock, "shop", "orders",
+		foreignKeyRows().AddRow("orders", "customer_id", "customers", "id", "fk_customer"))
+
+	// The focus walk loads both tables, so including the referenced one
+	// reuses what was read.
+	cfg := config.Config{
+		Database:     "shop",
+		Focus:        []string{"orders"},
+		ExternalRefs: config.ExternalInclude,
+		Concurrency:  1,
+	}
+	schema, err := Extract(context.Background(), db, cfg, nil)
+	mustNoError(t, err, "extracting schema")
+
+	if len(schema.Tables) != 2 || schema.Tables[0].Name != "customers" || len(schema.Tables[0].Columns) != 1 {
+		t.Fatalf("unexpected tables: %+v", schema.Tables)
+	}
+
+	expectNoRemaining(t, mock)
+}
+
+func TestExtractRejectsNegativeConcurrency(t *testing.T) {
+	db, _, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	_, err = Extract(context.Background(), db, config.Config{Database: "db", Concurrency: -1}, nil)
+	expectError(t, err, "negative concurrency")
+}
@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/motchang/marid/internal/config"
+	"github.com/motchang/marid/pkg/utils"
+)
+
+// tableSelector decides which tables are extracted. Tables named in --tables
+// and tables matching an --include pattern are selected (every table when
+// neither is given), and anything matching an --exclude pattern is then
+// removed.
+type tableSelector struct {
+	explicit []string
+	include  []utils.Pattern
+	exclude  []utils.Pattern
+}
+
+func newTableSelector(cfg config.Config) (*tableSelector, error) {
+	include, err := utils.CompilePatterns(cfg.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --include pattern: %w", err)
+	}
+
+	exclude, err := utils.CompilePatterns(cfg.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --exclude pattern: %w", err)
+	}
+
+	return &tableSelector{
+		explicit: cfg.GetTablesList(),
+		include:  include,
+		exclude:  exclude,
+	}, nil
+}
+
+func (s *tableSelector) selects(tableName string) bool {
+	if utils.MatchAny(s.exclude, tableName) {
+		return false
+	}
+
+	if len(s.explicit) == 0 && len(s.include) == 0 {
+		return true
+	}
+
+	return contains(s.explicit, tableName) || utils.MatchAny(s.include, tableName)
+}
+
+// warnings reports selection criteria that did not do what the user asked
+// for: explicitly named tables that are missing or excluded, and include
+// patterns that matched nothing.
+func (s *tableSelector) warnings(database string, available []string) []string {
+	var warnings []string
+
+	for _, name := range s.explicit {
+		switch {
+		case !contains(available, name):
+			warnings = append(warnings, fmt.Sprintf("table %q listed in --tables does not exist in database %q", name, database))
+		case utils.MatchAny(s.exclude, name):
+			warnings = append(warnings, fmt.Sprintf("table %q listed in --tables is removed by --exclude", name))
+		}
+	}
+
+	for _, pattern := range s.include {
+		matched := false
+		for _, name := range available {
+			if pattern.Match(name) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			warnings = append(warnings, fmt.Sprintf("--include pattern %q matched no tables in database %q", pattern, database))
+		}
+	}
+
+	return warnings
+}
+
+func contains(values []string, target string) bool {
+	for _, v := range values {
+		if v == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+
+	"github.com/motchang/marid/internal/config"
+)
+
+func TestTableSelectorSelects(t *testing.T) {
+	available := []string{"billing_invoices", "billing_payments", "orders", "tmp_import", "users", "users_old"}
+
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want []string
+	}{
+		{
+			name: "no criteria selects everything",
+			cfg:  config.Config{},
+			want: available,
+		},
+		{
+			name: "explicit list only",
+			cfg:  config.Config{Tables: "orders,users"},
+			want: []string{"orders", "users"},
+		},
+		{
+			name: "include glob",
+			cfg:  config.Config{Include: []string{"billing_*"}},
+			want: []string{"billing_invoices", "billing_payments"},
+		},
+		{
+			name: "explicit list and include are combined",
+			cfg:  config.Config{Tables: "users", Include: []string{"billing_*"}},
+			want: []string{"billing_invoices", "billing_payments", "users"},
+		},
+		{
+			name: "exclude narrows the full schema",
+			cfg:  config.Config{Exclude: []string{"tmp_*", "re:_old$"}},
+			want: []string{"billing_invoices", "billing_payments", "orders", "users"},
+		},
+		{
+			name: "exclude wins over include",
+			cfg:  config.Config{Include: []string{"re:^(billing|users)"}, Exclude: []string{"re:_old$", "billing_payments"}},
+			want: []string{"billing_invoices", "users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, err := newTableSelector(tt.cfg)
+			mustNoError(t, err, "building selector")
+
+			var got []string
+			for _, name := range available {
+				if selector.selects(name) {
+					got = append(got, name)
+				}
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTableSelectorRejectsInvalidPatterns(t *testing.T) {
+	if _, err := newTableSelector(config.Config{Include: []string{"re:("}}); err == nil {
+		t.Error("expected an invalid include pattern to be rejected")
+	}
+
+	if _, err := newTableSelector(config.Config{Exclude: []string{"[a-"}}); err == nil {
+		t.Error("expected an invalid exclude pattern to be rejected")
+	}
+}
+
+func TestTableSelectorWarnings(t *testing.T) {
+	selector, err := newTableSelector(config.Config{
+		Tables:  "users,ghosts,users_old",
+		Include: []string{"billing_*", "audit_*"},
+		Exclude: []string{"re:_old$"},
+	})
+	mustNoError(t, err, "building selector")
+
+	got := selector.warnings("shop", []string{"billing_invoices", "users", "users_old"})
+	want := []string{
+		`table "ghosts" listed in --tables does not exist in database "shop"`,
+		`table "users_old" listed in --tables is removed by --exclude`,
+		`--include pattern "audit_*" matched no tables in database "shop"`,
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("warnings() = %q, want %q", got, want)
+	}
+}
+
+func TestGetTablesAppliesPatternsAndReportsMissingTables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	cfg := config.Config{Database: "db", Tables: "users,customers", Include: []string{"billing_*"}, Exclude: []string{"tmp_*"}}
+	rows := tableRows().
+		AddRow("billing_invoices", "").
+		AddRow("orders", "").
+		AddRow("tmp_billing", "").
+		AddRow("users", "Registered users")
+	mock.ExpectQuery(regexp.QuoteMeta(tablesQuery)).
+		WithArgs(cfg.Database).
+		WillReturnRows(rows)
+
+	selection, err := getTables(context.Background(), db, cfg)
+	mustNoError(t, err, "getting tables")
+
+	if want := []string{"billing_invoices", "users"}; !slices.Equal(selection.Tables, want) {
+		t.Errorf("tables = %v, want %v", selection.Tables, want)
+	}
+
+	if want := []string{"billing_invoices", "orders", "tmp_billing", "users"}; !slices.Equal(selection.Available, want) {
+		t.Errorf("available = %v, want %v", selection.Available, want)
+	}
+
+	if got := selection.Catalog["users"].Comment; got != "Registered users" {
+		t.Errorf("users comment = %q, want %q", got, "Registered users")
+	}
+
+	if want := []string{`table "customers" listed in --tables does not exist in database "db"`}; !slices.Equal(selection.Warnings, want) {
+		t.Errorf("warnings = %q, want %q", selection.Warnings, want)
+	}
+
+	expectNoRemaining(t, mock)
+}
+
+func TestGetTablesRejectsInvalidPatternBeforeQuerying(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	mustNoError(t, err, "creating mock")
+	defer func() { _ = db.Close() }()
+
+	_, err = getTables(context.Background(), db, config.Config{Database: "db", Include: []string{"re:["}})
+	expectError(t, err, "invalid include pattern")
+	expectNoRemaining(t, mock)
+}
@@ -0,0 +1,55 @@
+// Filters the index as the search box is typed into, and renders the
+// diagrams with Mermaid when the site carries a bundle.
+(function () {
+  "use strict";
+
+  var search = document.getElementById("search");
+  if (search) {
+    var rows = document.querySelectorAll("[data-search]");
+    var none = document.getElementById("no-match");
+
+    var filter = function () {
+      var terms = search.value.toLowerCase().split(/\s+/).filter(Boolean);
+      var shown = 0;
+      rows.forEach(function (row) {
+        var text = row.getAttribute("data-search");
+        var match = terms.every(function (term) {
+          return text.indexOf(term) !== -1;
+        });
+        row.hidden = !match;
+        if (match) {
+          shown++;
+        }
+      });
+      if (none) {
+        none.hidden = shown !== 0;
+      }
+    };
+
+    search.addEventListener("input", filter);
+    filter();
+  }
+
+  // Each SVG image stays in place until Mermaid has drawn its diagram, and
+  // for good if it cannot.
+  if (window.mermaid) {
+    window.mermaid.initialize({ startOnLoad: false, securityLevel: "strict" });
+    document.querySelectorAll("figure.diagram pre.mermaid").forEach(function (source, i) {
+      window.mermaid
+        .render("diagram-" + i, source.textContent)
+        .then(function (result) {
+          var drawn = document.createElement("div");
+          drawn.className = "mermaid";
+          drawn.setAttribute("role", "img");
+          drawn.setAttribute("aria-label", source.getAttribute("aria-label"));
+          drawn.innerHTML = result.svg;
+          var image = source.parentNode.querySelector("img");
+          if (image) {
+            image.replaceWith(drawn);
+          }
+          source.remove();
+        })
+        .catch(function () {});
+    });
+  }
+})();
@@ -0,0 +1,450 @@
+// Package site builds a static HTML documentation site of a schema: an index
+// of the tables with search, an overview diagram and a page per table with
+// its columns, keys, relationships and neighbourhood diagram. The diagrams
+// are rendered in the browser by the site's own copy of Mermaid, over SVG
+// images drawn by marid that show without JavaScript or without a bundle.
+// The site references nothing outside itself, so it can be browsed from disk.
+package site
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/mermaid"
+	"github.com/motchang/marid/pkg/formatter/svg"
+	"github.com/motchang/marid/pkg/utils"
+)
+
+// files holds the page templates and the assets copied into every site,
+// including the vendored Mermaid bundle when scripts/vendor-mermaid.sh has
+// placed it in assets.
+//
+//go:embed templates assets
+var files embed.FS
+
+// MermaidBundle is the file name of the Mermaid bundle among the assets.
+const MermaidBundle = "mermaid.min.js"
+
+// DefaultTitle heads sites without an Options.Title.
+const DefaultTitle = "Database schema"
+
+// Options configure a site.
+type Options struct {
+	// Title heads every page; empty means DefaultTitle.
+	Title string
+	// FormatOptions configure the diagrams and the column types, such as
+	// formatter.OptionTypeDisplay and formatter.OptionTypeStyle.
+	FormatOptions formatter.Options
+	// MermaidJS is a Mermaid bundle to render the diagrams in the browser
+	// with, in place of the vendored one. Without either, the diagrams are
+	// only the SVG images marid draws.
+	MermaidJS []byte
+}
+
+// page is the data of a page template.
+type page struct {
+	// Site is the site title.
+	Site string
+	// Title is the page title, empty on the index.
+	Title string
+	// Root is the path from the page to the site root.
+	Root string
+	// Mermaid is set when the site carries a Mermaid bundle.
+	Mermaid bool
+
+	Tables  []entry
+	Table   *tablePage
+	Diagram diagram
+}
+
+// entry is a table in the index.
+type entry struct {
+	Name, Alias, Comment string
+	File                 string
+	Columns              int
+	// Search is the lower-cased text the search box matches.
+	Search string
+}
+
+// tablePage is the content of a table's page.
+type tablePage struct {
+	formatter.Table
+	Rows       []row
+	Links      []link
+	References []link
+	Diagram    diagram
+	Neighbours int
+}
+
+// row is a column in a table's page.
+type row struct {
+	Name, Type, Keys, Comment string
+	Nullable                  bool
+	Default                   string
+	HasDefault                bool
+}
+
+// link is a foreign key from Table.Column to RefTable.RefColumn, seen from
+// one of the two tables.
+type link struct {
+	Table, Column, RefTable, RefColumn string
+	// Target is the table at the other end, and File its page, if it has
+	// one.
+	Target, File string
+	Notes        string
+}
+
+// diagram is a diagram on a page: an SVG image drawn by marid and, when the
+// site carries a Mermaid bundle, the Mermaid source rendered in its place.
+type diagram struct {
+	Source string
+	Image  string
+	Alt    string
+}
+
+// builder writes the pages of a site.
+type builder struct {
+	dir         string
+	opts        Options
+	bundle      []byte
+	typeDisplay string
+	typeStyle   string
+	// draw makes the SVG images, and source the Mermaid source when the
+	// site carries a bundle.
+	draw   formatter.Formatter
+	source formatter.Formatter
+	// files maps table names to their page, relative to the tables
+	// directory.
+	files map[string]string
+	paths []string
+}
+
+// Build writes the site of the render data to dir and returns the paths it
+// wrote, the index first. Stub tables standing in for tables outside the
+// selection appear in the diagrams but get no page.
+func Build(dir string, data formatter.RenderData, opts Options) ([]string, error) {
+	if len(data.Tables) == 0 {
+		return nil, fmt.Errorf("no tables found in schema")
+	}
+
+	b := &builder{dir: dir, opts: opts, bundle: opts.MermaidJS}
+	if b.opts.Title == "" {
+		b.opts.Title = DefaultTitle
+	}
+	if b.bundle == nil {
+		b.bundle, _ = fs.ReadFile(files, "assets/"+MermaidBundle)
+	}
+
+	var err error
+	if b.typeDisplay, err = formatter.ParseTypeDisplay(opts.FormatOptions[formatter.OptionTypeDisplay]); err != nil {
+		return nil, err
+	}
+	if b.typeStyle, err = formatter.ParseTypeStyle(opts.FormatOptions[formatter.OptionTypeStyle]); err != nil {
+		return nil, err
+	}
+	if b.draw, err = formatter.Configure(svg.New(), opts.FormatOptions); err != nil {
+		return nil, fmt.Errorf("invalid format options: %w", err)
+	}
+	if b.bundle != nil {
+		if b.source, err = formatter.Configure(mermaid.New(), opts.FormatOptions); err != nil {
+			return nil, fmt.Errorf("invalid format options: %w", err)
+		}
+	}
+
+	b.files = pageFiles(data.Tables)
+	for _, sub := range []string{"tables", "assets"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, fmt.Errorf("creating site directory: %w", err)
+		}
+	}
+
+	if err := b.writeIndex(data); err != nil {
+		return b.paths, err
+	}
+	if err := b.writeOverview(data); err != nil {
+		return b.paths, err
+	}
+	for _, table := range data.Tables {
+		if table.External {
+			continue
+		}
+		if err := b.writeTable(table, data.Tables); err != nil {
+			return b.paths, err
+		}
+	}
+	return b.paths, b.writeAssets()
+}
+
+// pageFiles names the page of every table but the stubs after the table,
+// sanitized and made unique regardless of case.
+func pageFiles(tables []formatter.Table) map[string]string {
+	pages := make(map[string]string, len(tables))
+	taken := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		if table.External {
+			continue
+		}
+		base := strings.ToLower(utils.SanitizeIdentifier(table.Name))
+		if base == "" {
+			base = "_"
+		}
+
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		taken[name] = true
+		pages[table.Name] = name + ".html"
+	}
+	return pages
+}
+
+func (b *builder) writeIndex(data formatter.RenderData) error {
+	var entries []entry
+	for _, table := range data.Tables {
+		if table.External {
+			continue
+		}
+
+		words := []string{table.Name, table.Alias, table.Comment}
+		for _, column := range table.Columns {
+			words = append(words, column.Name)
+		}
+		entries = append(entries, entry{
+			Name:    table.Name,
+			Alias:   aliasOf(table),
+			Comment: table.Comment,
+			File:    "tables/" + b.files[table.Name],
+			Columns: len(table.Columns),
+			Search:  strings.ToLower(strings.Join(strings.Fields(strings.Join(words, " ")), " ")),
+		})
+	}
+
+	return b.writePage("index.html", "index.html", page{Tables: entries})
+}
+
+func (b *builder) writeOverview(data formatter.RenderData) error {
+	overview, err := b.diagram("overview", data, "Diagram of every table")
+	if err != nil {
+		return fmt.Errorf("rendering overview: %w", err)
+	}
+	return b.writePage("overview.html", "overview.html", page{Title: "Overview", Diagram: overview})
+}
+
+func (b *builder) writeTable(table formatter.Table, tables []formatter.Table) error {
+	neighbours := neighbourhood(tables, table.Name)
+	picture, err := b.diagram("tables/"+strings.TrimSuffix(b.files[table.Name], ".html"), neighbours,
+		fmt.Sprintf("Diagram of %s and the tables it is linked to", table.Name))
+	if err != nil {
+		return fmt.Errorf("rendering %s: %w", table.Name, err)
+	}
+
+	content := &tablePage{
+		Table:      table,
+		Rows:       b.rows(table),
+		Diagram:    picture,
+		Neighbours: len(neighbours.Tables) - 1,
+	}
+	content.Alias = aliasOf(table)
+	for _, fk := range table.ForeignKeys {
+		content.Links = append(content.Links, link{
+			Table: table.Name, Column: fk.ColumnName, RefTable: fk.ReferencedTable, RefColumn: fk.ReferencedColumn,
+			Target: fk.ReferencedTable, File: b.files[fk.ReferencedTable], Notes: notes(fk),
+		})
+	}
+	for _, other := range tables {
+		for _, fk := range other.ForeignKeys {
+			if fk.ReferencedTable != table.Name {
+				continue
+			}
+			content.References = append(content.References, link{
+				Table: other.Name, Column: fk.ColumnName, RefTable: table.Name, RefColumn: fk.ReferencedColumn,
+				Target: other.Name, File: b.files[other.Name], Notes: notes(fk),
+			})
+		}
+	}
+
+	return b.writePage("tables/"+b.files[table.Name], "table.html", page{Title: table.Name, Root: "../", Table: content})
+}
+
+// rows describes the columns of a table in its page.
+func (b *builder) rows(table formatter.Table) []row {
+	primary := make(map[string]bool, len(table.PrimaryKey))
+	for _, name := range table.PrimaryKey {
+		primary[name] = true
+	}
+	foreign := make(map[string]bool, len(table.ForeignKeys))
+	for _, fk := range table.ForeignKeys {
+		foreign[fk.ColumnName] = true
+	}
+
+	rows := make([]row, len(table.Columns))
+	for i, column := range table.Columns {
+		var keys []string
+		if primary[column.Name] || column.IsPrimary {
+			keys = append(keys, "PK")
+		}
+		if foreign[column.Name] {
+			keys = append(keys, "FK")
+		}
+		if column.IsUnique {
+			keys = append(keys, "UK")
+		}
+
+		columnType := column.Type(b.typeDisplay)
+		if b.typeStyle == formatter.TypeStylePortable {
+			columnType = column.PortableType(b.typeDisplay)
+		}
+
+		rows[i] = row{
+			Name:     column.Name,
+			Type:     columnType,
+			Keys:     strings.Join(keys, ", "),
+			Comment:  column.Comment,
+			Nullable: column.IsNullable,
+		}
+		if column.Default != nil {
+			rows[i].Default, rows[i].HasDefault = *column.Default, true
+			if rows[i].Default == "" {
+				rows[i].Default = "''"
+			}
+		}
+	}
+	return rows
+}
+
+// diagram renders the render data for a page: an SVG image written under
+// name in the site and, with a bundle, Mermaid source for the page.
+func (b *builder) diagram(name string, data formatter.RenderData, alt string) (diagram, error) {
+	output, err := b.draw.Render(data)
+	if err != nil {
+		return diagram{}, err
+	}
+	image := name + ".svg"
+	if err := b.write(image, []byte(output)); err != nil {
+		return diagram{}, err
+	}
+	// Pages link to images from their own directory.
+	picture := diagram{Image: filepath.Base(image), Alt: alt}
+
+	if b.source != nil {
+		if picture.Source, err = b.source.Render(data); err != nil {
+			return diagram{}, err
+		}
+	}
+	return picture, nil
+}
+
+func (b *builder) writePage(path, name string, data page) error {
+	tmpl, err := template.ParseFS(files, "templates/layout.html", "templates/"+name)
+	if err != nil {
+		return err
+	}
+
+	data.Site = b.opts.Title
+	data.Mermaid = b.bundle != nil
+	var out bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&out, "layout", data); err != nil {
+		return fmt.Errorf("rendering %s: %w", path, err)
+	}
+	return b.write(path, out.Bytes())
+}
+
+func (b *builder) writeAssets() error {
+	for _, name := range []string{"site.css", "site.js"} {
+		content, err := fs.ReadFile(files, "assets/"+name)
+		if err != nil {
+			return err
+		}
+		if err := b.write("assets/"+name, content); err != nil {
+			return err
+		}
+	}
+	if b.bundle != nil {
+		return b.write("assets/"+MermaidBundle, b.bundle)
+	}
+	return nil
+}
+
+// write writes a file of the site and records its path.
+func (b *builder) write(name string, content []byte) error {
+	path := filepath.Join(b.dir, filepath.FromSlash(name))
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("writing site: %w", err)
+	}
+	b.paths = append(b.paths, path)
+	return nil
+}
+
+// neighbourhood returns the named table, focused, with the tables it
+// references and the tables referencing it, keeping only the foreign keys
+// between the table and its neighbours.
+func neighbourhood(tables []formatter.Table, name string) formatter.RenderData {
+	present := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		present[table.Name] = true
+	}
+	near := map[string]bool{name: true}
+	for _, table := range tables {
+		for _, fk := range table.ForeignKeys {
+			if table.Name == name && present[fk.ReferencedTable] {
+				near[fk.ReferencedTable] = true
+			}
+			if fk.ReferencedTable == name {
+				near[table.Name] = true
+			}
+		}
+	}
+
+	var data formatter.RenderData
+	for _, table := range tables {
+		if !near[table.Name] {
+			continue
+		}
+		var foreignKeys []formatter.ForeignKey
+		for _, fk := range table.ForeignKeys {
+			if near[fk.ReferencedTable] && (table.Name == name || fk.ReferencedTable == name) {
+				foreignKeys = append(foreignKeys, fk)
+			}
+		}
+		table.ForeignKeys = foreignKeys
+		table.Focus = table.Name == name
+		data.Tables = append(data.Tables, table)
+	}
+	return data
+}
+
+// aliasOf returns the table's alias when it differs from its name.
+func aliasOf(table formatter.Table) string {
+	if table.Alias == table.Name {
+		return ""
+	}
+	return table.Alias
+}
+
+// notes describes what is notable about a foreign key: its name, a
+// cardinality other than many-to-one, and whether it was inferred or declared
+// in an overlay.
+func notes(fk formatter.ForeignKey) string {
+	var notes []string
+	if fk.RelationName != "" {
+		notes = append(notes, fk.RelationName)
+	}
+	if fk.Cardinality != "" && fk.Cardinality != formatter.CardinalityManyToOne {
+		notes = append(notes, fk.Cardinality)
+	}
+	if fk.Inferred {
+		notes = append(notes, "inferred")
+	}
+	if fk.Virtual {
+		notes = append(notes, "virtual")
+	}
+	return strings.Join(notes, ", ")
+}
@@ -0,0 +1,210 @@
+package site
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestBuildWritesPages(t *testing.T) {
+	dir := t.TempDir()
+
+	paths, err := Build(dir, formattertest.SampleRenderData(), Options{Title: "Shop"})
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+	if len(paths) == 0 || paths[0] != filepath.Join(dir, "index.html") {
+		t.Fatalf("Build returned %v, want the index first", paths)
+	}
+	for _, name := range []string{"overview.html", "overview.svg", "tables/teams.html", "tables/users.html", "tables/users.svg", "assets/site.css", "assets/site.js"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("missing %s: %v", name, err)
+		}
+	}
+
+	index := readFile(t, filepath.Join(dir, "index.html"))
+	for _, want := range []string{
+		"<title>Shop</title>",
+		`<input id="search"`,
+		`<tr data-search="users id email team_id"><td><a href="tables/users.html">users</a>`,
+		`<a href="overview.html">`,
+	} {
+		if !strings.Contains(index, want) {
+			t.Errorf("index lacks %q:\n%s", want, index)
+		}
+	}
+
+	users := readFile(t, filepath.Join(dir, "tables", "users.html"))
+	for _, want := range []string{
+		"<title>users · Shop</title>",
+		`<link rel="stylesheet" href="../assets/site.css">`,
+		"<tr><td><code>email</code></td><td>varchar</td><td>no</td><td>UK</td>",
+		`<li><code>team_id</code> → <a href="teams.html">teams</a>.<code>id</code> (belongs_to)</li>`,
+		`<img src="users.svg" alt="Diagram of users and the tables it is linked to">`,
+	} {
+		if !strings.Contains(users, want) {
+			t.Errorf("users page lacks %q:\n%s", want, users)
+		}
+	}
+
+	teams := readFile(t, filepath.Join(dir, "tables", "teams.html"))
+	if want := `<li><a href="users.html">users</a>.<code>team_id</code> → <code>id</code> (belongs_to)</li>`; !strings.Contains(teams, want) {
+		t.Errorf("teams page lacks %q:\n%s", want, teams)
+	}
+}
+
+func TestBuildRendersMermaidInTheBrowserWithABundle(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := Build(dir, formattertest.SampleRenderData(), Options{MermaidJS: []byte("window.mermaid = {};")}); err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	if got := readFile(t, filepath.Join(dir, "assets", MermaidBundle)); got != "window.mermaid = {};" {
+		t.Errorf("bundle = %q", got)
+	}
+	// The SVG images remain for browsers without JavaScript.
+	if _, err := os.Stat(filepath.Join(dir, "tables", "users.svg")); err != nil {
+		t.Errorf("users.svg missing alongside the Mermaid source: %v", err)
+	}
+
+	users := readFile(t, filepath.Join(dir, "tables", "users.html"))
+	for _, want := range []string{
+		`<script src="../assets/mermaid.min.js"></script>`,
+		`<img src="users.svg" alt="Diagram of users and the tables it is linked to">`,
+		`<pre class="mermaid" aria-label="Diagram of users and the tables it is linked to" hidden>erDiagram`,
+		"teams ||--o{ users : &#34;belongs_to&#34;",
+	} {
+		if !strings.Contains(users, want) {
+			t.Errorf("users page lacks %q:\n%s", want, users)
+		}
+	}
+}
+
+func TestBuildWithoutABundleShowsOnlySVG(t *testing.T) {
+	if _, err := fs.Stat(files, "assets/"+MermaidBundle); err == nil {
+		t.Skip("built with a vendored Mermaid bundle")
+	}
+	dir := t.TempDir()
+
+	if _, err := Build(dir, formattertest.SampleRenderData(), Options{}); err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	users := readFile(t, filepath.Join(dir, "tables", "users.html"))
+	if strings.Contains(users, "mermaid") {
+		t.Errorf("users page refers to Mermaid without a bundle:\n%s", users)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "assets", MermaidBundle)); !os.IsNotExist(err) {
+		t.Errorf("%s written without a bundle: %v", MermaidBundle, err)
+	}
+}
+
+func TestBuildReferencesNothingOutsideTheSite(t *testing.T) {
+	dir := t.TempDir()
+
+	paths, err := Build(dir, formattertest.SampleRenderData(), Options{MermaidJS: []byte("/* bundle */")})
+	if err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	remote := regexp.MustCompile(`(?i)(src|href)\s*=\s*"(https?:)?//|url\(\s*['"]?(https?:)?//|@import`)
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".html") || strings.HasSuffix(path, ".css") {
+			if match := remote.FindString(readFile(t, path)); match != "" {
+				t.Errorf("%s references %q", path, match)
+			}
+		}
+	}
+}
+
+func TestBuildEscapesSchemaText(t *testing.T) {
+	dir := t.TempDir()
+	data := formatter.RenderData{Tables: []formatter.Table{{
+		Name:    "notes",
+		Comment: "<script>alert(1)</script>",
+		Columns: []formatter.Column{{Name: "body", DataType: "text", Comment: `"quoted" & <b>`}},
+	}}}
+
+	if _, err := Build(dir, data, Options{}); err != nil {
+		t.Fatalf("Build returned error: %v", err)
+	}
+
+	page := readFile(t, filepath.Join(dir, "tables", "notes.html"))
+	if strings.Contains(page, "<script>alert") || strings.Contains(page, "<b>") {
+		t.Errorf("schema text reached the page unescaped:\n%s", page)
+	}
+	if !strings.Contains(page, "&lt;script&gt;alert(1)&lt;/script&gt;") {
+		t.Errorf("escaped comment missing:\n%s", page)
+	}
+}
+
+func TestPageFilesAreUnique(t *testing.T) {
+	files := pageFiles([]formatter.Table{{Name: "Users"}, {Name: "users"}, {Name: "order items"}, {Name: "stub", External: true}})
+
+	want := map[string]string{"Users": "users.html", "users": "users_2.html", "order items": "order_items.html"}
+	if len(files) != len(want) {
+		t.Fatalf("pageFiles = %v, want %v", files, want)
+	}
+	for name, file := range want {
+		if files[name] != file {
+			t.Errorf("page of %q = %q, want %q", name, files[name], file)
+		}
+	}
+}
+
+func TestNeighbourhood(t *testing.T) {
+	tables := []formatter.Table{
+		{Name: "orders", ForeignKeys: []formatter.ForeignKey{
+			{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id"},
+			{ColumnName: "shop_id", ReferencedTable: "shops", ReferencedColumn: "id"},
+		}},
+		{Name: "shops"},
+		{Name: "teams"},
+		{Name: "users", ForeignKeys: []formatter.ForeignKey{
+			{ColumnName: "team_id", ReferencedTable: "teams", ReferencedColumn: "id"},
+			{ColumnName: "org_id", ReferencedTable: "orgs", ReferencedColumn: "id"},
+		}},
+	}
+
+	data := neighbourhood(tables, "users")
+
+	var names []string
+	for _, table := range data.Tables {
+		names = append(names, table.Name)
+		if table.Focus != (table.Name == "users") {
+			t.Errorf("%s Focus = %v", table.Name, table.Focus)
+		}
+	}
+	if got, want := strings.Join(names, ","), "orders,teams,users"; got != want {
+		t.Fatalf("neighbourhood tables = %s, want %s", got, want)
+	}
+	if fks := data.Tables[0].ForeignKeys; len(fks) != 1 || fks[0].ReferencedTable != "users" {
+		t.Errorf("orders keeps %v, want only its foreign key to users", fks)
+	}
+	if fks := data.Tables[2].ForeignKeys; len(fks) != 1 || fks[0].ReferencedTable != "teams" {
+		t.Errorf("users keeps %v, want only its foreign key to teams", fks)
+	}
+}
+
+func TestBuildNoTables(t *testing.T) {
+	if _, err := Build(t.TempDir(), formatter.RenderData{}, Options{}); err == nil {
+		t.Fatal("expected an error for empty render data")
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 type mock struct {
 	expectations []*ExpectedQuery
+	unordered    bool
 	mu           sync.Mutex
 }
 
@@ -30,6 +31,7 @@ type ExpectedQuery struct {
 type Sqlmock interface {
 	ExpectQuery(query string) *ExpectedQuery
 	ExpectationsWereMet() error
+	MatchExpectationsInOrder(bool)
 }
 
 func New(options ...interface{}) (*sql.DB, Sqlmock, error) {
@@ -52,6 +54,14 @@ func (m *mock) ExpectQuery(query string) *ExpectedQuery {
 	return exp
 }
 
+// MatchExpectationsInOrder lets queries match any remaining expectation when
+// set to false, for code that runs queries concurrently.
+func (m *mock) MatchExpectationsInOrder(ordered bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unordered = !ordered
+}
+
 func (m *mock) ExpectationsWereMet() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -104,25 +114,27 @@ func (c *conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 		return nil, fmt.Errorf("unexpected query: %s", query)
 	}
 
-	exp := c.mock.expectations[0]
-	normalizedQuery := normalize(query)
-	if !exp.regex.MatchString(normalizedQuery) {
-		return nil, fmt.Errorf("query %s did not match expectation %s", query, exp.regex.String())
-	}
-
-	if len(exp.args) > 0 {
-		if len(args) != len(exp.args) {
-			return nil, fmt.Errorf("query %s args %v do not match expectation %v", query, args, exp.args)
-		}
-		for i, arg := range args {
-			if arg.Value != exp.args[i] {
-				return nil, fmt.Errorf("arg %d mismatch: %v vs %v", i, arg.Value, exp.args[i])
+	index := 0
+	if c.mock.unordered {
+		index = -1
+		for i, candidate := range c.mock.expectations {
+			if candidate.matches(query, args) == nil {
+				index = i
+				break
 			}
 		}
+		if index < 0 {
+			return nil, fmt.Errorf("query %s matched no remaining expectation", query)
+		}
+	}
+
+	exp := c.mock.expectations[index]
+	if err := exp.matches(query, args); err != nil {
+		return nil, err
 	}
 
 	exp.fulfilled = true
-	c.mock.expectations = c.mock.expectations[1:]
+	c.mock.expectations = append(c.mock.expectations[:index:index], c.mock.expectations[index+1:]...)
 
 	if exp.err != nil {
 		return nil, exp.err
@@ -135,6 +147,25 @@ func (c *conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 	return exp.rows.clone(), nil
 }
 
+func (e *ExpectedQuery) matches(query string, args []driver.NamedValue) error {
+	if !e.regex.MatchString(normalize(query)) {
+		return fmt.Errorf("query %s did not match expectation %s", query, e.regex.String())
+	}
+
+	if len(e.args) > 0 {
+		if len(args) != len(e.args) {
+			return fmt.Errorf("query %s args %v do not match expectation %v", query, args, e.args)
+		}
+		for i, arg := range args {
+			if arg.Value != e.args[i] {
+				return fmt.Errorf("arg %d mismatch: %v vs %v", i, arg.Value, e.args[i])
+			}
+		}
+	}
+
+	return nil
+}
+
 func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
 	return nil, errors.New("exec not supported")
 }
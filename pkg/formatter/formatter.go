@@ -1,5 +1,11 @@
 package formatter
 
+import (
+	"strings"
+
+	"github.com/motchang/marid/pkg/utils"
+)
+
 // Formatter defines the contract for rendering database schemas into specific output formats.
 type Formatter interface {
 	// Name returns the formatter name (e.g., "mermaid").
@@ -7,43 +13,146 @@ type Formatter interface {
 	// MediaType returns the MIME type associated with the output (e.g., "text/plain").
 	MediaType() string
 	// Render builds the formatted representation for the provided render data.
+	// Binary formats return their bytes as the string; see IsText.
 	Render(RenderData) (string, error)
 }
 
+// IsText reports whether output of mediaType is text, which may be printed
+// with a trailing newline, rather than binary data to be written as is.
+func IsText(mediaType string) bool {
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	mediaType = strings.TrimSpace(strings.ToLower(mediaType))
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, "+json") ||
+		mediaType == "application/json" || mediaType == "application/xml"
+}
+
 // Factory constructs a Formatter instance.
 type Factory func() Formatter
 
+// Loader constructs a Formatter instance, or reports why it cannot.
+type Loader func() (Formatter, error)
+
 // DefaultFormat is the fallback format name when none is provided.
 const DefaultFormat = "mermaid"
 
 // RenderData represents normalized schema information passed to formatters.
 type RenderData struct {
-	Tables []Table
+	Tables []Table `json:"tables"`
 }
 
 // Table represents a database table for rendering purposes.
 type Table struct {
-	Name        string
-	Comment     string
-	Columns     []Column
-	PrimaryKey  []string
-	ForeignKeys []ForeignKey
+	Name        string       `json:"name"`
+	Comment     string       `json:"comment,omitempty"`
+	Columns     []Column     `json:"columns"`
+	PrimaryKey  []string     `json:"primary_key,omitempty"`
+	ForeignKeys []ForeignKey `json:"foreign_keys,omitempty"`
+	// Indexes lists the table's indexes, the primary key first.
+	Indexes []Index `json:"indexes,omitempty"`
+	// Focus marks a table the diagram was centred on, for formatters to highlight.
+	Focus bool `json:"focus,omitempty"`
+	// External marks a stub standing in for a referenced table outside the
+	// selection; it only lists the referenced columns.
+	External bool `json:"external,omitempty"`
+	// Alias is an optional display name; Name stays the table's identifier.
+	Alias string `json:"alias,omitempty"`
+	// Group is an optional grouping label assigned by an overlay file.
+	Group string `json:"group,omitempty"`
 }
 
 // Column represents a database column for rendering purposes.
 type Column struct {
-	Name       string
-	DataType   string
-	IsNullable bool
-	IsPrimary  bool
-	IsUnique   bool
-	Comment    string
+	Name       string `json:"name"`
+	DataType   string `json:"data_type"`
+	IsNullable bool   `json:"is_nullable,omitempty"`
+	IsPrimary  bool   `json:"is_primary,omitempty"`
+	IsUnique   bool   `json:"is_unique,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	// ColumnType is the full MySQL type, such as "decimal(10,2)" or
+	// "bigint unsigned"; DataType is only its base name.
+	ColumnType string `json:"column_type,omitempty"`
+	// CharacterMaxLength, NumericPrecision and NumericScale are nil when they
+	// do not apply to the type.
+	CharacterMaxLength *int64 `json:"character_max_length,omitempty"`
+	NumericPrecision   *int64 `json:"numeric_precision,omitempty"`
+	NumericScale       *int64 `json:"numeric_scale,omitempty"`
+	Unsigned           bool   `json:"unsigned,omitempty"`
+	// EnumValues lists the members of an ENUM or SET column.
+	EnumValues []string `json:"enum_values,omitempty"`
+	// Default is the default expression, or nil when the column has none.
+	Default *string `json:"default,omitempty"`
+	// Extra holds MySQL's EXTRA flags, such as "auto_increment".
+	Extra        string `json:"extra,omitempty"`
+	CharacterSet string `json:"character_set,omitempty"`
+	Collation    string `json:"collation,omitempty"`
+}
+
+// Type returns the column type to display in the given TypeDisplay mode. Full
+// falls back to DataType when the full type is unknown.
+func (c Column) Type(display string) string {
+	if display == TypeDisplayFull && c.ColumnType != "" {
+		return c.ColumnType
+	}
+	return c.DataType
+}
+
+// PortableType is Type with the base type replaced by its database-neutral
+// name, so "bigint unsigned" becomes "integer unsigned".
+func (c Column) PortableType(display string) string {
+	raw := c.Type(display)
+	if !strings.HasPrefix(strings.ToLower(raw), strings.ToLower(c.DataType)) {
+		return utils.FormatColumnType(c.DataType)
+	}
+	return utils.FormatColumnType(c.DataType) + raw[len(c.DataType):]
+}
+
+// Index represents a table index for rendering purposes.
+type Index struct {
+	Name string `json:"name"`
+	// Columns lists the indexed columns in index order; "(expression)" stands
+	// for a functional key part.
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique,omitempty"`
+	// Type is MySQL's index type, such as "BTREE" or "FULLTEXT".
+	Type string `json:"type,omitempty"`
 }
 
 // ForeignKey represents a foreign key relationship for rendering purposes.
 type ForeignKey struct {
-	ColumnName       string
-	ReferencedTable  string
-	ReferencedColumn string
-	RelationName     string
+	ColumnName       string `json:"column_name"`
+	ReferencedTable  string `json:"referenced_table"`
+	ReferencedColumn string `json:"referenced_column"`
+	RelationName     string `json:"relation_name,omitempty"`
+	// Inferred marks a relationship guessed from naming conventions rather
+	// than declared in the database, for formatters to render distinctly.
+	Inferred bool `json:"inferred,omitempty"`
+	// Virtual marks a relationship declared in an overlay file because it only
+	// exists in application code.
+	Virtual bool `json:"virtual,omitempty"`
+	// Cardinality is one of the Cardinality constants; empty means
+	// CardinalityManyToOne.
+	Cardinality string `json:"cardinality,omitempty"`
+}
+
+// Cardinalities of a foreign key, read from the referencing table to the
+// referenced one.
+const (
+	// CardinalityManyToOne is an ordinary foreign key: many referencing rows
+	// point at one referenced row.
+	CardinalityManyToOne = "many-to-one"
+	// CardinalityOneToOne allows at most one referencing row per referenced row.
+	CardinalityOneToOne = "one-to-one"
+	// CardinalityOneToMany relates each referencing row to many referenced rows.
+	CardinalityOneToMany = "one-to-many"
+	// CardinalityManyToMany relates many rows on both sides.
+	CardinalityManyToMany = "many-to-many"
+)
+
+// Cardinalities lists the accepted Cardinality values.
+var Cardinalities = []string{
+	CardinalityManyToOne,
+	CardinalityOneToOne,
+	CardinalityOneToMany,
+	CardinalityManyToMany,
 }
@@ -1,11 +1,20 @@
 package formatter_test
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/motchang/marid/pkg/formatter"
 	"github.com/motchang/marid/pkg/formatter/formattertest"
+	"github.com/motchang/marid/pkg/formatter/markdown"
 	"github.com/motchang/marid/pkg/formatter/mermaid"
+	"github.com/motchang/marid/pkg/formatter/pdf"
+	"github.com/motchang/marid/pkg/formatter/png"
+	"github.com/motchang/marid/pkg/formatter/svg"
+	"github.com/motchang/marid/pkg/formatter/template"
 )
 
 func TestFormatterContract(t *testing.T) {
@@ -13,12 +22,30 @@ func TestFormatterContract(t *testing.T) {
 
 	testData := formattertest.SampleRenderData()
 
+	sampleSVG, err := os.ReadFile("svg/testdata/sample.svg")
+	if err != nil {
+		t.Fatalf("reading the svg golden file: %v", err)
+	}
+
+	sampleMarkdown, err := os.ReadFile("markdown/testdata/sample.md")
+	if err != nil {
+		t.Fatalf("reading the markdown golden file: %v", err)
+	}
+
+	tableList, err := template.Parse("tables.tmpl", "{{ range .Tables }}{{ .Name }}: {{ join \", \" (columnNames .) }}\n{{ end }}")
+	if err != nil {
+		t.Fatalf("parsing the contract template: %v", err)
+	}
+
+	// Binary formats are checked by their signature rather than a golden
+	// file, which would only change with the fonts and encoders.
 	tests := []struct {
-		name            string
-		formatter       formatter.Formatter
-		wantName        string
-		wantMediaType   string
-		wantRenderMatch string
+		name             string
+		formatter        formatter.Formatter
+		wantName         string
+		wantMediaType    string
+		wantRenderMatch  string
+		wantRenderPrefix string
 	}{
 		{
 			name:            "mermaid implements contract",
@@ -27,6 +54,41 @@ func TestFormatterContract(t *testing.T) {
 			wantMediaType:   "text/plain",
 			wantRenderMatch: formattertest.SampleMermaidOutput(),
 		},
+		{
+			name:            "svg implements contract",
+			formatter:       svg.New(),
+			wantName:        "svg",
+			wantMediaType:   "image/svg+xml",
+			wantRenderMatch: string(sampleSVG),
+		},
+		{
+			name:             "png implements contract",
+			formatter:        png.New(),
+			wantName:         "png",
+			wantMediaType:    "image/png",
+			wantRenderPrefix: "\x89PNG\r\n\x1a\n",
+		},
+		{
+			name:             "pdf implements contract",
+			formatter:        pdf.New(),
+			wantName:         "pdf",
+			wantMediaType:    "application/pdf",
+			wantRenderPrefix: "%PDF-",
+		},
+		{
+			name:            "markdown implements contract",
+			formatter:       markdown.New(),
+			wantName:        "markdown",
+			wantMediaType:   "text/markdown",
+			wantRenderMatch: string(sampleMarkdown),
+		},
+		{
+			name:            "template implements contract",
+			formatter:       tableList,
+			wantName:        "template",
+			wantMediaType:   "text/plain",
+			wantRenderMatch: "teams: id, name\nusers: id, email, team_id\n",
+		},
 	}
 
 	for _, tt := range tests {
@@ -47,9 +109,21 @@ func TestFormatterContract(t *testing.T) {
 				t.Fatalf("Render returned error: %v", err)
 			}
 
-			if got != tt.wantRenderMatch {
+			if tt.wantRenderPrefix != "" {
+				if !strings.HasPrefix(got, tt.wantRenderPrefix) {
+					t.Fatalf("Render() output starts with %q, want %q", got[:min(len(got), 16)], tt.wantRenderPrefix)
+				}
+			} else if got != tt.wantRenderMatch {
 				t.Fatalf("Render() output mismatch\n--- want ---\n%s\n--- got ---\n%s", tt.wantRenderMatch, got)
 			}
+
+			var streamed strings.Builder
+			if err := formatter.RenderTo(context.Background(), tt.formatter, &streamed, testData, nil); err != nil {
+				t.Fatalf("RenderTo returned error: %v", err)
+			}
+			if streamed.String() != got {
+				t.Fatalf("RenderTo() output differs from Render()\n--- Render ---\n%s\n--- RenderTo ---\n%s", got, streamed.String())
+			}
 		})
 	}
 }
@@ -62,3 +136,44 @@ func TestFormatterContractRejectsEmptyTables(t *testing.T) {
 		t.Fatalf("Render should fail when no tables are provided")
 	}
 }
+
+func TestFormatterOptionSpecsAreAccepted(t *testing.T) {
+	t.Parallel()
+
+	// The template option names a file, so it needs one that exists.
+	templateFile := filepath.Join(t.TempDir(), "tables.tmpl")
+	if err := os.WriteFile(templateFile, []byte("{{ range .Tables }}{{ .Name }}\n{{ end }}"), 0o644); err != nil {
+		t.Fatalf("writing the template: %v", err)
+	}
+
+	for _, name := range formatter.Available() {
+		f, err := formatter.Get(name)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", name, err)
+		}
+
+		specs := formatter.Specs(f)
+		for i, spec := range specs {
+			if i > 0 && specs[i-1].Name >= spec.Name {
+				t.Errorf("%s: options are not sorted by name at %q", name, spec.Name)
+			}
+
+			value := spec.Default
+			switch {
+			case value != "":
+			case spec.Name == formatter.OptionTemplate:
+				value = templateFile
+			case spec.Kind == formatter.KindEnum:
+				value = spec.Values[0]
+			case spec.Kind == formatter.KindInt:
+				value = "1"
+			default:
+				value = "x=x" // any text, and a valid style rule
+			}
+
+			if _, err := formatter.Configure(f, formatter.Options{spec.Name: value}); err != nil {
+				t.Errorf("%s: declared option %s=%s is rejected: %v", name, spec.Name, value, err)
+			}
+		}
+	}
+}
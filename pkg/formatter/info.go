@@ -0,0 +1,103 @@
+package formatter
+
+// Feature names a part of the schema a format can show.
+type Feature string
+
+// Schema features.
+const (
+	// FeatureComments shows table and column comments.
+	FeatureComments Feature = "comments"
+	// FeatureCardinality shows how many rows each side of a relationship
+	// relates to.
+	FeatureCardinality Feature = "cardinality"
+	// FeatureIndexes lists table indexes.
+	FeatureIndexes Feature = "indexes"
+	// FeatureStyles colours tables by OptionStyle rules.
+	FeatureStyles Feature = "styles"
+)
+
+// Metadata is what a formatter says about itself in Info.
+type Metadata struct {
+	Description string
+	// Extension is the file name extension of the output, with its dot;
+	// empty means "." and the format name.
+	Extension string
+	Features  []Feature
+}
+
+// MetadataProvider is implemented by formatters that describe themselves.
+type MetadataProvider interface {
+	Metadata() Metadata
+}
+
+// Info describes a registered format, for people and for scripts discovering
+// what marid can write.
+type Info struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	MediaType   string       `json:"media_type"`
+	Extension   string       `json:"extension"`
+	Binary      bool         `json:"binary"`
+	Options     []OptionSpec `json:"options"`
+	Features    []Feature    `json:"features"`
+	// Error says why the format could not be loaded, such as a broken
+	// plugin; the other fields are then empty.
+	Error string `json:"error,omitempty"`
+}
+
+// Describe returns the Info of the named format, falling back to
+// DefaultFormat when empty.
+func Describe(name string) (Info, error) {
+	if name == "" {
+		name = DefaultFormat
+	}
+
+	f, err := Get(name)
+	if err != nil {
+		return Info{}, err
+	}
+	return describe(name, f), nil
+}
+
+// Infos returns the Info of every registered format, sorted by name. A format
+// that cannot be loaded is listed with its Error, so one broken plugin does
+// not hide the others.
+func Infos() []Info {
+	names := Available()
+	infos := make([]Info, 0, len(names))
+	for _, name := range names {
+		info, err := Describe(name)
+		if err != nil {
+			info = Info{Name: name, Options: []OptionSpec{}, Features: []Feature{}, Error: err.Error()}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// describe builds the Info of f, registered as name.
+func describe(name string, f Formatter) Info {
+	info := Info{
+		Name:      name,
+		MediaType: f.MediaType(),
+		Binary:    !IsText(f.MediaType()),
+		Options:   Specs(f),
+	}
+	if p, ok := f.(MetadataProvider); ok {
+		m := p.Metadata()
+		info.Description = m.Description
+		info.Extension = m.Extension
+		info.Features = append([]Feature(nil), m.Features...)
+	}
+
+	if info.Extension == "" {
+		info.Extension = "." + info.Name
+	}
+	if info.Options == nil {
+		info.Options = []OptionSpec{}
+	}
+	if info.Features == nil {
+		info.Features = []Feature{}
+	}
+	return info
+}
@@ -0,0 +1,110 @@
+package formatter_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+func TestDescribeUsesFormatterMetadata(t *testing.T) {
+	info, err := formatter.Describe("")
+	if err != nil {
+		t.Fatalf("Describe returned error: %v", err)
+	}
+
+	if info.Name != formatter.DefaultFormat || info.MediaType != "text/plain" || info.Extension != ".mmd" || info.Binary {
+		t.Errorf("Describe(\"\") = %+v", info)
+	}
+	if info.Description == "" {
+		t.Error("mermaid has no description")
+	}
+	wantFeatures := []formatter.Feature{formatter.FeatureComments, formatter.FeatureCardinality, formatter.FeatureStyles}
+	if !reflect.DeepEqual(info.Features, wantFeatures) {
+		t.Errorf("features = %v, want %v", info.Features, wantFeatures)
+	}
+	if len(info.Options) == 0 || info.Options[0].Name != formatter.OptionCommentLength {
+		t.Errorf("options = %+v, want mermaid's sorted options", info.Options)
+	}
+}
+
+func TestDescribeFillsDefaults(t *testing.T) {
+	name := fmt.Sprintf("bare-%d", time.Now().UnixNano())
+	formatter.Register(name, func() formatter.Formatter {
+		return &formattertest.MockFormatter{MediaTypeValue: "application/octet-stream"}
+	})
+
+	info, err := formatter.Describe(name)
+	if err != nil {
+		t.Fatalf("Describe returned error: %v", err)
+	}
+
+	want := formatter.Info{
+		Name:      name,
+		MediaType: "application/octet-stream",
+		Extension: "." + name,
+		Binary:    true,
+		Options:   []formatter.OptionSpec{},
+		Features:  []formatter.Feature{},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Describe = %+v, want %+v", info, want)
+	}
+}
+
+func TestDescribeUnknownFormat(t *testing.T) {
+	if _, err := formatter.Describe("unknown"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestInfosFollowAvailable(t *testing.T) {
+	infos := formatter.Infos()
+	names := formatter.Available()
+	if len(infos) != len(names) {
+		t.Fatalf("Infos has %d entries, Available %d", len(infos), len(names))
+	}
+	for i, info := range infos {
+		if info.Name != names[i] {
+			t.Errorf("Infos()[%d] = %q, want %q", i, info.Name, names[i])
+		}
+	}
+}
+
+func TestInfosListFormatsThatFailToLoad(t *testing.T) {
+	stamp := time.Now().UnixNano()
+	broken := fmt.Sprintf("broken-%d", stamp)
+	working := fmt.Sprintf("working-%d", stamp)
+
+	// The loader fails only while this test runs, so tests walking every
+	// registered format are not disturbed.
+	failing := true
+	defer func() { failing = false }()
+	formatter.RegisterLoader(broken, func() (formatter.Formatter, error) {
+		if failing {
+			return nil, fmt.Errorf("plugin is missing")
+		}
+		return &formattertest.MockFormatter{}, nil
+	})
+	formatter.Register(working, func() formatter.Formatter {
+		return &formattertest.MockFormatter{MediaTypeValue: "text/plain"}
+	})
+
+	byName := make(map[string]formatter.Info)
+	for _, info := range formatter.Infos() {
+		byName[info.Name] = info
+	}
+
+	if info := byName[broken]; info.Error != "plugin is missing" || info.MediaType != "" {
+		t.Errorf("%s = %+v, want its load error", broken, info)
+	}
+	if info := byName[working]; info.Error != "" || info.MediaType != "text/plain" {
+		t.Errorf("%s = %+v, want it described", working, info)
+	}
+	if info := byName[formatter.DefaultFormat]; info.Error != "" || info.Description == "" {
+		t.Errorf("%s = %+v, want the built-in format described", formatter.DefaultFormat, info)
+	}
+}
@@ -0,0 +1,194 @@
+package layout
+
+import (
+	"sort"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// crossingSweeps is the number of down-and-up barycentric sweeps
+// ReduceCrossings makes.
+const crossingSweeps = 8
+
+// Graph holds the foreign keys between tables by their index, ignoring
+// self references and references to tables that are not rendered.
+type Graph struct {
+	// parents are the tables each table references.
+	parents [][]int
+	// children are the tables referencing each table.
+	children [][]int
+}
+
+// NewGraph indexes the foreign keys between tables.
+func NewGraph(tables []formatter.Table) Graph {
+	index := make(map[string]int, len(tables))
+	for i, table := range tables {
+		index[table.Name] = i
+	}
+
+	g := Graph{parents: make([][]int, len(tables)), children: make([][]int, len(tables))}
+	for i, table := range tables {
+		for _, fk := range table.ForeignKeys {
+			j, ok := index[fk.ReferencedTable]
+			if !ok || j == i {
+				continue
+			}
+			g.parents[i] = append(g.parents[i], j)
+			g.children[j] = append(g.children[j], i)
+		}
+	}
+	return g
+}
+
+// Layers groups tables by foreign-key depth: tables referencing nothing come
+// first, and every other table one layer after the deepest table it
+// references. Cycles are broken at the unplaced table with the fewest
+// unplaced parents, earliest first. Each layer keeps the input order.
+func (g Graph) Layers() [][]int {
+	n := len(g.parents)
+	level := make([]int, n)
+	placed := make([]bool, n)
+
+	for count := 0; count < n; {
+		next, fewest := -1, n+1
+		for i := 0; i < n; i++ {
+			if placed[i] {
+				continue
+			}
+
+			unplaced := 0
+			for _, p := range g.parents[i] {
+				if !placed[p] {
+					unplaced++
+				}
+			}
+			if unplaced < fewest {
+				next, fewest = i, unplaced
+			}
+			if unplaced == 0 {
+				break
+			}
+		}
+
+		for _, p := range g.parents[next] {
+			if placed[p] && level[p]+1 > level[next] {
+				level[next] = level[p] + 1
+			}
+		}
+		placed[next] = true
+		count++
+	}
+
+	var layers [][]int
+	for i := 0; i < n; i++ {
+		for len(layers) <= level[i] {
+			layers = append(layers, nil)
+		}
+		layers[level[i]] = append(layers[level[i]], i)
+	}
+	return layers
+}
+
+// ReduceCrossings reorders tables within their layers by the barycentric
+// heuristic: each sweep down sorts a layer by the mean position of the tables
+// it references in the layers above, and each sweep up by the mean position of
+// the tables referencing it in the layers below. The ordering with the fewest
+// crossings seen is kept, the earliest on a tie.
+func (g Graph) ReduceCrossings(layers [][]int) [][]int {
+	best, bestCrossings := cloneLayers(layers), g.Crossings(layers)
+
+	for sweep := 0; sweep < crossingSweeps && bestCrossings > 0; sweep++ {
+		for l := 1; l < len(layers); l++ {
+			g.sortByBarycenter(layers, l, g.parents)
+		}
+		for l := len(layers) - 2; l >= 0; l-- {
+			g.sortByBarycenter(layers, l, g.children)
+		}
+
+		if crossings := g.Crossings(layers); crossings < bestCrossings {
+			best, bestCrossings = cloneLayers(layers), crossings
+		}
+	}
+	return best
+}
+
+// sortByBarycenter sorts layer l by the mean position of each table's
+// neighbours within their own layers. Tables without neighbours keep their
+// current position.
+func (g Graph) sortByBarycenter(layers [][]int, l int, neighbours [][]int) {
+	position := positions(layers)
+
+	layer := layers[l]
+	barycenter := make(map[int]float64, len(layer))
+	for pos, i := range layer {
+		barycenter[i] = float64(pos)
+		if len(neighbours[i]) == 0 {
+			continue
+		}
+
+		sum := 0
+		for _, j := range neighbours[i] {
+			sum += position[j]
+		}
+		barycenter[i] = float64(sum) / float64(len(neighbours[i]))
+	}
+
+	sort.SliceStable(layer, func(a, b int) bool {
+		return barycenter[layer[a]] < barycenter[layer[b]]
+	})
+}
+
+// Crossings counts pairs of foreign keys between the same two layers whose
+// ends are in opposite orders, which a layered drawing has to cross.
+func (g Graph) Crossings(layers [][]int) int {
+	position := positions(layers)
+	layer := make(map[int]int)
+	for l, tables := range layers {
+		for _, i := range tables {
+			layer[i] = l
+		}
+	}
+
+	type edge struct{ from, to int }
+	var edges []edge
+	for i, parents := range g.parents {
+		for _, p := range parents {
+			if layer[p] != layer[i] {
+				edges = append(edges, edge{from: p, to: i})
+			}
+		}
+	}
+
+	crossings := 0
+	for a := 0; a < len(edges); a++ {
+		for b := a + 1; b < len(edges); b++ {
+			e, f := edges[a], edges[b]
+			if layer[e.from] != layer[f.from] || layer[e.to] != layer[f.to] {
+				continue
+			}
+			if (position[e.from]-position[f.from])*(position[e.to]-position[f.to]) < 0 {
+				crossings++
+			}
+		}
+	}
+	return crossings
+}
+
+// positions maps each table to its position within its layer.
+func positions(layers [][]int) map[int]int {
+	position := make(map[int]int)
+	for _, layer := range layers {
+		for pos, i := range layer {
+			position[i] = pos
+		}
+	}
+	return position
+}
+
+func cloneLayers(layers [][]int) [][]int {
+	clone := make([][]int, len(layers))
+	for i, layer := range layers {
+		clone[i] = append([]int(nil), layer...)
+	}
+	return clone
+}
@@ -0,0 +1,54 @@
+package layout
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func references(table string) []formatter.ForeignKey {
+	return []formatter.ForeignKey{{ColumnName: table + "_id", ReferencedTable: table, ReferencedColumn: "id"}}
+}
+
+// crossingTables references the first two tables in the opposite order of
+// the last two, so a layered drawing in input order crosses once.
+func crossingTables() []formatter.Table {
+	return []formatter.Table{
+		{Name: "accounts"},
+		{Name: "brands"},
+		{Name: "products", ForeignKeys: references("brands")},
+		{Name: "sessions", ForeignKeys: references("accounts")},
+	}
+}
+
+func TestGraphLayers(t *testing.T) {
+	tables := []formatter.Table{
+		{Name: "order_items", ForeignKeys: append(references("orders"), references("products")...)},
+		{Name: "orders", ForeignKeys: references("users")},
+		{Name: "products"},
+		{Name: "users", ForeignKeys: append(references("users"), references("elsewhere")...)},
+	}
+
+	got := NewGraph(tables).Layers()
+	if want := [][]int{{2, 3}, {1}, {0}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Layers() = %v, want %v", got, want)
+	}
+}
+
+func TestGraphReduceCrossings(t *testing.T) {
+	g := NewGraph(crossingTables())
+
+	layers := g.Layers()
+	if got := g.Crossings(layers); got != 1 {
+		t.Fatalf("Crossings() before reduction = %d, want 1", got)
+	}
+
+	reduced := g.ReduceCrossings(layers)
+	if got := g.Crossings(reduced); got != 0 {
+		t.Errorf("Crossings() after reduction = %d, want 0", got)
+	}
+	if want := [][]int{{0, 1}, {3, 2}}; !reflect.DeepEqual(reduced, want) {
+		t.Errorf("ReduceCrossings() = %v, want %v", reduced, want)
+	}
+}
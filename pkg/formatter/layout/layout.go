@@ -0,0 +1,622 @@
+// Package layout places the tables and relationships of an ER diagram on a
+// plane, so that vector and raster formatters draw the same picture.
+//
+// Tables are arranged in layers by foreign-key depth, referenced tables
+// above the tables referencing them, and ordered within their layer to
+// reduce crossings. Relationships are routed orthogonally through the gaps
+// between layers, and through a gutter right of the tables when they span
+// more than one gap.
+package layout
+
+import (
+	"math"
+	"sort"
+	"unicode"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// Metrics are the sizes, in pixels, a layout is computed with.
+type Metrics struct {
+	// FontSize is the size of table and column text, LabelSize that of
+	// relationship labels and TitleSize that of the diagram title.
+	FontSize  float64
+	LabelSize float64
+	TitleSize float64
+	// CharWidth is the advance of one character at FontSize; wide characters,
+	// such as CJK ideographs, take two.
+	CharWidth float64
+	// RowHeight is the height of a column row, HeaderHeight that of the row
+	// holding the table name, and TitleHeight the space the title takes.
+	RowHeight    float64
+	HeaderHeight float64
+	TitleHeight  float64
+	// PaddingX is the space between a box's border and its text, ColumnGap
+	// the space between the name, type and key columns.
+	PaddingX  float64
+	ColumnGap float64
+	// BoxGap separates tables within a layer and LayerGap separates layers;
+	// relationships are routed through the latter.
+	BoxGap   float64
+	LayerGap float64
+	// Margin surrounds the whole diagram.
+	Margin float64
+}
+
+// DefaultMetrics suit a monospaced font such as Go Mono, whose characters
+// advance 0.6 em.
+var DefaultMetrics = Metrics{
+	FontSize:     14,
+	LabelSize:    12,
+	TitleSize:    20,
+	CharWidth:    8.4,
+	RowHeight:    22,
+	HeaderHeight: 28,
+	TitleHeight:  40,
+	PaddingX:     10,
+	ColumnGap:    16,
+	BoxGap:       48,
+	LayerGap:     96,
+	Margin:       24,
+}
+
+// Clearances around relationships, in pixels.
+const (
+	// endClearance keeps the horizontal run of a relationship clear of the
+	// crow's foot marks drawn where it meets a box.
+	endClearance = 24
+	// gutterSpacing separates relationships routed through the gutter.
+	gutterSpacing = 16
+	// loopReach is how far a self reference loops out of its box, and
+	// loopSpacing how much further each further loop reaches.
+	loopReach   = 32
+	loopSpacing = 12
+	// minBoxWidth keeps boxes with short names from looking cramped.
+	minBoxWidth = 80
+)
+
+// Point is a position in pixels from the top left corner.
+type Point struct {
+	X, Y float64
+}
+
+// Rect is an axis-aligned rectangle.
+type Rect struct {
+	X, Y, W, H float64
+}
+
+// Row is a column of a table as drawn in its box.
+type Row struct {
+	Name string
+	Type string
+	// Keys lists the column's key constraints, such as "PK, FK".
+	Keys string
+	// Baseline is the y of the row's text.
+	Baseline float64
+}
+
+// Box is a table drawn as a header holding its label above one row per
+// column.
+type Box struct {
+	Rect
+	Table formatter.Table
+	// Label is the table's alias or name, marked when the table is external.
+	Label string
+	// HeaderBaseline is the y of the label's text.
+	HeaderBaseline float64
+	// NameX, TypeX and KeysX are where each column of row text starts.
+	NameX, TypeX, KeysX float64
+	Rows                []Row
+}
+
+// EndKind is the crow's foot notation at one end of a relationship.
+type EndKind int
+
+// Relationship end kinds.
+const (
+	// EndOne is exactly one, drawn as two bars.
+	EndOne EndKind = iota
+	// EndZeroOrOne is drawn as a bar and a circle.
+	EndZeroOrOne
+	// EndZeroOrMany is drawn as a crow's foot and a circle.
+	EndZeroOrMany
+)
+
+// End is where a relationship meets a box.
+type End struct {
+	At Point
+	// Dir is the unit vector from At along the relationship, away from the
+	// box.
+	Dir  Point
+	Kind EndKind
+}
+
+// Edge is a relationship routed as a polyline from the referenced table to
+// the referencing one.
+type Edge struct {
+	Points []Point
+	// From is the end at the referenced table and To the end at the
+	// referencing one.
+	From, To End
+	Label    string
+	LabelAt  Point
+	// LabelAnchor is "middle" when the label is centred above a horizontal
+	// run, and "start" when it follows a vertical one.
+	LabelAnchor string
+	// Dashed marks relationships the database does not enforce.
+	Dashed bool
+}
+
+// Diagram is a laid out ER diagram.
+type Diagram struct {
+	Width, Height float64
+	Title         string
+	TitleAt       Point
+	Boxes         []Box
+	Edges         []Edge
+}
+
+// Options configure a layout.
+type Options struct {
+	// Metrics default to DefaultMetrics.
+	Metrics Metrics
+	// ColumnType returns the type shown for a column; nil shows its data
+	// type.
+	ColumnType func(formatter.Column) string
+	// Title is drawn above the tables when set.
+	Title string
+}
+
+// TextWidth returns the width of s at FontSize.
+func (m Metrics) TextWidth(s string) float64 {
+	width := 0.0
+	for _, r := range s {
+		width += m.CharWidth
+		if isWide(r) {
+			width += m.CharWidth
+		}
+	}
+	return width
+}
+
+// isWide reports whether r takes two columns in a monospaced font.
+func isWide(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		r >= 0x3000 && r <= 0x303f || r >= 0xff00 && r <= 0xff60 || r >= 0xffe0 && r <= 0xffe6
+}
+
+// Layout places data's tables and routes its relationships. Relationships to
+// tables that are not in data are left out.
+func Layout(data formatter.RenderData, opts Options) Diagram {
+	m := opts.Metrics
+	if m == (Metrics{}) {
+		m = DefaultMetrics
+	}
+	columnType := opts.ColumnType
+	if columnType == nil {
+		columnType = func(c formatter.Column) string { return c.DataType }
+	}
+
+	tables := data.Tables
+	boxes := make([]Box, len(tables))
+	for i, table := range tables {
+		boxes[i] = newBox(table, m, columnType)
+	}
+
+	graph := NewGraph(tables)
+	layers := graph.ReduceCrossings(graph.Layers())
+
+	top := m.Margin
+	if opts.Title != "" {
+		top += m.TitleHeight
+	}
+
+	layerWidths := make([]float64, len(layers))
+	maxWidth := 0.0
+	for l, layer := range layers {
+		for k, i := range layer {
+			if k > 0 {
+				layerWidths[l] += m.BoxGap
+			}
+			layerWidths[l] += boxes[i].W
+		}
+		maxWidth = math.Max(maxWidth, layerWidths[l])
+	}
+
+	layerTops := make([]float64, len(layers))
+	layerBottoms := make([]float64, len(layers))
+	y := top
+	for l, layer := range layers {
+		layerTops[l] = y
+		height := 0.0
+		for _, i := range layer {
+			height = math.Max(height, boxes[i].H)
+		}
+		layerBottoms[l] = y + height
+		y += height + m.LayerGap
+
+		x := m.Margin + (maxWidth-layerWidths[l])/2
+		for _, i := range layer {
+			boxes[i].place(x, layerTops[l], m)
+			x += boxes[i].W + m.BoxGap
+		}
+	}
+
+	r := router{
+		metrics:      m,
+		tables:       tables,
+		boxes:        boxes,
+		level:        make([]int, len(tables)),
+		layerBottoms: layerBottoms,
+		right:        m.Margin + maxWidth,
+	}
+	for l, layer := range layers {
+		for _, i := range layer {
+			r.level[i] = l
+		}
+	}
+	edges := r.route()
+
+	diagram := Diagram{
+		Width:  r.right + m.Margin,
+		Height: top + m.Margin,
+		Title:  opts.Title,
+		Boxes:  boxes,
+		Edges:  edges,
+	}
+	if len(layers) > 0 {
+		diagram.Height = layerBottoms[len(layers)-1] + m.Margin
+		if r.bottomChannel {
+			diagram.Height += m.LayerGap
+		}
+	}
+	if opts.Title != "" {
+		diagram.TitleAt = Point{X: m.Margin, Y: m.Margin + m.TitleSize}
+		diagram.Width = math.Max(diagram.Width, 2*m.Margin+m.TextWidth(opts.Title)*m.TitleSize/m.FontSize)
+	}
+	return diagram
+}
+
+// newBox sizes the box of a table; place positions it.
+func newBox(table formatter.Table, m Metrics, columnType func(formatter.Column) string) Box {
+	box := Box{Table: table, Label: table.Alias}
+	if box.Label == "" {
+		box.Label = table.Name
+	}
+	if table.External {
+		box.Label += " (external)"
+	}
+
+	var nameWidth, typeWidth, keysWidth float64
+	for _, column := range table.Columns {
+		row := Row{Name: column.Name, Type: columnType(column), Keys: keyConstraints(table, column)}
+		nameWidth = math.Max(nameWidth, m.TextWidth(row.Name))
+		typeWidth = math.Max(typeWidth, m.TextWidth(row.Type))
+		keysWidth = math.Max(keysWidth, m.TextWidth(row.Keys))
+		box.Rows = append(box.Rows, row)
+	}
+
+	// Offsets from the box's left edge until place makes them absolute.
+	box.NameX = m.PaddingX
+	box.TypeX = box.NameX + nameWidth + m.ColumnGap
+	box.KeysX = box.TypeX + typeWidth
+	if typeWidth > 0 {
+		box.KeysX += m.ColumnGap
+	}
+	rowsWidth := box.KeysX + keysWidth + m.PaddingX
+	if keysWidth == 0 {
+		rowsWidth -= m.ColumnGap
+	}
+
+	box.W = math.Max(minBoxWidth, math.Max(rowsWidth, m.TextWidth(box.Label)+2*m.PaddingX))
+	box.H = m.HeaderHeight + float64(len(box.Rows))*m.RowHeight
+	if len(box.Rows) > 0 {
+		box.H += m.RowHeight / 4
+	}
+	return box
+}
+
+// place moves a box's top left corner to (x, y).
+func (b *Box) place(x, y float64, m Metrics) {
+	b.X, b.Y = x, y
+	b.NameX += x
+	b.TypeX += x
+	b.KeysX += x
+
+	textOffset := m.FontSize * 0.35
+	b.HeaderBaseline = y + m.HeaderHeight/2 + textOffset
+	for k := range b.Rows {
+		b.Rows[k].Baseline = y + m.HeaderHeight + m.RowHeight/8 + float64(k)*m.RowHeight + m.RowHeight/2 + textOffset
+	}
+}
+
+// keyConstraints lists a column's key constraints the way the Mermaid
+// formatter does.
+func keyConstraints(table formatter.Table, column formatter.Column) string {
+	keys := ""
+	add := func(key string) {
+		if keys != "" {
+			keys += ", "
+		}
+		keys += key
+	}
+
+	isPrimary := false
+	for _, name := range table.PrimaryKey {
+		if name == column.Name {
+			isPrimary = true
+			add("PK")
+			break
+		}
+	}
+	for _, fk := range table.ForeignKeys {
+		if fk.ColumnName == column.Name {
+			add("FK")
+			break
+		}
+	}
+	if column.IsUnique && !isPrimary {
+		add("UK")
+	}
+	return keys
+}
+
+// Sides of a box relationships attach to.
+const (
+	sideTop = iota
+	sideBottom
+)
+
+// router routes relationships between placed boxes.
+type router struct {
+	metrics      Metrics
+	tables       []formatter.Table
+	boxes        []Box
+	level        []int
+	layerBottoms []float64
+	// right is the right edge of the boxes, and grows by the gutter and self
+	// reference loops.
+	right float64
+	// bottomChannel is set when relationships run below the last layer.
+	bottomChannel bool
+}
+
+// route is one relationship on its way to becoming an Edge.
+type route struct {
+	parent, child   int
+	fk              formatter.ForeignKey
+	parentSide      int
+	childSide       int
+	parentX, childX float64
+	// channels are the gaps, by the layer above them, the relationship runs
+	// horizontally through, and ys its height in each.
+	channels []int
+	ys       []float64
+	gutter   bool
+	loop     int
+}
+
+func (r *router) route() []Edge {
+	index := make(map[string]int, len(r.tables))
+	for i, table := range r.tables {
+		index[table.Name] = i
+	}
+
+	var routes []*route
+	loops := make(map[int]int)
+	for i, table := range r.tables {
+		for _, fk := range table.ForeignKeys {
+			j, ok := index[fk.ReferencedTable]
+			if !ok {
+				continue
+			}
+
+			rt := &route{parent: j, child: i, fk: fk}
+			lp, lc := r.level[j], r.level[i]
+			switch {
+			case i == j:
+				rt.loop = loops[i]
+				loops[i]++
+			case lp < lc:
+				rt.parentSide, rt.childSide = sideBottom, sideTop
+				rt.channels = []int{lp}
+				if lc > lp+1 {
+					rt.channels = append(rt.channels, lc-1)
+				}
+			case lp > lc:
+				rt.parentSide, rt.childSide = sideTop, sideBottom
+				rt.channels = []int{lp - 1}
+				if lp-1 > lc {
+					rt.channels = append(rt.channels, lc)
+				}
+			default:
+				rt.parentSide, rt.childSide = sideBottom, sideBottom
+				rt.channels = []int{lp}
+			}
+			rt.gutter = len(rt.channels) > 1
+			routes = append(routes, rt)
+		}
+	}
+
+	r.assignPorts(routes)
+	gutters := r.assignChannels(routes)
+
+	for i, n := range loops {
+		r.right = math.Max(r.right, r.boxes[i].X+r.boxes[i].W+loopReach+float64(n-1)*loopSpacing)
+	}
+	gutterX := r.right + endClearance
+	if gutters > 0 {
+		r.right = gutterX + float64(gutters-1)*gutterSpacing + endClearance
+	}
+
+	edges := make([]Edge, 0, len(routes))
+	gutter := 0
+	for _, rt := range routes {
+		var points []Point
+		if rt.parent == rt.child {
+			points = r.loopPoints(rt)
+		} else {
+			from := Point{X: rt.parentX, Y: r.sideY(rt.parent, rt.parentSide)}
+			to := Point{X: rt.childX, Y: r.sideY(rt.child, rt.childSide)}
+			switch {
+			case rt.gutter:
+				x := gutterX + float64(gutter)*gutterSpacing
+				gutter++
+				points = []Point{from, {from.X, rt.ys[0]}, {x, rt.ys[0]}, {x, rt.ys[1]}, {to.X, rt.ys[1]}, to}
+			case from.X == to.X && rt.parentSide != rt.childSide:
+				points = []Point{from, to}
+			default:
+				points = []Point{from, {from.X, rt.ys[0]}, {to.X, rt.ys[0]}, to}
+			}
+		}
+
+		referenced, referencing := endKinds(rt.fk.Cardinality)
+		edge := Edge{
+			Points: points,
+			From:   newEnd(points[0], points[1], referenced),
+			To:     newEnd(points[len(points)-1], points[len(points)-2], referencing),
+			Label:  rt.fk.RelationName,
+			Dashed: rt.fk.Inferred || rt.fk.Virtual,
+		}
+		if rt.fk.Inferred {
+			edge.Label += " (inferred)"
+		}
+		if edge.Label != "" {
+			edge.LabelAt, edge.LabelAnchor = labelPosition(points, r.metrics)
+		}
+		edges = append(edges, edge)
+	}
+	return edges
+}
+
+// assignPorts spreads the relationships meeting each side of a box along it,
+// ordered by where their other end is to keep them from crossing.
+func (r *router) assignPorts(routes []*route) {
+	type port struct {
+		rt     *route
+		parent bool
+		other  float64
+	}
+	sides := make(map[[2]int][]port)
+	for _, rt := range routes {
+		if rt.parent == rt.child {
+			continue
+		}
+		parentKey, childKey := [2]int{rt.parent, rt.parentSide}, [2]int{rt.child, rt.childSide}
+		sides[parentKey] = append(sides[parentKey], port{rt: rt, parent: true, other: r.centerX(rt.child)})
+		sides[childKey] = append(sides[childKey], port{rt: rt, other: r.centerX(rt.parent)})
+	}
+
+	for key, ports := range sides {
+		sort.SliceStable(ports, func(a, b int) bool { return ports[a].other < ports[b].other })
+		box := r.boxes[key[0]]
+		for k, p := range ports {
+			x := box.X + box.W*float64(k+1)/float64(len(ports)+1)
+			if p.parent {
+				p.rt.parentX = x
+			} else {
+				p.rt.childX = x
+			}
+		}
+	}
+}
+
+// assignChannels gives each relationship its own height in every gap it
+// runs through, and returns how many need the gutter.
+func (r *router) assignChannels(routes []*route) int {
+	counts := make(map[int]int)
+	gutters := 0
+	for _, rt := range routes {
+		for _, c := range rt.channels {
+			counts[c]++
+			if c == len(r.layerBottoms)-1 {
+				r.bottomChannel = true
+			}
+		}
+		if rt.gutter {
+			gutters++
+		}
+	}
+
+	usable := r.metrics.LayerGap - 2*endClearance
+	used := make(map[int]int)
+	for _, rt := range routes {
+		for _, c := range rt.channels {
+			used[c]++
+			y := r.layerBottoms[c] + endClearance + usable*float64(used[c])/float64(counts[c]+1)
+			rt.ys = append(rt.ys, y)
+		}
+	}
+	return gutters
+}
+
+// loopPoints routes a self reference out of the right side of its box and
+// back.
+func (r *router) loopPoints(rt *route) []Point {
+	box := r.boxes[rt.child]
+	m := r.metrics
+
+	y1, y2 := box.Y+m.HeaderHeight/2, box.Y+m.HeaderHeight+m.RowHeight/2
+	if box.H < m.HeaderHeight+m.RowHeight {
+		y1, y2 = box.Y+box.H/4, box.Y+box.H*3/4
+	}
+	right := box.X + box.W
+	out := right + loopReach + float64(rt.loop)*loopSpacing
+	return []Point{{right, y1}, {out, y1}, {out, y2}, {right, y2}}
+}
+
+func (r *router) sideY(i, side int) float64 {
+	if side == sideTop {
+		return r.boxes[i].Y
+	}
+	return r.boxes[i].Y + r.boxes[i].H
+}
+
+func (r *router) centerX(i int) float64 {
+	return r.boxes[i].X + r.boxes[i].W/2
+}
+
+// endKinds returns the notation at the referenced and the referencing end of
+// a relationship, matching the Mermaid formatter's markers.
+func endKinds(cardinality string) (EndKind, EndKind) {
+	switch cardinality {
+	case formatter.CardinalityOneToOne:
+		return EndOne, EndZeroOrOne
+	case formatter.CardinalityOneToMany:
+		return EndZeroOrMany, EndOne
+	case formatter.CardinalityManyToMany:
+		return EndZeroOrMany, EndZeroOrMany
+	default:
+		return EndOne, EndZeroOrMany
+	}
+}
+
+func newEnd(at, next Point, kind EndKind) End {
+	dx, dy := next.X-at.X, next.Y-at.Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return End{At: at, Dir: Point{Y: 1}, Kind: kind}
+	}
+	return End{At: at, Dir: Point{X: dx / length, Y: dy / length}, Kind: kind}
+}
+
+// labelPosition places a label above the middle of the longest horizontal
+// run, or beside the longest vertical one when there is none.
+func labelPosition(points []Point, m Metrics) (Point, string) {
+	best, bestLength, horizontal := 0, -1.0, false
+	for k := 1; k < len(points); k++ {
+		a, b := points[k-1], points[k]
+		isHorizontal := a.Y == b.Y
+		length := math.Abs(b.X-a.X) + math.Abs(b.Y-a.Y)
+		if isHorizontal && !horizontal || isHorizontal == horizontal && length > bestLength {
+			best, bestLength, horizontal = k, length, isHorizontal
+		}
+	}
+
+	a, b := points[best-1], points[best]
+	mid := Point{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+	if horizontal {
+		return Point{X: mid.X, Y: mid.Y - 4}, "middle"
+	}
+	return Point{X: mid.X + 6, Y: mid.Y + m.LabelSize*0.35}, "start"
+}
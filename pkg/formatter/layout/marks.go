@@ -0,0 +1,49 @@
+package layout
+
+// Sizes of the crow's foot marks, in pixels along and across a relationship.
+const (
+	barNear     = 6
+	barFar      = 10
+	barHalf     = 6
+	crowLength  = 12
+	crowHalf    = 7
+	circleAt    = 17
+	circleRadii = 4
+)
+
+// Segment is a straight line.
+type Segment struct {
+	From, To Point
+}
+
+// Circle is drawn filled with the background so the relationship line does
+// not show through it.
+type Circle struct {
+	Center Point
+	Radius float64
+}
+
+// Marks returns the lines and circles drawing the end's crow's foot
+// notation: bars for one, a circle for zero and a crow's foot for many.
+func (e End) Marks() ([]Segment, []Circle) {
+	along := func(d float64) Point { return Point{X: e.At.X + e.Dir.X*d, Y: e.At.Y + e.Dir.Y*d} }
+	across := func(p Point, d float64) Point { return Point{X: p.X - e.Dir.Y*d, Y: p.Y + e.Dir.X*d} }
+	bar := func(d float64) Segment {
+		p := along(d)
+		return Segment{From: across(p, -barHalf), To: across(p, barHalf)}
+	}
+	circle := Circle{Center: along(circleAt), Radius: circleRadii}
+
+	switch e.Kind {
+	case EndOne:
+		return []Segment{bar(barNear), bar(barFar)}, nil
+	case EndZeroOrOne:
+		return []Segment{bar(barNear)}, []Circle{circle}
+	default:
+		tip := along(crowLength)
+		return []Segment{
+			{From: across(e.At, -crowHalf), To: tip},
+			{From: across(e.At, crowHalf), To: tip},
+		}, []Circle{circle}
+	}
+}
@@ -0,0 +1,139 @@
+package layout
+
+import (
+	"strings"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// Colours shared by the formatters that draw a layout.
+const (
+	Background = "#ffffff"
+	LineColour = "#333333"
+	KeysColour = "#666666"
+)
+
+// BoxColours are the colours of a table's box.
+type BoxColours struct {
+	Body   string
+	Header string
+	Stroke string
+	Text   string
+}
+
+// DefaultColours follow Mermaid's default theme.
+var DefaultColours = BoxColours{Body: "#ffffff", Header: "#ececff", Stroke: "#9370db", Text: "#333333"}
+
+// With applies the fill, stroke and color properties of a palette style, such
+// as "fill:#fde2e4,stroke:#c9184a", to the header, border and header text.
+func (c BoxColours) With(style string) BoxColours {
+	for _, property := range strings.Split(style, ",") {
+		name, value, _ := strings.Cut(property, ":")
+		switch strings.TrimSpace(name) {
+		case "fill":
+			c.Header = strings.TrimSpace(value)
+		case "stroke":
+			c.Stroke = strings.TrimSpace(value)
+		case "color":
+			c.Text = strings.TrimSpace(value)
+		}
+	}
+	return c
+}
+
+// Settings are the options shared by the formatters that draw a layout.
+type Settings struct {
+	TypeDisplay string
+	TypeStyle   string
+	Title       string
+	StyleRules  []formatter.StyleRule
+	Palette     string
+}
+
+// DefaultSettings returns the settings drawing formatters start from.
+func DefaultSettings() Settings {
+	return Settings{
+		TypeDisplay: formatter.TypeDisplayShort,
+		TypeStyle:   formatter.TypeStyleRaw,
+		Palette:     formatter.DefaultPalette,
+	}
+}
+
+// SettingsOptions lists the options Settings.Set accepts.
+func SettingsOptions() []formatter.OptionSpec {
+	return formatter.CommonOptions(
+		formatter.OptionTypeDisplay, formatter.OptionTypeStyle, formatter.OptionTitle,
+		formatter.OptionStyle, formatter.OptionPalette,
+	)
+}
+
+// Set applies the option formatter.OptionTypeDisplay,
+// formatter.OptionTypeStyle, formatter.OptionTitle, formatter.OptionStyle or
+// formatter.OptionPalette, and reports false for any other name.
+func (s *Settings) Set(name, value string) (bool, error) {
+	switch name {
+	case formatter.OptionTypeDisplay:
+		display, err := formatter.ParseTypeDisplay(value)
+		if err != nil {
+			return true, err
+		}
+		s.TypeDisplay = display
+	case formatter.OptionTypeStyle:
+		style, err := formatter.ParseTypeStyle(value)
+		if err != nil {
+			return true, err
+		}
+		s.TypeStyle = style
+	case formatter.OptionTitle:
+		s.Title = strings.Join(strings.Fields(value), " ")
+	case formatter.OptionStyle:
+		rules, err := formatter.ParseStyleRules(value)
+		if err != nil {
+			return true, err
+		}
+		s.StyleRules = rules
+	case formatter.OptionPalette:
+		palette, err := formatter.ParsePalette(value)
+		if err != nil {
+			return true, err
+		}
+		s.Palette = palette
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// Layout lays out data with the configured column types and title.
+func (s Settings) Layout(data formatter.RenderData) Diagram {
+	return Layout(data, Options{ColumnType: s.columnType, Title: s.Title})
+}
+
+// columnType returns the type shown for a column in the configured display
+// mode and style.
+func (s Settings) columnType(column formatter.Column) string {
+	if s.TypeStyle == formatter.TypeStylePortable {
+		return column.PortableType(s.TypeDisplay)
+	}
+	return column.Type(s.TypeDisplay)
+}
+
+// Classes returns the style classes whose rules match table, in the order
+// the rules name them, and the colours its box is drawn in: the first
+// matching class colours its header and border.
+func (s Settings) Classes(table formatter.Table) ([]string, BoxColours) {
+	colours := DefaultColours
+	var names []string
+	for _, class := range formatter.StyleClasses(s.StyleRules, s.Palette) {
+		for _, rule := range s.StyleRules {
+			if rule.Class == class.Name && rule.Matches(table) {
+				if names == nil {
+					colours = colours.With(class.Style)
+				}
+				names = append(names, class.Name)
+				break
+			}
+		}
+	}
+	return names, colours
+}
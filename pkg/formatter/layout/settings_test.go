@@ -0,0 +1,59 @@
+package layout
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func TestBoxColoursWith(t *testing.T) {
+	got := DefaultColours.With("fill:#e63946, stroke:#9d0208,color:#ffffff")
+	want := BoxColours{Body: DefaultColours.Body, Header: "#e63946", Stroke: "#9d0208", Text: "#ffffff"}
+	if got != want {
+		t.Errorf("With() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSettingsSet(t *testing.T) {
+	s := DefaultSettings()
+	for name, value := range map[string]string{
+		formatter.OptionTypeDisplay: "full",
+		formatter.OptionTitle:       "  Sales \n schema ",
+		formatter.OptionPalette:     "vivid",
+	} {
+		if known, err := s.Set(name, value); !known || err != nil {
+			t.Fatalf("Set(%q, %q) = %v, %v; want true, nil", name, value, known, err)
+		}
+	}
+	if s.TypeDisplay != formatter.TypeDisplayFull || s.Title != "Sales schema" || s.Palette != "vivid" {
+		t.Errorf("settings = %+v", s)
+	}
+
+	if known, err := s.Set(formatter.OptionPalette, "neon"); !known || err == nil {
+		t.Errorf("Set with an invalid palette = %v, %v; want true and an error", known, err)
+	}
+	if known, _ := s.Set(formatter.OptionDirection, "LR"); known {
+		t.Error("Set reported a layout-independent option as known")
+	}
+}
+
+func TestSettingsClasses(t *testing.T) {
+	s := DefaultSettings()
+	if _, err := s.Set(formatter.OptionStyle, "billing=billing_*;money=tag:money"); err != nil {
+		t.Fatal(err)
+	}
+
+	classes, colours := s.Classes(formatter.Table{Name: "billing_invoices", Comment: "Invoices #money"})
+	if want := []string{"billing", "money"}; !reflect.DeepEqual(classes, want) {
+		t.Errorf("classes = %v, want %v", classes, want)
+	}
+	if want := DefaultColours.With(formatter.Palettes[formatter.DefaultPalette][0]); colours != want {
+		t.Errorf("colours = %+v, want the first class's %+v", colours, want)
+	}
+
+	classes, colours = s.Classes(formatter.Table{Name: "users"})
+	if classes != nil || colours != DefaultColours {
+		t.Errorf("unmatched table got %v, %+v; want no classes and the default colours", classes, colours)
+	}
+}
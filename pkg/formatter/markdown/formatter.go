@@ -0,0 +1,343 @@
+// Package markdown renders a data dictionary in Markdown: the schema's
+// Mermaid diagram followed by a section per table with its columns, indexes
+// and relationships, linked to one another for wikis and repository docs.
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/mermaid"
+)
+
+func init() {
+	formatter.Register("markdown", func() formatter.Formatter {
+		return New()
+	})
+}
+
+// DefaultTitle heads documents without a formatter.OptionTitle.
+const DefaultTitle = "Database schema"
+
+// Formatter renders data dictionaries in Markdown.
+type Formatter struct {
+	title       string
+	typeDisplay string
+	typeStyle   string
+	// diagram renders the embedded Mermaid diagram.
+	diagram formatter.Formatter
+}
+
+// New creates a new Markdown formatter instance.
+func New() Formatter {
+	return Formatter{
+		title:       DefaultTitle,
+		typeDisplay: formatter.TypeDisplayShort,
+		typeStyle:   formatter.TypeStyleRaw,
+		diagram:     mermaid.New(),
+	}
+}
+
+// WithOptions returns the formatter configured by opts. It accepts the options
+// of the mermaid formatter: formatter.OptionTitle heads the document, and the
+// others shape the diagram, with formatter.OptionTypeDisplay and
+// formatter.OptionTypeStyle applying to the column tables too.
+func (f Formatter) WithOptions(opts formatter.Options) (formatter.Formatter, error) {
+	diagramOpts := make(formatter.Options, len(opts))
+	for name, value := range opts {
+		switch name {
+		case formatter.OptionTitle:
+			f.title = value
+			continue
+		case formatter.OptionTypeDisplay:
+			display, err := formatter.ParseTypeDisplay(value)
+			if err != nil {
+				return nil, err
+			}
+			f.typeDisplay = display
+		case formatter.OptionTypeStyle:
+			style, err := formatter.ParseTypeStyle(value)
+			if err != nil {
+				return nil, err
+			}
+			f.typeStyle = style
+		}
+		diagramOpts[name] = value
+	}
+
+	diagram, err := formatter.Configure(f.diagram, diagramOpts)
+	if err != nil {
+		return nil, err
+	}
+	f.diagram = diagram
+	return f, nil
+}
+
+// OptionSpecs lists the options WithOptions accepts.
+func (f Formatter) OptionSpecs() []formatter.OptionSpec {
+	specs := mermaid.New().OptionSpecs()
+	for i := range specs {
+		if specs[i].Name == formatter.OptionTitle {
+			specs[i].Description = "Document title"
+			specs[i].Default = DefaultTitle
+		}
+	}
+	return specs
+}
+
+// Metadata describes the format.
+func (f Formatter) Metadata() formatter.Metadata {
+	return formatter.Metadata{
+		Description: "Markdown data dictionary with an embedded Mermaid diagram",
+		Extension:   ".md",
+		Features: []formatter.Feature{
+			formatter.FeatureComments, formatter.FeatureCardinality, formatter.FeatureIndexes, formatter.FeatureStyles,
+		},
+	}
+}
+
+// Name returns the formatter name.
+func (f Formatter) Name() string {
+	return "markdown"
+}
+
+// MediaType returns the formatter output media type.
+func (f Formatter) MediaType() string {
+	return "text/markdown"
+}
+
+// Render builds the data dictionary for the provided render data.
+func (f Formatter) Render(data formatter.RenderData) (string, error) {
+	if len(data.Tables) == 0 {
+		return "", fmt.Errorf("no tables found in schema")
+	}
+
+	diagram, err := f.diagram.Render(data)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", inline(f.title))
+	fmt.Fprintf(&b, "```mermaid\n%s```\n", diagram)
+
+	anchors := tableAnchors(data.Tables)
+	for _, table := range data.Tables {
+		b.WriteString("\n")
+		f.writeTable(&b, table, data.Tables, anchors)
+	}
+	return b.String(), nil
+}
+
+// writeTable writes the section of a table.
+func (f Formatter) writeTable(b *strings.Builder, table formatter.Table, tables []formatter.Table, anchors map[string]string) {
+	fmt.Fprintf(b, "<a id=\"%s\"></a>\n\n## %s\n", anchors[table.Name], inline(table.Name))
+	if table.Alias != "" && table.Alias != table.Name {
+		fmt.Fprintf(b, "\n*%s*\n", inline(table.Alias))
+	}
+	if table.External {
+		b.WriteString("\nOutside the selection; only the referenced columns are listed.\n")
+	}
+	if table.Comment != "" {
+		fmt.Fprintf(b, "\n%s\n", inline(table.Comment))
+	}
+
+	f.writeColumns(b, table)
+	writeIndexes(b, table.Indexes)
+	writeForeignKeys(b, table.ForeignKeys, anchors)
+	writeReferences(b, table.Name, tables, anchors)
+}
+
+func (f Formatter) writeColumns(b *strings.Builder, table formatter.Table) {
+	if len(table.Columns) == 0 {
+		return
+	}
+
+	primary := make(map[string]bool, len(table.PrimaryKey))
+	for _, name := range table.PrimaryKey {
+		primary[name] = true
+	}
+	foreign := make(map[string]bool, len(table.ForeignKeys))
+	for _, fk := range table.ForeignKeys {
+		foreign[fk.ColumnName] = true
+	}
+
+	b.WriteString("\n### Columns\n\n")
+	b.WriteString("| Column | Type | Nullable | Key | Default | Comment |\n")
+	b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	for _, column := range table.Columns {
+		var keys []string
+		if primary[column.Name] || column.IsPrimary {
+			keys = append(keys, "PK")
+		}
+		if foreign[column.Name] {
+			keys = append(keys, "FK")
+		}
+		if column.IsUnique {
+			keys = append(keys, "UK")
+		}
+
+		fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s |\n",
+			cell(column.Name), cell(f.columnType(column)), yesNo(column.IsNullable),
+			strings.Join(keys, ", "), defaultValue(column.Default), cell(column.Comment))
+	}
+}
+
+// columnType returns the type shown for a column in the configured display
+// mode and style.
+func (f Formatter) columnType(column formatter.Column) string {
+	if f.typeStyle == formatter.TypeStylePortable {
+		return column.PortableType(f.typeDisplay)
+	}
+	return column.Type(f.typeDisplay)
+}
+
+func writeIndexes(b *strings.Builder, indexes []formatter.Index) {
+	if len(indexes) == 0 {
+		return
+	}
+
+	b.WriteString("\n### Indexes\n\n")
+	b.WriteString("| Index | Columns | Unique | Type |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+	for _, index := range indexes {
+		fmt.Fprintf(b, "| %s | %s | %s | %s |\n",
+			cell(index.Name), cell(strings.Join(index.Columns, ", ")), yesNo(index.Unique), cell(index.Type))
+	}
+}
+
+func writeForeignKeys(b *strings.Builder, foreignKeys []formatter.ForeignKey, anchors map[string]string) {
+	if len(foreignKeys) == 0 {
+		return
+	}
+
+	b.WriteString("\n### Foreign keys\n\n")
+	for _, fk := range foreignKeys {
+		fmt.Fprintf(b, "- %s → %s.%s%s\n",
+			code(fk.ColumnName), link(fk.ReferencedTable, anchors), code(fk.ReferencedColumn), details(fk))
+	}
+}
+
+// writeReferences lists the foreign keys of tables pointing at the named
+// table.
+func writeReferences(b *strings.Builder, name string, tables []formatter.Table, anchors map[string]string) {
+	var lines []string
+	for _, other := range tables {
+		for _, fk := range other.ForeignKeys {
+			if fk.ReferencedTable != name {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("- %s.%s → %s%s\n",
+				link(other.Name, anchors), code(fk.ColumnName), code(fk.ReferencedColumn), details(fk)))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	b.WriteString("\n### Referenced by\n\n")
+	for _, line := range lines {
+		b.WriteString(line)
+	}
+}
+
+// details describes what is notable about a foreign key: its name, a
+// cardinality other than many-to-one, and whether it was inferred or declared
+// in an overlay.
+func details(fk formatter.ForeignKey) string {
+	var notes []string
+	if fk.RelationName != "" {
+		notes = append(notes, inline(fk.RelationName))
+	}
+	if fk.Cardinality != "" && fk.Cardinality != formatter.CardinalityManyToOne {
+		notes = append(notes, fk.Cardinality)
+	}
+	if fk.Inferred {
+		notes = append(notes, "inferred")
+	}
+	if fk.Virtual {
+		notes = append(notes, "virtual")
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(notes, ", ") + ")"
+}
+
+// tableAnchors returns the id of each table's section. Names that map to the
+// same id, such as Orders and orders or order items and order-items, get a
+// numeric suffix in table order.
+func tableAnchors(tables []formatter.Table) map[string]string {
+	anchors := make(map[string]string, len(tables))
+	taken := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		base := anchor(table.Name)
+
+		id := base
+		for n := 2; taken[id]; n++ {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken[id] = true
+		anchors[table.Name] = id
+	}
+	return anchors
+}
+
+// anchor returns the id of a table's section before de-duplication.
+func anchor(name string) string {
+	var b strings.Builder
+	b.WriteString("table-")
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	return b.String()
+}
+
+// link links to a table's section, or names the table when the document has
+// none.
+func link(name string, anchors map[string]string) string {
+	if id, ok := anchors[name]; ok {
+		return fmt.Sprintf("[%s](#%s)", inline(name), id)
+	}
+	return code(name)
+}
+
+// code formats a name as inline code.
+func code(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "'") + "`"
+}
+
+// inline keeps text on one line.
+func inline(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// cell escapes text for a table cell, keeping line breaks as <br>.
+func cell(text string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+func defaultValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	if *value == "" {
+		return "`''`"
+	}
+	return cell(code(*value))
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,170 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+func TestFormatterMetadata(t *testing.T) {
+	f := New()
+
+	if got, want := f.Name(), "markdown"; got != want {
+		t.Fatalf("Name() = %q, want %q", got, want)
+	}
+	if got, want := f.MediaType(), "text/markdown"; got != want {
+		t.Fatalf("MediaType() = %q, want %q", got, want)
+	}
+	if got, want := f.Metadata().Extension, ".md"; got != want {
+		t.Fatalf("Metadata().Extension = %q, want %q", got, want)
+	}
+}
+
+func TestInitRegistersFormatter(t *testing.T) {
+	f, err := formatter.Get("markdown")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	if _, ok := f.(Formatter); !ok {
+		t.Fatalf("Get returned %T, want markdown.Formatter", f)
+	}
+}
+
+func TestRenderNoTables(t *testing.T) {
+	if _, err := New().Render(formatter.RenderData{}); err == nil {
+		t.Fatal("expected an error for empty render data")
+	}
+}
+
+func TestRenderDataDictionary(t *testing.T) {
+	got, err := New().Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	want := "# Database schema\n\n```mermaid\n" + formattertest.SampleMermaidOutput() + "```\n" + `
+<a id="table-teams"></a>
+
+## teams
+
+### Columns
+
+| Column | Type | Nullable | Key | Default | Comment |
+| --- | --- | --- | --- | --- | --- |
+| id | int | no | PK |  |  |
+| name | text | no |  |  |  |
+
+### Referenced by
+
+- [users](#table-users).` + "`team_id` → `id` (belongs_to)" + `
+
+<a id="table-users"></a>
+
+## users
+
+### Columns
+
+| Column | Type | Nullable | Key | Default | Comment |
+| --- | --- | --- | --- | --- | --- |
+| id | int | no | PK |  |  |
+| email | varchar | no | UK |  |  |
+| team_id | int | no | FK |  |  |
+
+### Foreign keys
+
+- ` + "`team_id` → [teams](#table-teams).`id` (belongs_to)\n"
+
+	if got != want {
+		t.Errorf("Render mismatch\n--- got ---\n%s\n--- want ---\n%s", got, want)
+	}
+}
+
+func TestRenderDetails(t *testing.T) {
+	deflt, empty := "CURRENT_TIMESTAMP", ""
+	data := formatter.RenderData{Tables: []formatter.Table{{
+		Name:    "Order Items",
+		Alias:   "Line items",
+		Comment: "One row\nper product",
+		Columns: []formatter.Column{
+			{Name: "created_at", DataType: "datetime", Default: &deflt},
+			{Name: "note", DataType: "varchar", ColumnType: "varchar(255)", IsNullable: true, Default: &empty, Comment: "a | b\nc"},
+			{Name: "order_id", DataType: "bigint"},
+		},
+		Indexes: []formatter.Index{
+			{Name: "idx_order", Columns: []string{"order_id", "created_at"}, Type: "BTREE"},
+		},
+		ForeignKeys: []formatter.ForeignKey{
+			{ColumnName: "order_id", ReferencedTable: "orders", ReferencedColumn: "id", Inferred: true, Cardinality: formatter.CardinalityOneToOne},
+		},
+	}}}
+
+	f, err := formatter.Configure(New(), formatter.Options{
+		formatter.OptionTitle:       "Shop",
+		formatter.OptionTypeDisplay: formatter.TypeDisplayFull,
+	})
+	if err != nil {
+		t.Fatalf("Configure returned error: %v", err)
+	}
+	got, err := f.Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"# Shop\n",
+		`<a id="table-order-items"></a>`,
+		"## Order Items\n\n*Line items*\n\nOne row per product\n",
+		"| created_at | datetime | no |  | `CURRENT_TIMESTAMP` |  |\n",
+		"| note | varchar(255) | yes |  | `''` | a \\| b<br>c |\n",
+		"| idx_order | order_id, created_at | no | BTREE |\n",
+		"- `order_id` → `orders`.`id` (one-to-one, inferred)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "title: Shop") {
+		t.Errorf("the title reached the diagram front matter:\n%s", got)
+	}
+}
+
+func TestRenderGivesCollidingNamesDistinctAnchors(t *testing.T) {
+	data := formatter.RenderData{Tables: []formatter.Table{
+		{Name: "Orders"},
+		{Name: "order items"},
+		{Name: "order-items", ForeignKeys: []formatter.ForeignKey{
+			{ColumnName: "order_id", ReferencedTable: "orders", ReferencedColumn: "id"},
+		}},
+		{Name: "orders"},
+	}}
+
+	got, err := New().Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		`<a id="table-orders"></a>`,
+		`<a id="table-order-items"></a>`,
+		`<a id="table-order-items-2"></a>`,
+		`<a id="table-orders-2"></a>`,
+		"- `order_id` → [orders](#table-orders-2).`id`\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Render lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestWithOptionsRejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []formatter.Options{
+		{formatter.OptionTypeDisplay: "huge"},
+		{"bogus": "1"},
+	} {
+		if _, err := formatter.Configure(New(), opts); err == nil {
+			t.Errorf("Configure(%v) returned no error", opts)
+		}
+	}
+}
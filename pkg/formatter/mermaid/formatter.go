@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"unicode"
 
 	"github.com/motchang/marid/pkg/formatter"
 )
@@ -15,11 +16,123 @@ func init() {
 }
 
 // Formatter renders ER diagrams using Mermaid syntax.
-type Formatter struct{}
+type Formatter struct {
+	typeDisplay string
+	typeStyle   string
+	// commentLength truncates comments to this many characters; zero keeps
+	// them whole.
+	commentLength int
+	tableComments string
+	frontMatter   frontMatter
+	styleRules    []formatter.StyleRule
+	palette       string
+}
 
 // New creates a new Mermaid formatter instance.
 func New() Formatter {
-	return Formatter{}
+	return Formatter{
+		typeDisplay:   formatter.TypeDisplayShort,
+		typeStyle:     formatter.TypeStyleRaw,
+		tableComments: formatter.TableCommentsNone,
+		palette:       formatter.DefaultPalette,
+	}
+}
+
+// WithOptions returns the formatter configured by opts. It accepts the column
+// options formatter.OptionTypeDisplay, formatter.OptionTypeStyle and
+// formatter.OptionCommentLength, formatter.OptionTableComments, and the
+// front-matter options formatter.OptionTitle, formatter.OptionDirection,
+// formatter.OptionEntityPadding and formatter.OptionTheme, and the styling
+// options formatter.OptionStyle and formatter.OptionPalette.
+func (f Formatter) WithOptions(opts formatter.Options) (formatter.Formatter, error) {
+	for name, value := range opts {
+		switch name {
+		case formatter.OptionTypeDisplay:
+			display, err := formatter.ParseTypeDisplay(value)
+			if err != nil {
+				return nil, err
+			}
+			f.typeDisplay = display
+		case formatter.OptionTypeStyle:
+			style, err := formatter.ParseTypeStyle(value)
+			if err != nil {
+				return nil, err
+			}
+			f.typeStyle = style
+		case formatter.OptionCommentLength:
+			length, err := formatter.ParseCommentLength(value)
+			if err != nil {
+				return nil, err
+			}
+			f.commentLength = length
+		case formatter.OptionTableComments:
+			mode, err := formatter.ParseTableComments(value)
+			if err != nil {
+				return nil, err
+			}
+			f.tableComments = mode
+		case formatter.OptionTitle:
+			f.frontMatter.title = normalizeText(value, 0)
+		case formatter.OptionDirection:
+			direction, err := formatter.ParseDirection(value)
+			if err != nil {
+				return nil, err
+			}
+			f.frontMatter.layoutDirection = direction
+		case formatter.OptionEntityPadding:
+			padding, err := formatter.ParseEntityPadding(value)
+			if err != nil {
+				return nil, err
+			}
+			f.frontMatter.entityPadding = padding
+		case formatter.OptionTheme:
+			theme, err := parseTheme(value)
+			if err != nil {
+				return nil, err
+			}
+			f.frontMatter.theme = theme
+		case formatter.OptionStyle:
+			rules, err := formatter.ParseStyleRules(value)
+			if err != nil {
+				return nil, err
+			}
+			f.styleRules = rules
+		case formatter.OptionPalette:
+			palette, err := formatter.ParsePalette(value)
+			if err != nil {
+				return nil, err
+			}
+			f.palette = palette
+		default:
+			return nil, fmt.Errorf("unknown mermaid option %q", name)
+		}
+	}
+	return f, nil
+}
+
+// OptionSpecs lists the options WithOptions accepts.
+func (f Formatter) OptionSpecs() []formatter.OptionSpec {
+	specs := formatter.CommonOptions(
+		formatter.OptionTypeDisplay, formatter.OptionTypeStyle, formatter.OptionCommentLength,
+		formatter.OptionTableComments, formatter.OptionTitle, formatter.OptionDirection,
+		formatter.OptionEntityPadding, formatter.OptionStyle, formatter.OptionPalette,
+	)
+	specs = append(specs, formatter.OptionSpec{
+		Name:        formatter.OptionTheme,
+		Description: "Mermaid theme",
+		Kind:        formatter.KindEnum,
+		Values:      append([]string(nil), themes...),
+	})
+	return formatter.SortOptions(specs)
+}
+
+// Metadata describes the format.
+func (f Formatter) Metadata() formatter.Metadata {
+	return formatter.Metadata{
+		Description: "Mermaid erDiagram source, for Markdown renderers and the Mermaid CLI",
+		Extension:   ".mmd",
+		Features:    []formatter.Feature{formatter.FeatureComments, formatter.FeatureCardinality, formatter.FeatureStyles},
+	}
 }
 
 // Name returns the formatter name.
@@ -40,39 +153,122 @@ func (f Formatter) Render(data formatter.RenderData) (string, error) {
 
 	var builder strings.Builder
 
+	ids := newEntityIDs(data.Tables)
+
+	if err := f.frontMatter.write(&builder); err != nil {
+		return "", err
+	}
 	builder.WriteString("erDiagram\n")
-	writeTables(&builder, data.Tables)
-	writeRelationships(&builder, buildRelationships(data.Tables))
+	f.writeTables(&builder, data.Tables, ids)
+	writeRelationships(&builder, buildRelationships(data.Tables), ids)
+	f.writeClasses(&builder, data.Tables, ids)
 
 	return builder.String(), nil
 }
 
-func writeTables(builder *strings.Builder, tables []formatter.Table) {
+// columnType returns the type shown for a column in the configured display
+// mode and style.
+func (f Formatter) columnType(column formatter.Column) string {
+	if f.typeStyle == formatter.TypeStylePortable {
+		return column.PortableType(f.typeDisplay)
+	}
+	return column.Type(f.typeDisplay)
+}
+
+func (f Formatter) writeTables(builder *strings.Builder, tables []formatter.Table, ids entityIDs) {
 	for _, table := range tables {
-		_, _ = fmt.Fprintf(builder, "    %s {\n", table.Name)
+		comment := normalizeText(table.Comment, f.commentLength)
+
+		labelComment := ""
+		switch f.tableComments {
+		case formatter.TableCommentsAlias:
+			labelComment = comment
+		case formatter.TableCommentsComment:
+			if comment != "" {
+				_, _ = fmt.Fprintf(builder, "    %%%% %s: %s\n", table.Name, comment)
+			}
+		}
+
+		_, _ = fmt.Fprintf(builder, "    %s {\n", entityHeader(table, ids.entity(table.Name), labelComment))
 
 		for _, column := range table.Columns {
-			builder.WriteString(columnAttrLine(table, column) + "\n")
+			builder.WriteString(columnAttrLine(table, column, f.columnType(column), f.commentLength) + "\n")
 		}
 
 		builder.WriteString("    }\n")
 	}
 }
 
-func columnAttrLine(table formatter.Table, column formatter.Column) string {
-	attrLine := fmt.Sprintf("        %s %s", column.Name, column.DataType)
+// entityHeader names the entity id. Display aliases, the real names of tables
+// whose id had to be escaped, the table comment when given, and the label of
+// external stubs, which keeps them from being mistaken for tables that have no
+// other columns, are written with Mermaid's entity alias syntax, e.g.
+// users["users: Registered customers"].
+func entityHeader(table formatter.Table, id, comment string) string {
+	label := table.Alias
+	if label == "" && (table.External || id != table.Name || comment != "") {
+		label = table.Name
+	}
+	if table.External {
+		label += " (external)"
+	}
+	if comment != "" {
+		label += ": " + comment
+	}
+
+	if label == "" {
+		return id
+	}
+	return id + "[" + quoteText(label, 0) + "]"
+}
+
+// columnAttrLine writes a column as an entity attribute. The comment is
+// normalized to one line and cut to commentLength characters when that is
+// positive.
+func columnAttrLine(table formatter.Table, column formatter.Column, columnType string, commentLength int) string {
+	attrLine := fmt.Sprintf("        %s %s", attributeName(column.Name), attributeType(columnType))
 
 	if keyConstraints := columnKeyConstraints(table, column); len(keyConstraints) > 0 {
 		attrLine += " " + strings.Join(keyConstraints, ", ")
 	}
 
-	if column.Comment != "" {
-		attrLine += fmt.Sprintf(" \"%s\"", column.Comment)
+	if comment := quoteText(column.Comment, commentLength); comment != `""` {
+		attrLine += " " + comment
 	}
 
 	return attrLine
 }
 
+// attributeType fits a column type into Mermaid's attribute type syntax, which
+// allows letters, digits, "-", "_", brackets and parentheses: quotes are
+// dropped, commas become "-" and spaces "_", so "decimal(10,2) unsigned"
+// renders as "decimal(10-2)_unsigned". A type that is left empty, or that
+// would start with something other than a letter, is written as "unknown" or
+// prefixed with "_".
+func attributeType(columnType string) string {
+	var builder strings.Builder
+	for _, r := range strings.Join(strings.Fields(columnType), " ") {
+		switch {
+		case r == ' ':
+			builder.WriteRune('_')
+		case r == ',':
+			builder.WriteRune('-')
+		case r == '-' || r == '_' || strings.ContainsRune("[]()", r),
+			r < 0x80 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'):
+			builder.WriteRune(r)
+		}
+	}
+
+	attrType := builder.String()
+	switch {
+	case attrType == "":
+		return "unknown"
+	case !unicode.IsLetter(rune(attrType[0])) && attrType[0] != '_':
+		return "_" + attrType
+	}
+	return attrType
+}
+
 func columnKeyConstraints(table formatter.Table, column formatter.Column) []string {
 	keyConstraints := []string{}
 
@@ -100,6 +296,9 @@ type relationship struct {
 	SourceTable      string
 	TargetTable      string
 	RelationName     string
+	Inferred         bool
+	Virtual          bool
+	Cardinality      string
 	CrossingDistance int
 }
 
@@ -121,6 +320,9 @@ func buildRelationships(tables []formatter.Table) []relationship {
 				SourceTable:      fk.ReferencedTable,
 				TargetTable:      table.Name,
 				RelationName:     fk.RelationName,
+				Inferred:         fk.Inferred,
+				Virtual:          fk.Virtual,
+				Cardinality:      fk.Cardinality,
 				CrossingDistance: crossingDistance(tablePositions, fk.ReferencedTable, targetPos, targetExists),
 			})
 		}
@@ -141,12 +343,99 @@ func crossingDistance(tablePositions map[string]int, sourceTable string, targetP
 	return abs(sourcePos - targetPos)
 }
 
-func writeRelationships(builder *strings.Builder, relationships []relationship) {
+// writeRelationships draws declared foreign keys as solid lines. Inferred and
+// virtual relationships, which the database does not enforce, are dashed, and
+// inferred ones say so in their label.
+func writeRelationships(builder *strings.Builder, relationships []relationship, ids entityIDs) {
 	for _, rel := range relationships {
-		_, _ = fmt.Fprintf(builder, "    %s ||--o{ %s : \"%s\"\n",
-			rel.SourceTable,
-			rel.TargetTable,
-			rel.RelationName)
+		line, label := "--", rel.RelationName
+		if rel.Inferred || rel.Virtual {
+			line = ".."
+		}
+		if rel.Inferred {
+			label += " (inferred)"
+		}
+
+		referenced, referencing := cardinalityMarkers(rel.Cardinality)
+		_, _ = fmt.Fprintf(builder, "    %s %s%s%s %s : %s\n",
+			ids.entity(rel.SourceTable),
+			referenced,
+			line,
+			referencing,
+			ids.entity(rel.TargetTable),
+			quoteText(label, 0))
+	}
+}
+
+// cardinalityMarkers returns the Mermaid crow's foot markers for the
+// referenced (left) and referencing (right) end of a relationship.
+func cardinalityMarkers(cardinality string) (string, string) {
+	switch cardinality {
+	case formatter.CardinalityOneToOne:
+		return "||", "o|"
+	case formatter.CardinalityOneToMany:
+		return "}o", "||"
+	case formatter.CardinalityManyToMany:
+		return "}o", "o{"
+	default:
+		return "||", "o{"
+	}
+}
+
+// tableClass is a Mermaid class and the tables it is attached to.
+type tableClass struct {
+	name    string
+	style   string
+	applies func(formatter.Table) bool
+}
+
+// flagClasses are the classes attached to tables by their flags. They are
+// written after the rule classes so their styles take precedence.
+var flagClasses = []tableClass{
+	{name: "focus", style: "stroke-width:4px,font-weight:bold", applies: func(t formatter.Table) bool { return t.Focus }},
+	{name: "external", style: "stroke-dasharray:5 5", applies: func(t formatter.Table) bool { return t.External }},
+}
+
+// tableClasses returns the classes of the style rules, coloured from the
+// palette, followed by flagClasses.
+func (f Formatter) tableClasses() []tableClass {
+	var classes []tableClass
+	for _, class := range formatter.StyleClasses(f.styleRules, f.palette) {
+		name := class.Name
+		classes = append(classes, tableClass{
+			name:  name,
+			style: class.Style,
+			applies: func(t formatter.Table) bool {
+				for _, rule := range f.styleRules {
+					if rule.Class == name && rule.Matches(t) {
+						return true
+					}
+				}
+				return false
+			},
+		})
+	}
+	return append(classes, flagClasses...)
+}
+
+// writeClasses colours tables by the style rules and highlights focus tables
+// and external stubs. A class is only defined when some table uses it, so
+// plain diagrams are unchanged.
+func (f Formatter) writeClasses(builder *strings.Builder, tables []formatter.Table, ids entityIDs) {
+	for _, class := range f.tableClasses() {
+		var names []string
+		for _, table := range tables {
+			if class.applies(table) {
+				names = append(names, ids.entity(table.Name))
+			}
+		}
+
+		if len(names) == 0 {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(builder, "    classDef %s %s\n", class.name, class.style)
+		_, _ = fmt.Fprintf(builder, "    class %s %s\n", strings.Join(names, ","), class.name)
 	}
 }
 
@@ -149,9 +149,10 @@ func TestRenderIncludesColumnComments(t *testing.T) {
 	}
 }
 
-// TestRenderForeignKeyToTableOutsideDiagram covers what marid produces when
-// --tables filters the schema: a foreign key can point at a table that is not
-// part of the rendered set, so its crossing distance cannot be computed.
+// TestRenderForeignKeyToTableOutsideDiagram covers render data holding a
+// foreign key to a table that is not part of the rendered set, so its crossing
+// distance cannot be computed. The diagram package normally adds a stub for
+// such tables (see --external-refs), but the formatter must not rely on it.
 func TestRenderForeignKeyToTableOutsideDiagram(t *testing.T) {
 	f := New()
 
@@ -347,7 +348,7 @@ func TestColumnAttrLine(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := columnAttrLine(tt.table, tt.column); got != tt.want {
+			if got := columnAttrLine(tt.table, tt.column, tt.column.DataType, 0); got != tt.want {
 				t.Errorf("columnAttrLine() = %q, want %q", got, tt.want)
 			}
 		})
@@ -463,3 +464,221 @@ func TestAbs(t *testing.T) {
 		}
 	}
 }
+
+func TestRenderHighlightsFocusTables(t *testing.T) {
+	f := New()
+
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "customers", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "orders", Focus: true, Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "invoices", Focus: true, Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+		},
+	}
+
+	got, err := f.Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	want := "    classDef focus stroke-width:4px,font-weight:bold\n" +
+		"    class orders,invoices focus\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("expected focus tables to be styled\nwant suffix:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestRenderWithoutFocusEmitsNoClasses(t *testing.T) {
+	got, err := New().Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if strings.Contains(got, "classDef") {
+		t.Errorf("expected no class definitions without focus tables, got:\n%s", got)
+	}
+}
+
+func TestRenderLabelsExternalStubs(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "customers", External: true, Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+		},
+	}
+
+	got, err := New().Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	want := "erDiagram\n" +
+		"    customers[\"customers (external)\"] {\n" +
+		"        id int\n" +
+		"    }\n" +
+		"    classDef external stroke-dasharray:5 5\n" +
+		"    class customers external\n"
+	if got != want {
+		t.Errorf("Render() mismatch\n--- want ---\n%s\n--- got ---\n%s", want, got)
+	}
+}
+
+func TestRenderDrawsInferredRelationshipsDashed(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "users", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{
+				Name:    "orders",
+				Columns: []formatter.Column{{Name: "user_id", DataType: "int"}, {Name: "shop_id", DataType: "int"}},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "shop_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "fk_shop"},
+					{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "user_id", Inferred: true},
+				},
+			},
+		},
+	}
+
+	got, err := New().Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"    users ||--o{ orders : \"fk_shop\"\n",
+		"    users ||..o{ orders : \"user_id (inferred)\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderUsesAliasesAsEntityLabels(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "users", Alias: "Customer", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "accounts", Alias: "Account", External: true, Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+		},
+	}
+
+	got, err := New().Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	for _, want := range []string{"    users[\"Customer\"] {\n", "    accounts[\"Account (external)\"] {\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output:\n%s", want, got)
+		}
+	}
+}
+
+func TestRenderVirtualRelationshipCardinality(t *testing.T) {
+	tests := []struct {
+		cardinality string
+		want        string
+	}{
+		{cardinality: "", want: "    posts ||..o{ comments : \"owner\"\n"},
+		{cardinality: formatter.CardinalityManyToOne, want: "    posts ||..o{ comments : \"owner\"\n"},
+		{cardinality: formatter.CardinalityOneToOne, want: "    posts ||..o| comments : \"owner\"\n"},
+		{cardinality: formatter.CardinalityOneToMany, want: "    posts }o..|| comments : \"owner\"\n"},
+		{cardinality: formatter.CardinalityManyToMany, want: "    posts }o..o{ comments : \"owner\"\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.cardinality, func(t *testing.T) {
+			data := formatter.RenderData{
+				Tables: []formatter.Table{
+					{Name: "posts", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+					{
+						Name:    "comments",
+						Columns: []formatter.Column{{Name: "owner_id", DataType: "int"}},
+						ForeignKeys: []formatter.ForeignKey{
+							{ColumnName: "owner_id", ReferencedTable: "posts", ReferencedColumn: "id", RelationName: "owner", Virtual: true, Cardinality: tt.cardinality},
+						},
+					},
+				},
+			}
+
+			got, err := New().Render(data)
+			if err != nil {
+				t.Fatalf("Render returned error: %v", err)
+			}
+
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("expected %q in output:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRenderTypeDisplay(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{{
+			Name: "orders",
+			Columns: []formatter.Column{
+				{Name: "id", DataType: "bigint", ColumnType: "bigint unsigned"},
+				{Name: "total", DataType: "decimal", ColumnType: "decimal(10,2)"},
+				{Name: "status", DataType: "enum", ColumnType: "enum('new','paid')"},
+				{Name: "note", DataType: "text"},
+			},
+		}},
+	}
+
+	tests := []struct {
+		name string
+		opts formatter.Options
+		want []string
+	}{
+		{
+			name: "short by default",
+			want: []string{"        id bigint\n", "        total decimal\n", "        status enum\n", "        note text\n"},
+		},
+		{
+			name: "portable types",
+			opts: formatter.Options{formatter.OptionTypeStyle: formatter.TypeStylePortable},
+			want: []string{"        id integer\n", "        total float\n", "        status enum\n", "        note string\n"},
+		},
+		{
+			name: "portable full types keep the type's parameters",
+			opts: formatter.Options{formatter.OptionTypeStyle: formatter.TypeStylePortable, formatter.OptionTypeDisplay: formatter.TypeDisplayFull},
+			want: []string{"        id integer_unsigned\n", "        total float(10-2)\n", "        note string\n"},
+		},
+		{
+			name: "full types fit Mermaid's attribute syntax",
+			opts: formatter.Options{formatter.OptionTypeDisplay: formatter.TypeDisplayFull},
+			want: []string{"        id bigint_unsigned\n", "        total decimal(10-2)\n", "        status enum(new-paid)\n", "        note text\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := formatter.Configure(New(), tt.opts)
+			if err != nil {
+				t.Fatalf("Configure returned error: %v", err)
+			}
+
+			got, err := f.Render(data)
+			if err != nil {
+				t.Fatalf("Render returned error: %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in output:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestWithOptionsRejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []formatter.Options{
+		{formatter.OptionTypeDisplay: "medium"},
+		{formatter.OptionTypeStyle: "fancy"},
+		{"colour": "blue"},
+	} {
+		if _, err := New().WithOptions(opts); err == nil {
+			t.Errorf("WithOptions(%v) returned no error", opts)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package mermaid
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// themes are the themes Mermaid ships with.
+var themes = []string{"default", "neutral", "dark", "forest", "base"}
+
+// frontMatter holds the settings Mermaid reads from the YAML block before the
+// diagram.
+type frontMatter struct {
+	title           string
+	theme           string
+	layoutDirection string
+	entityPadding   int
+}
+
+// frontMatterBlock is the YAML layout of the block.
+type frontMatterBlock struct {
+	Title  string             `yaml:"title,omitempty"`
+	Config *frontMatterConfig `yaml:"config,omitempty"`
+}
+
+// frontMatterConfig holds the configuration keys marid sets.
+type frontMatterConfig struct {
+	Theme string    `yaml:"theme,omitempty"`
+	ER    *erConfig `yaml:"er,omitempty"`
+}
+
+// erConfig holds Mermaid's erDiagram settings.
+type erConfig struct {
+	LayoutDirection string `yaml:"layoutDirection,omitempty"`
+	EntityPadding   int    `yaml:"entityPadding,omitempty"`
+}
+
+// write writes the block, or nothing when no setting is made, so plain
+// diagrams are unchanged.
+func (m frontMatter) write(builder *strings.Builder) error {
+	block := frontMatterBlock{Title: m.title}
+	if m.theme != "" {
+		block.Config = &frontMatterConfig{Theme: m.theme}
+	}
+	if m.layoutDirection != "" || m.entityPadding > 0 {
+		if block.Config == nil {
+			block.Config = &frontMatterConfig{}
+		}
+		block.Config.ER = &erConfig{LayoutDirection: m.layoutDirection, EntityPadding: m.entityPadding}
+	}
+
+	if block.Title == "" && block.Config == nil {
+		return nil
+	}
+
+	var content strings.Builder
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(block); err != nil {
+		return fmt.Errorf("error writing front matter: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("error writing front matter: %w", err)
+	}
+
+	builder.WriteString("---\n")
+	builder.WriteString(content.String())
+	builder.WriteString("---\n")
+	return nil
+}
+
+// parseTheme validates a theme name; empty means Mermaid's default.
+func parseTheme(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	theme := strings.ToLower(value)
+	for _, known := range themes {
+		if theme == known {
+			return theme, nil
+		}
+	}
+	return "", fmt.Errorf("invalid theme %q: want %s", value, strings.Join(themes, ", "))
+}
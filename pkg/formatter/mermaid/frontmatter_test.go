@@ -0,0 +1,136 @@
+package mermaid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+func TestRenderWritesFrontMatter(t *testing.T) {
+	f, err := New().WithOptions(formatter.Options{
+		formatter.OptionTitle:         "Shop: orders\nand users",
+		formatter.OptionDirection:     "lr",
+		formatter.OptionEntityPadding: "20",
+		formatter.OptionTheme:         "Forest",
+	})
+	if err != nil {
+		t.Fatalf("WithOptions returned error: %v", err)
+	}
+
+	got, err := f.Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	want := "---\n" +
+		"title: 'Shop: orders and users'\n" +
+		"config:\n" +
+		"  theme: forest\n" +
+		"  er:\n" +
+		"    layoutDirection: LR\n" +
+		"    entityPadding: 20\n" +
+		"---\n" +
+		"erDiagram\n"
+	if !strings.HasPrefix(got, want) {
+		t.Errorf("front matter mismatch\n--- want prefix ---\n%s\n--- got ---\n%s", want, got)
+	}
+
+	if err := checkGrammar(got); err != nil {
+		t.Errorf("output does not parse: %v\n%s", err, got)
+	}
+}
+
+func TestRenderWritesOnlyTheSetFrontMatter(t *testing.T) {
+	f, err := New().WithOptions(formatter.Options{formatter.OptionTitle: "Shop"})
+	if err != nil {
+		t.Fatalf("WithOptions returned error: %v", err)
+	}
+
+	got, err := f.Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if want := "---\ntitle: Shop\n---\nerDiagram\n"; !strings.HasPrefix(got, want) {
+		t.Errorf("expected %q at the start of:\n%s", want, got)
+	}
+}
+
+func TestWithOptionsRejectsInvalidFrontMatter(t *testing.T) {
+	for _, opts := range []formatter.Options{
+		{formatter.OptionDirection: "up"},
+		{formatter.OptionEntityPadding: "-5"},
+		{formatter.OptionTheme: "solarized"},
+		{formatter.OptionTableComments: "tooltip"},
+	} {
+		if _, err := New().WithOptions(opts); err == nil {
+			t.Errorf("WithOptions(%v) returned no error", opts)
+		}
+	}
+}
+
+func TestRenderTableComments(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "users", Comment: "Registered\ncustomers", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "accounts", Alias: "Account", Comment: "Billing accounts", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "tags", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+		},
+	}
+
+	tests := []struct {
+		mode   string
+		want   []string
+		absent []string
+	}{
+		{
+			mode: formatter.TableCommentsAlias,
+			want: []string{
+				"    users[\"users: Registered customers\"] {\n",
+				"    accounts[\"Account: Billing accounts\"] {\n",
+				"    tags {\n",
+			},
+		},
+		{
+			mode:   formatter.TableCommentsComment,
+			want:   []string{"    %% users: Registered customers\n    users {\n", "    %% accounts: Billing accounts\n    accounts[\"Account\"] {\n"},
+			absent: []string{"%% tags"},
+		},
+		{
+			mode:   formatter.TableCommentsNone,
+			want:   []string{"    users {\n", "    accounts[\"Account\"] {\n"},
+			absent: []string{"Registered", "Billing"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f, err := New().WithOptions(formatter.Options{formatter.OptionTableComments: tt.mode})
+			if err != nil {
+				t.Fatalf("WithOptions returned error: %v", err)
+			}
+
+			got, err := f.Render(data)
+			if err != nil {
+				t.Fatalf("Render returned error: %v", err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in output:\n%s", want, got)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(got, absent) {
+					t.Errorf("did not expect %q in output:\n%s", absent, got)
+				}
+			}
+
+			if err := checkGrammar(got); err != nil {
+				t.Errorf("output does not parse: %v\n%s", err, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// grammarLines are the line shapes of the erDiagram subset the formatter
+// writes, following Mermaid's lexer: entity names, attribute names and types
+// are bare words, and quoted strings end at the next double quote and cannot
+// hold control characters or backslashes, which are written as entity codes.
+var grammarLines = []*regexp.Regexp{
+	regexp.MustCompile(`^erDiagram$`),
+	regexp.MustCompile(`^    [A-Za-z_][A-Za-z0-9_]*(\["[^"\\\x00-\x1f]*"\])? \{$`),
+	regexp.MustCompile(`^        [*A-Za-z_][A-Za-z0-9\-_\[\]()]* [*A-Za-z_][A-Za-z0-9\-_\[\]()]*( (PK|FK|UK)(, (PK|FK|UK))*)?( "[^"\\\x00-\x1f]*")?$`),
+	regexp.MustCompile(`^    \}$`),
+	regexp.MustCompile(`^    %% [^\x00-\x1f]*$`),
+	regexp.MustCompile(`^    [A-Za-z_][A-Za-z0-9_]* (\|\||\}o|o\||o\{)(--|\.\.)(\|\||o\{|o\||\}o) [A-Za-z_][A-Za-z0-9_]* : "[^"\\\x00-\x1f]*"$`),
+	regexp.MustCompile(`^    classDef [A-Za-z_][A-Za-z0-9_-]* \S+$`),
+	regexp.MustCompile(`^    class [A-Za-z_][A-Za-z0-9_]*(,[A-Za-z_][A-Za-z0-9_]*)* [A-Za-z_][A-Za-z0-9_-]*$`),
+}
+
+// checkGrammar reports the first line of a rendered diagram that Mermaid
+// would not parse, and entity blocks that are not closed. A leading front
+// matter block must be valid YAML.
+func checkGrammar(diagram string) error {
+	if !strings.HasSuffix(diagram, "\n") {
+		return fmt.Errorf("diagram does not end with a newline")
+	}
+
+	if rest, ok := strings.CutPrefix(diagram, "---\n"); ok {
+		block, body, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return fmt.Errorf("front matter is not closed")
+		}
+		var parsed map[string]any
+		if err := yaml.Unmarshal([]byte(block), &parsed); err != nil {
+			return fmt.Errorf("front matter is not YAML: %w", err)
+		}
+		diagram = body
+	}
+
+	open := false
+	for i, line := range strings.Split(strings.TrimSuffix(diagram, "\n"), "\n") {
+		matched := false
+		for _, shape := range grammarLines {
+			if shape.MatchString(line) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("line %d does not parse: %q", i+1, line)
+		}
+
+		switch {
+		case strings.HasSuffix(line, " {"):
+			if open {
+				return fmt.Errorf("line %d opens an entity inside another", i+1)
+			}
+			open = true
+		case line == "    }":
+			if !open {
+				return fmt.Errorf("line %d closes no entity", i+1)
+			}
+			open = false
+		case strings.HasPrefix(line, "        ") && !open:
+			return fmt.Errorf("line %d is an attribute outside an entity", i+1)
+		}
+	}
+
+	if open {
+		return fmt.Errorf("entity left open")
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package mermaid
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/utils"
+)
+
+// reservedWords are erDiagram keywords, compared case-insensitively, that
+// cannot name an entity without confusing Mermaid's parser.
+var reservedWords = map[string]bool{
+	"erdiagram":  true,
+	"title":      true,
+	"acctitle":   true,
+	"accdescr":   true,
+	"direction":  true,
+	"style":      true,
+	"classdef":   true,
+	"class":      true,
+	"end":        true,
+	"u":          true,
+	"to":         true,
+	"optionally": true,
+	"one":        true,
+	"only":       true,
+	"zero":       true,
+	"many":       true,
+	"or":         true,
+	"more":       true,
+}
+
+// entityIDs maps table names to the identifiers their entities are written
+// with.
+type entityIDs map[string]string
+
+// newEntityIDs assigns every table an entity identifier. Names Mermaid
+// accepts as they are — ASCII letters, digits and underscores, not starting
+// with a digit and not a keyword — are kept. Any other name, such as
+// "order-items", "shop.orders", "注文" or "class", gets a sanitized
+// identifier made unique with a numeric suffix, and entityHeader shows the
+// real name as the entity's label.
+func newEntityIDs(tables []formatter.Table) entityIDs {
+	ids := make(entityIDs, len(tables))
+	taken := make(map[string]bool, len(tables))
+
+	for _, table := range tables {
+		if isPlainIdentifier(table.Name) {
+			ids[table.Name] = table.Name
+			taken[table.Name] = true
+		}
+	}
+
+	for _, table := range tables {
+		if _, ok := ids[table.Name]; ok {
+			continue
+		}
+
+		base := sanitizedEntity(table.Name)
+		id := base
+		for n := 2; taken[id]; n++ {
+			id = fmt.Sprintf("%s_%d", base, n)
+		}
+
+		ids[table.Name] = id
+		taken[id] = true
+	}
+
+	return ids
+}
+
+// entity returns the identifier of a table. Tables missing from the diagram
+// are sanitized the same way, without the uniqueness guarantee.
+func (ids entityIDs) entity(name string) string {
+	if id, ok := ids[name]; ok {
+		return id
+	}
+	if isPlainIdentifier(name) {
+		return name
+	}
+	return sanitizedEntity(name)
+}
+
+// sanitizedEntity turns name into an identifier Mermaid accepts.
+func sanitizedEntity(name string) string {
+	id := utils.SanitizeIdentifier(name)
+	if id == "" || reservedWords[strings.ToLower(id)] {
+		id += "_"
+	}
+	return id
+}
+
+// isPlainIdentifier reports whether Mermaid accepts name as an entity
+// identifier unchanged.
+func isPlainIdentifier(name string) bool {
+	return name != "" && utils.SanitizeIdentifier(name) == name && !reservedWords[strings.ToLower(name)]
+}
+
+// attributeName fits a column name into Mermaid's attribute name syntax.
+// Mermaid has no way to label attributes, so names it cannot parse are
+// written sanitized.
+func attributeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+	return utils.SanitizeIdentifier(name)
+}
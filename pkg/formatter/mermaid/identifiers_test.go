@@ -0,0 +1,76 @@
+package mermaid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func TestNewEntityIDs(t *testing.T) {
+	tables := []formatter.Table{
+		{Name: "orders"},
+		{Name: "order-items"},
+		{Name: "order items"},
+		{Name: "order_items"},
+		{Name: "shop.orders"},
+		{Name: "注文"},
+		{Name: "class"},
+		{Name: "2fa_codes"},
+	}
+
+	want := map[string]string{
+		"orders":      "orders",
+		"order_items": "order_items",
+		"order-items": "order_items_2",
+		"order items": "order_items_3",
+		"shop.orders": "shop_orders",
+		"注文":          "__",
+		"class":       "class_",
+		"2fa_codes":   "_2fa_codes",
+	}
+
+	ids := newEntityIDs(tables)
+	for name, id := range want {
+		if got := ids.entity(name); got != id {
+			t.Errorf("entity(%q) = %q, want %q", name, got, id)
+		}
+	}
+
+	// Tables outside the diagram are escaped on the fly.
+	if got := ids.entity("audit-log"); got != "audit_log" {
+		t.Errorf("entity of an unknown table = %q, want %q", got, "audit_log")
+	}
+}
+
+func TestRenderEscapesIdentifiers(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "order-items", Focus: true, Columns: []formatter.Column{
+				{Name: "order id", DataType: "int"},
+			}, ForeignKeys: []formatter.ForeignKey{
+				{ColumnName: "order id", ReferencedTable: "sales.orders", ReferencedColumn: "id", RelationName: "fk_order"},
+			}},
+			{Name: "sales.orders", Alias: `The "orders"`, Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "end", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+		},
+	}
+
+	got, err := New().Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"    order_items[\"order-items\"] {\n",
+		"        order_id int FK\n",
+		"    sales_orders[\"The #quot;orders#quot;\"] {\n",
+		"    end_[\"end\"] {\n",
+		"    sales_orders ||--o{ order_items : \"fk_order\"\n",
+		"    class order_items focus\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output:\n%s", want, got)
+		}
+	}
+}
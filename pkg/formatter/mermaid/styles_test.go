@@ -0,0 +1,98 @@
+package mermaid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func TestRenderAppliesStyleRules(t *testing.T) {
+	f, err := New().WithOptions(formatter.Options{
+		formatter.OptionStyle:   "billing=billing_*;auth=tag:auth;catalog=group:catalog",
+		formatter.OptionPalette: "vivid",
+	})
+	if err != nil {
+		t.Fatalf("WithOptions returned error: %v", err)
+	}
+
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "billing_invoices", Focus: true, Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "users", Comment: "Accounts #auth", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "billing-refunds", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "products", Group: "Catalog", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{Name: "logs", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+		},
+	}
+
+	got, err := f.Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	vivid := formatter.Palettes["vivid"]
+	want := "    classDef billing " + vivid[0] + "\n" +
+		"    class billing_invoices billing\n" +
+		"    classDef auth " + vivid[1] + "\n" +
+		"    class users auth\n" +
+		"    classDef catalog " + vivid[2] + "\n" +
+		"    class products catalog\n" +
+		"    classDef focus stroke-width:4px,font-weight:bold\n" +
+		"    class billing_invoices focus\n"
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("class assignments mismatch\n--- want suffix ---\n%s\n--- got ---\n%s", want, got)
+	}
+
+	if err := checkGrammar(got); err != nil {
+		t.Errorf("output does not parse: %v\n%s", err, got)
+	}
+}
+
+func TestRenderUsesEntityIDsForStyledTables(t *testing.T) {
+	f, err := New().WithOptions(formatter.Options{formatter.OptionStyle: "orders=order*"})
+	if err != nil {
+		t.Fatalf("WithOptions returned error: %v", err)
+	}
+
+	got, err := f.Render(formatter.RenderData{
+		Tables: []formatter.Table{{Name: "order-items", Columns: []formatter.Column{{Name: "id", DataType: "int"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if !strings.Contains(got, "    class order_items orders\n") {
+		t.Errorf("expected the sanitized entity id in the class line, got:\n%s", got)
+	}
+}
+
+func TestRenderSkipsUnusedStyleClasses(t *testing.T) {
+	f, err := New().WithOptions(formatter.Options{formatter.OptionStyle: "billing=billing_*"})
+	if err != nil {
+		t.Fatalf("WithOptions returned error: %v", err)
+	}
+
+	got, err := f.Render(formatter.RenderData{
+		Tables: []formatter.Table{{Name: "users", Columns: []formatter.Column{{Name: "id", DataType: "int"}}}},
+	})
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if strings.Contains(got, "classDef") {
+		t.Errorf("expected no class definitions when no table matches, got:\n%s", got)
+	}
+}
+
+func TestWithOptionsRejectsInvalidStyles(t *testing.T) {
+	for _, opts := range []formatter.Options{
+		{formatter.OptionStyle: "billing"},
+		{formatter.OptionStyle: "external=stub_*"},
+		{formatter.OptionPalette: "neon"},
+	} {
+		if _, err := New().WithOptions(opts); err == nil {
+			t.Errorf("WithOptions(%v) returned no error", opts)
+		}
+	}
+}
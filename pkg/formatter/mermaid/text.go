@@ -0,0 +1,53 @@
+package mermaid
+
+import (
+	"strings"
+	"unicode"
+)
+
+// ellipsis ends text that quoteText truncated.
+const ellipsis = "…"
+
+// textEscaper writes the characters a Mermaid string cannot hold as Mermaid
+// entity codes, which the renderer shows as the characters themselves.
+var textEscaper = strings.NewReplacer(`"`, "#quot;", `\`, "#92;")
+
+// quoteText turns free text — comments, labels, constraint names — into a
+// double-quoted Mermaid string: normalized by normalizeText, with double
+// quotes and backslashes escaped as #quot; and #92;.
+func quoteText(text string, maxLength int) string {
+	return `"` + textEscaper.Replace(normalizeText(text, maxLength)) + `"`
+}
+
+// normalizeText puts free text on one line, as a Mermaid string cannot span
+// lines. Line breaks, tabs and other control characters become spaces, runs
+// of spaces collapse, and the text is trimmed; everything else, quotes and
+// backslashes included, is kept. When maxLength is positive, longer text is
+// cut to maxLength characters ending in an ellipsis.
+func normalizeText(text string, maxLength int) string {
+	var builder strings.Builder
+
+	length, pendingSpace := 0, false
+	for _, r := range text {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			pendingSpace = length > 0
+			continue
+		}
+
+		if pendingSpace {
+			builder.WriteByte(' ')
+			length++
+			pendingSpace = false
+		}
+		builder.WriteRune(r)
+		length++
+	}
+
+	normalized := builder.String()
+	if maxLength <= 0 || length <= maxLength {
+		return normalized
+	}
+
+	runes := []rune(normalized)
+	return strings.TrimRightFunc(string(runes[:maxLength-1]), unicode.IsSpace) + ellipsis
+}
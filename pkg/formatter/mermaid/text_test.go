@@ -0,0 +1,174 @@
+package mermaid
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+func TestQuoteText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		maxLength int
+		want      string
+	}{
+		{name: "plain", text: "user id", want: `"user id"`},
+		{name: "empty", text: "", want: `""`},
+		{name: "double quotes", text: `the "real" name`, want: `"the #quot;real#quot; name"`},
+		{name: "backslashes", text: `C:\data\dump`, want: `"C:#92;data#92;dump"`},
+		{name: "quotes and backslashes", text: `path C:\tmp "quoted"`, want: `"path C:#92;tmp #quot;quoted#quot;"`},
+		{name: "single quotes are kept", text: "it's", want: `"it's"`},
+		{name: "line breaks and tabs", text: "first line\r\nsecond\tline\n", want: `"first line second line"`},
+		{name: "control characters", text: "a\x00b\x1bc", want: `"a b c"`},
+		{name: "surrounding space", text: "  padded  ", want: `"padded"`},
+		{name: "full-width quotes are kept", text: "顧客の＂氏名＂\n「本名」", want: `"顧客の＂氏名＂ 「本名」"`},
+		{name: "short enough", text: "12345", maxLength: 5, want: `"12345"`},
+		{name: "truncated", text: "123456", maxLength: 5, want: `"1234…"`},
+		{name: "truncated by characters", text: "登録済みの顧客", maxLength: 4, want: `"登録済…"`},
+		{name: "truncation drops the trailing space", text: "ab cd", maxLength: 4, want: `"ab…"`},
+		{name: "truncation counts escaped characters once", text: `a"b"cd`, maxLength: 5, want: `"a#quot;b#quot;…"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteText(tt.text, tt.maxLength); got != tt.want {
+				t.Errorf("quoteText(%q, %d) = %s, want %s", tt.text, tt.maxLength, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderEscapesFreeText(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "users", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+			{
+				Name: "orders",
+				Columns: []formatter.Column{
+					{Name: "user_id", DataType: "int", Comment: "注文者の\n\"ユーザー\"ID"},
+					{Name: "note", DataType: "text", Comment: " \n "},
+				},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: `fk "orders" users`},
+				},
+			},
+		},
+	}
+
+	got, err := New().Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	for _, want := range []string{
+		"        user_id int FK \"注文者の #quot;ユーザー#quot;ID\"\n",
+		"        note text\n",
+		"    users ||--o{ orders : \"fk #quot;orders#quot; users\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output:\n%s", want, got)
+		}
+	}
+
+	if err := checkGrammar(got); err != nil {
+		t.Errorf("output does not parse: %v\n%s", err, got)
+	}
+}
+
+func TestRenderTruncatesComments(t *testing.T) {
+	data := formatter.RenderData{
+		Tables: []formatter.Table{{
+			Name:    "users",
+			Columns: []formatter.Column{{Name: "bio", DataType: "text", Comment: "A long free-text biography"}},
+		}},
+	}
+
+	f, err := New().WithOptions(formatter.Options{formatter.OptionCommentLength: "10"})
+	if err != nil {
+		t.Fatalf("WithOptions returned error: %v", err)
+	}
+
+	got, err := f.Render(data)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if want := "        bio text \"A long fr…\"\n"; !strings.Contains(got, want) {
+		t.Errorf("expected %q in output:\n%s", want, got)
+	}
+}
+
+func TestSampleOutputParses(t *testing.T) {
+	got, err := New().Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	if err := checkGrammar(got); err != nil {
+		t.Errorf("sample output does not parse: %v\n%s", err, got)
+	}
+}
+
+func FuzzRenderFreeText(f *testing.F) {
+	f.Add("users", "Registered users", "id", "int", "primary id", "fk_orders_users", 0)
+	f.Add("order-items", `say "hi"`, "注文 ID", "decimal(10,2) unsigned", "顧客の\n「氏名」\"本名\"", "a\\b", 8)
+	f.Add("class", "\x00\t\r\n", "", "'''", "\"", "\n", 1)
+	f.Add("2fa", "日本語", "end", "enum('a','b')", "\xff\xfe", "", 3)
+
+	f.Fuzz(func(t *testing.T, tableName, alias, columnName, columnType, comment, relation string, commentLength int) {
+		if commentLength < 0 {
+			commentLength = -commentLength
+		}
+		commentLength %= 100
+
+		data := formatter.RenderData{
+			Tables: []formatter.Table{
+				{Name: "parents", Columns: []formatter.Column{{Name: "id", DataType: "int"}}},
+				{
+					Name:       tableName,
+					Alias:      alias,
+					Comment:    comment,
+					Focus:      true,
+					PrimaryKey: []string{columnName},
+					Columns: []formatter.Column{
+						{Name: columnName, DataType: columnType, ColumnType: columnType, Comment: comment},
+					},
+					ForeignKeys: []formatter.ForeignKey{
+						{ColumnName: columnName, ReferencedTable: "parents", ReferencedColumn: "id", RelationName: relation},
+						{ColumnName: columnName, ReferencedTable: tableName + " archive", ReferencedColumn: "id", RelationName: relation, Inferred: true},
+					},
+				},
+			},
+		}
+
+		fmttr, err := New().WithOptions(formatter.Options{
+			formatter.OptionTypeDisplay:   formatter.TypeDisplayFull,
+			formatter.OptionCommentLength: strconv.Itoa(commentLength),
+			formatter.OptionTitle:         alias,
+		})
+		if err != nil {
+			t.Fatalf("WithOptions returned error: %v", err)
+		}
+
+		got, err := fmttr.Render(data)
+		if err != nil {
+			t.Fatalf("Render returned error: %v", err)
+		}
+
+		if err := checkGrammar(got); err != nil {
+			t.Fatalf("output does not parse: %v\n%s", err, got)
+		}
+
+		if commentLength > 0 {
+			normalized := normalizeText(comment, commentLength)
+			if n := utf8.RuneCountInString(normalized); n > commentLength {
+				t.Fatalf("comment %q is %d characters, longer than %d", normalized, n, commentLength)
+			}
+		}
+	})
+}
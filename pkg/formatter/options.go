@@ -0,0 +1,229 @@
+package formatter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Options holds formatter settings by name, such as OptionTypeDisplay.
+type Options map[string]string
+
+// Configurable is implemented by formatters that accept Options.
+type Configurable interface {
+	// WithOptions returns the formatter configured by opts. Unknown names and
+	// invalid values are errors.
+	WithOptions(Options) (Formatter, error)
+}
+
+// OptionTypeDisplay chooses how column types are shown: TypeDisplayShort or
+// TypeDisplayFull.
+const OptionTypeDisplay = "type-display"
+
+// Column type display modes.
+const (
+	// TypeDisplayShort shows the base type, such as "varchar".
+	TypeDisplayShort = "short"
+	// TypeDisplayFull shows the full type, such as "varchar(255)".
+	TypeDisplayFull = "full"
+)
+
+// OptionTypeStyle chooses the vocabulary of column types: TypeStyleRaw or
+// TypeStylePortable.
+const OptionTypeStyle = "type-style"
+
+// Column type styles.
+const (
+	// TypeStyleRaw shows MySQL's own type names, such as "bigint".
+	TypeStyleRaw = "raw"
+	// TypeStylePortable shows database-neutral names, such as "integer".
+	TypeStylePortable = "portable"
+)
+
+// OptionCommentLength truncates comments to at most this many characters; "0"
+// keeps them whole.
+const OptionCommentLength = "comment-length"
+
+// OptionTableComments chooses how table comments are shown:
+// TableCommentsAlias, TableCommentsComment or TableCommentsNone.
+const OptionTableComments = "table-comments"
+
+// Table comment modes.
+const (
+	// TableCommentsNone leaves table comments out.
+	TableCommentsNone = "none"
+	// TableCommentsAlias shows the comment in the entity's visible label.
+	TableCommentsAlias = "alias"
+	// TableCommentsComment writes the comment as a source comment, which
+	// renderers do not show.
+	TableCommentsComment = "comment"
+)
+
+// Diagram-wide options.
+const (
+	// OptionTitle is the diagram's title.
+	OptionTitle = "title"
+	// OptionDirection is the layout direction: one of Directions.
+	OptionDirection = "direction"
+	// OptionEntityPadding is the space, in pixels, around an entity's text.
+	OptionEntityPadding = "entity-padding"
+	// OptionTheme is the name of a renderer theme.
+	OptionTheme = "theme"
+)
+
+// Raster options.
+const (
+	// OptionDPI is the resolution of raster output, in dots per inch.
+	OptionDPI = "dpi"
+	// OptionPageSize is the page of paged output: one of PageSizes.
+	OptionPageSize = "page-size"
+)
+
+// OptionTemplate is the path of the text/template file the template format
+// renders.
+const OptionTemplate = "template"
+
+// MaxDPI bounds OptionDPI.
+const MaxDPI = 1200
+
+// PageSizeFit sizes the page to the diagram.
+const PageSizeFit = "fit"
+
+// PageSizes lists the accepted OptionPageSize values. Fixed sizes turn
+// landscape for diagrams wider than they are tall.
+var PageSizes = []string{PageSizeFit, "a4", "a3", "letter", "legal"}
+
+// Directions lists the accepted OptionDirection values: top to bottom,
+// bottom to top, left to right and right to left.
+var Directions = []string{"TB", "BT", "LR", "RL"}
+
+// Configure applies opts to f. Formatters without options accept only an
+// empty set, and formatters that declare their options with Describer are
+// checked against those declarations first.
+func Configure(f Formatter, opts Options) (Formatter, error) {
+	if len(opts) == 0 {
+		return f, nil
+	}
+
+	configurable, ok := f.(Configurable)
+	if !ok {
+		return nil, fmt.Errorf("format %q takes no options", f.Name())
+	}
+	if specs := Specs(f); specs != nil {
+		if err := ValidateOptions(f.Name(), specs, opts); err != nil {
+			return nil, err
+		}
+	}
+	return configurable.WithOptions(opts)
+}
+
+// ParseTypeDisplay validates a OptionTypeDisplay value; empty means
+// TypeDisplayShort.
+func ParseTypeDisplay(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", TypeDisplayShort:
+		return TypeDisplayShort, nil
+	case TypeDisplayFull:
+		return TypeDisplayFull, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: want %s or %s", OptionTypeDisplay, value, TypeDisplayShort, TypeDisplayFull)
+	}
+}
+
+// ParseTypeStyle validates a OptionTypeStyle value; empty means TypeStyleRaw.
+func ParseTypeStyle(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", TypeStyleRaw:
+		return TypeStyleRaw, nil
+	case TypeStylePortable:
+		return TypeStylePortable, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: want %s or %s", OptionTypeStyle, value, TypeStyleRaw, TypeStylePortable)
+	}
+}
+
+// ParseCommentLength validates a OptionCommentLength value; empty means 0.
+func ParseCommentLength(value string) (int, error) {
+	return parseCount(OptionCommentLength, value, "a number of characters, or 0 for no limit")
+}
+
+// ParseEntityPadding validates a OptionEntityPadding value; empty means 0,
+// which leaves the renderer's default.
+func ParseEntityPadding(value string) (int, error) {
+	return parseCount(OptionEntityPadding, value, "a number of pixels")
+}
+
+// ParseDPI validates a OptionDPI value; empty means 0, which leaves the
+// formatter's default.
+func ParseDPI(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	dpi, err := strconv.Atoi(value)
+	if err != nil || dpi < 1 || dpi > MaxDPI {
+		return 0, fmt.Errorf("invalid %s %q: want a resolution from 1 to %d", OptionDPI, value, MaxDPI)
+	}
+	return dpi, nil
+}
+
+// ParsePageSize validates a OptionPageSize value, in any case; empty means
+// PageSizeFit.
+func ParsePageSize(value string) (string, error) {
+	if value == "" {
+		return PageSizeFit, nil
+	}
+
+	size := strings.ToLower(value)
+	for _, known := range PageSizes {
+		if size == known {
+			return size, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q: want %s", OptionPageSize, value, strings.Join(PageSizes, ", "))
+}
+
+// parseCount parses a non-negative integer option; empty means 0.
+func parseCount(name, value, want string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return 0, fmt.Errorf("invalid %s %q: want %s", name, value, want)
+	}
+	return count, nil
+}
+
+// ParseTableComments validates a OptionTableComments value; empty means
+// TableCommentsNone.
+func ParseTableComments(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "", TableCommentsNone:
+		return TableCommentsNone, nil
+	case TableCommentsAlias:
+		return TableCommentsAlias, nil
+	case TableCommentsComment:
+		return TableCommentsComment, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: want %s, %s or %s",
+			OptionTableComments, value, TableCommentsAlias, TableCommentsComment, TableCommentsNone)
+	}
+}
+
+// ParseDirection validates a OptionDirection value, in any case; empty means
+// none was chosen.
+func ParseDirection(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	direction := strings.ToUpper(value)
+	for _, known := range Directions {
+		if direction == known {
+			return direction, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q: want %s", OptionDirection, value, strings.Join(Directions, ", "))
+}
@@ -0,0 +1,140 @@
+package formatter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// plainFormatter accepts no options.
+type plainFormatter struct{}
+
+func (plainFormatter) Name() string                                { return "plain" }
+func (plainFormatter) MediaType() string                           { return "text/plain" }
+func (plainFormatter) Render(formatter.RenderData) (string, error) { return "", nil }
+
+func TestConfigure(t *testing.T) {
+	f := plainFormatter{}
+
+	got, err := formatter.Configure(f, nil)
+	if err != nil || got != f {
+		t.Fatalf("Configure without options = %v, %v; want the formatter unchanged", got, err)
+	}
+
+	_, err = formatter.Configure(f, formatter.Options{formatter.OptionTypeDisplay: formatter.TypeDisplayFull})
+	if err == nil || !strings.Contains(err.Error(), `format "plain" takes no options`) {
+		t.Fatalf("expected an error for options to a plain formatter, got %v", err)
+	}
+}
+
+func TestParseTypeDisplay(t *testing.T) {
+	tests := map[string]string{"": formatter.TypeDisplayShort, "short": formatter.TypeDisplayShort, "FULL": formatter.TypeDisplayFull}
+	for value, want := range tests {
+		if got, err := formatter.ParseTypeDisplay(value); err != nil || got != want {
+			t.Errorf("ParseTypeDisplay(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := formatter.ParseTypeDisplay("long"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestColumnType(t *testing.T) {
+	column := formatter.Column{DataType: "varchar", ColumnType: "varchar(255)"}
+	if got := column.Type(formatter.TypeDisplayShort); got != "varchar" {
+		t.Errorf("short type = %q", got)
+	}
+	if got := column.Type(formatter.TypeDisplayFull); got != "varchar(255)" {
+		t.Errorf("full type = %q", got)
+	}
+	if got := (formatter.Column{DataType: "int"}).Type(formatter.TypeDisplayFull); got != "int" {
+		t.Errorf("full type without ColumnType = %q", got)
+	}
+}
+
+func TestParseTypeStyle(t *testing.T) {
+	tests := map[string]string{"": formatter.TypeStyleRaw, "raw": formatter.TypeStyleRaw, "Portable": formatter.TypeStylePortable}
+	for value, want := range tests {
+		if got, err := formatter.ParseTypeStyle(value); err != nil || got != want {
+			t.Errorf("ParseTypeStyle(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := formatter.ParseTypeStyle("ansi"); err == nil {
+		t.Error("expected an error for an unknown style")
+	}
+}
+
+func TestColumnPortableType(t *testing.T) {
+	column := formatter.Column{DataType: "bigint", ColumnType: "bigint unsigned"}
+	if got := column.PortableType(formatter.TypeDisplayShort); got != "integer" {
+		t.Errorf("short portable type = %q", got)
+	}
+	if got := column.PortableType(formatter.TypeDisplayFull); got != "integer unsigned" {
+		t.Errorf("full portable type = %q", got)
+	}
+	if got := (formatter.Column{DataType: "geometry"}).PortableType(formatter.TypeDisplayShort); got != "geometry" {
+		t.Errorf("unmapped portable type = %q", got)
+	}
+}
+
+func TestParseCommentLength(t *testing.T) {
+	tests := map[string]int{"": 0, "0": 0, "80": 80}
+	for value, want := range tests {
+		if got, err := formatter.ParseCommentLength(value); err != nil || got != want {
+			t.Errorf("ParseCommentLength(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"-1", "long"} {
+		if _, err := formatter.ParseCommentLength(value); err == nil {
+			t.Errorf("ParseCommentLength(%q) returned no error", value)
+		}
+	}
+}
+
+func TestParseDPI(t *testing.T) {
+	tests := map[string]int{"": 0, "1": 1, "300": 300}
+	for value, want := range tests {
+		if got, err := formatter.ParseDPI(value); err != nil || got != want {
+			t.Errorf("ParseDPI(%q) = %d, %v; want %d", value, got, err, want)
+		}
+	}
+
+	for _, value := range []string{"0", "-96", "1201", "high"} {
+		if _, err := formatter.ParseDPI(value); err == nil {
+			t.Errorf("ParseDPI(%q) returned no error", value)
+		}
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	tests := map[string]string{"": formatter.PageSizeFit, "A4": "a4", "letter": "letter"}
+	for value, want := range tests {
+		if got, err := formatter.ParsePageSize(value); err != nil || got != want {
+			t.Errorf("ParsePageSize(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := formatter.ParsePageSize("tabloid"); err == nil {
+		t.Error("expected an error for an unknown page size")
+	}
+}
+
+func TestIsText(t *testing.T) {
+	tests := map[string]bool{
+		"text/plain":                   true,
+		"text/markdown; charset=utf-8": true,
+		"image/svg+xml":                true,
+		"application/json":             true,
+		"image/png":                    false,
+		"application/pdf":              false,
+	}
+	for mediaType, want := range tests {
+		if got := formatter.IsText(mediaType); got != want {
+			t.Errorf("IsText(%q) = %v, want %v", mediaType, got, want)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// pointsPerInch is PDF's unit of length.
+const pointsPerInch = 72
+
+// margin surrounds the image on fixed page sizes, in points.
+const margin = 36
+
+// pageSizes are the portrait fixed page sizes, in points.
+var pageSizes = map[string][2]float64{
+	"a4":     {595.28, 841.89},
+	"a3":     {841.89, 1190.55},
+	"letter": {612, 792},
+	"legal":  {612, 1008},
+}
+
+// encode writes a PDF 1.4 document with one page showing img to out. The page fits
+// the image at dpi, or is a fixed size the image is shrunk to fit, centred
+// within the margins. The output has no dates or IDs, so it is reproducible.
+func encode(out io.Writer, img *image.RGBA, dpi float64, pageSize, title string) error {
+	bounds := img.Bounds()
+	width := float64(bounds.Dx()) * pointsPerInch / dpi
+	height := float64(bounds.Dy()) * pointsPerInch / dpi
+
+	pageW, pageH := width, height
+	x, y := 0.0, 0.0
+	if pageSize != formatter.PageSizeFit {
+		size, ok := pageSizes[pageSize]
+		if !ok {
+			return fmt.Errorf("unknown page size %q", pageSize)
+		}
+		pageW, pageH = size[0], size[1]
+		if width > height {
+			pageW, pageH = pageH, pageW
+		}
+
+		scale := math.Min(1, math.Min((pageW-2*margin)/width, (pageH-2*margin)/height))
+		width, height = width*scale, height*scale
+		x, y = (pageW-width)/2, (pageH-height)/2
+	}
+
+	pixels, err := compress(rgb(img))
+	if err != nil {
+		return err
+	}
+	content := fmt.Sprintf("q %s 0 0 %s %s %s cm /Im0 Do Q\n", num(width), num(height), num(x), num(y))
+
+	info := "<< /Producer (marid) >>"
+	if title != "" {
+		info = fmt.Sprintf("<< /Producer (marid) /Title %s >>", text(title))
+	}
+
+	w := &writer{out: out}
+	w.header()
+	w.object("<< /Type /Catalog /Pages 2 0 R >>")
+	w.object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>")
+	w.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << /XObject << /Im0 4 0 R >> >> /Contents 5 0 R >>",
+		num(pageW), num(pageH)))
+	w.stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+		bounds.Dx(), bounds.Dy()), pixels)
+	w.stream("", []byte(content))
+	w.object(info)
+	w.trailer(6)
+	return w.err
+}
+
+// writer writes a PDF file, counting bytes to record where each object starts
+// for the cross-reference table. After a failed write it writes nothing more
+// and keeps the error.
+type writer struct {
+	out     io.Writer
+	written int
+	offsets []int
+	err     error
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.out.Write(p)
+	w.written += n
+	w.err = err
+	return n, err
+}
+
+func (w *writer) printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(w, format, args...)
+}
+
+func (w *writer) header() {
+	// The comment's high bytes mark the file as binary for transfer tools.
+	w.printf("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+}
+
+func (w *writer) object(body string) {
+	w.begin()
+	w.printf("%s\nendobj\n", body)
+}
+
+func (w *writer) stream(dictionary string, data []byte) {
+	w.begin()
+	if dictionary != "" {
+		dictionary += " "
+	}
+	w.printf("<< %s/Length %d >>\nstream\n", dictionary, len(data))
+	_, _ = w.Write(data)
+	w.printf("\nendstream\nendobj\n")
+}
+
+func (w *writer) begin() {
+	w.offsets = append(w.offsets, w.written)
+	w.printf("%d 0 obj\n", len(w.offsets))
+}
+
+func (w *writer) trailer(info int) {
+	start := w.written
+	w.printf("xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, offset := range w.offsets {
+		w.printf("%010d 00000 n \n", offset)
+	}
+	w.printf("trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(w.offsets)+1, info, start)
+}
+
+// rgb returns img's pixels as rows of RGB triples, dropping alpha; the
+// drawing has an opaque background.
+func rgb(img *image.RGBA) []byte {
+	bounds := img.Bounds()
+	pixels := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(bounds.Min.X, y):img.PixOffset(bounds.Max.X, y)]
+		for i := 0; i < len(row); i += 4 {
+			pixels = append(pixels, row[i], row[i+1], row[i+2])
+		}
+	}
+	return pixels
+}
+
+func compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	z, err := zlib.NewWriterLevel(&b, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := z.Write(data); err != nil {
+		return nil, err
+	}
+	if err := z.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// num formats a length in points with at most two decimals.
+func num(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+// text writes s as a PDF string: a literal for ASCII, or UTF-16 with a byte
+// order mark otherwise.
+func text(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r >= 0x80 || r < 0x20 {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s) + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	b.WriteString(">")
+	return b.String()
+}
@@ -0,0 +1,114 @@
+// Package pdf renders ER diagrams as single-page PDF documents holding an
+// image drawn by package raster, from the same layout as the svg formatter.
+package pdf
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/raster"
+)
+
+func init() {
+	formatter.Register("pdf", func() formatter.Formatter {
+		return New()
+	})
+}
+
+// DefaultDPI draws the image at twice the screen resolution, so it stays
+// sharp when printed.
+const DefaultDPI = 2 * raster.PixelsPerInch
+
+// Formatter renders ER diagrams as PDF documents.
+type Formatter struct {
+	settings raster.Settings
+	pageSize string
+}
+
+// New creates a new PDF formatter instance.
+func New() Formatter {
+	return Formatter{settings: raster.DefaultSettings(DefaultDPI), pageSize: formatter.PageSizeFit}
+}
+
+// WithOptions returns the formatter configured by opts. It accepts
+// formatter.OptionPageSize, formatter.OptionDPI and the options of
+// layout.Settings.
+func (f Formatter) WithOptions(opts formatter.Options) (formatter.Formatter, error) {
+	return f.withOptions(opts)
+}
+
+func (f Formatter) withOptions(opts formatter.Options) (Formatter, error) {
+	for name, value := range opts {
+		if name == formatter.OptionPageSize {
+			size, err := formatter.ParsePageSize(value)
+			if err != nil {
+				return f, err
+			}
+			f.pageSize = size
+			continue
+		}
+
+		known, err := f.settings.Set(name, value)
+		if err != nil {
+			return f, err
+		}
+		if !known {
+			return f, fmt.Errorf("unknown pdf option %q", name)
+		}
+	}
+	return f, nil
+}
+
+// OptionSpecs lists the options WithOptions accepts.
+func (f Formatter) OptionSpecs() []formatter.OptionSpec {
+	return formatter.SortOptions(append(raster.SettingsOptions(DefaultDPI), formatter.CommonOptions(formatter.OptionPageSize)...))
+}
+
+// Metadata describes the format.
+func (f Formatter) Metadata() formatter.Metadata {
+	return formatter.Metadata{
+		Description: "Single-page PDF of the svg layout, drawn in pure Go",
+		Features:    []formatter.Feature{formatter.FeatureCardinality, formatter.FeatureStyles},
+	}
+}
+
+// Name returns the formatter name.
+func (f Formatter) Name() string {
+	return "pdf"
+}
+
+// MediaType returns the formatter output media type.
+func (f Formatter) MediaType() string {
+	return "application/pdf"
+}
+
+// Render draws the render data and returns the document's bytes.
+func (f Formatter) Render(data formatter.RenderData) (string, error) {
+	var b bytes.Buffer
+	if err := f.RenderTo(context.Background(), &b, data, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderTo draws the render data, configured by opts, and writes the document
+// to w.
+func (f Formatter) RenderTo(ctx context.Context, w io.Writer, data formatter.RenderData, opts formatter.Options) error {
+	f, err := f.withOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	img, err := f.settings.Draw(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	if err := encode(w, img, float64(f.settings.DPI), f.pageSize, f.settings.Title); err != nil {
+		return fmt.Errorf("encoding pdf: %w", err)
+	}
+	return nil
+}
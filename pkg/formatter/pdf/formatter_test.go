@@ -0,0 +1,208 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+func TestFormatterMetadata(t *testing.T) {
+	f := New()
+
+	if got, want := f.Name(), "pdf"; got != want {
+		t.Fatalf("Name() = %q, want %q", got, want)
+	}
+
+	if got, want := f.MediaType(), "application/pdf"; got != want {
+		t.Fatalf("MediaType() = %q, want %q", got, want)
+	}
+}
+
+func TestInitRegistersFormatter(t *testing.T) {
+	got, err := formatter.Get("pdf")
+	if err != nil {
+		t.Fatalf("formatter.Get(%q) returned error: %v", "pdf", err)
+	}
+
+	if _, ok := got.(Formatter); !ok {
+		t.Fatalf("formatter.Get(%q) returned %T, want pdf.Formatter", "pdf", got)
+	}
+}
+
+func TestRenderNoTables(t *testing.T) {
+	if _, err := New().Render(formatter.RenderData{}); err == nil {
+		t.Fatal("expected an error for empty render data")
+	}
+}
+
+func TestRenderWritesAValidDocument(t *testing.T) {
+	f, err := New().WithOptions(formatter.Options{formatter.OptionTitle: "Team (core)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	if !strings.HasPrefix(out, "%PDF-1.4\n") || !strings.HasSuffix(out, "%%EOF\n") {
+		t.Fatalf("output is not framed as a PDF: %q...", out[:20])
+	}
+	if err := checkXref(out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, `/Title (Team \(core\))`) {
+		t.Error("document info has no escaped title")
+	}
+
+	// The page fits the image at the default resolution.
+	width, height := imageSize(t, out)
+	wantBox := fmt.Sprintf("/MediaBox [0 0 %s %s]", num(float64(width)*72/DefaultDPI), num(float64(height)*72/DefaultDPI))
+	if !strings.Contains(out, wantBox) {
+		t.Errorf("output has no %s", wantBox)
+	}
+
+	// The image holds every pixel.
+	match := regexp.MustCompile(`/Filter /FlateDecode /Length (\d+) >>\nstream\n`).FindStringSubmatchIndex(out)
+	length, _ := strconv.Atoi(out[match[2]:match[3]])
+	z, err := zlib.NewReader(strings.NewReader(out[match[1] : match[1]+length]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixels, err := io.ReadAll(z)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pixels) != width*height*3 {
+		t.Errorf("image data has %d bytes, want %d", len(pixels), width*height*3)
+	}
+}
+
+func TestRenderFitsFixedPageSizes(t *testing.T) {
+	f, err := New().WithOptions(formatter.Options{formatter.OptionPageSize: "A4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := f.Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sample is taller than wide, so the page stays portrait.
+	if !strings.Contains(out, "/MediaBox [0 0 595.28 841.89]") {
+		t.Error("output is not on a portrait A4 page")
+	}
+	if err := checkXref(out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncodeTurnsWidePagesLandscape(t *testing.T) {
+	var out bytes.Buffer
+	err := encode(&out, imageOf(800, 200), 96, "letter", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("/MediaBox [0 0 792 612]")) {
+		t.Error("a wide image is not on a landscape letter page")
+	}
+}
+
+func TestWithOptionsRejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []formatter.Options{
+		{formatter.OptionPageSize: "tabloid"},
+		{formatter.OptionDPI: "high"},
+		{formatter.OptionDirection: "LR"},
+	} {
+		if _, err := New().WithOptions(opts); err == nil {
+			t.Errorf("WithOptions(%v) returned no error", opts)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := map[string]string{
+		`a (b) \ c`: `(a \(b\) \\ c)`,
+		"注文":        "<FEFF6CE86587>",
+		"🗂":         "<FEFFD83DDDC2>",
+	}
+	for s, want := range tests {
+		if got := text(s); got != want {
+			t.Errorf("text(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
+
+// checkXref verifies that every cross-reference entry, and startxref, point
+// at what they name.
+func checkXref(document string) error {
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllStringSubmatch(document, -1)
+	if len(entries) == 0 {
+		return fmt.Errorf("no cross-reference entries")
+	}
+	for k, entry := range entries {
+		offset, _ := strconv.Atoi(entry[1])
+		if want := fmt.Sprintf("%d 0 obj\n", k+1); !strings.HasPrefix(document[offset:], want) {
+			return fmt.Errorf("xref entry %d points at %q", k+1, document[offset:offset+10])
+		}
+	}
+
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindStringSubmatch(document)
+	if match == nil {
+		return fmt.Errorf("no startxref")
+	}
+	offset, _ := strconv.Atoi(match[1])
+	if !strings.HasPrefix(document[offset:], "xref\n") {
+		return fmt.Errorf("startxref points at %q", document[offset:offset+10])
+	}
+	return nil
+}
+
+func imageSize(t *testing.T, document string) (int, int) {
+	t.Helper()
+
+	match := regexp.MustCompile(`/Width (\d+) /Height (\d+)`).FindStringSubmatch(document)
+	if match == nil {
+		t.Fatal("no image dimensions in the document")
+	}
+	width, _ := strconv.Atoi(match[1])
+	height, _ := strconv.Atoi(match[2])
+	return width, height
+}
+
+func imageOf(width, height int) *image.RGBA {
+	return image.NewRGBA(image.Rect(0, 0, width, height))
+}
+
+// shortWriter accepts limit bytes, then fails.
+type shortWriter struct {
+	limit int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, io.ErrShortWrite
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestRenderToReportsWriteErrors(t *testing.T) {
+	err := New().RenderTo(context.Background(), &shortWriter{limit: 100}, formattertest.SampleRenderData(), nil)
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("RenderTo to a failing writer = %v, want io.ErrShortWrite", err)
+	}
+}
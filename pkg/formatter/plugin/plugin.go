@@ -0,0 +1,217 @@
+// Package plugin runs external formatters: executables that speak a JSON
+// protocol on standard input and output, so teams can add output formats
+// without changing marid.
+//
+// marid starts a plugin once per request and writes one Request to its
+// standard input. A describe request is the handshake: the plugin answers with
+// a Description in JSON, naming the protocol version it speaks. A render
+// request carries the render data and options, and the plugin writes the
+// rendered diagram to standard output. Standard error is passed through, and a
+// plugin that exits with a non-zero status or outlives Options.Timeout fails
+// the request.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// ProtocolVersion is the version of the protocol marid speaks; plugins
+// answering the handshake with another version are refused.
+const ProtocolVersion = 1
+
+// Actions a Request asks of a plugin.
+const (
+	// ActionDescribe asks for the plugin's Description.
+	ActionDescribe = "describe"
+	// ActionRender asks for the rendering of the request's data.
+	ActionRender = "render"
+)
+
+// DefaultTimeout is the usual bound on each run of a plugin.
+const DefaultTimeout = 30 * time.Second
+
+// Options control how a plugin is run.
+type Options struct {
+	// Timeout bounds each run of the plugin; zero means no limit.
+	Timeout time.Duration
+	// Stderr receives what the plugin writes to its standard error; nil
+	// discards it.
+	Stderr io.Writer
+}
+
+// Request is what marid writes to a plugin's standard input.
+type Request struct {
+	Protocol int    `json:"protocol"`
+	Action   string `json:"action"`
+	// Format is the name the plugin is registered under, so one executable
+	// can serve several formats.
+	Format  string                `json:"format"`
+	Options formatter.Options     `json:"options,omitempty"`
+	Data    *formatter.RenderData `json:"data,omitempty"`
+}
+
+// Description is a plugin's answer to ActionDescribe.
+type Description struct {
+	Protocol    int    `json:"protocol"`
+	MediaType   string `json:"media_type"`
+	Description string `json:"description"`
+	// Extension is the file name extension of the output, with its dot; empty
+	// means "." and the format name.
+	Extension string                 `json:"extension"`
+	Options   []formatter.OptionSpec `json:"options"`
+	Features  []formatter.Feature    `json:"features"`
+}
+
+// Formatter renders diagrams by running a plugin.
+type Formatter struct {
+	name string
+	path string
+	run  Options
+	desc Description
+	opts formatter.Options
+}
+
+// Load shakes hands with the plugin at path under ctx and returns it as the
+// formatter of the format name, running the plugin as run says.
+func Load(ctx context.Context, name, path string, run Options) (Formatter, error) {
+	f := Formatter{name: name, path: path, run: run}
+
+	var out bytes.Buffer
+	if err := f.exec(ctx, Request{Action: ActionDescribe}, &out); err != nil {
+		return f, err
+	}
+
+	if err := json.Unmarshal(out.Bytes(), &f.desc); err != nil {
+		return f, fmt.Errorf("plugin %s (%s) answered the handshake with invalid JSON: %w", name, path, err)
+	}
+	if f.desc.Protocol != ProtocolVersion {
+		return f, fmt.Errorf("plugin %s (%s) speaks protocol %d; marid speaks %d", name, path, f.desc.Protocol, ProtocolVersion)
+	}
+	if f.desc.MediaType == "" {
+		return f, fmt.Errorf("plugin %s (%s) did not name its media type", name, path)
+	}
+	return f, nil
+}
+
+// WithOptions returns the formatter configured by opts, which must be among
+// the options the plugin declared. They are sent along with each render
+// request.
+func (f Formatter) WithOptions(opts formatter.Options) (formatter.Formatter, error) {
+	return f.withOptions(opts)
+}
+
+func (f Formatter) withOptions(opts formatter.Options) (Formatter, error) {
+	if len(opts) == 0 {
+		return f, nil
+	}
+	if err := formatter.ValidateOptions(f.name, f.OptionSpecs(), opts); err != nil {
+		return f, err
+	}
+
+	merged := make(formatter.Options, len(f.opts)+len(opts))
+	for name, value := range f.opts {
+		merged[name] = value
+	}
+	for name, value := range opts {
+		merged[name] = value
+	}
+	f.opts = merged
+	return f, nil
+}
+
+// OptionSpecs lists the options the plugin declared.
+func (f Formatter) OptionSpecs() []formatter.OptionSpec {
+	return formatter.SortOptions(append([]formatter.OptionSpec(nil), f.desc.Options...))
+}
+
+// Metadata describes the format as the plugin does.
+func (f Formatter) Metadata() formatter.Metadata {
+	return formatter.Metadata{
+		Description: f.desc.Description,
+		Extension:   f.desc.Extension,
+		Features:    f.desc.Features,
+	}
+}
+
+// Name returns the formatter name.
+func (f Formatter) Name() string {
+	return f.name
+}
+
+// Path returns the plugin's executable.
+func (f Formatter) Path() string {
+	return f.path
+}
+
+// MediaType returns the media type the plugin declared.
+func (f Formatter) MediaType() string {
+	return f.desc.MediaType
+}
+
+// Render runs the plugin and returns its output.
+func (f Formatter) Render(data formatter.RenderData) (string, error) {
+	var b bytes.Buffer
+	if err := f.RenderTo(context.Background(), &b, data, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderTo runs the plugin on the render data, configured by opts, and copies
+// its output to w as it arrives.
+func (f Formatter) RenderTo(ctx context.Context, w io.Writer, data formatter.RenderData, opts formatter.Options) error {
+	f, err := f.withOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	return f.exec(ctx, Request{Action: ActionRender, Options: f.opts, Data: &data}, w)
+}
+
+// exec starts the plugin, writes req to its standard input and its standard
+// output to stdout.
+func (f Formatter) exec(ctx context.Context, req Request, stdout io.Writer) error {
+	req.Protocol = ProtocolVersion
+	req.Format = f.name
+	// Plugins see text as marid does, without HTML escapes such as \u003e.
+	var input bytes.Buffer
+	enc := json.NewEncoder(&input)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(req); err != nil {
+		return fmt.Errorf("encoding the request to plugin %s: %w", f.name, err)
+	}
+
+	runCtx := ctx
+	if f.run.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, f.run.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(runCtx, f.path)
+	cmd.Stdin = &input
+	cmd.Stdout = stdout
+	cmd.Stderr = f.run.Stderr
+	// Children of the plugin holding its pipes must not keep marid waiting.
+	cmd.WaitDelay = time.Second
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("plugin %s (%s) timed out after %s", f.name, f.path, f.run.Timeout)
+		}
+		return fmt.Errorf("plugin %s (%s) failed: %w", f.name, f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+// pluginMode, when set in the environment, makes the test binary act as a
+// plugin instead of running the tests.
+const pluginMode = "MARID_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(pluginMode); mode != "" {
+		os.Exit(fakePlugin(mode))
+	}
+	os.Exit(m.Run())
+}
+
+// fakePlugin answers one request the way mode says and returns its exit code.
+// The "tables" plugin lists the tables, one per line, after the prefix option.
+func fakePlugin(mode string) int {
+	var req Request
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, "bad request:", err)
+		return 2
+	}
+
+	switch mode {
+	case "fail":
+		fmt.Fprintln(os.Stderr, "cannot render today")
+		return 3
+	case "slow":
+		time.Sleep(time.Minute)
+		return 0
+	}
+
+	if req.Action == ActionDescribe {
+		desc := Description{
+			Protocol:    ProtocolVersion,
+			MediaType:   "text/plain",
+			Description: "Table names, one per line",
+			Extension:   ".txt",
+			Options:     []formatter.OptionSpec{{Name: "prefix", Kind: formatter.KindString}},
+			Features:    []formatter.Feature{formatter.FeatureComments},
+		}
+		if mode == "future" {
+			desc.Protocol = ProtocolVersion + 1
+		}
+		_ = json.NewEncoder(os.Stdout).Encode(desc)
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "rendering %s\n", req.Format)
+	for _, table := range req.Data.Tables {
+		fmt.Printf("%s%s\n", req.Options["prefix"], table.Name)
+	}
+	return 0
+}
+
+// testPlugin returns the path of the test binary acting as a plugin in mode.
+func testPlugin(t *testing.T, mode string) string {
+	t.Helper()
+
+	path, err := os.Executable()
+	if err != nil {
+		t.Fatalf("finding the test binary: %v", err)
+	}
+	t.Setenv(pluginMode, mode)
+	return path
+}
+
+func TestLoadShakesHands(t *testing.T) {
+	f, err := Load(context.Background(), "tables", testPlugin(t, "tables"), Options{})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	info := formatter.Info{Name: f.Name(), MediaType: f.MediaType(), Extension: f.Metadata().Extension, Options: f.OptionSpecs()}
+	if info.Name != "tables" || info.MediaType != "text/plain" || info.Extension != ".txt" {
+		t.Errorf("unexpected description: %+v", info)
+	}
+	if len(info.Options) != 1 || info.Options[0].Name != "prefix" {
+		t.Errorf("unexpected options: %+v", info.Options)
+	}
+}
+
+func TestLoadRefusesOtherProtocols(t *testing.T) {
+	_, err := Load(context.Background(), "tables", testPlugin(t, "future"), Options{})
+	if err == nil || !strings.Contains(err.Error(), "speaks protocol 2; marid speaks 1") {
+		t.Fatalf("Load returned %v, want a protocol error", err)
+	}
+}
+
+func TestLoadStopsWhenTheContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := Load(ctx, "tables", testPlugin(t, "slow"), Options{Timeout: DefaultTimeout})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Load returned %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Load took %s to stop", elapsed)
+	}
+}
+
+func TestRenderToStreamsOutputAndPassesStderrThrough(t *testing.T) {
+	var stderr bytes.Buffer
+	f, err := Load(context.Background(), "tables", testPlugin(t, "tables"), Options{Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := f.RenderTo(context.Background(), &out, formattertest.SampleRenderData(), formatter.Options{"prefix": "- "}); err != nil {
+		t.Fatalf("RenderTo returned error: %v", err)
+	}
+	if got, want := out.String(), "- teams\n- users\n"; got != want {
+		t.Errorf("RenderTo wrote %q, want %q", got, want)
+	}
+	if got := stderr.String(); got != "rendering tables\n" {
+		t.Errorf("stderr = %q", got)
+	}
+
+	if err := f.RenderTo(context.Background(), &out, formattertest.SampleRenderData(), formatter.Options{"colour": "red"}); err == nil {
+		t.Error("expected an error for an undeclared option")
+	}
+}
+
+func TestRenderReportsFailures(t *testing.T) {
+	var stderr bytes.Buffer
+	f, err := Load(context.Background(), "tables", testPlugin(t, "tables"), Options{Stderr: &stderr})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	t.Setenv(pluginMode, "fail")
+	_, err = f.Render(formattertest.SampleRenderData())
+	if err == nil || !strings.Contains(err.Error(), "plugin tables") || !strings.Contains(err.Error(), "exit status 3") {
+		t.Fatalf("Render returned %v, want the exit status", err)
+	}
+	if got := stderr.String(); got != "cannot render today\n" {
+		t.Errorf("stderr = %q", got)
+	}
+}
+
+func TestRenderTimesOut(t *testing.T) {
+	f, err := Load(context.Background(), "tables", testPlugin(t, "tables"), Options{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	t.Setenv(pluginMode, "slow")
+	_, err = f.Render(formattertest.SampleRenderData())
+	if err == nil || !strings.Contains(err.Error(), "timed out after 100ms") {
+		t.Fatalf("Render returned %v, want a timeout", err)
+	}
+}
+
+func TestDiscoverFindsExecutablesOnPath(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	write := func(dir, name string, mode os.FileMode) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(first, "marid-format-dbml", 0o755)
+	write(first, "marid-format-notes", 0o644)
+	write(second, "marid-format-dbml", 0o755)
+	write(second, "marid-format-plantuml", 0o755)
+	write(second, "other-tool", 0o755)
+
+	got := Discover(strings.Join([]string{first, "", filepath.Join(first, "missing"), second}, string(os.PathListSeparator)))
+	want := map[string]string{
+		"dbml":     filepath.Join(first, "marid-format-dbml"),
+		"plantuml": filepath.Join(second, "marid-format-plantuml"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Discover = %v, want %v", got, want)
+	}
+	for name, path := range want {
+		if got[name] != path {
+			t.Errorf("Discover[%s] = %q, want %q", name, got[name], path)
+		}
+	}
+}
+
+func TestRegisterAddsFormats(t *testing.T) {
+	name := fmt.Sprintf("tables%d", time.Now().UnixNano())
+	if err := Register(context.Background(), name, testPlugin(t, "tables"), Options{Stderr: &bytes.Buffer{}}); err != nil {
+		t.Fatalf("Register returned error: %v", err)
+	}
+
+	found := false
+	for _, available := range formatter.Available() {
+		found = found || available == name
+	}
+	if !found {
+		t.Fatalf("%s is missing from %v", name, formatter.Available())
+	}
+
+	info, err := formatter.Describe(name)
+	if err != nil {
+		t.Fatalf("Describe returned error: %v", err)
+	}
+	if info.Extension != ".txt" || info.Binary {
+		t.Errorf("unexpected info: %+v", info)
+	}
+
+	f, err := formatter.Get(name, formatter.Options{"prefix": "* "})
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	out, err := f.Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if out != "* teams\n* users\n" {
+		t.Errorf("Render = %q", out)
+	}
+
+	// Registering the name again points it at another plugin.
+	if err := Register(context.Background(), name, filepath.Join(t.TempDir(), "missing"), Options{}); err != nil {
+		t.Fatalf("re-registering returned error: %v", err)
+	}
+	if _, err := formatter.Get(name); err == nil {
+		t.Error("expected the missing plugin to fail")
+	}
+}
+
+func TestRegisterRejectsBuiltInAndInvalidNames(t *testing.T) {
+	builtIn := fmt.Sprintf("builtin%d", time.Now().UnixNano())
+	formatter.Register(builtIn, func() formatter.Formatter {
+		return &formattertest.MockFormatter{}
+	})
+
+	if err := Register(context.Background(), builtIn, "/bin/true", Options{}); err == nil || !strings.Contains(err.Error(), "built in") {
+		t.Errorf("Register over a built-in format returned %v", err)
+	}
+	if err := Register(context.Background(), "bad name", "/bin/true", Options{}); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+	if err := Register(context.Background(), "empty", "", Options{}); err == nil {
+		t.Error("expected an error for an empty path")
+	}
+}
@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+// CommandPrefix starts the name of plugin executables; the rest of the name,
+// without any ".exe", is the format name.
+const CommandPrefix = "marid-format-"
+
+// validName matches format names plugins may register.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+var (
+	pluginsMu sync.Mutex
+	plugins   = make(map[string]*entry)
+)
+
+// entry is a registered plugin, loaded the first time its format is used.
+type entry struct {
+	path string
+	// ctx bounds the handshake, which runs lazily behind formatter.Get and
+	// so cannot be handed a context of its own.
+	ctx  context.Context
+	run  Options
+	once sync.Once
+	f    Formatter
+	err  error
+}
+
+func (e *entry) load(name string) (Formatter, error) {
+	e.once.Do(func() {
+		e.f, e.err = Load(e.ctx, name, e.path, e.run)
+	})
+	return e.f, e.err
+}
+
+// Register makes the plugin at path available as the format name. The
+// handshake runs under ctx the first time the format is used, and the plugin
+// is run as run says. Registering a plugin's name again replaces its path and
+// settings, but built-in formats cannot be replaced.
+func Register(ctx context.Context, name, path string, run Options) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("invalid plugin name %q: use letters, digits, '-' and '_'", name)
+	}
+	if path == "" {
+		return fmt.Errorf("plugin %s has no path", name)
+	}
+
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+
+	if _, ok := plugins[name]; ok {
+		plugins[name] = &entry{path: path, ctx: ctx, run: run}
+		return nil
+	}
+	if formatter.Registered(name) {
+		return fmt.Errorf("format %q is built in; give the plugin another name", name)
+	}
+
+	plugins[name] = &entry{path: path, ctx: ctx, run: run}
+	formatter.RegisterLoader(name, func() (formatter.Formatter, error) {
+		pluginsMu.Lock()
+		e := plugins[name]
+		pluginsMu.Unlock()
+		return e.load(name)
+	})
+	return nil
+}
+
+// RegisterPath registers the plugins Discover finds in pathList, as Register
+// does. Built-in formats take precedence over plugins of the same name.
+func RegisterPath(ctx context.Context, pathList string, run Options) {
+	for name, path := range Discover(pathList) {
+		_ = Register(ctx, name, path, run)
+	}
+}
+
+// Discover finds the plugins in the directories of pathList, a list such as
+// $PATH: executables named CommandPrefix followed by the format name. Like the
+// shell, it keeps the first of several plugins with the same name. It returns
+// the plugins' paths by format name.
+func Discover(pathList string) map[string]string {
+	found := make(map[string]string)
+	for _, dir := range filepath.SplitList(pathList) {
+		if dir == "" {
+			continue
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), CommandPrefix)
+			if !ok {
+				continue
+			}
+			name = strings.TrimSuffix(name, ".exe")
+			if _, seen := found[name]; seen || !validName.MatchString(name) {
+				continue
+			}
+
+			path := filepath.Join(dir, e.Name())
+			if executable(path) {
+				found[name] = path
+			}
+		}
+	}
+	return found
+}
+
+// executable reports whether path is a file that can be run.
+func executable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0o111 != 0
+}
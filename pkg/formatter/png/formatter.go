@@ -0,0 +1,104 @@
+// Package png renders ER diagrams as PNG images, drawn by package raster from
+// the same layout as the svg formatter.
+package png
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	imagepng "image/png"
+	"io"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/raster"
+)
+
+func init() {
+	formatter.Register("png", func() formatter.Formatter {
+		return New()
+	})
+}
+
+// DefaultDPI draws one image pixel per layout pixel, matching the size of the
+// svg output.
+const DefaultDPI = raster.PixelsPerInch
+
+// Formatter renders ER diagrams as PNG images.
+type Formatter struct {
+	settings raster.Settings
+}
+
+// New creates a new PNG formatter instance.
+func New() Formatter {
+	return Formatter{settings: raster.DefaultSettings(DefaultDPI)}
+}
+
+// WithOptions returns the formatter configured by opts. It accepts
+// formatter.OptionDPI and the options of layout.Settings.
+func (f Formatter) WithOptions(opts formatter.Options) (formatter.Formatter, error) {
+	return f.withOptions(opts)
+}
+
+func (f Formatter) withOptions(opts formatter.Options) (Formatter, error) {
+	for name, value := range opts {
+		known, err := f.settings.Set(name, value)
+		if err != nil {
+			return f, err
+		}
+		if !known {
+			return f, fmt.Errorf("unknown png option %q", name)
+		}
+	}
+	return f, nil
+}
+
+// OptionSpecs lists the options WithOptions accepts.
+func (f Formatter) OptionSpecs() []formatter.OptionSpec {
+	return formatter.SortOptions(raster.SettingsOptions(DefaultDPI))
+}
+
+// Metadata describes the format.
+func (f Formatter) Metadata() formatter.Metadata {
+	return formatter.Metadata{
+		Description: "PNG image of the svg layout, drawn in pure Go",
+		Features:    []formatter.Feature{formatter.FeatureCardinality, formatter.FeatureStyles},
+	}
+}
+
+// Name returns the formatter name.
+func (f Formatter) Name() string {
+	return "png"
+}
+
+// MediaType returns the formatter output media type.
+func (f Formatter) MediaType() string {
+	return "image/png"
+}
+
+// Render draws the render data and returns the encoded image's bytes.
+func (f Formatter) Render(data formatter.RenderData) (string, error) {
+	var b bytes.Buffer
+	if err := f.RenderTo(context.Background(), &b, data, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderTo draws the render data, configured by opts, and writes the encoded
+// image to w.
+func (f Formatter) RenderTo(ctx context.Context, w io.Writer, data formatter.RenderData, opts formatter.Options) error {
+	f, err := f.withOptions(opts)
+	if err != nil {
+		return err
+	}
+
+	img, err := f.settings.Draw(ctx, data)
+	if err != nil {
+		return err
+	}
+
+	if err := imagepng.Encode(w, img); err != nil {
+		return fmt.Errorf("encoding png: %w", err)
+	}
+	return nil
+}
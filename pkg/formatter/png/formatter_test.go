@@ -0,0 +1,107 @@
+package png
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	imagepng "image/png"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+func TestFormatterMetadata(t *testing.T) {
+	f := New()
+
+	if got, want := f.Name(), "png"; got != want {
+		t.Fatalf("Name() = %q, want %q", got, want)
+	}
+
+	if got, want := f.MediaType(), "image/png"; got != want {
+		t.Fatalf("MediaType() = %q, want %q", got, want)
+	}
+}
+
+func TestInitRegistersFormatter(t *testing.T) {
+	got, err := formatter.Get("png")
+	if err != nil {
+		t.Fatalf("formatter.Get(%q) returned error: %v", "png", err)
+	}
+
+	if _, ok := got.(Formatter); !ok {
+		t.Fatalf("formatter.Get(%q) returned %T, want png.Formatter", "png", got)
+	}
+}
+
+func TestRenderNoTables(t *testing.T) {
+	if _, err := New().Render(formatter.RenderData{}); err == nil {
+		t.Fatal("expected an error for empty render data")
+	}
+}
+
+func TestRenderScalesWithDPI(t *testing.T) {
+	var widths []int
+	for _, dpi := range []string{"", "192"} {
+		f, err := New().WithOptions(formatter.Options{formatter.OptionDPI: dpi})
+		if err != nil {
+			t.Fatalf("WithOptions(dpi=%q): %v", dpi, err)
+		}
+
+		out, err := f.Render(formattertest.SampleRenderData())
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+
+		img, err := imagepng.Decode(strings.NewReader(out))
+		if err != nil {
+			t.Fatalf("decoding the output at dpi %q: %v", dpi, err)
+		}
+		widths = append(widths, img.Bounds().Dx())
+	}
+
+	if widths[1] < 2*widths[0]-1 || widths[1] > 2*widths[0] {
+		t.Errorf("widths at 96 and 192 dpi = %v, want the second twice the first", widths)
+	}
+}
+
+func TestRenderIsReproducible(t *testing.T) {
+	first, err := New().Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := New().Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal([]byte(first), []byte(second)) {
+		t.Error("rendering the same data twice gave different images")
+	}
+}
+
+func TestWithOptionsRejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []formatter.Options{
+		{formatter.OptionDPI: "0"},
+		{formatter.OptionPageSize: "a4"},
+		{formatter.OptionPalette: "neon"},
+	} {
+		if _, err := New().WithOptions(opts); err == nil {
+			t.Errorf("WithOptions(%v) returned no error", opts)
+		}
+	}
+}
+
+func TestRenderToStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var b bytes.Buffer
+	err := New().RenderTo(ctx, &b, formattertest.SampleRenderData(), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RenderTo after cancellation = %v, want context.Canceled", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("RenderTo wrote %d bytes after cancellation", b.Len())
+	}
+}
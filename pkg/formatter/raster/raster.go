@@ -0,0 +1,316 @@
+// Package raster draws a laid out ER diagram into an image entirely in Go,
+// with the Go Mono fonts built in, for the png and pdf formatters. It draws
+// what the svg formatter writes, so the formats look alike.
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+
+	"github.com/motchang/marid/pkg/formatter/layout"
+)
+
+// PixelsPerInch is the resolution layouts are measured in, as in CSS, so a
+// diagram drawn at this resolution matches the svg formatter's size.
+const PixelsPerInch = 96
+
+// MaxPixels bounds the size of the images Draw allocates.
+const MaxPixels = 200_000_000
+
+// Line widths and dash patterns, in layout pixels, matching the svg
+// formatter.
+var (
+	focusWidth   = 3.0
+	externalDash = []float64{5, 5}
+	inferredDash = []float64{6, 4}
+)
+
+// circleSegments is the number of sides circles are drawn with.
+const circleSegments = 32
+
+var (
+	fontsOnce         sync.Once
+	regular, bold     *opentype.Font
+	errFonts          error
+	faceMu            sync.Mutex
+	faceCache         = make(map[faceKey]font.Face)
+	defaultLineColour = mustParseColour(layout.LineColour)
+)
+
+type faceKey struct {
+	bold bool
+	size float64
+}
+
+// Draw renders d at dpi, so one layout pixel becomes dpi/PixelsPerInch image
+// pixels.
+func Draw(d layout.Diagram, s layout.Settings, dpi float64) (*image.RGBA, error) {
+	scale := dpi / PixelsPerInch
+	width, height := int(math.Ceil(d.Width*scale)), int(math.Ceil(d.Height*scale))
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("diagram has no area")
+	}
+	if float64(width)*float64(height) > MaxPixels {
+		return nil, fmt.Errorf("diagram would be %dx%d pixels; lower the dpi", width, height)
+	}
+
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, width, height)), scale: scale}
+	draw.Draw(c.img, c.img.Bounds(), image.NewUniform(mustParseColour(layout.Background)), image.Point{}, draw.Src)
+
+	m := layout.DefaultMetrics
+	if d.Title != "" {
+		if err := c.text(true, m.TitleSize, d.TitleAt, "start", d.Title, defaultLineColour); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, box := range d.Boxes {
+		if err := c.box(box, s, m); err != nil {
+			return nil, err
+		}
+	}
+	for _, edge := range d.Edges {
+		if err := c.edge(edge, m); err != nil {
+			return nil, err
+		}
+	}
+	return c.img, nil
+}
+
+// canvas draws in layout coordinates, scaled to the image.
+type canvas struct {
+	img   *image.RGBA
+	scale float64
+}
+
+func (c *canvas) box(box layout.Box, s layout.Settings, m layout.Metrics) error {
+	_, colours := s.Classes(box.Table)
+	stroke := mustParseColour(colours.Stroke)
+
+	width, dash := 1.0, []float64(nil)
+	if box.Table.Focus {
+		width = focusWidth
+	}
+	if box.Table.External {
+		dash = externalDash
+	}
+
+	header := layout.Rect{X: box.X, Y: box.Y, W: box.W, H: m.HeaderHeight}
+	c.fillRect(box.Rect, mustParseColour(colours.Body))
+	c.fillRect(header, mustParseColour(colours.Header))
+	c.strokeRect(box.Rect, width, dash, stroke)
+	c.strokeRect(header, width, dash, stroke)
+
+	center := layout.Point{X: box.X + box.W/2, Y: box.HeaderBaseline}
+	if err := c.text(true, m.FontSize, center, "middle", box.Label, mustParseColour(colours.Text)); err != nil {
+		return err
+	}
+
+	text, keys := mustParseColour(layout.DefaultColours.Text), mustParseColour(layout.KeysColour)
+	for _, row := range box.Rows {
+		for _, cell := range []struct {
+			x      float64
+			value  string
+			colour color.Color
+		}{{box.NameX, row.Name, text}, {box.TypeX, row.Type, text}, {box.KeysX, row.Keys, keys}} {
+			if cell.value == "" {
+				continue
+			}
+			if err := c.text(false, m.FontSize, layout.Point{X: cell.x, Y: row.Baseline}, "start", cell.value, cell.colour); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (c *canvas) edge(edge layout.Edge, m layout.Metrics) error {
+	var dash []float64
+	if edge.Dashed {
+		dash = inferredDash
+	}
+	c.polyline(edge.Points, 1, dash, defaultLineColour)
+
+	background := mustParseColour(layout.Background)
+	for _, end := range []layout.End{edge.From, edge.To} {
+		segments, circles := end.Marks()
+		for _, s := range segments {
+			c.polyline([]layout.Point{s.From, s.To}, 1, nil, defaultLineColour)
+		}
+		for _, circle := range circles {
+			c.fillCircle(circle.Center, circle.Radius+0.5, defaultLineColour)
+			c.fillCircle(circle.Center, circle.Radius-0.5, background)
+		}
+	}
+
+	if edge.Label == "" {
+		return nil
+	}
+	return c.text(false, m.LabelSize, edge.LabelAt, edge.LabelAnchor, edge.Label, defaultLineColour)
+}
+
+// fill fills a polygon.
+func (c *canvas) fill(points []layout.Point, colour color.Color) {
+	if len(points) < 3 {
+		return
+	}
+
+	bounds := c.img.Bounds()
+	z := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	z.DrawOp = draw.Over
+	z.MoveTo(float32(points[0].X*c.scale), float32(points[0].Y*c.scale))
+	for _, p := range points[1:] {
+		z.LineTo(float32(p.X*c.scale), float32(p.Y*c.scale))
+	}
+	z.ClosePath()
+	z.Draw(c.img, bounds, image.NewUniform(colour), image.Point{})
+}
+
+func (c *canvas) fillRect(r layout.Rect, colour color.Color) {
+	c.fill([]layout.Point{{X: r.X, Y: r.Y}, {X: r.X + r.W, Y: r.Y}, {X: r.X + r.W, Y: r.Y + r.H}, {X: r.X, Y: r.Y + r.H}}, colour)
+}
+
+func (c *canvas) strokeRect(r layout.Rect, width float64, dash []float64, colour color.Color) {
+	c.polyline([]layout.Point{
+		{X: r.X, Y: r.Y}, {X: r.X + r.W, Y: r.Y}, {X: r.X + r.W, Y: r.Y + r.H}, {X: r.X, Y: r.Y + r.H}, {X: r.X, Y: r.Y},
+	}, width, dash, colour)
+}
+
+func (c *canvas) fillCircle(center layout.Point, radius float64, colour color.Color) {
+	points := make([]layout.Point, circleSegments)
+	for k := range points {
+		angle := 2 * math.Pi * float64(k) / circleSegments
+		points[k] = layout.Point{X: center.X + radius*math.Cos(angle), Y: center.Y + radius*math.Sin(angle)}
+	}
+	c.fill(points, colour)
+}
+
+// polyline strokes the lines between points, centred on them. A dash
+// pattern alternates drawn and skipped lengths and carries on around
+// corners, like SVG's stroke-dasharray.
+func (c *canvas) polyline(points []layout.Point, width float64, dash []float64, colour color.Color) {
+	phase, on := 0, true
+	left := 0.0
+	if len(dash) > 0 {
+		left = dash[0]
+	}
+
+	for k := 1; k < len(points); k++ {
+		a, b := points[k-1], points[k]
+		length := math.Hypot(b.X-a.X, b.Y-a.Y)
+		if length == 0 {
+			continue
+		}
+		if len(dash) == 0 {
+			c.segment(a, b, width, colour)
+			continue
+		}
+
+		for done := 0.0; done < length; {
+			step := math.Min(left, length-done)
+			if on {
+				c.segment(lerp(a, b, done/length), lerp(a, b, (done+step)/length), width, colour)
+			}
+			done += step
+			left -= step
+			if left <= 0 {
+				phase = (phase + 1) % len(dash)
+				left, on = dash[phase], !on
+			}
+		}
+	}
+}
+
+// segment fills the rectangle of the given width around the line from a to
+// b, extended by half the width at each end so corners join squarely.
+func (c *canvas) segment(a, b layout.Point, width float64, colour color.Color) {
+	length := math.Hypot(b.X-a.X, b.Y-a.Y)
+	dx, dy := (b.X-a.X)/length*width/2, (b.Y-a.Y)/length*width/2
+	a = layout.Point{X: a.X - dx, Y: a.Y - dy}
+	b = layout.Point{X: b.X + dx, Y: b.Y + dy}
+	c.fill([]layout.Point{
+		{X: a.X + dy, Y: a.Y - dx}, {X: b.X + dy, Y: b.Y - dx},
+		{X: b.X - dy, Y: b.Y + dx}, {X: a.X - dy, Y: a.Y + dx},
+	}, colour)
+}
+
+// text draws text with its baseline at at, starting there or centred on it.
+func (c *canvas) text(isBold bool, size float64, at layout.Point, anchor, text string, colour color.Color) error {
+	face, err := fontFace(isBold, size*c.scale)
+	if err != nil {
+		return err
+	}
+
+	x := at.X * c.scale
+	if anchor == "middle" {
+		x -= float64(font.MeasureString(face, text)) / 64 / 2
+	}
+
+	d := font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(colour),
+		Face: face,
+		Dot:  fixed.Point26_6{X: fixed.Int26_6(math.Round(x * 64)), Y: fixed.Int26_6(math.Round(at.Y * c.scale * 64))},
+	}
+	d.DrawString(text)
+	return nil
+}
+
+// fontFace returns Go Mono, or Go Mono Bold, at size pixels.
+func fontFace(isBold bool, size float64) (font.Face, error) {
+	fontsOnce.Do(func() {
+		if regular, errFonts = opentype.Parse(gomono.TTF); errFonts != nil {
+			return
+		}
+		bold, errFonts = opentype.Parse(gomonobold.TTF)
+	})
+	if errFonts != nil {
+		return nil, fmt.Errorf("loading fonts: %w", errFonts)
+	}
+
+	faceMu.Lock()
+	defer faceMu.Unlock()
+
+	key := faceKey{bold: isBold, size: size}
+	if face, ok := faceCache[key]; ok {
+		return face, nil
+	}
+
+	f := regular
+	if isBold {
+		f = bold
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+	if err != nil {
+		return nil, fmt.Errorf("loading fonts: %w", err)
+	}
+	faceCache[key] = face
+	return face, nil
+}
+
+func lerp(a, b layout.Point, t float64) layout.Point {
+	return layout.Point{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t}
+}
+
+// mustParseColour parses a "#rrggbb" colour, as palettes and the layout
+// colours are written; anything else is drawn black.
+func mustParseColour(hex string) color.RGBA {
+	value, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil || len(hex) != 7 || hex[0] != '#' {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 0xff}
+}
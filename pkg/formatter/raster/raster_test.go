@@ -0,0 +1,103 @@
+package raster
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+	"github.com/motchang/marid/pkg/formatter/layout"
+)
+
+func TestDrawMatchesLayout(t *testing.T) {
+	s := layout.DefaultSettings()
+	d := s.Layout(formattertest.SampleRenderData())
+
+	for _, dpi := range []float64{PixelsPerInch, 2 * PixelsPerInch} {
+		img, err := Draw(d, s, dpi)
+		if err != nil {
+			t.Fatalf("Draw at %v dpi: %v", dpi, err)
+		}
+
+		scale := dpi / PixelsPerInch
+		want := image.Rect(0, 0, int(math.Ceil(d.Width*scale)), int(math.Ceil(d.Height*scale)))
+		if img.Bounds() != want {
+			t.Errorf("Draw at %v dpi is %v, want %v", dpi, img.Bounds(), want)
+		}
+
+		at := func(x, y float64) color.RGBA {
+			return img.RGBAAt(int(x*scale), int(y*scale))
+		}
+		if got := at(1, 1); got != mustParseColour(layout.Background) {
+			t.Errorf("corner at %v dpi = %v, want the background", dpi, got)
+		}
+
+		box := d.Boxes[0]
+		if got := at(box.X+4, box.Y+4); got != mustParseColour(layout.DefaultColours.Header) {
+			t.Errorf("header at %v dpi = %v, want the header colour", dpi, got)
+		}
+		// Borders are antialiased, so only check they are drawn.
+		if got := at(box.X, box.Y+box.H/2); got == mustParseColour(layout.DefaultColours.Body) {
+			t.Errorf("border at %v dpi is not drawn", dpi)
+		}
+	}
+}
+
+func TestDrawColoursStyledTables(t *testing.T) {
+	s := layout.DefaultSettings()
+	if _, err := s.Set(formatter.OptionStyle, "people=users"); err != nil {
+		t.Fatal(err)
+	}
+	d := s.Layout(formattertest.SampleRenderData())
+
+	img, err := Draw(d, s, PixelsPerInch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, colours := s.Classes(formatter.Table{Name: "users"})
+	for _, box := range d.Boxes {
+		want := layout.DefaultColours.Header
+		if box.Table.Name == "users" {
+			want = colours.Header
+		}
+		if got := img.RGBAAt(int(box.X)+4, int(box.Y)+4); got != mustParseColour(want) {
+			t.Errorf("%s header = %v, want %s", box.Table.Name, got, want)
+		}
+	}
+}
+
+func TestDrawRejectsHugeImages(t *testing.T) {
+	d := layout.Diagram{Width: 100_000, Height: 100_000}
+	if _, err := Draw(d, layout.DefaultSettings(), PixelsPerInch); err == nil {
+		t.Error("expected an error for an image over MaxPixels")
+	}
+}
+
+func TestPolylineDashes(t *testing.T) {
+	c := &canvas{img: image.NewRGBA(image.Rect(0, 0, 40, 3)), scale: 1}
+	black := color.RGBA{A: 0xff}
+	c.polyline([]layout.Point{{X: 0, Y: 1.5}, {X: 40, Y: 1.5}}, 1, []float64{6, 4}, black)
+
+	for x, want := range map[int]bool{2: true, 8: false, 12: true, 18: false, 22: true} {
+		if got := c.img.RGBAAt(x, 1) == black; got != want {
+			t.Errorf("pixel %d drawn = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestMustParseColour(t *testing.T) {
+	tests := map[string]color.RGBA{
+		"#fde2e4": {R: 0xfd, G: 0xe2, B: 0xe4, A: 0xff},
+		"#FFFFFF": {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		"red":     {A: 0xff},
+		"#fff":    {A: 0xff},
+	}
+	for hex, want := range tests {
+		if got := mustParseColour(hex); got != want {
+			t.Errorf("mustParseColour(%q) = %v, want %v", hex, got, want)
+		}
+	}
+}
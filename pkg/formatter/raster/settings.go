@@ -0,0 +1,67 @@
+package raster
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strconv"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/layout"
+)
+
+// Settings are the options shared by the raster formatters.
+type Settings struct {
+	layout.Settings
+	DPI int
+}
+
+// DefaultSettings returns the settings a raster formatter drawing at dpi by
+// default starts from.
+func DefaultSettings(dpi int) Settings {
+	return Settings{Settings: layout.DefaultSettings(), DPI: dpi}
+}
+
+// SettingsOptions lists the options Settings.Set accepts, for a formatter
+// drawing at defaultDPI unless told otherwise.
+func SettingsOptions(defaultDPI int) []formatter.OptionSpec {
+	dpi := formatter.CommonOptions(formatter.OptionDPI)[0]
+	dpi.Default = strconv.Itoa(defaultDPI)
+	return append(layout.SettingsOptions(), dpi)
+}
+
+// Set applies formatter.OptionDPI or an option of layout.Settings, and
+// reports false for any other name.
+func (s *Settings) Set(name, value string) (bool, error) {
+	if name != formatter.OptionDPI {
+		return s.Settings.Set(name, value)
+	}
+
+	dpi, err := formatter.ParseDPI(value)
+	if err != nil {
+		return true, err
+	}
+	if dpi > 0 {
+		s.DPI = dpi
+	}
+	return true, nil
+}
+
+// Draw lays out data and draws it at the configured resolution, unless ctx is
+// done first.
+func (s Settings) Draw(ctx context.Context, data formatter.RenderData) (*image.RGBA, error) {
+	if len(data.Tables) == 0 {
+		return nil, fmt.Errorf("no tables found in schema")
+	}
+
+	diagram := s.Layout(data)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	img, err := Draw(diagram, s.Settings, float64(s.DPI))
+	if err != nil {
+		return nil, err
+	}
+	return img, ctx.Err()
+}
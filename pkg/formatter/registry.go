@@ -9,18 +9,31 @@ import (
 
 var (
 	registryMu sync.RWMutex
-	registry   = make(map[string]Factory)
+	registry   = make(map[string]Loader)
 )
 
 // Register adds a formatter factory to the registry.
 //
 // It panics if the name is empty, the factory is nil, or the name is already registered.
 func Register(name string, factory Factory) {
+	if factory == nil {
+		panic("formatter factory cannot be nil")
+	}
+	RegisterLoader(name, func() (Formatter, error) {
+		return factory(), nil
+	})
+}
+
+// RegisterLoader adds a loader to the registry, for formatters whose
+// construction can fail, such as external plugins.
+//
+// It panics if the name is empty, the loader is nil, or the name is already registered.
+func RegisterLoader(name string, loader Loader) {
 	if name == "" {
 		panic("formatter name cannot be empty")
 	}
-	if factory == nil {
-		panic("formatter factory cannot be nil")
+	if loader == nil {
+		panic("formatter loader cannot be nil")
 	}
 
 	registryMu.Lock()
@@ -30,24 +43,44 @@ func Register(name string, factory Factory) {
 		panic(fmt.Sprintf("formatter %q is already registered", name))
 	}
 
-	registry[name] = factory
+	registry[name] = loader
 }
 
-// Get returns a formatter for the provided name, falling back to DefaultFormat when empty.
-func Get(name string) (Formatter, error) {
+// Registered reports whether a formatter is registered under name.
+func Registered(name string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	_, ok := registry[name]
+	return ok
+}
+
+// Get returns a formatter for the provided name, falling back to DefaultFormat
+// when empty, configured by each of opts in turn.
+func Get(name string, opts ...Options) (Formatter, error) {
 	formatName := name
 	if formatName == "" {
 		formatName = DefaultFormat
 	}
 
 	registryMu.RLock()
-	factory, ok := registry[formatName]
+	load, ok := registry[formatName]
 	registryMu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("unknown format %q. Available formats: %s", formatName, strings.Join(Available(), ", "))
 	}
 
-	return factory(), nil
+	f, err := load()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range opts {
+		var err error
+		if f, err = Configure(f, o); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
 }
 
 // Available returns the list of registered formatter names in sorted order.
@@ -33,6 +33,25 @@ func TestGetUnknownFormat(t *testing.T) {
 	}
 }
 
+func TestGetAppliesOptions(t *testing.T) {
+	fmttr, err := formatter.Get("mermaid", formatter.Options{formatter.OptionTitle: "Teams"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err := fmttr.Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(out, "title: Teams") {
+		t.Errorf("expected the title option to be applied:\n%s", out)
+	}
+
+	if _, err := formatter.Get("mermaid", formatter.Options{"colour": "red"}); err == nil {
+		t.Error("expected an error for an undeclared option")
+	}
+}
+
 func TestAvailableIsSorted(t *testing.T) {
 	names := formatter.Available()
 	for i := 1; i < len(names); i++ {
@@ -87,3 +106,25 @@ func TestRegisterPanicsOnDuplicateName(t *testing.T) {
 		formatter.Register(name, newMockFactory())
 	})
 }
+
+func TestGetReportsLoaderErrors(t *testing.T) {
+	name := fmt.Sprintf("test-loader-error-%d", time.Now().UnixNano())
+
+	// The loader fails only while this test runs, so tests walking every
+	// registered format are not disturbed.
+	failing := true
+	defer func() { failing = false }()
+	formatter.RegisterLoader(name, func() (formatter.Formatter, error) {
+		if failing {
+			return nil, fmt.Errorf("plugin is missing")
+		}
+		return &formattertest.MockFormatter{}, nil
+	})
+
+	if !formatter.Registered(name) {
+		t.Fatal("expected the loader to be registered")
+	}
+	if _, err := formatter.Get(name); err == nil || err.Error() != "plugin is missing" {
+		t.Fatalf("Get returned %v, want the loader's error", err)
+	}
+}
@@ -0,0 +1,171 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OptionKind is the type of value an option takes.
+type OptionKind string
+
+// Option kinds.
+const (
+	// KindString takes any text.
+	KindString OptionKind = "string"
+	// KindInt takes a non-negative integer.
+	KindInt OptionKind = "int"
+	// KindEnum takes one of the option's Values, in any case.
+	KindEnum OptionKind = "enum"
+)
+
+// OptionSpec describes an option a formatter accepts.
+type OptionSpec struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Kind        OptionKind `json:"kind"`
+	// Default is the value used when the option is not set; empty when the
+	// formatter or renderer decides.
+	Default string `json:"default"`
+	// Values lists the values a KindEnum option accepts.
+	Values []string `json:"values,omitempty"`
+}
+
+// Describer is implemented by configurable formatters to declare the options
+// they accept.
+type Describer interface {
+	// OptionSpecs lists the accepted options, sorted by name.
+	OptionSpecs() []OptionSpec
+}
+
+// commonOptions describe the options several formatters share.
+var commonOptions = map[string]OptionSpec{
+	OptionTypeDisplay: {
+		Description: "How column types are shown: short (e.g. varchar) or full (e.g. varchar(255))",
+		Kind:        KindEnum, Default: TypeDisplayShort, Values: []string{TypeDisplayShort, TypeDisplayFull},
+	},
+	OptionTypeStyle: {
+		Description: "Column type names: raw (MySQL's, e.g. bigint) or portable (e.g. integer)",
+		Kind:        KindEnum, Default: TypeStyleRaw, Values: []string{TypeStyleRaw, TypeStylePortable},
+	},
+	OptionCommentLength: {
+		Description: "Truncate comments to this many characters; 0 keeps them whole",
+		Kind:        KindInt, Default: "0",
+	},
+	OptionTableComments: {
+		Description: "How table comments are shown: in the entity label, as a source comment, or not at all",
+		Kind:        KindEnum, Default: TableCommentsNone, Values: []string{TableCommentsNone, TableCommentsAlias, TableCommentsComment},
+	},
+	OptionTitle: {
+		Description: "Diagram title",
+		Kind:        KindString,
+	},
+	OptionDirection: {
+		Description: "Layout direction",
+		Kind:        KindEnum, Values: Directions,
+	},
+	OptionEntityPadding: {
+		Description: "Space in pixels around the text of each entity",
+		Kind:        KindInt,
+	},
+	OptionStyle: {
+		Description: fmt.Sprintf("Style rules CLASS=SELECTOR separated by %q, where SELECTOR is a name pattern, tag:TAG or group:GROUP", StyleRuleSeparator),
+		Kind:        KindString,
+	},
+	OptionPalette: {
+		Description: "Palette colouring the style classes",
+		Kind:        KindEnum, Default: DefaultPalette, Values: PaletteNames(),
+	},
+	OptionDPI: {
+		Description: fmt.Sprintf("Resolution in dots per inch, from 1 to %d", MaxDPI),
+		Kind:        KindInt,
+	},
+	OptionPageSize: {
+		Description: "Page size; fixed sizes turn landscape for wide diagrams",
+		Kind:        KindEnum, Default: PageSizeFit, Values: PageSizes,
+	},
+}
+
+// CommonOptions returns the specs of shared options such as
+// OptionTypeDisplay, in the order named. It panics on a name it does not
+// describe, which is a programming error.
+func CommonOptions(names ...string) []OptionSpec {
+	specs := make([]OptionSpec, len(names))
+	for i, name := range names {
+		spec, ok := commonOptions[name]
+		if !ok {
+			panic(fmt.Sprintf("formatter: no common option %q", name))
+		}
+		spec.Name = name
+		spec.Values = append([]string(nil), spec.Values...)
+		specs[i] = spec
+	}
+	return specs
+}
+
+// SortOptions sorts specs by name.
+func SortOptions(specs []OptionSpec) []OptionSpec {
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+	return specs
+}
+
+// Specs returns the options f declares, or nil when it declares none.
+func Specs(f Formatter) []OptionSpec {
+	if d, ok := f.(Describer); ok {
+		return d.OptionSpecs()
+	}
+	return nil
+}
+
+// ValidateOptions checks that every option in opts is declared in specs and
+// has a value of its kind. Formatters may check values further.
+func ValidateOptions(format string, specs []OptionSpec, opts Options) error {
+	byName := make(map[string]OptionSpec, len(specs))
+	names := make([]string, len(specs))
+	for i, spec := range specs {
+		byName[spec.Name] = spec
+		names[i] = spec.Name
+	}
+
+	keys := make([]string, 0, len(opts))
+	for name := range opts {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+
+	for _, name := range keys {
+		spec, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("format %q has no option %q; it accepts %s", format, name, strings.Join(names, ", "))
+		}
+		if err := spec.check(opts[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check validates value against the spec's kind; empty means unset.
+func (s OptionSpec) check(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	switch s.Kind {
+	case KindInt:
+		if n, err := strconv.Atoi(value); err != nil || n < 0 {
+			return fmt.Errorf("invalid %s %q: want a non-negative integer", s.Name, value)
+		}
+	case KindEnum:
+		for _, known := range s.Values {
+			if strings.EqualFold(value, known) {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid %s %q: want %s", s.Name, value, strings.Join(s.Values, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package formatter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func TestValidateOptions(t *testing.T) {
+	specs := []formatter.OptionSpec{
+		{Name: "size", Kind: formatter.KindInt},
+		{Name: "mode", Kind: formatter.KindEnum, Values: []string{"fast", "slow"}},
+		{Name: "label", Kind: formatter.KindString},
+	}
+
+	valid := formatter.Options{"size": "12", "mode": "FAST", "label": "anything"}
+	if err := formatter.ValidateOptions("demo", specs, valid); err != nil {
+		t.Errorf("ValidateOptions(%v) = %v, want nil", valid, err)
+	}
+
+	tests := map[string]formatter.Options{
+		`format "demo" has no option "colour"; it accepts size, mode, label`: {"colour": "red"},
+		`invalid size "-1": want a non-negative integer`:                     {"size": "-1"},
+		`invalid mode "medium": want fast, slow`:                             {"mode": "medium"},
+	}
+	for want, opts := range tests {
+		if err := formatter.ValidateOptions("demo", specs, opts); err == nil || err.Error() != want {
+			t.Errorf("ValidateOptions(%v) = %v, want %q", opts, err, want)
+		}
+	}
+}
+
+func TestCommonOptions(t *testing.T) {
+	specs := formatter.CommonOptions(formatter.OptionTypeDisplay, formatter.OptionTitle)
+	if len(specs) != 2 || specs[0].Name != formatter.OptionTypeDisplay || specs[1].Name != formatter.OptionTitle {
+		t.Fatalf("CommonOptions = %+v", specs)
+	}
+	if specs[0].Default != formatter.TypeDisplayShort || specs[0].Kind != formatter.KindEnum {
+		t.Errorf("type-display spec = %+v", specs[0])
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an undescribed option")
+		}
+	}()
+	formatter.CommonOptions("colour")
+}
+
+// describedFormatter declares one option.
+type describedFormatter struct {
+	plainFormatter
+}
+
+func (describedFormatter) OptionSpecs() []formatter.OptionSpec {
+	return []formatter.OptionSpec{{Name: "mode", Kind: formatter.KindEnum, Values: []string{"fast"}}}
+}
+
+func (f describedFormatter) WithOptions(formatter.Options) (formatter.Formatter, error) {
+	return f, nil
+}
+
+func TestConfigureChecksDeclaredOptions(t *testing.T) {
+	f := describedFormatter{}
+
+	if _, err := formatter.Configure(f, formatter.Options{"mode": "fast"}); err != nil {
+		t.Errorf("Configure with a declared option = %v", err)
+	}
+
+	_, err := formatter.Configure(f, formatter.Options{"speed": "fast"})
+	if err == nil || !strings.Contains(err.Error(), `format "plain" has no option "speed"`) {
+		t.Errorf("Configure with an undeclared option = %v", err)
+	}
+}
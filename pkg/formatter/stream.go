@@ -0,0 +1,58 @@
+package formatter
+
+import (
+	"context"
+	"io"
+)
+
+// StreamFormatter is implemented by formatters that write their output as they
+// render it rather than building it in memory, which suits large and binary
+// formats.
+type StreamFormatter interface {
+	Formatter
+	// RenderTo writes the rendering of data, configured by opts, to w. It stops
+	// with ctx's error once ctx is done.
+	RenderTo(ctx context.Context, w io.Writer, data RenderData, opts Options) error
+}
+
+// Stream returns f as a StreamFormatter. Formatters that only implement Render
+// are adapted: they are configured with Configure, and the string they render
+// is written out whole.
+func Stream(f Formatter) StreamFormatter {
+	if s, ok := f.(StreamFormatter); ok {
+		return s
+	}
+	return stringStream{Formatter: f}
+}
+
+// RenderTo writes f's rendering of data, configured by opts, to w.
+func RenderTo(ctx context.Context, f Formatter, w io.Writer, data RenderData, opts Options) error {
+	return Stream(f).RenderTo(ctx, w, data, opts)
+}
+
+// stringStream adapts a formatter implementing Render only.
+type stringStream struct {
+	Formatter
+}
+
+func (s stringStream) RenderTo(ctx context.Context, w io.Writer, data RenderData, opts Options) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f, err := Configure(s.Formatter, opts)
+	if err != nil {
+		return err
+	}
+
+	output, err := f.Render(data)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, output)
+	return err
+}
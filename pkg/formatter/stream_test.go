@@ -0,0 +1,61 @@
+package formatter_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+	"github.com/motchang/marid/pkg/formatter/mermaid"
+	"github.com/motchang/marid/pkg/formatter/svg"
+)
+
+func TestRenderToAdaptsStringFormatters(t *testing.T) {
+	var b strings.Builder
+	err := formatter.RenderTo(context.Background(), mermaid.New(), &b, formattertest.SampleRenderData(), nil)
+	if err != nil {
+		t.Fatalf("RenderTo returned error: %v", err)
+	}
+
+	if got, want := b.String(), formattertest.SampleMermaidOutput(); got != want {
+		t.Errorf("RenderTo wrote\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderToAppliesOptions(t *testing.T) {
+	var b strings.Builder
+	opts := formatter.Options{formatter.OptionTitle: "Teams"}
+	if err := formatter.RenderTo(context.Background(), mermaid.New(), &b, formattertest.SampleRenderData(), opts); err != nil {
+		t.Fatalf("RenderTo returned error: %v", err)
+	}
+	if !strings.Contains(b.String(), "title: Teams") {
+		t.Errorf("RenderTo ignored the title option:\n%s", b.String())
+	}
+
+	err := formatter.RenderTo(context.Background(), plainFormatter{}, &b, formattertest.SampleRenderData(), opts)
+	if err == nil || !strings.Contains(err.Error(), "takes no options") {
+		t.Errorf("expected options to a plain formatter to be rejected, got %v", err)
+	}
+}
+
+func TestRenderToStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var b strings.Builder
+	err := formatter.RenderTo(ctx, mermaid.New(), &b, formattertest.SampleRenderData(), nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RenderTo after cancellation = %v, want context.Canceled", err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("RenderTo wrote %q after cancellation", b.String())
+	}
+}
+
+func TestStreamKeepsStreamFormatters(t *testing.T) {
+	if _, ok := formatter.Stream(svg.New()).(svg.Formatter); !ok {
+		t.Error("Stream adapted a formatter that implements RenderTo")
+	}
+}
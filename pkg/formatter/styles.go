@@ -0,0 +1,213 @@
+package formatter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/motchang/marid/pkg/utils"
+)
+
+// OptionStyle assigns style classes to tables: rules of the form
+// CLASS=SELECTOR separated by StyleRuleSeparator, such as
+// "billing=name:billing_*;auth=tag:auth". See ParseStyleRules.
+const OptionStyle = "style"
+
+// OptionPalette names the built-in palette, one of Palettes, that colours the
+// classes of OptionStyle.
+const OptionPalette = "palette"
+
+// StyleRuleSeparator separates the rules of an OptionStyle value.
+const StyleRuleSeparator = ";"
+
+// DefaultPalette is the palette used when OptionPalette is not set.
+const DefaultPalette = "pastel"
+
+// Palettes are the built-in palettes by name. Classes take their colours in
+// the order they first appear in the rules, starting over when a palette runs
+// out.
+var Palettes = map[string][]string{
+	"pastel": {
+		"fill:#fde2e4,stroke:#c9184a",
+		"fill:#d8f3dc,stroke:#2d6a4f",
+		"fill:#dfe7fd,stroke:#3a0ca3",
+		"fill:#fff1c1,stroke:#b08900",
+		"fill:#e9e3f5,stroke:#6a4c93",
+		"fill:#d8f3f5,stroke:#0a7e8c",
+	},
+	"vivid": {
+		"fill:#e63946,stroke:#9d0208,color:#ffffff",
+		"fill:#2a9d8f,stroke:#1d6f65,color:#ffffff",
+		"fill:#457b9d,stroke:#1d3557,color:#ffffff",
+		"fill:#f4a261,stroke:#c26a1d,color:#000000",
+		"fill:#8338ec,stroke:#4c1d95,color:#ffffff",
+		"fill:#06d6a0,stroke:#04896a,color:#000000",
+	},
+	"mono": {
+		"fill:#f8f9fa,stroke:#212529",
+		"fill:#e9ecef,stroke:#212529",
+		"fill:#dee2e6,stroke:#212529",
+		"fill:#ced4da,stroke:#212529",
+		"fill:#adb5bd,stroke:#212529",
+		"fill:#6c757d,stroke:#212529,color:#ffffff",
+	},
+}
+
+// Style rule selectors.
+const (
+	// SelectorName matches the table name against a glob or "re:" pattern.
+	SelectorName = "name"
+	// SelectorTag matches a "#tag" word in the table comment.
+	SelectorTag = "tag"
+	// SelectorGroup matches the group assigned by an overlay file.
+	SelectorGroup = "group"
+)
+
+// reservedClasses are the classes formatters attach to tables by their flags.
+var reservedClasses = map[string]bool{"focus": true, "external": true}
+
+var (
+	classNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	commentTag       = regexp.MustCompile(`(?:^|\s)#([\p{L}\p{N}_-]+)`)
+)
+
+// StyleRule attaches a style class to the tables its selector matches.
+type StyleRule struct {
+	Class    string
+	Selector string
+	Value    string
+	pattern  utils.Pattern
+}
+
+// ParseStyleRules parses an OptionStyle value. Each rule is CLASS=SELECTOR,
+// where the selector is "name:PATTERN" (or just PATTERN) for a glob or
+// "re:" table name pattern, "tag:TAG" for tables whose comment carries
+// "#TAG", or "group:GROUP" for tables in an overlay group. A class may
+// appear in several rules, and a table takes every class whose rules match.
+func ParseStyleRules(value string) ([]StyleRule, error) {
+	var rules []StyleRule
+	for _, text := range strings.Split(value, StyleRuleSeparator) {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		rule, err := parseStyleRule(text)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseStyleRule(text string) (StyleRule, error) {
+	class, selector, ok := strings.Cut(text, "=")
+	class, selector = strings.TrimSpace(class), strings.TrimSpace(selector)
+	if !ok || selector == "" {
+		return StyleRule{}, fmt.Errorf("invalid %s rule %q: want CLASS=SELECTOR", OptionStyle, text)
+	}
+	if !classNamePattern.MatchString(class) {
+		return StyleRule{}, fmt.Errorf("invalid %s rule %q: class names are letters, digits, \"_\" and \"-\"", OptionStyle, text)
+	}
+	if reservedClasses[class] {
+		return StyleRule{}, fmt.Errorf("invalid %s rule %q: class %q is reserved", OptionStyle, text, class)
+	}
+
+	rule := StyleRule{Class: class, Selector: SelectorName, Value: selector}
+	if kind, value, ok := strings.Cut(selector, ":"); ok {
+		switch kind {
+		case SelectorName, SelectorTag, SelectorGroup:
+			rule.Selector, rule.Value = kind, strings.TrimSpace(value)
+		}
+	}
+	if rule.Value == "" {
+		return StyleRule{}, fmt.Errorf("invalid %s rule %q: empty %s selector", OptionStyle, text, rule.Selector)
+	}
+
+	if rule.Selector == SelectorName {
+		pattern, err := utils.CompilePattern(rule.Value)
+		if err != nil {
+			return StyleRule{}, fmt.Errorf("invalid %s rule %q: %w", OptionStyle, text, err)
+		}
+		rule.pattern = pattern
+	}
+	return rule, nil
+}
+
+// Matches reports whether the rule applies to table. Tags and groups are
+// compared case-insensitively.
+func (r StyleRule) Matches(table Table) bool {
+	switch r.Selector {
+	case SelectorTag:
+		for _, tag := range CommentTags(table.Comment) {
+			if strings.EqualFold(tag, r.Value) {
+				return true
+			}
+		}
+		return false
+	case SelectorGroup:
+		return table.Group != "" && strings.EqualFold(table.Group, r.Value)
+	default:
+		return r.pattern.Match(table.Name)
+	}
+}
+
+// CommentTags returns the "#tag" words of a comment, without the "#".
+func CommentTags(comment string) []string {
+	var tags []string
+	for _, match := range commentTag.FindAllStringSubmatch(comment, -1) {
+		tags = append(tags, match[1])
+	}
+	return tags
+}
+
+// StyleClass is a style class with the style its palette gives it.
+type StyleClass struct {
+	Name  string
+	Style string
+}
+
+// StyleClasses returns the classes of rules in the order they first appear,
+// coloured from the named palette.
+func StyleClasses(rules []StyleRule, palette string) []StyleClass {
+	colours := Palettes[palette]
+	if len(colours) == 0 {
+		colours = Palettes[DefaultPalette]
+	}
+
+	var classes []StyleClass
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if seen[rule.Class] {
+			continue
+		}
+		seen[rule.Class] = true
+		classes = append(classes, StyleClass{Name: rule.Class, Style: colours[len(classes)%len(colours)]})
+	}
+	return classes
+}
+
+// ParsePalette validates an OptionPalette value; empty means DefaultPalette.
+func ParsePalette(value string) (string, error) {
+	if value == "" {
+		return DefaultPalette, nil
+	}
+
+	palette := strings.ToLower(value)
+	if _, ok := Palettes[palette]; !ok {
+		return "", fmt.Errorf("invalid %s %q: want %s", OptionPalette, value, strings.Join(PaletteNames(), ", "))
+	}
+	return palette, nil
+}
+
+// PaletteNames returns the names of the built-in palettes, sorted.
+func PaletteNames() []string {
+	names := make([]string, 0, len(Palettes))
+	for name := range Palettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
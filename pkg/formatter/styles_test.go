@@ -0,0 +1,102 @@
+package formatter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func TestParseStyleRules(t *testing.T) {
+	rules, err := formatter.ParseStyleRules(" billing=billing_* ; auth=tag:auth;catalog=group:Catalog;;legacy=name:re:_old$")
+	if err != nil {
+		t.Fatalf("ParseStyleRules returned error: %v", err)
+	}
+
+	var got [][3]string
+	for _, rule := range rules {
+		got = append(got, [3]string{rule.Class, rule.Selector, rule.Value})
+	}
+	want := [][3]string{
+		{"billing", formatter.SelectorName, "billing_*"},
+		{"auth", formatter.SelectorTag, "auth"},
+		{"catalog", formatter.SelectorGroup, "Catalog"},
+		{"legacy", formatter.SelectorName, "re:_old$"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStyleRules() = %v, want %v", got, want)
+	}
+}
+
+func TestParseStyleRulesRejectsInvalidRules(t *testing.T) {
+	for _, value := range []string{
+		"billing",
+		"billing=",
+		"=billing_*",
+		"bill ing=billing_*",
+		"focus=orders",
+		"auth=tag:",
+		"billing=re:(",
+	} {
+		if _, err := formatter.ParseStyleRules(value); err == nil {
+			t.Errorf("ParseStyleRules(%q) returned no error", value)
+		}
+	}
+}
+
+func TestStyleRuleMatches(t *testing.T) {
+	rules, err := formatter.ParseStyleRules("a=billing_*;b=tag:Auth;c=group:catalog")
+	if err != nil {
+		t.Fatalf("ParseStyleRules returned error: %v", err)
+	}
+
+	tests := []struct {
+		table formatter.Table
+		want  []bool
+	}{
+		{formatter.Table{Name: "billing_invoices"}, []bool{true, false, false}},
+		{formatter.Table{Name: "users", Comment: "Login accounts #auth #core"}, []bool{false, true, false}},
+		{formatter.Table{Name: "hashes", Comment: "no tag: a#auth"}, []bool{false, false, false}},
+		{formatter.Table{Name: "products", Group: "Catalog"}, []bool{false, false, true}},
+	}
+	for _, tt := range tests {
+		for i, rule := range rules {
+			if got := rule.Matches(tt.table); got != tt.want[i] {
+				t.Errorf("rule %s Matches(%+v) = %v, want %v", rule.Class, tt.table, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestCommentTags(t *testing.T) {
+	got := formatter.CommentTags("#billing Invoices, see #ledger-v2 and issue#12")
+	if want := []string{"billing", "ledger-v2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CommentTags() = %v, want %v", got, want)
+	}
+}
+
+func TestStyleClasses(t *testing.T) {
+	rules, err := formatter.ParseStyleRules("billing=billing_*;auth=tag:auth;billing=tag:billing")
+	if err != nil {
+		t.Fatalf("ParseStyleRules returned error: %v", err)
+	}
+
+	mono := formatter.Palettes["mono"]
+	want := []formatter.StyleClass{{Name: "billing", Style: mono[0]}, {Name: "auth", Style: mono[1]}}
+	if got := formatter.StyleClasses(rules, "mono"); !reflect.DeepEqual(got, want) {
+		t.Errorf("StyleClasses() = %v, want %v", got, want)
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	tests := map[string]string{"": formatter.DefaultPalette, "Vivid": "vivid", "mono": "mono"}
+	for value, want := range tests {
+		if got, err := formatter.ParsePalette(value); err != nil || got != want {
+			t.Errorf("ParsePalette(%q) = %q, %v; want %q", value, got, err, want)
+		}
+	}
+
+	if _, err := formatter.ParsePalette("neon"); err == nil {
+		t.Error("expected an error for an unknown palette")
+	}
+}
@@ -0,0 +1,220 @@
+// Package svg renders ER diagrams as standalone SVG documents, laid out by
+// package layout, so no browser or Mermaid installation is needed to view
+// them.
+package svg
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/layout"
+)
+
+func init() {
+	formatter.Register("svg", func() formatter.Formatter {
+		return New()
+	})
+}
+
+// fontFamily lists monospaced fonts whose advance matches
+// layout.DefaultMetrics.
+const fontFamily = "'Go Mono', 'DejaVu Sans Mono', Menlo, Consolas, monospace"
+
+// Line styles of the drawing.
+const (
+	focusStroke  = "3"
+	externalDash = "5 5"
+	inferredDash = "6 4"
+)
+
+// Formatter renders ER diagrams as SVG.
+type Formatter struct {
+	settings layout.Settings
+}
+
+// New creates a new SVG formatter instance.
+func New() Formatter {
+	return Formatter{settings: layout.DefaultSettings()}
+}
+
+// WithOptions returns the formatter configured by opts. It accepts the
+// options of layout.Settings.
+func (f Formatter) WithOptions(opts formatter.Options) (formatter.Formatter, error) {
+	return f.withOptions(opts)
+}
+
+func (f Formatter) withOptions(opts formatter.Options) (Formatter, error) {
+	for name, value := range opts {
+		known, err := f.settings.Set(name, value)
+		if err != nil {
+			return f, err
+		}
+		if !known {
+			return f, fmt.Errorf("unknown svg option %q", name)
+		}
+	}
+	return f, nil
+}
+
+// OptionSpecs lists the options WithOptions accepts.
+func (f Formatter) OptionSpecs() []formatter.OptionSpec {
+	return formatter.SortOptions(layout.SettingsOptions())
+}
+
+// Metadata describes the format.
+func (f Formatter) Metadata() formatter.Metadata {
+	return formatter.Metadata{
+		Description: "Standalone SVG image laid out by marid itself",
+		Features:    []formatter.Feature{formatter.FeatureCardinality, formatter.FeatureStyles},
+	}
+}
+
+// Name returns the formatter name.
+func (f Formatter) Name() string {
+	return "svg"
+}
+
+// MediaType returns the formatter output media type.
+func (f Formatter) MediaType() string {
+	return "image/svg+xml"
+}
+
+// Render lays out the render data and draws it as an SVG document.
+func (f Formatter) Render(data formatter.RenderData) (string, error) {
+	var b strings.Builder
+	if err := f.RenderTo(context.Background(), &b, data, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderTo lays out the render data, configured by opts, and writes it to w
+// as an SVG document.
+func (f Formatter) RenderTo(ctx context.Context, w io.Writer, data formatter.RenderData, opts formatter.Options) error {
+	f, err := f.withOptions(opts)
+	if err != nil {
+		return err
+	}
+	if len(data.Tables) == 0 {
+		return fmt.Errorf("no tables found in schema")
+	}
+
+	diagram := f.settings.Layout(data)
+	m := layout.DefaultMetrics
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	_, _ = fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="%s" font-size="%s">`+"\n",
+		num(diagram.Width), num(diagram.Height), num(diagram.Width), num(diagram.Height), fontFamily, num(m.FontSize))
+	_, _ = fmt.Fprintf(b, `  <rect width="100%%" height="100%%" fill="%s"/>`+"\n", layout.Background)
+
+	if diagram.Title != "" {
+		_, _ = fmt.Fprintf(b, `  <text x="%s" y="%s" font-size="%s" font-weight="bold" fill="%s">%s</text>`+"\n",
+			num(diagram.TitleAt.X), num(diagram.TitleAt.Y), num(m.TitleSize), layout.LineColour, escape(diagram.Title))
+	}
+
+	for _, box := range diagram.Boxes {
+		f.writeBox(b, box)
+	}
+	for _, edge := range diagram.Edges {
+		writeEdge(b, edge, m)
+	}
+
+	b.WriteString("</svg>\n")
+	return b.Flush()
+}
+
+// writeBox draws a table. The first matching style class colours its header
+// and border, and every matching class is listed in its class attribute.
+func (f Formatter) writeBox(b *bufio.Writer, box layout.Box) {
+	classes, colours := f.settings.Classes(box.Table)
+	classNames := append([]string{"entity"}, classes...)
+
+	border := fmt.Sprintf(`stroke="%s"`, colours.Stroke)
+	if box.Table.Focus {
+		classNames = append(classNames, "focus")
+		border += fmt.Sprintf(` stroke-width="%s"`, focusStroke)
+	}
+	if box.Table.External {
+		classNames = append(classNames, "external")
+		border += fmt.Sprintf(` stroke-dasharray="%s"`, externalDash)
+	}
+
+	m := layout.DefaultMetrics
+	_, _ = fmt.Fprintf(b, `  <g class="%s" data-table="%s">`+"\n", strings.Join(classNames, " "), escape(box.Table.Name))
+	_, _ = fmt.Fprintf(b, `    <rect x="%s" y="%s" width="%s" height="%s" fill="%s" %s/>`+"\n",
+		num(box.X), num(box.Y), num(box.W), num(box.H), colours.Body, border)
+	_, _ = fmt.Fprintf(b, `    <rect x="%s" y="%s" width="%s" height="%s" fill="%s" %s/>`+"\n",
+		num(box.X), num(box.Y), num(box.W), num(m.HeaderHeight), colours.Header, border)
+	_, _ = fmt.Fprintf(b, `    <text x="%s" y="%s" text-anchor="middle" font-weight="bold" fill="%s">%s</text>`+"\n",
+		num(box.X+box.W/2), num(box.HeaderBaseline), colours.Text, escape(box.Label))
+
+	for _, row := range box.Rows {
+		writeText(b, box.NameX, row.Baseline, layout.DefaultColours.Text, row.Name)
+		writeText(b, box.TypeX, row.Baseline, layout.DefaultColours.Text, row.Type)
+		writeText(b, box.KeysX, row.Baseline, layout.KeysColour, row.Keys)
+	}
+	b.WriteString("  </g>\n")
+}
+
+func writeText(b *bufio.Writer, x, y float64, fill, text string) {
+	if text == "" {
+		return
+	}
+	_, _ = fmt.Fprintf(b, `    <text x="%s" y="%s" fill="%s">%s</text>`+"\n", num(x), num(y), fill, escape(text))
+}
+
+// writeEdge draws a relationship with its crow's foot ends and label.
+func writeEdge(b *bufio.Writer, edge layout.Edge, m layout.Metrics) {
+	points := make([]string, len(edge.Points))
+	for k, p := range edge.Points {
+		points[k] = num(p.X) + "," + num(p.Y)
+	}
+
+	dash := ""
+	if edge.Dashed {
+		dash = fmt.Sprintf(` stroke-dasharray="%s"`, inferredDash)
+	}
+
+	b.WriteString(`  <g class="relationship">` + "\n")
+	_, _ = fmt.Fprintf(b, `    <polyline points="%s" fill="none" stroke="%s"%s/>`+"\n", strings.Join(points, " "), layout.LineColour, dash)
+	for _, end := range []layout.End{edge.From, edge.To} {
+		segments, circles := end.Marks()
+		for _, s := range segments {
+			_, _ = fmt.Fprintf(b, `    <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s"/>`+"\n",
+				num(s.From.X), num(s.From.Y), num(s.To.X), num(s.To.Y), layout.LineColour)
+		}
+		for _, c := range circles {
+			_, _ = fmt.Fprintf(b, `    <circle cx="%s" cy="%s" r="%s" fill="%s" stroke="%s"/>`+"\n",
+				num(c.Center.X), num(c.Center.Y), num(c.Radius), layout.Background, layout.LineColour)
+		}
+	}
+	if edge.Label != "" {
+		_, _ = fmt.Fprintf(b, `    <text x="%s" y="%s" text-anchor="%s" font-size="%s" fill="%s">%s</text>`+"\n",
+			num(edge.LabelAt.X), num(edge.LabelAt.Y), edge.LabelAnchor, num(m.LabelSize), layout.LineColour, escape(edge.Label))
+	}
+	b.WriteString("  </g>\n")
+}
+
+// num formats a coordinate with at most two decimals, so output is stable
+// and compact.
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// escape makes text safe in XML character data and attribute values.
+func escape(text string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(text))
+	return b.String()
+}
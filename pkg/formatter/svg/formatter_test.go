@@ -0,0 +1,183 @@
+package svg
+
+import (
+	"encoding/xml"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestFormatterMetadata(t *testing.T) {
+	f := New()
+
+	if got, want := f.Name(), "svg"; got != want {
+		t.Fatalf("Name() = %q, want %q", got, want)
+	}
+
+	if got, want := f.MediaType(), "image/svg+xml"; got != want {
+		t.Fatalf("MediaType() = %q, want %q", got, want)
+	}
+}
+
+func TestInitRegistersFormatter(t *testing.T) {
+	got, err := formatter.Get("svg")
+	if err != nil {
+		t.Fatalf("formatter.Get(%q) returned error: %v", "svg", err)
+	}
+
+	if _, ok := got.(Formatter); !ok {
+		t.Fatalf("formatter.Get(%q) returned %T, want svg.Formatter", "svg", got)
+	}
+}
+
+func TestRenderNoTables(t *testing.T) {
+	if _, err := New().Render(formatter.RenderData{}); err == nil {
+		t.Fatal("expected an error for empty render data")
+	}
+}
+
+// featureData exercises every drawing feature: aliases, external stubs, focus
+// tables, self references, relationships spanning several layers, inferred
+// and virtual relationships, each cardinality, wide characters and markup.
+func featureData() formatter.RenderData {
+	id := formatter.Column{Name: "id", DataType: "bigint", ColumnType: "bigint unsigned"}
+	return formatter.RenderData{
+		Tables: []formatter.Table{
+			{Name: "billing_accounts", PrimaryKey: []string{"id"}, Columns: []formatter.Column{id, {Name: "owner", DataType: "varchar", ColumnType: "varchar(64)", IsUnique: true}}},
+			{
+				Name: "billing_invoices", PrimaryKey: []string{"id"}, Focus: true,
+				Columns: []formatter.Column{id, {Name: "account_id", DataType: "bigint"}, {Name: "amount", DataType: "decimal", ColumnType: "decimal(10,2)"}},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "account_id", ReferencedTable: "billing_accounts", ReferencedColumn: "id", RelationName: "fk_invoice_account"},
+				},
+			},
+			{
+				Name: "billing_lines", PrimaryKey: []string{"id"},
+				Columns: []formatter.Column{id, {Name: "invoice_id", DataType: "bigint"}, {Name: "account_id", DataType: "bigint"}, {Name: "parent_id", DataType: "bigint"}},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "invoice_id", ReferencedTable: "billing_invoices", ReferencedColumn: "id", RelationName: "fk_line_invoice", Cardinality: formatter.CardinalityOneToOne},
+					{ColumnName: "account_id", ReferencedTable: "billing_accounts", ReferencedColumn: "id", RelationName: "account_id", Inferred: true},
+					{ColumnName: "parent_id", ReferencedTable: "billing_lines", ReferencedColumn: "id", RelationName: "fk_line_parent"},
+				},
+			},
+			{Name: "users", Alias: "顧客 <customers>", Comment: "Registered customers", Columns: []formatter.Column{id}},
+			{
+				Name: "tags", Columns: []formatter.Column{{Name: "user_id", DataType: "bigint"}},
+				ForeignKeys: []formatter.ForeignKey{
+					{ColumnName: "user_id", ReferencedTable: "users", ReferencedColumn: "id", RelationName: "tagged & owned", Virtual: true, Cardinality: formatter.CardinalityManyToMany},
+					{ColumnName: "user_id", ReferencedTable: "teams", ReferencedColumn: "id", RelationName: "fk_tag_team", Cardinality: formatter.CardinalityOneToMany},
+				},
+			},
+			{Name: "teams", External: true, Columns: []formatter.Column{{Name: "id", DataType: "bigint"}}},
+		},
+	}
+}
+
+func TestRenderMatchesGoldenFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		opts formatter.Options
+		data formatter.RenderData
+	}{
+		{name: "sample", data: formattertest.SampleRenderData()},
+		{
+			name: "features",
+			opts: formatter.Options{
+				formatter.OptionTitle:       "Billing\nschema",
+				formatter.OptionTypeDisplay: formatter.TypeDisplayFull,
+				formatter.OptionStyle:       "billing=billing_*;people=tag:people;people=users",
+			},
+			data: featureData(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New().WithOptions(tt.opts)
+			if err != nil {
+				t.Fatalf("WithOptions returned error: %v", err)
+			}
+
+			got, err := f.Render(tt.data)
+			if err != nil {
+				t.Fatalf("Render returned error: %v", err)
+			}
+			if err := checkWellFormed(got); err != nil {
+				t.Fatalf("output is not well-formed XML: %v\n%s", err, got)
+			}
+
+			golden := filepath.Join("testdata", tt.name+".svg")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatalf("writing golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("reading golden file (run go test with -update to create it): %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("Render() differs from %s; run go test with -update and review the diff", golden)
+			}
+		})
+	}
+}
+
+func TestRenderIsDeterministic(t *testing.T) {
+	first, err := New().Render(featureData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	for run := 0; run < 10; run++ {
+		if got, _ := New().Render(featureData()); got != first {
+			t.Fatalf("run %d rendered differently", run)
+		}
+	}
+}
+
+func TestRenderEscapesMarkup(t *testing.T) {
+	got, err := New().Render(featureData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+
+	for _, want := range []string{"顧客 &lt;customers&gt;", "tagged &amp; owned"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in output", want)
+		}
+	}
+}
+
+func TestWithOptionsRejectsInvalidOptions(t *testing.T) {
+	for _, opts := range []formatter.Options{
+		{formatter.OptionTypeDisplay: "long"},
+		{formatter.OptionTypeStyle: "sql"},
+		{formatter.OptionStyle: "billing"},
+		{formatter.OptionPalette: "neon"},
+		{formatter.OptionTheme: "dark"},
+	} {
+		if _, err := New().WithOptions(opts); err == nil {
+			t.Errorf("WithOptions(%v) returned no error", opts)
+		}
+	}
+}
+
+func checkWellFormed(document string) error {
+	decoder := xml.NewDecoder(strings.NewReader(document))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
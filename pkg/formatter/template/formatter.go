@@ -0,0 +1,191 @@
+// Package template renders diagrams through a user-supplied text/template
+// file, for one-off documents such as wiki pages, Confluence markup or custom
+// YAML that do not deserve a formatter or a plugin of their own.
+package template
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/motchang/marid/pkg/formatter"
+)
+
+func init() {
+	formatter.Register("template", func() formatter.Formatter {
+		return New()
+	})
+}
+
+// Data is the dot of a template: the render data and the diagram title.
+type Data struct {
+	formatter.RenderData
+	// Title is the formatter.OptionTitle option, empty when unset.
+	Title string
+}
+
+// Formatter renders the render data through a template file.
+type Formatter struct {
+	path string
+	// source is the template text, quoted in error messages.
+	source      string
+	tmpl        *texttemplate.Template
+	title       string
+	typeDisplay string
+	typeStyle   string
+}
+
+// New creates a new template formatter instance. It needs
+// formatter.OptionTemplate before it can render.
+func New() Formatter {
+	return Formatter{
+		typeDisplay: formatter.TypeDisplayShort,
+		typeStyle:   formatter.TypeStyleRaw,
+	}
+}
+
+// Parse returns a formatter rendering the template text, named path in error
+// messages.
+func Parse(path, source string) (Formatter, error) {
+	f := New()
+	return f.parse(path, source)
+}
+
+// WithOptions returns the formatter configured by opts. It accepts
+// formatter.OptionTemplate, whose file is read and parsed at once, and
+// formatter.OptionTitle, formatter.OptionTypeDisplay and
+// formatter.OptionTypeStyle, which the template sees as .Title and through
+// typeOf.
+func (f Formatter) WithOptions(opts formatter.Options) (formatter.Formatter, error) {
+	return f.withOptions(opts)
+}
+
+func (f Formatter) withOptions(opts formatter.Options) (Formatter, error) {
+	for name, value := range opts {
+		switch name {
+		case formatter.OptionTemplate:
+			source, err := os.ReadFile(value)
+			if err != nil {
+				return f, fmt.Errorf("reading template: %w", err)
+			}
+			if f, err = f.parse(value, string(source)); err != nil {
+				return f, err
+			}
+		case formatter.OptionTitle:
+			f.title = value
+		case formatter.OptionTypeDisplay:
+			display, err := formatter.ParseTypeDisplay(value)
+			if err != nil {
+				return f, err
+			}
+			f.typeDisplay = display
+		case formatter.OptionTypeStyle:
+			style, err := formatter.ParseTypeStyle(value)
+			if err != nil {
+				return f, err
+			}
+			f.typeStyle = style
+		default:
+			return f, fmt.Errorf("unknown template option %q", name)
+		}
+	}
+	return f, nil
+}
+
+func (f Formatter) parse(path, source string) (Formatter, error) {
+	f.path, f.source = path, source
+
+	// The helpers are bound to the render data when the template runs.
+	tmpl, err := texttemplate.New(path).Funcs(f.funcs(formatter.RenderData{})).Option("missingkey=error").Parse(source)
+	if err != nil {
+		return f, f.templateError("invalid template", err)
+	}
+	f.tmpl = tmpl
+	return f, nil
+}
+
+// OptionSpecs lists the options WithOptions accepts.
+func (f Formatter) OptionSpecs() []formatter.OptionSpec {
+	specs := formatter.CommonOptions(formatter.OptionTitle, formatter.OptionTypeDisplay, formatter.OptionTypeStyle)
+	specs = append(specs, formatter.OptionSpec{
+		Name:        formatter.OptionTemplate,
+		Description: "Path of the text/template file to render",
+		Kind:        formatter.KindString,
+	})
+	return formatter.SortOptions(specs)
+}
+
+// Metadata describes the format.
+func (f Formatter) Metadata() formatter.Metadata {
+	return formatter.Metadata{
+		Description: "Any text, rendered through a Go text/template file",
+		Extension:   ".txt",
+		Features:    []formatter.Feature{formatter.FeatureComments, formatter.FeatureCardinality},
+	}
+}
+
+// Name returns the formatter name.
+func (f Formatter) Name() string {
+	return "template"
+}
+
+// MediaType returns the formatter output media type.
+func (f Formatter) MediaType() string {
+	return "text/plain"
+}
+
+// Render runs the template on the render data and returns its output.
+func (f Formatter) Render(data formatter.RenderData) (string, error) {
+	var b bytes.Buffer
+	if err := f.RenderTo(context.Background(), &b, data, nil); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// RenderTo runs the template, configured by opts, on the render data and
+// writes its output to w.
+func (f Formatter) RenderTo(ctx context.Context, w io.Writer, data formatter.RenderData, opts formatter.Options) error {
+	f, err := f.withOptions(opts)
+	if err != nil {
+		return err
+	}
+	if f.tmpl == nil {
+		return fmt.Errorf("the template format needs a template file: set --template FILE or --format-opt %s=FILE", formatter.OptionTemplate)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tmpl, err := f.tmpl.Clone()
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Funcs(f.funcs(data)).Execute(w, Data{RenderData: data, Title: f.title}); err != nil {
+		return f.templateError("template", err)
+	}
+	return nil
+}
+
+// templateError rewrites an error of text/template, which reads "template:
+// PATH:LINE:...", to start with what failed and to quote the template line.
+func (f Formatter) templateError(what string, err error) error {
+	msg := strings.TrimPrefix(err.Error(), "template: ")
+
+	if rest, ok := strings.CutPrefix(msg, f.path+":"); ok {
+		digits := rest
+		if i := strings.IndexFunc(rest, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+			digits = rest[:i]
+		}
+		lines := strings.Split(f.source, "\n")
+		if line, err := strconv.Atoi(digits); err == nil && line >= 1 && line <= len(lines) {
+			msg += fmt.Sprintf("\n%5d | %s", line, lines[line-1])
+		}
+	}
+	return fmt.Errorf("%s %s", what, msg)
+}
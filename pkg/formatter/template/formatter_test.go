@@ -0,0 +1,174 @@
+package template
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/formatter/formattertest"
+)
+
+// writeTemplate writes source to a template file and returns its path.
+func writeTemplate(t *testing.T, source string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "doc.tmpl")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func render(t *testing.T, source string, opts formatter.Options) string {
+	t.Helper()
+
+	f, err := Parse("doc.tmpl", source)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	var b strings.Builder
+	if err := f.RenderTo(context.Background(), &b, formattertest.SampleRenderData(), opts); err != nil {
+		t.Fatalf("RenderTo returned error: %v", err)
+	}
+	return b.String()
+}
+
+func TestFormatterMetadata(t *testing.T) {
+	f := New()
+
+	if got, want := f.Name(), "template"; got != want {
+		t.Fatalf("Name() = %q, want %q", got, want)
+	}
+	if got, want := f.MediaType(), "text/plain"; got != want {
+		t.Fatalf("MediaType() = %q, want %q", got, want)
+	}
+	if _, err := f.Render(formattertest.SampleRenderData()); err == nil || !strings.Contains(err.Error(), "needs a template file") {
+		t.Fatalf("Render without a template returned %v", err)
+	}
+}
+
+func TestTemplateFileOption(t *testing.T) {
+	path := writeTemplate(t, "# {{ .Title }}\n{{ range .Tables }}- {{ .Name }}\n{{ end }}")
+
+	f, err := formatter.Configure(New(), formatter.Options{formatter.OptionTemplate: path, formatter.OptionTitle: "Shop"})
+	if err != nil {
+		t.Fatalf("Configure returned error: %v", err)
+	}
+	got, err := f.Render(formattertest.SampleRenderData())
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if want := "# Shop\n- teams\n- users\n"; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	if _, err := formatter.Configure(New(), formatter.Options{formatter.OptionTemplate: filepath.Join(t.TempDir(), "missing.tmpl")}); err == nil {
+		t.Error("expected an error for a missing template file")
+	}
+}
+
+func TestTextHelpers(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{`{{ join ", " (columnNames "users") }}`, "id, email, team_id"},
+		{`{{ "user_accounts" | title }}`, "User Accounts"},
+		{`{{ "UserAccounts" | snake }}`, "user_accounts"},
+		{`{{ "HTTPServerID" | kebab }}`, "http-server-id"},
+		{`{{ "user_accounts" | camel }}`, "userAccounts"},
+		{`{{ "user-accounts" | pascal }}`, "UserAccounts"},
+		{`{{ "a\nb" | indent 2 }}`, "  a\n  b"},
+		{`{{ "" | default "none" }}`, "none"},
+		{`{{ repeat 3 "=" }}`, "==="},
+		{`{{ replace "_" " " "a_b" }}`, "a b"},
+	}
+
+	for _, tt := range tests {
+		if got := render(t, tt.source, nil); got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestTypeHelpers(t *testing.T) {
+	data := formatter.RenderData{Tables: []formatter.Table{{
+		Name:    "users",
+		Columns: []formatter.Column{{Name: "id", DataType: "bigint", ColumnType: "bigint unsigned"}},
+	}}}
+
+	tests := []struct {
+		source string
+		opts   formatter.Options
+		want   string
+	}{
+		{`{{ range (table "users").Columns }}{{ typeOf . }}{{ end }}`, nil, "bigint"},
+		{`{{ range (table "users").Columns }}{{ typeOf . }}{{ end }}`,
+			formatter.Options{formatter.OptionTypeDisplay: "full", formatter.OptionTypeStyle: "portable"}, "integer unsigned"},
+		{`{{ portableType "varchar" }}`, nil, "string"},
+		{`{{ mapType (dict "BIGINT" "Long" "varchar" "String") "bigint" }}|{{ mapType (dict) "json" }}`, nil, "Long|json"},
+		{`{{ mapType (dict "INT" "Integer" "int" "int32" "Int" "Int32") "int" }}|{{ mapType (dict "INT" "Integer" "Int" "Int32") "int" }}`, nil, "int32|Integer"},
+	}
+
+	for _, tt := range tests {
+		f, err := Parse("doc.tmpl", tt.source)
+		if err != nil {
+			t.Fatalf("Parse(%s) returned error: %v", tt.source, err)
+		}
+		var b strings.Builder
+		if err := f.RenderTo(context.Background(), &b, data, tt.opts); err != nil {
+			t.Fatalf("%s: RenderTo returned error: %v", tt.source, err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s = %q, want %q", tt.source, b.String(), tt.want)
+		}
+	}
+}
+
+func TestRelationshipHelpers(t *testing.T) {
+	source := `{{ with foreignKey "users" "team_id" }}{{ .ReferencedTable }}.{{ .ReferencedColumn }} ({{ cardinality . }}){{ end }}
+{{ with foreignKey "users" "email" }}unexpected{{ else }}not a foreign key{{ end }}
+{{ range referencedBy "teams" }}{{ .Table }}.{{ .ColumnName }}{{ end }}`
+
+	want := "teams.id (many-to-one)\nnot a foreign key\nusers.team_id"
+	if got := render(t, source, nil); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSortHelpers(t *testing.T) {
+	source := `{{ range sortBy "Name" (table "users").Columns }}{{ .Name }} {{ end }}|{{ join "," (sortStrings (columnNames "users")) }}|{{ range sortBy "Name" .Tables }}{{ .Name }}{{ end }}`
+
+	want := "email id team_id |email,id,team_id|teamsusers"
+	if got := render(t, source, nil); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestErrorsQuoteTheTemplateLine(t *testing.T) {
+	_, err := Parse("doc.tmpl", "# Tables\n{{ range .Tables }}\n{{ bogus . }}\n{{ end }}\n")
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	want := "invalid template doc.tmpl:3: function \"bogus\" not defined\n    3 | {{ bogus . }}"
+	if err.Error() != want {
+		t.Errorf("parse error:\n%s\nwant\n%s", err, want)
+	}
+
+	f, err := Parse("doc.tmpl", "# Tables\n\n{{ (table \"orders\").Name }}\n")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	_, err = f.Render(formattertest.SampleRenderData())
+	if err == nil {
+		t.Fatal("expected an execution error")
+	}
+	for _, want := range []string{"template doc.tmpl:3:", `no table "orders"`, "\n    3 | {{ (table \"orders\").Name }}"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("execution error lacks %q:\n%s", want, err)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package template
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"unicode"
+
+	"github.com/motchang/marid/pkg/formatter"
+	"github.com/motchang/marid/pkg/utils"
+)
+
+// Reference is a foreign key seen from the table it points at.
+type Reference struct {
+	// Table is the name of the referencing table.
+	Table string
+	formatter.ForeignKey
+}
+
+// funcs returns the helpers templates may call, bound to data.
+func (f Formatter) funcs(data formatter.RenderData) texttemplate.FuncMap {
+	byName := make(map[string]formatter.Table, len(data.Tables))
+	for _, table := range data.Tables {
+		byName[table.Name] = table
+	}
+
+	// resolve accepts a table or a table name.
+	resolve := func(v any) (formatter.Table, error) {
+		switch t := v.(type) {
+		case formatter.Table:
+			return t, nil
+		case *formatter.Table:
+			return *t, nil
+		case string:
+			table, ok := byName[t]
+			if !ok {
+				return table, fmt.Errorf("no table %q", t)
+			}
+			return table, nil
+		}
+		return formatter.Table{}, fmt.Errorf("want a table or a table name, got %T", v)
+	}
+
+	return texttemplate.FuncMap{
+		// Text.
+		"join":      join,
+		"lower":     strings.ToLower,
+		"upper":     strings.ToUpper,
+		"title":     func(s string) string { return joinWords(s, " ", strings.ToUpper, strings.ToUpper) },
+		"snake":     func(s string) string { return joinWords(s, "_", strings.ToLower, strings.ToLower) },
+		"kebab":     func(s string) string { return joinWords(s, "-", strings.ToLower, strings.ToLower) },
+		"camel":     func(s string) string { return joinWords(s, "", strings.ToLower, strings.ToUpper) },
+		"pascal":    func(s string) string { return joinWords(s, "", strings.ToUpper, strings.ToUpper) },
+		"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"trim":      strings.TrimSpace,
+		"contains":  func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"repeat":    func(count int, s string) string { return strings.Repeat(s, max(count, 0)) },
+		"indent":    indent,
+		"default": func(fallback string, s string) string {
+			if s == "" {
+				return fallback
+			}
+			return s
+		},
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+
+		// Types.
+		"typeOf": func(column formatter.Column) string {
+			if f.typeStyle == formatter.TypeStylePortable {
+				return column.PortableType(f.typeDisplay)
+			}
+			return column.Type(f.typeDisplay)
+		},
+		"portableType": utils.FormatColumnType,
+		"dict":         dict,
+		"mapType":      mapType,
+
+		// Tables and relationships.
+		"table": resolve,
+		"columnNames": func(v any) ([]string, error) {
+			table, err := resolve(v)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, len(table.Columns))
+			for i, column := range table.Columns {
+				names[i] = column.Name
+			}
+			return names, nil
+		},
+		"foreignKey": func(v any, column string) (*formatter.ForeignKey, error) {
+			table, err := resolve(v)
+			if err != nil {
+				return nil, err
+			}
+			for _, fk := range table.ForeignKeys {
+				if fk.ColumnName == column {
+					return &fk, nil
+				}
+			}
+			return nil, nil
+		},
+		"referencedBy": func(v any) ([]Reference, error) {
+			table, err := resolve(v)
+			if err != nil {
+				return nil, err
+			}
+			var refs []Reference
+			for _, other := range data.Tables {
+				for _, fk := range other.ForeignKeys {
+					if fk.ReferencedTable == table.Name {
+						refs = append(refs, Reference{Table: other.Name, ForeignKey: fk})
+					}
+				}
+			}
+			return refs, nil
+		},
+		"cardinality": func(fk formatter.ForeignKey) string {
+			if fk.Cardinality == "" {
+				return formatter.CardinalityManyToOne
+			}
+			return fk.Cardinality
+		},
+
+		// Sorting.
+		"sortStrings": func(items []string) []string {
+			sorted := append([]string(nil), items...)
+			sort.Strings(sorted)
+			return sorted
+		},
+		"sortBy": sortBy,
+	}
+}
+
+// join joins the items of a list of any type with sep.
+func join(sep string, items any) (string, error) {
+	if strs, ok := items.([]string); ok {
+		return strings.Join(strs, sep), nil
+	}
+
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", fmt.Errorf("join: want a list, got %T", items)
+	}
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// indent prefixes every non-empty line of s with count spaces.
+func indent(count int, s string) string {
+	pad := strings.Repeat(" ", max(count, 0))
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// dict builds a map from alternating keys and values, for mapType.
+func dict(pairs ...string) (map[string]string, error) {
+	if len(pairs)%2 != 0 {
+		return nil, fmt.Errorf("dict: want pairs of keys and values, got %d arguments", len(pairs))
+	}
+	m := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		m[pairs[i]] = pairs[i+1]
+	}
+	return m, nil
+}
+
+// mapType looks dataType up in mapping, first as written and then ignoring
+// case, trying the keys in sorted order so the result does not depend on map
+// iteration. Types without an entry are returned unchanged.
+func mapType(mapping map[string]string, dataType string) string {
+	if to, ok := mapping[dataType]; ok {
+		return to
+	}
+
+	keys := make([]string, 0, len(mapping))
+	for from := range mapping {
+		keys = append(keys, from)
+	}
+	sort.Strings(keys)
+	for _, from := range keys {
+		if strings.EqualFold(from, dataType) {
+			return mapping[from]
+		}
+	}
+	return dataType
+}
+
+// words splits an identifier such as "user_accounts" or "userAccounts" into
+// its words.
+func words(s string) []string {
+	var out []string
+	var word []rune
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				out, word = append(out, string(word)), nil
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(word) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextLower {
+				out, word = append(out, string(word)), nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		out = append(out, string(word))
+	}
+	return out
+}
+
+// joinWords joins the words of s with sep, lower-casing each word and then
+// passing its first letter through first for the first word and rest for the
+// others.
+func joinWords(s, sep string, first, rest func(string) string) string {
+	ws := words(s)
+	for i, w := range ws {
+		w = strings.ToLower(w)
+		initial := rest
+		if i == 0 {
+			initial = first
+		}
+		r := []rune(w)
+		ws[i] = initial(string(r[0])) + string(r[1:])
+	}
+	return strings.Join(ws, sep)
+}
+
+// sortBy returns a copy of list, a list of structs such as .Tables or
+// .Columns, sorted by the named field. Strings sort case-insensitively and
+// ties keep their order.
+func sortBy(field string, list any) (any, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("sortBy: want a list, got %T", list)
+	}
+
+	keys := make([]reflect.Value, v.Len())
+	for i := range keys {
+		item := reflect.Indirect(v.Index(i))
+		if item.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("sortBy: want a list of tables, columns or foreign keys, got %T", list)
+		}
+		keys[i] = item.FieldByName(field)
+		if !keys[i].IsValid() {
+			return nil, fmt.Errorf("sortBy: %s has no field %q", item.Type().Name(), field)
+		}
+	}
+
+	less := func(a, b reflect.Value) bool {
+		switch a.Kind() {
+		case reflect.String:
+			return strings.ToLower(a.String()) < strings.ToLower(b.String())
+		case reflect.Int, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		}
+		return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+	}
+
+	index := make([]int, len(keys))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		return less(keys[index[i]], keys[index[j]])
+	})
+
+	out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+	for i, j := range index {
+		out.Index(i).Set(v.Index(j))
+	}
+	return out.Interface(), nil
+}
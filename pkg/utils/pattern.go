@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a pattern as a regular expression rather than a glob.
+const regexPrefix = "re:"
+
+// Pattern matches identifiers against either a shell-style glob (e.g.
+// "billing_*") or, when written with the "re:" prefix, a regular expression
+// (e.g. "re:_old$"). Regular expressions are unanchored, so add ^ and $ when a
+// whole-name match is intended.
+type Pattern struct {
+	expr string
+	glob string
+	re   *regexp.Regexp
+}
+
+// CompilePattern parses a glob or "re:" regular expression.
+func CompilePattern(expr string) (Pattern, error) {
+	if expr == "" {
+		return Pattern{}, fmt.Errorf("pattern cannot be empty")
+	}
+
+	if strings.HasPrefix(expr, regexPrefix) {
+		re, err := regexp.Compile(strings.TrimPrefix(expr, regexPrefix))
+		if err != nil {
+			return Pattern{}, fmt.Errorf("invalid regular expression %q: %w", expr, err)
+		}
+		return Pattern{expr: expr, re: re}, nil
+	}
+
+	// path.Match only reports malformed globs when it reaches them, so match
+	// against the empty string once to surface e.g. an unclosed "[" up front.
+	if _, err := path.Match(expr, ""); err != nil {
+		return Pattern{}, fmt.Errorf("invalid glob %q: %w", expr, err)
+	}
+
+	return Pattern{expr: expr, glob: expr}, nil
+}
+
+// CompilePatterns compiles every expression, stopping at the first invalid one.
+func CompilePatterns(exprs []string) ([]Pattern, error) {
+	patterns := make([]Pattern, 0, len(exprs))
+	for _, expr := range exprs {
+		pattern, err := CompilePattern(expr)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// Match reports whether name matches the pattern.
+func (p Pattern) Match(name string) bool {
+	if p.re != nil {
+		return p.re.MatchString(name)
+	}
+
+	matched, _ := path.Match(p.glob, name)
+	return matched
+}
+
+// String returns the pattern as it was written.
+func (p Pattern) String() string {
+	return p.expr
+}
+
+// MatchAny reports whether name matches at least one of the patterns.
+func MatchAny(patterns []Pattern, name string) bool {
+	for _, p := range patterns {
+		if p.Match(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package utils
+
+import "testing"
+
+func TestPatternMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		input   string
+		want    bool
+	}{
+		{name: "exact glob", pattern: "users", input: "users", want: true},
+		{name: "exact glob does not match a prefix", pattern: "users", input: "users_old", want: false},
+		{name: "prefix glob", pattern: "billing_*", input: "billing_invoices", want: true},
+		{name: "prefix glob rejects other prefixes", pattern: "billing_*", input: "catalog_items", want: false},
+		{name: "single character glob", pattern: "tmp_?", input: "tmp_1", want: true},
+		{name: "character class glob", pattern: "log_20[0-9][0-9]", input: "log_2024", want: true},
+		{name: "regex is unanchored", pattern: "re:_old", input: "users_old_v2", want: true},
+		{name: "anchored regex", pattern: "re:_old$", input: "users_old_v2", want: false},
+		{name: "regex alternation", pattern: "re:^(tmp|bak)_", input: "bak_orders", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := CompilePattern(tt.pattern)
+			if err != nil {
+				t.Fatalf("CompilePattern(%q) returned error: %v", tt.pattern, err)
+			}
+
+			if got := p.Match(tt.input); got != tt.want {
+				t.Errorf("Pattern(%q).Match(%q) = %t, want %t", tt.pattern, tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompilePatternRejectsInvalidInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{name: "empty pattern", pattern: ""},
+		{name: "unclosed glob class", pattern: "users_[0-9"},
+		{name: "invalid regex", pattern: "re:(unclosed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompilePattern(tt.pattern); err == nil {
+				t.Errorf("CompilePattern(%q) should fail", tt.pattern)
+			}
+		})
+	}
+}
+
+func TestCompilePatternsAndMatchAny(t *testing.T) {
+	patterns, err := CompilePatterns([]string{"tmp_*", "re:_old$"})
+	if err != nil {
+		t.Fatalf("CompilePatterns returned error: %v", err)
+	}
+
+	if got := patterns[1].String(); got != "re:_old$" {
+		t.Errorf("String() = %q, want the pattern as written", got)
+	}
+
+	for name, want := range map[string]bool{
+		"tmp_import": true,
+		"users_old":  true,
+		"users":      false,
+	} {
+		if got := MatchAny(patterns, name); got != want {
+			t.Errorf("MatchAny(%q) = %t, want %t", name, got, want)
+		}
+	}
+
+	if _, err := CompilePatterns([]string{"ok", "re:["}); err == nil {
+		t.Error("CompilePatterns should report the first invalid pattern")
+	}
+}
@@ -4,13 +4,28 @@ import (
 	"strings"
 )
 
-// SanitizeIdentifier sanitizes and escapes identifiers for diagram output
+// SanitizeIdentifier turns any identifier into one made of ASCII letters,
+// digits and underscores that does not start with a digit, as most diagram
+// languages require. Every other character, including non-ASCII letters,
+// becomes an underscore, so distinct identifiers can collide; callers that
+// need unique names must resolve that themselves.
 func SanitizeIdentifier(identifier string) string {
-	// Replace spaces and special characters
-	sanitized := strings.ReplaceAll(identifier, " ", "_")
-	sanitized = strings.ReplaceAll(sanitized, "-", "_")
+	var builder strings.Builder
+	for i, r := range identifier {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			builder.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				builder.WriteRune('_')
+			}
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
 
-	return sanitized
+	return builder.String()
 }
 
 // columnTypeDisplayNames maps common MySQL types to more readable formats.
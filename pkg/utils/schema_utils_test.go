@@ -15,7 +15,10 @@ func TestSanitizeIdentifier(t *testing.T) {
 		{name: "each repeated separator becomes its own underscore", input: "a  b--c", want: "a__b__c"},
 		{name: "leading and trailing separators are kept as underscores", input: " lead-trail ", want: "_lead_trail_"},
 		{name: "existing underscores are left alone", input: "already_ok", want: "already_ok"},
-		{name: "other punctuation is not sanitized", input: "keeps.dot$sign", want: "keeps.dot$sign"},
+		{name: "other punctuation becomes underscores", input: "shop.orders$v2", want: "shop_orders_v2"},
+		{name: "non-ASCII letters become underscores", input: "注文s", want: "__s"},
+		{name: "leading digit is prefixed", input: "2fa_codes", want: "_2fa_codes"},
+		{name: "digits elsewhere are kept", input: "v2", want: "v2"},
 		{name: "empty string", input: "", want: ""},
 	}
 
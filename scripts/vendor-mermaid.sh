@@ -0,0 +1,23 @@
+#!/usr/bin/env bash
+# Vendors the Mermaid bundle that "marid docs" embeds, so generated sites
+# render their diagrams in the browser without fetching anything. The SVG
+# images marid draws itself remain as the fallback without JavaScript.
+#
+# Usage: scripts/vendor-mermaid.sh [VERSION]
+set -euo pipefail
+
+version=${1:-${MERMAID_VERSION:-11.4.1}}
+target="$(cd "$(dirname "$0")/.." && pwd)/internal/site/assets/mermaid.min.js"
+
+tmp=$(mktemp -d)
+trap 'rm -rf "${tmp}"' EXIT
+
+curl -fsSL "https://registry.npmjs.org/mermaid/-/mermaid-${version}.tgz" -o "${tmp}/mermaid.tgz"
+tar -xzf "${tmp}/mermaid.tgz" -C "${tmp}" package/dist/mermaid.min.js
+
+{
+  echo "/*! Mermaid ${version} | MIT License | https://github.com/mermaid-js/mermaid */"
+  cat "${tmp}/package/dist/mermaid.min.js"
+} > "${target}"
+
+echo "vendored Mermaid ${version} to ${target}"